@@ -199,6 +199,28 @@ var adminCommands = []*cli.Command{
 			},
 		},
 	},
+	{
+		Name:  "support-bundle",
+		Usage: "Runs support bundle commands",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "create",
+				Usage:  "Creates a support bundle without a running Grafana server and writes it to a local file",
+				Action: runRunnerCommand(createSupportBundleCommand),
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "collectors",
+						Usage: "Comma separated list of collectors to include in addition to the default ones",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Path of the support bundle file. Defaults to grafana-support-bundle-<timestamp>.<format> in the current directory",
+					},
+				},
+			},
+		},
+	},
 	{
 		Name:  "user-manager",
 		Usage: "Runs different helpful user commands",
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/runner"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/services/supportbundles/supportbundlesimpl"
+)
+
+func createSupportBundleCommand(c utils.CommandLine, runner runner.Runner) error {
+	output := c.String("output")
+	if output == "" {
+		output = fmt.Sprintf("grafana-support-bundle-%s.%s", time.Now().Format("20060102150405"),
+			supportbundlesimpl.OfflineBundleExtension(runner.Cfg))
+	}
+
+	var collectors []string
+	for _, flag := range c.StringSlice("collectors") {
+		for _, uid := range strings.Split(flag, ",") {
+			if uid = strings.TrimSpace(uid); uid != "" {
+				collectors = append(collectors, uid)
+			}
+		}
+	}
+
+	// #nosec G304 - the output path is provided by the operator running the command
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create support bundle file: %w", err)
+	}
+
+	skipped, err := supportbundlesimpl.CreateOfflineBundle(context.Background(), runner.Cfg, runner.SQLStore,
+		runner.SettingsProvider, runner.BundleRegistry, collectors, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create support bundle: %w", err)
+	}
+
+	for _, uid := range skipped {
+		logger.Infof("Skipped collector %q: it is not available without a running Grafana server\n", uid)
+	}
+	logger.Infof("\n")
+	logger.Info(color.GreenString("Support bundle written to %s", output))
+	return nil
+}
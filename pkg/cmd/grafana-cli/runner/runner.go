@@ -6,6 +6,7 @@ import (
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	"github.com/grafana/grafana/pkg/services/secrets"
 	"github.com/grafana/grafana/pkg/services/secrets/manager"
+	"github.com/grafana/grafana/pkg/services/supportbundles/bundleregistry"
 	"github.com/grafana/grafana/pkg/services/user"
 	"github.com/grafana/grafana/pkg/setting"
 )
@@ -19,12 +20,13 @@ type Runner struct {
 	SecretsService    *manager.SecretsService
 	SecretsMigrator   secrets.Migrator
 	UserService       user.Service
+	BundleRegistry    *bundleregistry.Service
 }
 
 func New(cfg *setting.Cfg, sqlStore db.DB, settingsProvider setting.Provider,
 	encryptionService encryption.Internal, features featuremgmt.FeatureToggles,
 	secretsService *manager.SecretsService, secretsMigrator secrets.Migrator,
-	userService user.Service,
+	userService user.Service, bundleRegistry *bundleregistry.Service,
 ) Runner {
 	return Runner{
 		Cfg:               cfg,
@@ -35,5 +37,6 @@ func New(cfg *setting.Cfg, sqlStore db.DB, settingsProvider setting.Provider,
 		SecretsMigrator:   secretsMigrator,
 		Features:          features,
 		UserService:       userService,
+		BundleRegistry:    bundleRegistry,
 	}
 }
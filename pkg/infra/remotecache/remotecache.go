@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/gob"
 	"errors"
+	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/log"
@@ -14,6 +16,7 @@ import (
 	"github.com/grafana/grafana/pkg/registry"
 	"github.com/grafana/grafana/pkg/services/secrets"
 	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
 )
 
 var (
@@ -77,16 +80,56 @@ type RemoteCache struct {
 	client   CacheStorage
 	SQLStore db.DB
 	Cfg      *setting.Cfg
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// Stats are the cache lookups since Grafana started.
+type Stats struct {
+	Hits   int64
+	Misses int64
 }
 
 // Get reads object from Cache
 func (ds *RemoteCache) Get(ctx context.Context, key string) (interface{}, error) {
-	return ds.client.Get(ctx, key)
+	value, err := ds.client.Get(ctx, key)
+	ds.countLookup(err)
+	return value, err
 }
 
 // GetByteArray returns the cached value as an byte array
 func (ds *RemoteCache) GetByteArray(ctx context.Context, key string) ([]byte, error) {
-	return ds.client.GetByteArray(ctx, key)
+	value, err := ds.client.GetByteArray(ctx, key)
+	ds.countLookup(err)
+	return value, err
+}
+
+func (ds *RemoteCache) countLookup(err error) {
+	switch {
+	case err == nil:
+		ds.hits.Add(1)
+	case errors.Is(err, ErrCacheItemNotFound):
+		ds.misses.Add(1)
+	}
+}
+
+// Stats returns the hits and misses of the cache.
+func (ds *RemoteCache) Stats() Stats {
+	return Stats{Hits: ds.hits.Load(), Misses: ds.misses.Load()}
+}
+
+// Ping checks that the cache can be written to and read from, without
+// counting towards the cache stats.
+func (ds *RemoteCache) Ping(ctx context.Context) error {
+	key := "ping-" + util.GenerateShortUID()
+	if err := ds.client.SetByteArray(ctx, key, []byte("ping"), time.Minute); err != nil {
+		return fmt.Errorf("failed to write to the cache: %w", err)
+	}
+	if _, err := ds.client.GetByteArray(ctx, key); err != nil {
+		return fmt.Errorf("failed to read from the cache: %w", err)
+	}
+	return ds.client.Delete(ctx, key)
 }
 
 // SetByteArray stored the byte array in the cache
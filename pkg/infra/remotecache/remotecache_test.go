@@ -39,6 +39,8 @@ func TestCachedBasedOnConfig(t *testing.T) {
 	cfg := setting.NewCfg()
 	err := cfg.Load(setting.CommandLineArgs{
 		HomePath: "../../../",
+		// keep the data and logs written with the default configuration out of the source tree
+		Args: []string{"cfg:paths.data=" + t.TempDir(), "cfg:paths.logs=" + t.TempDir()},
 	})
 	require.Nil(t, err, "Failed to load config")
 
@@ -115,3 +117,18 @@ func TestCachePrefix(t *testing.T) {
 	_, err = cache.Get(context.Background(), "foo")
 	require.Error(t, err)
 }
+
+func TestRemoteCache_Stats(t *testing.T) {
+	cfg := &setting.Cfg{RemoteCacheOptions: &setting.RemoteCacheOptions{Name: databaseCacheType}}
+	cache, err := ProvideService(cfg, db.InitTestDB(t), fakes.NewFakeSecretsService())
+	require.NoError(t, err)
+
+	require.NoError(t, cache.SetByteArray(context.Background(), "key", []byte("value"), time.Minute))
+	_, err = cache.GetByteArray(context.Background(), "key")
+	require.NoError(t, err)
+	_, err = cache.GetByteArray(context.Background(), "missing")
+	require.ErrorIs(t, err, ErrCacheItemNotFound)
+
+	require.NoError(t, cache.Ping(context.Background()))
+	require.Equal(t, Stats{Hits: 1, Misses: 1}, cache.Stats())
+}
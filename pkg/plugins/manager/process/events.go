@@ -0,0 +1,56 @@
+package process
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType is what happened to a backend plugin process.
+type EventType string
+
+const (
+	EventStarted EventType = "started"
+	EventStopped EventType = "stopped"
+	// EventExited is recorded when a process exits without being stopped, e.g. because it crashed.
+	EventExited        EventType = "exited"
+	EventRestarted     EventType = "restarted"
+	EventRestartFailed EventType = "restart_failed"
+)
+
+// maxEvents is how many events are retained, the oldest ones are dropped first.
+const maxEvents = 500
+
+// Event is a lifecycle event of a backend plugin process.
+type Event struct {
+	PluginID string
+	Type     EventType
+	Time     time.Time
+	// Reason explains the event, e.g. why a restart failed.
+	Reason string
+}
+
+// eventHistory retains the most recent lifecycle events in memory.
+type eventHistory struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (h *eventHistory) record(pluginID string, eventType EventType, reason string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.events) == maxEvents {
+		copy(h.events, h.events[1:])
+		h.events = h.events[:maxEvents-1]
+	}
+	h.events = append(h.events, Event{PluginID: pluginID, Type: eventType, Time: time.Now(), Reason: reason})
+}
+
+func (h *eventHistory) list() []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events := make([]Event, len(h.events))
+	copy(events, h.events)
+	return events
+}
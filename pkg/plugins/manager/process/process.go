@@ -17,8 +17,9 @@ var _ Service = (*Manager)(nil)
 type Manager struct {
 	pluginRegistry registry.Service
 
-	mu  sync.Mutex
-	log log.Logger
+	mu     sync.Mutex
+	log    log.Logger
+	events eventHistory
 }
 
 func ProvideService(pluginRegistry registry.Service) *Manager {
@@ -51,7 +52,7 @@ func (m *Manager) Start(ctx context.Context, pluginID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if err := startPluginAndRestartKilledProcesses(ctx, p); err != nil {
+	if err := m.startPluginAndRestartKilledProcesses(ctx, p); err != nil {
 		return err
 	}
 
@@ -75,10 +76,17 @@ func (m *Manager) Stop(ctx context.Context, pluginID string) error {
 	if err := p.Stop(ctx); err != nil {
 		return err
 	}
+	m.events.record(p.ID, EventStopped, "")
 
 	return nil
 }
 
+// Events returns the most recent lifecycle events of the backend plugin processes, oldest first.
+// Only a limited number of events are kept in memory, and none survive a restart of Grafana.
+func (m *Manager) Events() []Event {
+	return m.events.list()
+}
+
 // shutdown stops all backend plugin processes
 func (m *Manager) shutdown(ctx context.Context) {
 	var wg sync.WaitGroup
@@ -96,17 +104,18 @@ func (m *Manager) shutdown(ctx context.Context) {
 	wg.Wait()
 }
 
-func startPluginAndRestartKilledProcesses(ctx context.Context, p *plugins.Plugin) error {
+func (m *Manager) startPluginAndRestartKilledProcesses(ctx context.Context, p *plugins.Plugin) error {
 	if err := p.Start(ctx); err != nil {
 		return err
 	}
+	m.events.record(p.ID, EventStarted, "")
 
 	if p.IsCorePlugin() {
 		return nil
 	}
 
 	go func(ctx context.Context, p *plugins.Plugin) {
-		if err := restartKilledProcess(ctx, p); err != nil {
+		if err := m.restartKilledProcess(ctx, p); err != nil {
 			p.Logger().Error("Attempt to restart killed plugin process failed", "error", err)
 		}
 	}(ctx, p)
@@ -114,8 +123,10 @@ func startPluginAndRestartKilledProcesses(ctx context.Context, p *plugins.Plugin
 	return nil
 }
 
-func restartKilledProcess(ctx context.Context, p *plugins.Plugin) error {
+func (m *Manager) restartKilledProcess(ctx context.Context, p *plugins.Plugin) error {
 	ticker := time.NewTicker(time.Second * 1)
+	// restarts are attempted every second, an exit and a repeated failure are only recorded once
+	exitRecorded, lastFailure := false, ""
 
 	for {
 		select {
@@ -133,12 +144,22 @@ func restartKilledProcess(ctx context.Context, p *plugins.Plugin) error {
 			if !p.Exited() {
 				continue
 			}
+			if !exitRecorded {
+				m.events.record(p.ID, EventExited, "the plugin process exited unexpectedly")
+				exitRecorded = true
+			}
 
 			p.Logger().Debug("Restarting plugin")
 			if err := p.Start(ctx); err != nil {
 				p.Logger().Error("Failed to restart plugin", "error", err)
+				if err.Error() != lastFailure {
+					m.events.record(p.ID, EventRestartFailed, err.Error())
+					lastFailure = err.Error()
+				}
 				continue
 			}
+			exitRecorded, lastFailure = false, ""
+			m.events.record(p.ID, EventRestarted, "")
 			p.Logger().Debug("Plugin restarted")
 		}
 	}
@@ -2,8 +2,10 @@ package process
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/plugins"
@@ -149,6 +151,13 @@ func TestProcessManager_ManagedBackendPluginLifecycle(t *testing.T) {
 		require.True(t, !p.Exited())
 		require.Equal(t, 2, bp.startCount)
 		require.Equal(t, 0, bp.stopCount)
+		require.Eventually(t, func() bool { return len(m.Events()) == 3 }, 5*time.Second, 10*time.Millisecond)
+		var events []EventType
+		for _, e := range m.Events() {
+			require.Equal(t, p.ID, e.PluginID)
+			events = append(events, e.Type)
+		}
+		require.Equal(t, []EventType{EventStarted, EventExited, EventRestarted}, events)
 
 		t.Run("When context is cancelled the plugin is stopped", func(t *testing.T) {
 			cancel()
@@ -161,6 +170,18 @@ func TestProcessManager_ManagedBackendPluginLifecycle(t *testing.T) {
 	})
 }
 
+func TestEventHistory(t *testing.T) {
+	var h eventHistory
+	for i := 0; i < maxEvents+10; i++ {
+		h.record(fmt.Sprintf("plugin-%d", i), EventStarted, "")
+	}
+
+	events := h.list()
+	require.Len(t, events, maxEvents)
+	require.Equal(t, "plugin-10", events[0].PluginID)
+	require.Equal(t, fmt.Sprintf("plugin-%d", maxEvents+9), events[maxEvents-1].PluginID)
+}
+
 type fakePluginRegistry struct {
 	store map[string]*plugins.Plugin
 }
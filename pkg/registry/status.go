@@ -0,0 +1,77 @@
+package registry
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// States of the background services.
+const (
+	BackgroundServiceStarting = "starting"
+	BackgroundServiceRunning  = "running"
+	BackgroundServiceStopped  = "stopped"
+	BackgroundServiceDisabled = "disabled"
+)
+
+// BackgroundServiceStatus is the state of a background service run by the server.
+type BackgroundServiceStatus struct {
+	Name      string     `json:"name"`
+	State     string     `json:"state"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	StoppedAt *time.Time `json:"stoppedAt,omitempty"`
+	// Error is the error the service stopped with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// BackgroundServiceTracker records the state of the background services as the
+// server runs them. It doesn't depend on the services themselves, so that the
+// services can depend on it to report on each other.
+type BackgroundServiceTracker struct {
+	mu       sync.RWMutex
+	statuses map[string]*BackgroundServiceStatus
+}
+
+func ProvideBackgroundServiceTracker() *BackgroundServiceTracker {
+	return &BackgroundServiceTracker{statuses: map[string]*BackgroundServiceStatus{}}
+}
+
+// SetState records the state of the named service, along with the error
+// it stopped with.
+func (t *BackgroundServiceTracker) SetState(name, state string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status, ok := t.statuses[name]
+	if !ok {
+		status = &BackgroundServiceStatus{Name: name}
+		t.statuses[name] = status
+	}
+
+	now := time.Now()
+	status.State = state
+	switch state {
+	case BackgroundServiceRunning:
+		status.StartedAt = &now
+	case BackgroundServiceStopped:
+		status.StoppedAt = &now
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+}
+
+// Statuses returns the state of every tracked service, sorted by name.
+func (t *BackgroundServiceTracker) Statuses() []BackgroundServiceStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	statuses := make([]BackgroundServiceStatus, 0, len(t.statuses))
+	for _, status := range t.statuses {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
@@ -38,9 +38,10 @@ type Options struct {
 func New(opts Options, cfg *setting.Cfg, httpServer *api.HTTPServer, roleRegistry accesscontrol.RoleRegistry,
 	provisioningService provisioning.ProvisioningService, backgroundServiceProvider registry.BackgroundServiceRegistry,
 	usageStatsProvidersRegistry registry.UsageStatsProvidersRegistry, statsCollectorService *statscollector.Service,
+	backgroundServiceTracker *registry.BackgroundServiceTracker,
 ) (*Server, error) {
 	statsCollectorService.RegisterProviders(usageStatsProvidersRegistry.GetServices())
-	s, err := newServer(opts, cfg, httpServer, roleRegistry, provisioningService, backgroundServiceProvider)
+	s, err := newServer(opts, cfg, httpServer, roleRegistry, provisioningService, backgroundServiceProvider, backgroundServiceTracker)
 	if err != nil {
 		return nil, err
 	}
@@ -54,6 +55,7 @@ func New(opts Options, cfg *setting.Cfg, httpServer *api.HTTPServer, roleRegistr
 
 func newServer(opts Options, cfg *setting.Cfg, httpServer *api.HTTPServer, roleRegistry accesscontrol.RoleRegistry,
 	provisioningService provisioning.ProvisioningService, backgroundServiceProvider registry.BackgroundServiceRegistry,
+	backgroundServiceTracker *registry.BackgroundServiceTracker,
 ) (*Server, error) {
 	rootCtx, shutdownFn := context.WithCancel(context.Background())
 	childRoutines, childCtx := errgroup.WithContext(rootCtx)
@@ -73,6 +75,7 @@ func newServer(opts Options, cfg *setting.Cfg, httpServer *api.HTTPServer, roleR
 		commit:              opts.Commit,
 		buildBranch:         opts.BuildBranch,
 		backgroundServices:  backgroundServiceProvider.GetServices(),
+		serviceTracker:      backgroundServiceTracker,
 	}
 
 	return s, nil
@@ -95,6 +98,7 @@ type Server struct {
 	commit             string
 	buildBranch        string
 	backgroundServices []registry.BackgroundService
+	serviceTracker     *registry.BackgroundServiceTracker
 
 	HTTPServer          *api.HTTPServer
 	roleRegistry        accesscontrol.RoleRegistry
@@ -139,12 +143,14 @@ func (s *Server) Run() error {
 
 	// Start background services.
 	for _, svc := range services {
+		serviceName := reflect.TypeOf(svc).String()
 		if registry.IsDisabled(svc) {
+			s.serviceTracker.SetState(serviceName, registry.BackgroundServiceDisabled, nil)
 			continue
 		}
 
 		service := svc
-		serviceName := reflect.TypeOf(service).String()
+		s.serviceTracker.SetState(serviceName, registry.BackgroundServiceStarting, nil)
 		s.childRoutines.Go(func() error {
 			select {
 			case <-s.context.Done():
@@ -152,15 +158,18 @@ func (s *Server) Run() error {
 			default:
 			}
 			s.log.Debug("Starting background service", "service", serviceName)
+			s.serviceTracker.SetState(serviceName, registry.BackgroundServiceRunning, nil)
 			err := service.Run(s.context)
 			// Do not return context.Canceled error since errgroup.Group only
 			// returns the first error to the caller - thus we can miss a more
 			// interesting error.
 			if err != nil && !errors.Is(err, context.Canceled) {
 				s.log.Error("Stopped background service", "service", serviceName, "reason", err)
+				s.serviceTracker.SetState(serviceName, registry.BackgroundServiceStopped, err)
 				return fmt.Errorf("%s run error: %w", serviceName, err)
 			}
 			s.log.Debug("Stopped background service", "service", serviceName, "reason", err)
+			s.serviceTracker.SetState(serviceName, registry.BackgroundServiceStopped, nil)
 			return nil
 		})
 	}
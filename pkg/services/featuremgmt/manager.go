@@ -14,13 +14,14 @@ var (
 )
 
 type FeatureManager struct {
-	isDevMod  bool
-	licensing licensing.Licensing
-	flags     map[string]*FeatureFlag
-	enabled   map[string]bool // only the "on" values
-	config    string          // path to config file
-	vars      map[string]interface{}
-	log       log.Logger
+	isDevMod   bool
+	licensing  licensing.Licensing
+	flags      map[string]*FeatureFlag
+	enabled    map[string]bool // only the "on" values
+	configured map[string]bool // flags set in the configuration rather than left to their default
+	config     string          // path to config file
+	vars       map[string]interface{}
+	log        log.Logger
 }
 
 // This will merge the flags with the current configuration
@@ -117,6 +118,11 @@ func (fm *FeatureManager) readFile() error {
 		return err
 	}
 
+	for _, flag := range cfg.Flags {
+		if flag.Expression != "" {
+			fm.configured[flag.Name] = true
+		}
+	}
 	fm.registerFlags(cfg.Flags...)
 	fm.vars = cfg.Vars
 
@@ -128,6 +134,11 @@ func (fm *FeatureManager) IsEnabled(flag string) bool {
 	return fm.enabled[flag]
 }
 
+// IsConfigured checks if the value of a feature was set in the configuration
+func (fm *FeatureManager) IsConfigured(flag string) bool {
+	return fm.configured[flag]
+}
+
 // GetEnabled returns a map contaning only the features that are enabled
 func (fm *FeatureManager) GetEnabled(ctx context.Context) map[string]bool {
 	enabled := make(map[string]bool, len(fm.enabled))
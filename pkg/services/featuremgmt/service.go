@@ -24,11 +24,12 @@ var (
 
 func ProvideManagerService(cfg *setting.Cfg, licensing licensing.Licensing) (*FeatureManager, error) {
 	mgmt := &FeatureManager{
-		isDevMod:  setting.Env != setting.Prod,
-		licensing: licensing,
-		flags:     make(map[string]*FeatureFlag, 30),
-		enabled:   make(map[string]bool),
-		log:       log.New("featuremgmt"),
+		isDevMod:   setting.Env != setting.Prod,
+		licensing:  licensing,
+		flags:      make(map[string]*FeatureFlag, 30),
+		enabled:    make(map[string]bool),
+		configured: make(map[string]bool),
+		log:        log.New("featuremgmt"),
 	}
 
 	// Register the standard flags
@@ -49,6 +50,7 @@ func ProvideManagerService(cfg *setting.Cfg, licensing licensing.Licensing) (*Fe
 			mgmt.flags[key] = flag
 		}
 		flag.Expression = fmt.Sprintf("%t", val) // true | false
+		mgmt.configured[key] = true
 	}
 
 	// Load config settings
@@ -45,6 +45,17 @@ func TestFeatureService(t *testing.T) {
 	// Enterprise features do not fall though automatically
 	require.False(t, mgmt.IsEnabled("a.yes.default"))
 	require.False(t, mgmt.IsEnabled("a.yes")) // licensed, but not enabled
+
+	t.Run("flags set in the configuration are reported as configured", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		_, err := cfg.Raw.Section("feature_toggles").NewKey("b.no", "false")
+		require.NoError(t, err)
+
+		mgmt, err := ProvideManagerService(cfg, license)
+		require.NoError(t, err)
+		require.True(t, mgmt.IsConfigured("b.no"))
+		require.False(t, mgmt.IsConfigured("a.yes"))
+	})
 }
 
 var (
@@ -78,6 +78,7 @@ func ProvideService(cfg *setting.Cfg, router routing.RouteRegister, accessContro
 			Description:       "LDAP authentication healthcheck and configuration data",
 			IncludedByDefault: false,
 			Default:           false,
+			Restricted:        true,
 			Fn:                s.supportBundleCollector,
 		})
 	}
@@ -971,6 +971,33 @@ func (g *GrafanaLive) ClientCount(orgID int64, channel string) (int, error) {
 	return len(p.Presence), nil
 }
 
+// NodeStats is a snapshot of the connections to a Grafana Live node.
+type NodeStats struct {
+	Clients       int
+	Users         int
+	Subscriptions int
+	// Channels are the number of subscribers to each active channel.
+	Channels map[string]int
+}
+
+// NodeStats returns the connections to this node. In HA setups the
+// other nodes have their own connections.
+func (g *GrafanaLive) NodeStats() NodeStats {
+	stats := NodeStats{Channels: map[string]int{}}
+	if g == nil || g.node == nil {
+		return stats
+	}
+
+	hub := g.node.Hub()
+	stats.Clients = hub.NumClients()
+	stats.Users = hub.NumUsers()
+	stats.Subscriptions = hub.NumSubscriptions()
+	for _, channel := range hub.Channels() {
+		stats.Channels[channel] = hub.NumSubscribers(channel)
+	}
+	return stats
+}
+
 func (g *GrafanaLive) HandleHTTPPublish(ctx *contextmodel.ReqContext) response.Response {
 	cmd := dtos.LivePublishCmd{}
 	if err := web.Bind(ctx.Req, &cmd); err != nil {
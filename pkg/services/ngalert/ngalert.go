@@ -355,6 +355,24 @@ func (ng *AlertNG) IsDisabled() bool {
 	return !ng.Cfg.UnifiedAlerting.IsEnabled()
 }
 
+// ListAlertRules returns the alert rules matching the query. Set query.OrgID to -1 to list rules of all organizations.
+func (ng *AlertNG) ListAlertRules(ctx context.Context, query *models.ListAlertRulesQuery) error {
+	return ng.store.ListAlertRules(ctx, query)
+}
+
+// GetAlertmanagerConfigurations returns the latest Alertmanager configuration of every organization.
+func (ng *AlertNG) GetAlertmanagerConfigurations(ctx context.Context) ([]*models.AlertConfiguration, error) {
+	return ng.store.GetAllLatestAlertmanagerConfiguration(ctx)
+}
+
+// GetAlertRuleStates returns the current state of all alert instances of the given alert rule.
+func (ng *AlertNG) GetAlertRuleStates(orgID int64, alertRuleUID string) []*state.State {
+	if ng.stateManager == nil {
+		return nil
+	}
+	return ng.stateManager.GetStatesForRuleUID(orgID, alertRuleUID)
+}
+
 func readQuotaConfig(cfg *setting.Cfg) (*quota.Map, error) {
 	limits := &quota.Map{}
 
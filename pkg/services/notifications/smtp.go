@@ -59,6 +59,21 @@ func (sc *SmtpClient) Send(messages ...*Message) (int, error) {
 	return sentEmailsCount, err
 }
 
+// TestConnection connects and authenticates to the SMTP server as when sending
+// emails, without sending any.
+func (sc *SmtpClient) TestConnection() error {
+	dialer, err := sc.createDialer()
+	if err != nil {
+		return err
+	}
+
+	conn, err := dialer.Dial()
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
 // buildEmail converts the Message DTO to a gomail message.
 func (sc *SmtpClient) buildEmail(msg *Message) *gomail.Message {
 	m := gomail.NewMessage()
@@ -20,6 +20,7 @@ type DashboardProvisioner interface {
 	GetProvisionerResolvedPath(name string) string
 	GetAllowUIUpdatesFromConfig(name string) bool
 	CleanUpOrphanedDashboards(ctx context.Context)
+	FileErrors() []FileError
 }
 
 // DashboardProvisionerFactory creates DashboardProvisioners based on input
@@ -126,6 +127,16 @@ func (provider *Provisioner) GetAllowUIUpdatesFromConfig(name string) bool {
 	return false
 }
 
+// FileErrors returns the dashboard files that failed to be provisioned, by all
+// the configured providers.
+func (provider *Provisioner) FileErrors() []FileError {
+	var errs []FileError
+	for _, reader := range provider.fileReaders {
+		errs = append(errs, reader.FileErrors()...)
+	}
+	return errs
+}
+
 func getFileReaders(
 	configs []*config, logger log.Logger, service dashboards.DashboardProvisioningService, store utils.DashboardStore,
 ) ([]*FileReader, error) {
@@ -67,3 +67,8 @@ func (dpm *ProvisionerMock) GetAllowUIUpdatesFromConfig(name string) bool {
 
 // CleanUpOrphanedDashboards not implemented for mocks
 func (dpm *ProvisionerMock) CleanUpOrphanedDashboards(ctx context.Context) {}
+
+// FileErrors not implemented for mocks
+func (dpm *ProvisionerMock) FileErrors() []FileError {
+	return nil
+}
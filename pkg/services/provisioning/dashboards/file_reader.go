@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -38,9 +39,19 @@ type FileReader struct {
 
 	mux                     sync.RWMutex
 	usageTracker            *usageTracker
+	fileErrors              []FileError
 	dbWriteAccessRestricted bool
 }
 
+// FileError is a dashboard file that failed to be provisioned during the last
+// walk of the disk.
+type FileError struct {
+	Provisioner string
+	Path        string
+	Error       string
+	Time        time.Time
+}
+
 // NewDashboardFileReader returns a new filereader based on `config`
 func NewDashboardFileReader(cfg *config, log log.Logger, service dashboards.DashboardProvisioningService, dashboardStore utils.DashboardStore) (*FileReader, error) {
 	var path string
@@ -87,8 +98,13 @@ func (fr *FileReader) pollChanges(ctx context.Context) {
 
 // walkDisk traverses the file system for the defined path, reading dashboard definition files,
 // and applies any change to the database.
-func (fr *FileReader) walkDisk(ctx context.Context) error {
+func (fr *FileReader) walkDisk(ctx context.Context) (err error) {
 	fr.log.Debug("Start walking disk", "path", fr.Path)
+	fileErrors := map[string]string{}
+	defer func() {
+		fr.setFileErrors(fileErrors, err)
+	}()
+
 	resolvedPath := fr.resolvedPath()
 	if _, err := os.Stat(resolvedPath); err != nil {
 		return err
@@ -109,9 +125,9 @@ func (fr *FileReader) walkDisk(ctx context.Context) error {
 
 	usageTracker := newUsageTracker()
 	if fr.FoldersFromFilesStructure {
-		err = fr.storeDashboardsInFoldersFromFileStructure(ctx, filesFoundOnDisk, provisionedDashboardRefs, resolvedPath, usageTracker)
+		err = fr.storeDashboardsInFoldersFromFileStructure(ctx, filesFoundOnDisk, provisionedDashboardRefs, resolvedPath, usageTracker, fileErrors)
 	} else {
-		err = fr.storeDashboardsInFolder(ctx, filesFoundOnDisk, provisionedDashboardRefs, usageTracker)
+		err = fr.storeDashboardsInFolder(ctx, filesFoundOnDisk, provisionedDashboardRefs, usageTracker, fileErrors)
 	}
 	if err != nil {
 		return err
@@ -140,7 +156,7 @@ func (fr *FileReader) isDatabaseAccessRestricted() bool {
 
 // storeDashboardsInFolder saves dashboards from the filesystem on disk to the folder from config
 func (fr *FileReader) storeDashboardsInFolder(ctx context.Context, filesFoundOnDisk map[string]os.FileInfo,
-	dashboardRefs map[string]*dashboards.DashboardProvisioning, usageTracker *usageTracker) error {
+	dashboardRefs map[string]*dashboards.DashboardProvisioning, usageTracker *usageTracker, fileErrors map[string]string) error {
 	folderID, err := fr.getOrCreateFolderID(ctx, fr.Cfg, fr.dashboardProvisioningService, fr.Cfg.Folder)
 	if err != nil && !errors.Is(err, ErrFolderNameMissing) {
 		return err
@@ -151,6 +167,7 @@ func (fr *FileReader) storeDashboardsInFolder(ctx context.Context, filesFoundOnD
 		provisioningMetadata, err := fr.saveDashboard(ctx, path, folderID, fileInfo, dashboardRefs)
 		if err != nil {
 			fr.log.Error("failed to save dashboard", "file", path, "error", err)
+			fileErrors[path] = err.Error()
 			continue
 		}
 
@@ -162,7 +179,7 @@ func (fr *FileReader) storeDashboardsInFolder(ctx context.Context, filesFoundOnD
 // storeDashboardsInFoldersFromFilesystemStructure saves dashboards from the filesystem on disk to the same folder
 // in Grafana as they are in on the filesystem.
 func (fr *FileReader) storeDashboardsInFoldersFromFileStructure(ctx context.Context, filesFoundOnDisk map[string]os.FileInfo,
-	dashboardRefs map[string]*dashboards.DashboardProvisioning, resolvedPath string, usageTracker *usageTracker) error {
+	dashboardRefs map[string]*dashboards.DashboardProvisioning, resolvedPath string, usageTracker *usageTracker, fileErrors map[string]string) error {
 	for path, fileInfo := range filesFoundOnDisk {
 		folderName := ""
 
@@ -180,6 +197,7 @@ func (fr *FileReader) storeDashboardsInFoldersFromFileStructure(ctx context.Cont
 		usageTracker.track(provisioningMetadata)
 		if err != nil {
 			fr.log.Error("failed to save dashboard", "file", path, "error", err)
+			fileErrors[path] = err.Error()
 		}
 	}
 	return nil
@@ -232,8 +250,7 @@ func (fr *FileReader) saveDashboard(ctx context.Context, path string, folderID i
 
 	jsonFile, err := fr.readDashboardFromFile(path, resolvedFileInfo.ModTime(), folderID)
 	if err != nil {
-		fr.log.Error("failed to load dashboard from ", "file", path, "error", err)
-		return provisioningMetadata, nil
+		return provisioningMetadata, fmt.Errorf("failed to load dashboard: %w", err)
 	}
 
 	upToDate := alreadyProvisioned
@@ -446,6 +463,33 @@ func (fr *FileReader) resolvedPath() string {
 	return path
 }
 
+// setFileErrors replaces the errors of the previous walk of the disk. walkErr
+// is the error that stopped the walk, if any.
+func (fr *FileReader) setFileErrors(fileErrors map[string]string, walkErr error) {
+	now := time.Now()
+	errs := make([]FileError, 0, len(fileErrors)+1)
+	if walkErr != nil {
+		errs = append(errs, FileError{Provisioner: fr.Cfg.Name, Path: fr.Path, Error: walkErr.Error(), Time: now})
+	}
+	for path, msg := range fileErrors {
+		errs = append(errs, FileError{Provisioner: fr.Cfg.Name, Path: path, Error: msg, Time: now})
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+
+	fr.mux.Lock()
+	defer fr.mux.Unlock()
+	fr.fileErrors = errs
+}
+
+// FileErrors returns the dashboard files that failed to be provisioned during
+// the last walk of the disk.
+func (fr *FileReader) FileErrors() []FileError {
+	fr.mux.RLock()
+	defer fr.mux.RUnlock()
+
+	return fr.fileErrors
+}
+
 func (fr *FileReader) getUsageTracker() *usageTracker {
 	fr.mux.RLock()
 	defer fr.mux.RUnlock()
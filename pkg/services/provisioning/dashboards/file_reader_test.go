@@ -325,6 +325,27 @@ func TestDashboardFileReader(t *testing.T) {
 			require.NoError(t, err)
 		})
 
+		t.Run("Broken dashboards should be reported as file errors", func(t *testing.T) {
+			setup()
+			cfg.Options["path"] = brokenDashboards
+
+			fakeService.On("GetProvisionedDashboardData", mock.Anything, configName).Return(nil, nil).Once()
+
+			reader, err := NewDashboardFileReader(cfg, logger, nil, fakeStore)
+			reader.dashboardProvisioningService = fakeService
+			require.NoError(t, err)
+
+			err = reader.walkDisk(context.Background())
+			require.NoError(t, err)
+
+			fileErrors := reader.FileErrors()
+			require.Len(t, fileErrors, 2)
+			require.Equal(t, configName, fileErrors[0].Provisioner)
+			require.Equal(t, "empty-json.json", filepath.Base(fileErrors[0].Path))
+			require.Equal(t, "invalid.json", filepath.Base(fileErrors[1].Path))
+			require.Contains(t, fileErrors[1].Error, "failed to load dashboard")
+		})
+
 		t.Run("Two dashboard providers should be able to provisioned the same dashboard without uid", func(t *testing.T) {
 			setup()
 			cfg1 := &config{Name: "1", Type: "file", OrgID: 1, Folder: "f1", Options: map[string]interface{}{"path": containingID}}
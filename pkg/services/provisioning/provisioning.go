@@ -89,6 +89,7 @@ type ProvisioningService interface {
 	ProvisionAlerting(ctx context.Context) error
 	GetDashboardProvisionerResolvedPath(name string) string
 	GetAllowUIUpdatesFromConfig(name string) bool
+	GetDashboardProvisioningErrors() []dashboards.FileError
 }
 
 // Add a public constructor for overriding service to be able to instantiate OSS as fallback
@@ -299,9 +300,25 @@ func (ps *ProvisioningServiceImpl) GetDashboardProvisionerResolvedPath(name stri
 }
 
 func (ps *ProvisioningServiceImpl) GetAllowUIUpdatesFromConfig(name string) bool {
+	// the dashboard provisioner is created once the dashboards are first provisioned
+	if ps.dashboardProvisioner == nil {
+		return false
+	}
 	return ps.dashboardProvisioner.GetAllowUIUpdatesFromConfig(name)
 }
 
+// GetDashboardProvisioningErrors returns the dashboard files that failed to be
+// provisioned the last time they were read, e.g. because of invalid JSON.
+func (ps *ProvisioningServiceImpl) GetDashboardProvisioningErrors() []dashboards.FileError {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	if ps.dashboardProvisioner == nil {
+		return nil
+	}
+	return ps.dashboardProvisioner.FileErrors()
+}
+
 func (ps *ProvisioningServiceImpl) cancelPolling() {
 	if ps.pollingCtxCancel != nil {
 		ps.log.Debug("Stop polling for dashboard changes")
@@ -1,6 +1,10 @@
 package provisioning
 
-import "context"
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/provisioning/dashboards"
+)
 
 type Calls struct {
 	RunInitProvisioners                 []interface{}
@@ -11,6 +15,7 @@ type Calls struct {
 	ProvisionAlerting                   []interface{}
 	GetDashboardProvisionerResolvedPath []interface{}
 	GetAllowUIUpdatesFromConfig         []interface{}
+	GetDashboardProvisioningErrors      []interface{}
 	Run                                 []interface{}
 }
 
@@ -23,6 +28,7 @@ type ProvisioningServiceMock struct {
 	ProvisionDashboardsFunc                 func() error
 	GetDashboardProvisionerResolvedPathFunc func(name string) string
 	GetAllowUIUpdatesFromConfigFunc         func(name string) bool
+	GetDashboardProvisioningErrorsFunc      func() []dashboards.FileError
 	RunFunc                                 func(ctx context.Context) error
 }
 
@@ -93,6 +99,14 @@ func (mock *ProvisioningServiceMock) GetAllowUIUpdatesFromConfig(name string) bo
 	return false
 }
 
+func (mock *ProvisioningServiceMock) GetDashboardProvisioningErrors() []dashboards.FileError {
+	mock.Calls.GetDashboardProvisioningErrors = append(mock.Calls.GetDashboardProvisioningErrors, nil)
+	if mock.GetDashboardProvisioningErrorsFunc != nil {
+		return mock.GetDashboardProvisioningErrorsFunc()
+	}
+	return nil
+}
+
 func (mock *ProvisioningServiceMock) Run(ctx context.Context) error {
 	mock.Calls.Run = append(mock.Calls.Run, nil)
 	if mock.RunFunc != nil {
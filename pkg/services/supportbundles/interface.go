@@ -1,10 +1,33 @@
 package supportbundles
 
-import "context"
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
 
 type SupportItem struct {
 	Filename  string
 	FileBytes []byte
+	// Files are the files of collectors writing more than one, e.g. rules.json and
+	// eval-state.json. They're added to the bundle along with Filename, if set.
+	Files []SupportFile
+}
+
+// SupportFile is a file of a SupportItem.
+type SupportFile struct {
+	Filename  string
+	FileBytes []byte
+}
+
+// AllFiles returns the files of the item, Filename first.
+func (i *SupportItem) AllFiles() []SupportFile {
+	files := make([]SupportFile, 0, len(i.Files)+1)
+	if i.Filename != "" {
+		files = append(files, SupportFile{Filename: i.Filename, FileBytes: i.FileBytes})
+	}
+	return append(files, i.Files...)
 }
 
 type State string
@@ -12,25 +35,100 @@ type State string
 const (
 	StatePending  State = "pending"
 	StateComplete State = "complete"
-	StateError    State = "error"
-	StateTimeout  State = "timeout"
+	// StatePartial is set when some, but not all, of the collectors failed.
+	StatePartial   State = "partial"
+	StateError     State = "error"
+	StateTimeout   State = "timeout"
+	StateCancelled State = "cancelled"
 )
 
 func (s State) String() string {
 	return string(s)
 }
 
+// HasArchive reports whether bundles in this state have an archive that can be downloaded.
+func (s State) HasArchive() bool {
+	return s == StateComplete || s == StatePartial
+}
+
 type Bundle struct {
 	UID       string `json:"uid"`
 	State     State  `json:"state"`
 	Creator   string `json:"creator"`
 	CreatedAt int64  `json:"createdAt"`
 	ExpiresAt int64  `json:"expiresAt"`
-	TarBytes  []byte `json:"tarBytes,omitempty"`
+	// Progress is the percentage of collectors that have finished running.
+	Progress int `json:"progress"`
+	// CurrentCollector is the UID of the collector currently running, if any.
+	CurrentCollector string `json:"currentCollector,omitempty"`
+	// Encrypted is true if the bundle archive is encrypted at rest.
+	Encrypted bool `json:"encrypted"`
+	// EncryptionKeyID is the ID of the data key the archive was encrypted with.
+	// Empty for archives encrypted with the legacy secret key.
+	EncryptionKeyID string `json:"encryptionKeyId,omitempty"`
+	// Format is the archive format of the bundle, tar.gz or zip.
+	// Empty for bundles created before the format was configurable, which are tar.gz.
+	Format string `json:"format,omitempty"`
+	// Description is a free-form description set when the bundle was created.
+	Description string `json:"description,omitempty"`
+	// Tags are key/value labels set when the bundle was created, e.g. incident=INC-1234.
+	Tags map[string]string `json:"tags,omitempty"`
+	// Collectors are the UIDs of the collectors run to create the bundle.
+	Collectors []string `json:"collectors,omitempty"`
+	// Params are the parameters set for the collectors, by collector UID.
+	Params map[string]map[string]int64 `json:"params,omitempty"`
+	// SkippedCollectors are the requested collectors left out because the
+	// creator isn't allowed to run them.
+	SkippedCollectors []string `json:"skippedCollectors,omitempty"`
+	// AutoAddedCollectors are the collectors added by smart collection because
+	// of the problems detected when the bundle was created.
+	AutoAddedCollectors []AutoAddedCollector `json:"autoAddedCollectors,omitempty"`
+	// Checksum is the hex encoded SHA-256 of the bundle archive, as downloaded.
+	Checksum string `json:"checksum,omitempty"`
+	// Size is the size of the stored archive in bytes, compressed and encrypted if
+	// encryption at rest is enabled.
+	Size int64 `json:"size,omitempty"`
+	// EstimatedCompletedAt is when the collection of the bundle is expected to
+	// be done, in unix seconds. Set when the collection starts.
+	EstimatedCompletedAt int64 `json:"estimatedCompletedAt,omitempty"`
+	// CompletedAt is when the collection of the bundle finished, successfully or
+	// not, in unix seconds. Zero while the bundle is pending.
+	CompletedAt int64 `json:"completedAt,omitempty"`
+	// Duration is how long the collection of the bundle took, in milliseconds.
+	Duration int64 `json:"durationMs,omitempty"`
+	// UploadedTo is where the archive was uploaded to, without the query of the
+	// URL. The archives of uploaded bundles aren't stored.
+	UploadedTo string `json:"uploadedTo,omitempty"`
+	// UploadError is why the upload of the archive failed, failing the bundle.
+	UploadError string `json:"uploadError,omitempty"`
+	TarBytes    []byte `json:"tarBytes,omitempty"`
+}
+
+// AutoAddedCollector is a collector added to a bundle because of a detected problem.
+type AutoAddedCollector struct {
+	UID string `json:"uid"`
+	// Reason is the problem detected.
+	Reason string `json:"reason"`
 }
 
 type CollectorFunc func(context.Context) (*SupportItem, error)
 
+// StreamCollectorFunc writes the files of a collector to w as they're produced,
+// for collectors whose output is too large to be held in memory.
+type StreamCollectorFunc func(ctx context.Context, w FileWriter) error
+
+// FileWriter creates the files of a streaming collector. Their content is
+// redacted line by line as it's written. The lines of JSON files holding a whole
+// value, e.g. an element of an array, are redacted structurally.
+type FileWriter interface {
+	// Create starts a new file, the previous one can't be written to anymore.
+	Create(filename string) (io.Writer, error)
+}
+
+// ErrOutputLimitReached is returned by FileWriter once a streaming collector has
+// written as much as it's allowed to, the rest of its output is left out.
+var ErrOutputLimitReached = errors.New("support bundle collector output limit reached")
+
 type Collector struct {
 	// UID is a unique identifier for the collector.
 	UID string `json:"uid"`
@@ -44,10 +142,82 @@ type Collector struct {
 	// Default determines if the collector is included by default.
 	// User can override this.
 	Default bool `json:"default"`
+	// Restricted collectors gather secrets-adjacent data and are only run for users
+	// allowed to create bundles on their support.bundles.collectors scope.
+	Restricted bool `json:"restricted"`
+	// Disabled collectors are forbidden from running by the instance configuration.
+	Disabled bool `json:"disabled"`
+	// Params are the parameters that can be set when a bundle is created, read
+	// by Fn with IntParam.
+	Params []CollectorParam `json:"params,omitempty"`
 	// Fn is the function that collects the support item.
 	Fn CollectorFunc `json:"-"`
+	// StreamFn, if set, is run instead of Fn to write the output of the collector
+	// as it's produced.
+	StreamFn StreamCollectorFunc `json:"-"`
+}
+
+// CollectorParam is a parameter of a collector, e.g. the number of log lines to
+// collect. Values outside of Min and Max are rejected.
+type CollectorParam struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Default is the value used when the parameter isn't set.
+	Default int64 `json:"default"`
+	Min     int64 `json:"min"`
+	Max     int64 `json:"max"`
+}
+
+type paramsKey struct{}
+
+// WithParams makes the parameters set for a collector available to its Fn.
+func WithParams(ctx context.Context, params map[string]int64) context.Context {
+	if len(params) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, paramsKey{}, params)
+}
+
+// IntParam returns the value of the parameter name set for the running
+// collector, or def if it isn't set.
+func IntParam(ctx context.Context, name string, def int64) int64 {
+	params, _ := ctx.Value(paramsKey{}).(map[string]int64)
+	if v, ok := params[name]; ok {
+		return v
+	}
+	return def
 }
 
 type Service interface {
 	RegisterSupportItemCollector(collector Collector)
 }
+
+var (
+	// ErrBundlesDisabled is returned by BundleCreator.Create when support bundles are disabled.
+	ErrBundlesDisabled = errors.New("support bundles are disabled")
+	ErrInvalidOptions  = errors.New("invalid support bundle options")
+)
+
+// Options are the options of a bundle created with BundleCreator.Create.
+type Options struct {
+	// Creator is recorded as the creator of the bundle, e.g. the name of the
+	// service creating it. Required.
+	Creator string
+	// Collectors are the UIDs of the collectors to run on top of the ones included by default.
+	Collectors []string
+	// Tags are key/value labels of the bundle, e.g. reason=panic.
+	Tags        map[string]string
+	Description string
+	// Retention overrides how long the bundle is kept, zero uses the default retention.
+	Retention time.Duration
+	// Params are the parameters of the collectors, by collector UID, optional.
+	Params map[string]map[string]int64
+}
+
+// BundleCreator creates bundles from other services, e.g. when they detect a
+// fatal condition. Bundles are collected in the background, Create returns the
+// pending bundle. Every requested collector is run, restricted ones included.
+// The services the support bundle service depends on can't depend on it.
+type BundleCreator interface {
+	Create(ctx context.Context, opts Options) (*Bundle, error)
+}
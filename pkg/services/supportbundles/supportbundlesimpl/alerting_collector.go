@@ -0,0 +1,128 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert"
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/state"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// alertingStateCollector writes the Unified Alerting rules to alerting/rules.json
+// and the result of their last evaluation to alerting/eval-state.json.
+func alertingStateCollector(ng *ngalert.AlertNG) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "alerting-state",
+		DisplayName:       "Alerting state",
+		Description:       "Unified Alerting rules and the result of their last evaluation",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type alertingRules struct {
+				Enabled bool           `json:"enabled"`
+				Note    string         `json:"note,omitempty"`
+				Rules   []alertingRule `json:"rules"`
+			}
+			type evalState struct {
+				Rules []alertingRuleState `json:"rules"`
+			}
+
+			rules := alertingRules{Rules: []alertingRule{}}
+			states := evalState{Rules: []alertingRuleState{}}
+			if ng == nil || ng.IsDisabled() {
+				rules.Note = "Unified Alerting is disabled on this instance"
+			} else {
+				rules.Enabled = true
+
+				query := ngmodels.ListAlertRulesQuery{OrgID: -1}
+				if err := ng.ListAlertRules(ctx, &query); err != nil {
+					return nil, err
+				}
+				rules.Rules, states.Rules = collectAlertingState(query.Result, ng.GetAlertRuleStates)
+			}
+
+			rulesData, err := json.Marshal(rules)
+			if err != nil {
+				return nil, err
+			}
+			statesData, err := json.Marshal(states)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Files: []supportbundles.SupportFile{
+					{Filename: "alerting/rules.json", FileBytes: rulesData},
+					{Filename: "alerting/eval-state.json", FileBytes: statesData},
+				},
+			}, nil
+		},
+	}
+}
+
+type alertingRule struct {
+	UID          string            `json:"uid"`
+	OrgID        int64             `json:"org_id"`
+	Title        string            `json:"title"`
+	NamespaceUID string            `json:"namespace_uid"`
+	RuleGroup    string            `json:"rule_group"`
+	IsPaused     bool              `json:"is_paused"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+type alertingInstanceState struct {
+	State              string            `json:"state"`
+	Reason             string            `json:"reason,omitempty"`
+	Error              string            `json:"error,omitempty"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	LastEvaluationTime time.Time         `json:"last_evaluation_time"`
+	EvaluationDuration string            `json:"evaluation_duration"`
+}
+
+type alertingRuleState struct {
+	UID       string                  `json:"uid"`
+	OrgID     int64                   `json:"org_id"`
+	Instances []alertingInstanceState `json:"instances"`
+}
+
+// collectAlertingState returns the rules with their annotations redacted and the
+// state of their instances, returned by ruleStates.
+func collectAlertingState(alertRules []*ngmodels.AlertRule, ruleStates func(orgID int64, uid string) []*state.State) ([]alertingRule, []alertingRuleState) {
+	rules := make([]alertingRule, 0, len(alertRules))
+	states := make([]alertingRuleState, 0, len(alertRules))
+	for _, r := range alertRules {
+		rules = append(rules, alertingRule{
+			UID:          r.UID,
+			OrgID:        r.OrgID,
+			Title:        r.Title,
+			NamespaceUID: r.NamespaceUID,
+			RuleGroup:    r.RuleGroup,
+			IsPaused:     r.IsPaused,
+			Annotations:  redactStringMap(r.Annotations),
+		})
+
+		rs := alertingRuleState{
+			UID:       r.UID,
+			OrgID:     r.OrgID,
+			Instances: []alertingInstanceState{},
+		}
+		for _, st := range ruleStates(r.OrgID, r.UID) {
+			is := alertingInstanceState{
+				State:              st.State.String(),
+				Reason:             st.StateReason,
+				Labels:             st.Labels,
+				LastEvaluationTime: st.LastEvaluationTime,
+				EvaluationDuration: st.EvaluationDuration.String(),
+			}
+			if st.Error != nil {
+				is.Error = redactURLCredentials(st.Error.Error())
+			}
+			rs.Instances = append(rs.Instances, is)
+		}
+		states = append(states, rs)
+	}
+	return rules, states
+}
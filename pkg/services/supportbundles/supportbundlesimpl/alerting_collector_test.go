@@ -0,0 +1,80 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/ngalert/eval"
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/state"
+)
+
+func TestAlertingStateCollector(t *testing.T) {
+	t.Run("reports that Unified Alerting is disabled", func(t *testing.T) {
+		item, err := alertingStateCollector(nil).Fn(context.Background())
+		require.NoError(t, err)
+		require.Len(t, item.Files, 2)
+		require.Equal(t, "alerting/rules.json", item.Files[0].Filename)
+		require.Equal(t, "alerting/eval-state.json", item.Files[1].Filename)
+
+		var rules struct {
+			Enabled bool           `json:"enabled"`
+			Note    string         `json:"note"`
+			Rules   []alertingRule `json:"rules"`
+		}
+		require.NoError(t, json.Unmarshal(item.Files[0].FileBytes, &rules))
+		require.False(t, rules.Enabled)
+		require.NotEmpty(t, rules.Note)
+		require.Empty(t, rules.Rules)
+	})
+}
+
+func TestCollectAlertingState(t *testing.T) {
+	evaluated := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	alertRules := []*ngmodels.AlertRule{
+		{
+			UID: "r1", OrgID: 1, Title: "High CPU", NamespaceUID: "folder", RuleGroup: "group",
+			Annotations: map[string]string{"summary": "CPU is high", "api_token": plantedSecret},
+		},
+		{UID: "r2", OrgID: 2, Title: "Paused", IsPaused: true},
+	}
+	ruleStates := func(orgID int64, uid string) []*state.State {
+		if uid != "r1" {
+			return nil
+		}
+		return []*state.State{{
+			OrgID:              orgID,
+			AlertRuleUID:       uid,
+			State:              eval.Error,
+			StateReason:        "error",
+			Error:              errors.New("Post https://admin:" + plantedSecret + "@prometheus:9090/api: timeout"),
+			Labels:             data.Labels{"instance": "host-1"},
+			LastEvaluationTime: evaluated,
+			EvaluationDuration: 2 * time.Second,
+		}}
+	}
+
+	rules, states := collectAlertingState(alertRules, ruleStates)
+	require.Equal(t, []alertingRule{
+		{UID: "r1", OrgID: 1, Title: "High CPU", NamespaceUID: "folder", RuleGroup: "group", Annotations: map[string]string{"summary": "CPU is high", "api_token": redactedValue}},
+		{UID: "r2", OrgID: 2, Title: "Paused", IsPaused: true},
+	}, rules)
+
+	require.Len(t, states, 2)
+	require.Equal(t, "r1", states[0].UID)
+	require.Len(t, states[0].Instances, 1)
+	instance := states[0].Instances[0]
+	require.Equal(t, "Error", instance.State)
+	require.Equal(t, map[string]string{"instance": "host-1"}, instance.Labels)
+	require.Equal(t, evaluated, instance.LastEvaluationTime)
+	require.Equal(t, "2s", instance.EvaluationDuration)
+	require.NotContains(t, instance.Error, plantedSecret)
+	require.Contains(t, instance.Error, "https://"+redactedValue+"@prometheus:9090")
+	require.Equal(t, alertingRuleState{UID: "r2", OrgID: 2, Instances: []alertingInstanceState{}}, states[1])
+}
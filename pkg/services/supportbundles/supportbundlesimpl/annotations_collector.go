@@ -0,0 +1,137 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+const (
+	// maxAnnotationOrgs caps the organizations reported, the ones with the most annotations first.
+	maxAnnotationOrgs       = 100
+	annotationsRecentWindow = 24 * time.Hour
+)
+
+// annotationsCollector reports the size and growth of the annotation table,
+// which alerting state history can make grow unbounded. Only aggregate queries are run.
+func annotationsCollector(sql db.DB) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "annotations-stats",
+		DisplayName:       "Annotation statistics",
+		Description:       "Number, age and size of the annotations, including the ones recorded by alerting",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type orgAnnotations struct {
+				OrgID       int64 `xorm:"org_id" json:"org_id"`
+				Annotations int64 `xorm:"total" json:"annotations"`
+			}
+			type annotationStats struct {
+				Total int64 `json:"total"`
+				// Alerting are the annotations recorded by alert state changes.
+				Alerting  int64      `json:"alerting"`
+				Dashboard int64      `json:"dashboard"`
+				Recent    int64      `json:"last_24h"`
+				Tags      int64      `json:"tags"`
+				Oldest    *time.Time `json:"oldest,omitempty"`
+				Newest    *time.Time `json:"newest,omitempty"`
+				// SizeBytes is the size of the table and its indices, when the database reports it.
+				SizeBytes *int64           `json:"size_bytes,omitempty"`
+				SizeError string           `json:"size_error,omitempty"`
+				ByOrg     []orgAnnotations `json:"by_org"`
+			}
+
+			stats := annotationStats{ByOrg: []orgAnnotations{}}
+			err := sql.WithDbSession(ctx, func(sess *db.Session) error {
+				var err error
+				if stats.Total, err = sess.Table("annotation").Count(); err != nil {
+					return err
+				}
+				if stats.Alerting, err = sess.Table("annotation").Where("alert_id > 0").Count(); err != nil {
+					return err
+				}
+				if stats.Dashboard, err = sess.Table("annotation").Where("dashboard_id > 0").Count(); err != nil {
+					return err
+				}
+				// epochs are in milliseconds
+				since := time.Now().Add(-annotationsRecentWindow).UnixMilli()
+				if stats.Recent, err = sess.Table("annotation").Where("epoch >= ?", since).Count(); err != nil {
+					return err
+				}
+				if stats.Tags, err = sess.Table("annotation_tag").Count(); err != nil {
+					return err
+				}
+
+				var bounds struct {
+					Oldest int64 `xorm:"oldest"`
+					Newest int64 `xorm:"newest"`
+				}
+				if _, err := sess.SQL("SELECT MIN(epoch) AS oldest, MAX(epoch) AS newest FROM annotation").Get(&bounds); err != nil {
+					return err
+				}
+				if stats.Total > 0 {
+					oldest, newest := time.UnixMilli(bounds.Oldest).UTC(), time.UnixMilli(bounds.Newest).UTC()
+					stats.Oldest, stats.Newest = &oldest, &newest
+				}
+
+				return sess.Table("annotation").Select("org_id, COUNT(*) AS total").
+					GroupBy("org_id").OrderBy("total DESC").Limit(maxAnnotationOrgs).Find(&stats.ByOrg)
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			size, err := annotationTableSize(ctx, sql)
+			if err != nil {
+				stats.SizeError = err.Error()
+			} else {
+				stats.SizeBytes = size
+			}
+
+			data, err := json.Marshal(stats)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "annotations-stats.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+// annotationTableSize returns the size on disk of the annotation table and its
+// indices, or nil if the database doesn't report it.
+func annotationTableSize(ctx context.Context, sql db.DB) (*int64, error) {
+	var rawSQL string
+	switch sql.GetDBType() {
+	case migrator.MySQL:
+		rawSQL = "SELECT COALESCE(data_length + index_length, 0) AS size FROM information_schema.TABLES WHERE table_schema = DATABASE() AND table_name = 'annotation'"
+	case migrator.Postgres:
+		rawSQL = "SELECT pg_total_relation_size('annotation') AS size"
+	case migrator.SQLite:
+		// only available when SQLite is built with the dbstat virtual table
+		rawSQL = "SELECT COALESCE(SUM(pgsize), 0) AS size FROM dbstat WHERE name = 'annotation' OR name IN (SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'annotation')"
+	default:
+		return nil, nil
+	}
+
+	var size struct {
+		Size int64 `xorm:"size"`
+	}
+	found := false
+	err := sql.WithDbSession(ctx, func(sess *db.Session) error {
+		var err error
+		found, err = sess.SQL(rawSQL).Get(&size)
+		return err
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &size.Size, nil
+}
@@ -0,0 +1,85 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/annotations"
+)
+
+func TestAnnotationsCollector(t *testing.T) {
+	type annotationStats struct {
+		Total     int64      `json:"total"`
+		Alerting  int64      `json:"alerting"`
+		Dashboard int64      `json:"dashboard"`
+		Recent    int64      `json:"last_24h"`
+		Oldest    *time.Time `json:"oldest"`
+		Newest    *time.Time `json:"newest"`
+		SizeBytes *int64     `json:"size_bytes"`
+		SizeError string     `json:"size_error"`
+		ByOrg     []struct {
+			OrgID       int64 `json:"org_id"`
+			Annotations int64 `json:"annotations"`
+		} `json:"by_org"`
+	}
+
+	sqlStore := db.InitTestDB(t)
+	collector := annotationsCollector(sqlStore)
+	require.Equal(t, "annotations-stats", collector.UID)
+
+	collect := func(t *testing.T) annotationStats {
+		t.Helper()
+		item, err := collector.Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "annotations-stats.json", item.Filename)
+
+		var stats annotationStats
+		require.NoError(t, json.Unmarshal(item.FileBytes, &stats))
+		return stats
+	}
+
+	t.Run("no annotations", func(t *testing.T) {
+		stats := collect(t)
+		require.Zero(t, stats.Total)
+		require.Nil(t, stats.Oldest)
+		require.Empty(t, stats.ByOrg)
+	})
+
+	t.Run("annotations", func(t *testing.T) {
+		now := time.Now()
+		items := []annotations.Item{
+			{OrgID: 1, AlertID: 3, Epoch: now.Add(-72 * time.Hour).UnixMilli()},
+			{OrgID: 1, AlertID: 3, Epoch: now.Add(-time.Hour).UnixMilli()},
+			{OrgID: 1, DashboardID: 5, Epoch: now.UnixMilli()},
+			{OrgID: 2, Epoch: now.UnixMilli()},
+		}
+		require.NoError(t, sqlStore.WithDbSession(context.Background(), func(sess *db.Session) error {
+			for _, item := range items {
+				item.Data = simplejson.New()
+				if _, err := sess.Insert(&item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+
+		stats := collect(t)
+		require.EqualValues(t, 4, stats.Total)
+		require.EqualValues(t, 2, stats.Alerting)
+		require.EqualValues(t, 1, stats.Dashboard)
+		require.EqualValues(t, 3, stats.Recent)
+		require.Equal(t, now.Add(-72*time.Hour).UnixMilli(), stats.Oldest.UnixMilli())
+		require.Equal(t, now.UnixMilli(), stats.Newest.UnixMilli())
+		require.Len(t, stats.ByOrg, 2)
+		require.EqualValues(t, 1, stats.ByOrg[0].OrgID)
+		require.EqualValues(t, 3, stats.ByOrg[0].Annotations)
+		// SQLite only reports the size when built with the dbstat virtual table
+		require.True(t, stats.SizeBytes != nil || stats.SizeError != "")
+	})
+}
@@ -3,8 +3,19 @@ package supportbundlesimpl
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"mime"
 	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
 
 	grafanaApi "github.com/grafana/grafana/pkg/api"
 	"github.com/grafana/grafana/pkg/api/response"
@@ -41,39 +52,200 @@ func (s *Service) registerAPIEndpoints(httpServer *grafanaApi.HTTPServer, routeR
 		subrouter.Post("/", authorize(orgRoleMiddleware,
 			ac.EvalPermission(ActionCreate)), routing.Wrap(s.handleCreate))
 		subrouter.Get("/:uid", authorize(orgRoleMiddleware,
-			ac.EvalPermission(ActionRead)), s.handleDownload)
+			ac.EvalPermission(ActionRead)), routing.Wrap(s.handleDownload))
+		subrouter.Get("/diff", authorize(orgRoleMiddleware,
+			ac.EvalPermission(ActionRead)), routing.Wrap(s.handleDiff))
+		if s.audit != nil {
+			subrouter.Get("/audit", authorize(middleware.ReqGrafanaAdmin,
+				ac.EvalPermission(ActionRead)), routing.Wrap(s.handleAudit))
+		}
+		subrouter.Get("/:uid/SHA256SUMS", authorize(orgRoleMiddleware,
+			ac.EvalPermission(ActionRead)), routing.Wrap(s.handleChecksums))
+		subrouter.Get("/:uid/files/*", authorize(orgRoleMiddleware,
+			ac.EvalPermission(ActionRead)), routing.Wrap(s.handleDownloadFile))
+		subrouter.Get("/:uid/status", authorize(orgRoleMiddleware,
+			ac.EvalPermission(ActionRead)), routing.Wrap(s.handleGet))
 		subrouter.Delete("/:uid", authorize(orgRoleMiddleware,
 			ac.EvalPermission(ActionDelete)), s.handleRemove)
+		subrouter.Delete("/", authorize(middleware.ReqGrafanaAdmin,
+			ac.EvalPermission(ActionDelete)), routing.Wrap(s.handlePurge))
+		subrouter.Post("/:uid/cancel", authorize(orgRoleMiddleware,
+			ac.EvalPermission(ActionCreate)), routing.Wrap(s.handleCancel))
+		subrouter.Post("/:uid/retry", authorize(orgRoleMiddleware,
+			ac.EvalPermission(ActionCreate)), routing.Wrap(s.handleRetry))
+		subrouter.Post("/:uid/extend", authorize(orgRoleMiddleware,
+			ac.EvalPermission(ActionRead)), routing.Wrap(s.handleExtend))
+		subrouter.Get("/jobs/:uid", authorize(orgRoleMiddleware,
+			ac.EvalPermission(ActionRead)), routing.Wrap(s.handleGetJob))
+		subrouter.Post("/validate", authorize(middleware.ReqGrafanaAdmin,
+			ac.EvalPermission(ActionRead)), routing.Wrap(s.handleValidate))
+		subrouter.Post("/validate/uploads", authorize(middleware.ReqGrafanaAdmin,
+			ac.EvalPermission(ActionRead)), routing.Wrap(s.handleCreateUpload))
+		subrouter.Get("/validate/uploads/:uid", authorize(middleware.ReqGrafanaAdmin,
+			ac.EvalPermission(ActionRead)), routing.Wrap(s.handleGetUpload))
+		subrouter.Put("/validate/uploads/:uid", authorize(middleware.ReqGrafanaAdmin,
+			ac.EvalPermission(ActionRead)), routing.Wrap(s.handleUploadChunk))
+		subrouter.Post("/validate/uploads/:uid/complete", authorize(middleware.ReqGrafanaAdmin,
+			ac.EvalPermission(ActionRead)), routing.Wrap(s.handleCompleteUpload))
+		subrouter.Delete("/validate/uploads/:uid", authorize(middleware.ReqGrafanaAdmin,
+			ac.EvalPermission(ActionRead)), routing.Wrap(s.handleCancelUpload))
 		subrouter.Get("/collectors", authorize(orgRoleMiddleware,
 			ac.EvalPermission(ActionCreate)), routing.Wrap(s.handleGetCollectors))
+		subrouter.Get("/collectors/:uid/preview", authorize(orgRoleMiddleware,
+			ac.EvalPermission(ActionCreate)), routing.Wrap(s.handlePreview))
+		subrouter.Get("/presets", authorize(orgRoleMiddleware,
+			ac.EvalPermission(ActionCreate)), routing.Wrap(s.handleGetPresets))
+		subrouter.Get("/templates", authorize(orgRoleMiddleware,
+			ac.EvalPermission(ActionRead)), routing.Wrap(s.handleListTemplates))
+		subrouter.Post("/templates", authorize(orgRoleMiddleware,
+			ac.EvalPermission(ActionCreate)), routing.Wrap(s.handleCreateTemplate))
+		subrouter.Get("/templates/:uid", authorize(orgRoleMiddleware,
+			ac.EvalPermission(ActionRead)), routing.Wrap(s.handleGetTemplate))
+		subrouter.Put("/templates/:uid", authorize(orgRoleMiddleware,
+			ac.EvalPermission(ActionCreate)), routing.Wrap(s.handleUpdateTemplate))
+		subrouter.Delete("/templates/:uid", authorize(orgRoleMiddleware,
+			ac.EvalPermission(ActionCreate)), routing.Wrap(s.handleDeleteTemplate))
+
+		if s.tokens != nil {
+			subrouter.Post("/tokens", authorize(middleware.ReqGrafanaAdmin,
+				ac.EvalPermission(ActionCreate)), routing.Wrap(s.handleCreateToken))
+			// authenticated by the token instead of a session, so that automation can create bundles
+			subrouter.Post("/tokens/create", s.requireCreationToken, routing.Wrap(s.handleCreate))
+		}
 	})
 }
 
+// handleList lists the bundles, newest first by default.
+//
+// Query parameters:
+//   - tag: key=value, or key to match any value. Bundles must have every tag.
+//   - sort: createdAt, expiresAt or state, order: asc or desc (default).
+//   - limit and page (starting at 1) page the results, every bundle is returned without a limit.
 func (s *Service) handleList(ctx *contextmodel.ReqContext) response.Response {
-	bundles, err := s.list(ctx.Req.Context())
-	if err != nil {
-		return response.Error(http.StatusInternalServerError, "failed to list bundles", err)
+	type listResponse struct {
+		TotalCount int                     `json:"totalCount"`
+		Bundles    []supportbundles.Bundle `json:"bundles"`
+		Page       int                     `json:"page"`
+		PerPage    int                     `json:"perPage"`
+	}
+
+	query := listQuery{
+		Tags:      map[string]string{},
+		Sort:      ctx.Query("sort"),
+		Ascending: ctx.Query("order") == "asc",
+		Limit:     ctx.QueryInt("limit"),
+		Page:      ctx.QueryInt("page"),
+	}
+	for _, tag := range ctx.QueryStrings("tag") {
+		key, value, _ := strings.Cut(tag, "=")
+		query.Tags[key] = value
+	}
+	if query.Page < 1 {
+		query.Page = 1
 	}
 
-	data, err := json.Marshal(bundles)
+	bundles, total, err := s.list(ctx.Req.Context(), query)
+	if errors.Is(err, ErrInvalidListQuery) {
+		return response.Error(http.StatusBadRequest, err.Error(), err)
+	}
 	if err != nil {
-		return response.Error(http.StatusInternalServerError, "failed to encode bundle", err)
+		return response.Error(http.StatusInternalServerError, "failed to list bundles", err)
 	}
 
-	return response.JSON(http.StatusOK, data)
+	return response.JSON(http.StatusOK, listResponse{
+		TotalCount: total,
+		Bundles:    bundles,
+		Page:       query.Page,
+		PerPage:    query.Limit,
+	})
 }
 
 func (s *Service) handleCreate(ctx *contextmodel.ReqContext) response.Response {
 	type command struct {
 		Collectors []string `json:"collectors"`
+		// Retention overrides how long the bundle is kept, e.g. "7d". Optional.
+		Retention   string            `json:"retention"`
+		Description string            `json:"description"`
+		Tags        map[string]string `json:"tags"`
+		// UploadURL is where to upload the archive to instead of storing it, e.g. a pre-signed S3 PUT URL. Optional.
+		UploadURL string `json:"uploadUrl"`
+		// Preset names a list of collectors to run along with Collectors, e.g. "minimal". Optional.
+		Preset string `json:"preset"`
+		// Template is the UID of a template of the organization whose collectors and tags are added. Optional.
+		Template string `json:"template"`
+		// Params are the parameters of the collectors by collector UID, e.g. {"log-tail": {"lines": 500}}. Optional.
+		Params map[string]map[string]int64 `json:"params"`
 	}
 
 	var c command
-	if err := web.Bind(ctx.Req, &c); err != nil {
+	var attachments []attachment
+	if mediaType, _, _ := mime.ParseMediaType(ctx.Req.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		// the request is sent as the "request" field, next to the "attachments" files
+		ctx.Req.Body = http.MaxBytesReader(ctx.Resp, ctx.Req.Body, s.attachmentsMaxSize+attachmentsUploadOverhead)
+		if err := ctx.Req.ParseMultipartForm(validateMemoryLimit); err != nil {
+			return response.Error(http.StatusBadRequest, fmt.Sprintf("failed to parse upload, attachments must be smaller than %d bytes in total", s.attachmentsMaxSize), err)
+		}
+		defer func() {
+			if err := ctx.Req.MultipartForm.RemoveAll(); err != nil {
+				s.log.Warn("Failed to remove uploaded support bundle attachments", "error", err)
+			}
+		}()
+
+		if request := ctx.Req.FormValue("request"); request != "" {
+			if err := json.Unmarshal([]byte(request), &c); err != nil {
+				return response.Error(http.StatusBadRequest, "failed to parse request", err)
+			}
+		}
+
+		var err error
+		attachments, err = readAttachments(ctx.Req.MultipartForm.File["attachments"])
+		if err != nil {
+			return response.Error(http.StatusBadRequest, "failed to read attachments", err)
+		}
+	} else if err := web.Bind(ctx.Req, &c); err != nil {
 		return response.Error(http.StatusBadRequest, "failed to parse request", err)
 	}
 
-	bundle, err := s.create(context.Background(), c.Collectors, ctx.SignedInUser)
+	var retention time.Duration
+	if c.Retention != "" {
+		var err error
+		retention, err = gtime.ParseDuration(c.Retention)
+		if err != nil || retention <= 0 {
+			return response.Error(http.StatusBadRequest, "invalid retention", err)
+		}
+	}
+
+	if ctx.QueryBool("dryRun") {
+		collectors, _, err := s.withTemplate(ctx.Req.Context(), ctx.SignedInUser.OrgID, c.Template, c.Collectors, nil)
+		if err == nil {
+			collectors, err = s.withPreset(c.Preset, collectors)
+		}
+		if err != nil {
+			return response.Error(http.StatusBadRequest, err.Error(), err)
+		}
+		return s.handleDryRun(ctx, collectors)
+	}
+
+	bundle, err := s.create(context.Background(), ctx.SignedInUser, createOptions{
+		Collectors:  c.Collectors,
+		Retention:   retention,
+		Description: c.Description,
+		Tags:        c.Tags,
+		Attachments: attachments,
+		UploadURL:   c.UploadURL,
+		Preset:      c.Preset,
+		Template:    c.Template,
+		Origin:      newRequestOrigin(ctx.Req),
+		Params:      c.Params,
+	})
+	if errors.Is(err, ErrUnknownCollector) || errors.Is(err, ErrCollectorDisabled) || errors.Is(err, ErrInvalidTags) || errors.Is(err, ErrInvalidAttachments) ||
+		errors.Is(err, ErrInvalidUploadURL) || errors.Is(err, ErrUnknownPreset) || errors.Is(err, ErrTemplateNotFound) || errors.Is(err, ErrInvalidTemplate) ||
+		errors.Is(err, ErrInvalidCollectorParams) {
+		return response.Error(http.StatusBadRequest, err.Error(), err)
+	}
+	if errors.Is(err, ErrTooManyBundles) {
+		return response.Error(http.StatusTooManyRequests, "too many support bundles are being created, try again later", err)
+	}
 	if err != nil {
 		return response.Error(http.StatusInternalServerError, "failed to create support bundle", err)
 	}
@@ -83,7 +255,24 @@ func (s *Service) handleCreate(ctx *contextmodel.ReqContext) response.Response {
 		return response.Error(http.StatusInternalServerError, "failed to encode bundle", err)
 	}
 
-	return response.JSON(http.StatusCreated, data)
+	// the bundle UID is the ID of the job collecting it
+	return response.JSON(http.StatusCreated, data).SetHeader("Location", rootUrl+"/jobs/"+bundle.UID)
+}
+
+// handleDryRun runs the collectors without persisting anything and returns the estimated bundle size.
+func (s *Service) handleDryRun(ctx *contextmodel.ReqContext, collectors []string) response.Response {
+	estimate, err := s.estimate(ctx.Req.Context(), collectors, ctx.SignedInUser)
+	if errors.Is(err, ErrUnknownCollector) || errors.Is(err, ErrCollectorDisabled) {
+		return response.Error(http.StatusBadRequest, err.Error(), err)
+	}
+	if errors.Is(err, ErrTooManyBundles) {
+		return response.Error(http.StatusTooManyRequests, "too many support bundles are being created, try again later", err)
+	}
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to estimate support bundle size", err)
+	}
+
+	return response.JSON(http.StatusOK, estimate)
 }
 
 func (s *Service) handleDownload(ctx *contextmodel.ReqContext) response.Response {
@@ -93,13 +282,216 @@ func (s *Service) handleDownload(ctx *contextmodel.ReqContext) response.Response
 		return response.Redirect("/support-bundles")
 	}
 
-	if bundle.State != supportbundles.StateComplete {
+	// bundles being created can be downloaded with the collectors done so far
+	partial := ctx.QueryBool("partial") && bundle.State == supportbundles.StatePending
+
+	// uploaded bundles aren't stored
+	if !partial && (!bundle.State.HasArchive() || bundle.UploadedTo != "") {
 		return response.Redirect("/support-bundles")
 	}
 
-	ctx.Resp.Header().Set("Content-Type", "application/tar+gzip")
-	ctx.Resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.tar.gz", uid))
-	return response.CreateNormalResponse(ctx.Resp.Header(), bundle.TarBytes, http.StatusOK)
+	if !partial {
+		// archives never change once stored, so they were last modified when the bundle was
+		// created. Revalidating a copy doesn't count as a download.
+		etag := checksumETag(bundle.Checksum)
+		lastModified := time.Unix(bundle.CreatedAt, 0).UTC()
+		if etag != "" {
+			ctx.Resp.Header().Set("ETag", etag)
+		}
+		ctx.Resp.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		if notModified(ctx.Req, etag, lastModified) {
+			// 304 responses have no body, not even the "null" of an empty JSON response
+			ctx.Resp.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+
+	if ok, wait := s.downloads.allow(ctx.SignedInUser, time.Now()); !ok {
+		ctx.Resp.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return response.Error(http.StatusTooManyRequests, "too many support bundle downloads, try again later", nil)
+	}
+
+	if partial {
+		return s.downloadPartial(ctx, bundle)
+	}
+
+	reader, size, err := s.store.GetReader(ctx.Req.Context(), uid)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to read support bundle", err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			s.log.Warn("Failed to close support bundle reader", "uid", uid, "error", err)
+		}
+	}()
+	s.audit.record(ctx.Req.Context(), ctx.SignedInUser, auditEntry{Action: auditActionDownload, BundleUID: uid, Encrypted: bundle.Encrypted})
+
+	format := bundle.Format
+	if format == "" {
+		format = formatTarGz
+	}
+
+	ctx.Resp.Header().Set("Content-Type", archiveContentType(format))
+	ctx.Resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", uid, format))
+	ctx.Resp.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	if digest := checksumDigest(bundle.Checksum); digest != "" {
+		ctx.Resp.Header().Set("Digest", digest)
+	}
+	ctx.Resp.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(ctx.Resp, reader); err != nil {
+		s.log.Error("Failed to stream support bundle", "uid", uid, "error", err)
+	}
+	return nil
+}
+
+// downloadPartial serves the archive of the collectors of a bundle being created
+// that are done so far. It's labelled as partial in its name, its manifest and
+// the partialBundleHeader header, and has no checksum as it changes as collectors finish.
+func (s *Service) downloadPartial(ctx *contextmodel.ReqContext, bundle *supportbundles.Bundle) response.Response {
+	archive, err := s.partialArchive(bundle)
+	if errors.Is(err, ErrPartialBundleNotAvailable) {
+		return response.Error(http.StatusConflict, "the support bundle is being created by another instance or has just completed, try again", err)
+	}
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to archive partial support bundle", err)
+	}
+	s.audit.record(ctx.Req.Context(), ctx.SignedInUser, auditEntry{Action: auditActionDownload, BundleUID: bundle.UID, Partial: true})
+
+	format := s.archiveFormat
+	if format == "" {
+		format = formatTarGz
+	}
+
+	return response.Respond(http.StatusOK, archive).
+		SetHeader("Content-Type", archiveContentType(format)).
+		SetHeader("Content-Disposition", fmt.Sprintf("attachment; filename=%s-partial.%s", bundle.UID, format)).
+		SetHeader(partialBundleHeader, "true")
+}
+
+// handleChecksums returns the checksum of the bundle archive in the format of sha256sum,
+// so that a downloaded bundle can be checked with `sha256sum -c SHA256SUMS`.
+func (s *Service) handleChecksums(ctx *contextmodel.ReqContext) response.Response {
+	uid := web.Params(ctx.Req)[":uid"]
+	bundle, err := s.get(ctx.Req.Context(), uid)
+	if err != nil {
+		return response.Error(http.StatusNotFound, "support bundle not found", err)
+	}
+	if bundle.Checksum == "" {
+		return response.Error(http.StatusNotFound, "support bundle has no checksum", nil)
+	}
+
+	format := bundle.Format
+	if format == "" {
+		format = formatTarGz
+	}
+
+	return response.Respond(http.StatusOK, fmt.Sprintf("%s  %s.%s\n", bundle.Checksum, uid, format)).
+		SetHeader("Content-Type", "text/plain; charset=utf-8").
+		SetHeader("Content-Disposition", "attachment; filename=SHA256SUMS")
+}
+
+// handleDownloadFile streams a single file of the bundle archive, e.g. settings.json.
+func (s *Service) handleDownloadFile(ctx *contextmodel.ReqContext) response.Response {
+	uid := web.Params(ctx.Req)[":uid"]
+	name := web.Params(ctx.Req)["*"]
+
+	bundle, err := s.get(ctx.Req.Context(), uid)
+	if err != nil {
+		return response.Error(http.StatusNotFound, "support bundle not found", err)
+	}
+
+	err = s.readBundleFile(ctx.Req.Context(), bundle, name, func(r io.Reader, size int64) error {
+		s.audit.record(ctx.Req.Context(), ctx.SignedInUser, auditEntry{Action: auditActionDownloadFile, BundleUID: uid, File: name, Encrypted: bundle.Encrypted})
+
+		contentType := mime.TypeByExtension(filepath.Ext(name))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		ctx.Resp.Header().Set("Content-Type", contentType)
+		ctx.Resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filepath.Base(name)))
+		ctx.Resp.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		ctx.Resp.WriteHeader(http.StatusOK)
+
+		_, err := io.Copy(ctx.Resp, r)
+		return err
+	})
+	if errors.Is(err, ErrBundleFileNotFound) {
+		return response.Error(http.StatusNotFound, "support bundle file not found", err)
+	}
+	if err != nil {
+		if ctx.Resp.Written() {
+			s.log.Error("Failed to stream support bundle file", "uid", uid, "file", name, "error", err)
+			return nil
+		}
+		return response.Error(http.StatusInternalServerError, "failed to read support bundle file", err)
+	}
+	return nil
+}
+
+func (s *Service) handleGet(ctx *contextmodel.ReqContext) response.Response {
+	uid := web.Params(ctx.Req)[":uid"]
+	bundle, err := s.get(ctx.Req.Context(), uid)
+	if err != nil {
+		return response.Error(http.StatusNotFound, "support bundle not found", err)
+	}
+
+	// only the bundle metadata and progress are returned, use the download endpoint for the archive
+	bundle.TarBytes = nil
+
+	return response.JSON(http.StatusOK, bundle)
+}
+
+// handleGetJob returns the status of the job collecting a bundle, for automation to poll until it's done.
+func (s *Service) handleGetJob(ctx *contextmodel.ReqContext) response.Response {
+	uid := web.Params(ctx.Req)[":uid"]
+	bundle, err := s.get(ctx.Req.Context(), uid)
+	if err != nil {
+		return response.Error(http.StatusNotFound, "support bundle job not found", err)
+	}
+
+	return response.JSON(http.StatusOK, newJob(bundle))
+}
+
+// handleDiff compares the settings, feature flags and plugins of the bundles
+// given by the a and b query parameters, e.g. from before and after an upgrade.
+func (s *Service) handleDiff(ctx *contextmodel.ReqContext) response.Response {
+	uidA, uidB := ctx.Query("a"), ctx.Query("b")
+	if uidA == "" || uidB == "" {
+		return response.Error(http.StatusBadRequest, "the a and b query parameters are required", nil)
+	}
+
+	// the read permission required by the route covers every bundle, each of them must still exist
+	a, err := s.get(ctx.Req.Context(), uidA)
+	if err != nil {
+		return response.Error(http.StatusNotFound, "support bundle a not found", err)
+	}
+	b, err := s.get(ctx.Req.Context(), uidB)
+	if err != nil {
+		return response.Error(http.StatusNotFound, "support bundle b not found", err)
+	}
+
+	diff, err := s.diff(ctx.Req.Context(), a, b)
+	if errors.Is(err, ErrBundleNotComparable) {
+		return response.Error(http.StatusBadRequest, err.Error(), err)
+	}
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to compare support bundles", err)
+	}
+
+	return response.JSON(http.StatusOK, diff)
+}
+
+// handleAudit returns the audit trail of the bundles, newest first. The uid query
+// parameter restricts it to a bundle, and limit bounds the number of entries.
+func (s *Service) handleAudit(ctx *contextmodel.ReqContext) response.Response {
+	entries, err := s.audit.list(ctx.Req.Context(), ctx.Query("uid"), ctx.QueryInt("limit"))
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to read the support bundle audit log", err)
+	}
+
+	return response.JSON(http.StatusOK, entries)
 }
 
 func (s *Service) handleRemove(ctx *contextmodel.ReqContext) response.Response {
@@ -108,16 +500,390 @@ func (s *Service) handleRemove(ctx *contextmodel.ReqContext) response.Response {
 	if err != nil {
 		return response.Error(http.StatusInternalServerError, "failed to remove bundle", err)
 	}
+	s.audit.record(ctx.Req.Context(), ctx.SignedInUser, auditEntry{Action: auditActionRemove, BundleUID: uid})
 
 	return response.Respond(http.StatusOK, "successfully removed the support bundle")
 }
 
+// handlePurge removes every bundle that isn't being created anymore.
+func (s *Service) handlePurge(ctx *contextmodel.ReqContext) response.Response {
+	type purgeResponse struct {
+		RemovedCount int `json:"removedCount"`
+		SkippedCount int `json:"skippedCount"`
+		*purgeResult
+	}
+
+	result, err := s.purge(ctx.Req.Context())
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to remove bundles", err)
+	}
+	for _, uid := range result.Removed {
+		s.audit.record(ctx.Req.Context(), ctx.SignedInUser, auditEntry{Action: auditActionRemove, BundleUID: uid})
+	}
+
+	return response.JSON(http.StatusOK, purgeResponse{
+		RemovedCount: len(result.Removed),
+		SkippedCount: len(result.Skipped),
+		purgeResult:  result,
+	})
+}
+
+func (s *Service) handleCancel(ctx *contextmodel.ReqContext) response.Response {
+	uid := web.Params(ctx.Req)[":uid"]
+	err := s.cancel(ctx.Req.Context(), uid)
+	if errors.Is(err, ErrBundleNotPending) {
+		return response.Error(http.StatusConflict, "support bundle is no longer being created", err)
+	}
+	if err != nil {
+		return response.Error(http.StatusNotFound, "failed to cancel bundle", err)
+	}
+
+	return response.Respond(http.StatusOK, "support bundle creation cancelled")
+}
+
+// handleExtend pushes the expiry of a bundle out by the duration in the request, e.g. {"extendBy": "7d"}.
+func (s *Service) handleExtend(ctx *contextmodel.ReqContext) response.Response {
+	type command struct {
+		ExtendBy string `json:"extendBy"`
+	}
+
+	uid := web.Params(ctx.Req)[":uid"]
+	if _, err := s.get(ctx.Req.Context(), uid); err != nil {
+		return response.Error(http.StatusNotFound, "support bundle not found", err)
+	}
+
+	var c command
+	if err := web.Bind(ctx.Req, &c); err != nil {
+		return response.Error(http.StatusBadRequest, "failed to parse request", err)
+	}
+	by, err := gtime.ParseDuration(c.ExtendBy)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "invalid extendBy", err)
+	}
+
+	bundle, err := s.extend(ctx.Req.Context(), uid, by)
+	if errors.Is(err, ErrInvalidExtension) {
+		return response.Error(http.StatusBadRequest, err.Error(), err)
+	}
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to extend support bundle expiry", err)
+	}
+	s.audit.record(ctx.Req.Context(), ctx.SignedInUser, auditEntry{Action: auditActionExtend, BundleUID: uid})
+
+	bundle.TarBytes = nil
+	return response.JSON(http.StatusOK, bundle)
+}
+
+func (s *Service) handleRetry(ctx *contextmodel.ReqContext) response.Response {
+	uid := web.Params(ctx.Req)[":uid"]
+	if _, err := s.get(ctx.Req.Context(), uid); err != nil {
+		return response.Error(http.StatusNotFound, "support bundle not found", err)
+	}
+
+	bundle, err := s.retry(ctx.Req.Context(), uid, ctx.SignedInUser)
+	if errors.Is(err, ErrBundlePending) {
+		return response.Error(http.StatusConflict, "support bundle is still being created", err)
+	}
+	if errors.Is(err, ErrBundleNotRetryable) {
+		return response.Error(http.StatusBadRequest, err.Error(), err)
+	}
+	if errors.Is(err, ErrTooManyBundles) {
+		return response.Error(http.StatusTooManyRequests, "too many support bundles are being created, try again later", err)
+	}
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to retry support bundle", err)
+	}
+
+	return response.JSON(http.StatusAccepted, bundle)
+}
+
+// handleValidate inspects a bundle uploaded in the bundle field of a multipart
+// form, e.g. one sent by a customer, without persisting it. The optional checksum
+// field is the expected checksum, or the content of a SHA256SUMS file.
+func (s *Service) handleValidate(ctx *contextmodel.ReqContext) response.Response {
+	ctx.Req.Body = http.MaxBytesReader(ctx.Resp, ctx.Req.Body, s.maxUploadSize)
+	if err := ctx.Req.ParseMultipartForm(validateMemoryLimit); err != nil {
+		return response.Error(http.StatusBadRequest, fmt.Sprintf("failed to parse upload, bundles must be smaller than %d bytes", s.maxUploadSize), err)
+	}
+	defer func() {
+		if err := ctx.Req.MultipartForm.RemoveAll(); err != nil {
+			s.log.Warn("Failed to remove uploaded support bundle", "error", err)
+		}
+	}()
+
+	file, header, err := ctx.Req.FormFile("bundle")
+	if err != nil {
+		return response.Error(http.StatusBadRequest, "the bundle file is missing", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	validation, err := s.validateBundle(ctx.Req.Context(), file, header.Size, ctx.Req.FormValue("checksum"))
+	if errors.Is(err, ErrInvalidBundle) {
+		return response.Error(http.StatusBadRequest, err.Error(), err)
+	}
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to validate support bundle", err)
+	}
+
+	return response.JSON(http.StatusOK, validation)
+}
+
+// handleCreateUpload starts the upload of a bundle in chunks, for bundles too
+// large to be uploaded to the validate endpoint in a single request. The body
+// is the size of the bundle in bytes and the optional expected checksum:
+//
+//	{"size": 4294967296, "checksum": "..."}
+//
+// The chunks are then sent with PUT and a Content-Range header, the upload is
+// resumed from the received bytes reported by GET, and validated once complete.
+func (s *Service) handleCreateUpload(ctx *contextmodel.ReqContext) response.Response {
+	var body struct {
+		Size     int64  `json:"size"`
+		Checksum string `json:"checksum"`
+	}
+	if err := web.Bind(ctx.Req, &body); err != nil {
+		return response.Error(http.StatusBadRequest, "failed to parse request", err)
+	}
+
+	upload, err := s.uploads.create(ctx.SignedInUser, body.Size, body.Checksum)
+	if err != nil {
+		return uploadErrorResponse(err, "failed to start support bundle upload")
+	}
+	return response.JSON(http.StatusCreated, upload)
+}
+
+// handleGetUpload returns how many bytes of an upload were received, the next chunk starts there.
+func (s *Service) handleGetUpload(ctx *contextmodel.ReqContext) response.Response {
+	upload, err := s.uploads.get(ctx.SignedInUser, web.Params(ctx.Req)[":uid"])
+	if err != nil {
+		return uploadErrorResponse(err, "failed to get support bundle upload")
+	}
+	return response.JSON(http.StatusOK, upload)
+}
+
+// handleUploadChunk writes the chunk in the body to an upload, at the range of
+// the Content-Range header, e.g. bytes 0-1048575/4294967296.
+func (s *Service) handleUploadChunk(ctx *contextmodel.ReqContext) response.Response {
+	upload, err := s.uploads.write(ctx.SignedInUser, web.Params(ctx.Req)[":uid"], ctx.Req.Header.Get("Content-Range"), ctx.Req.Body)
+	if err != nil {
+		return uploadErrorResponse(err, "failed to write support bundle upload chunk")
+	}
+	return response.JSON(http.StatusOK, upload)
+}
+
+// handleCompleteUpload validates a complete upload like handleValidate, and removes it.
+func (s *Service) handleCompleteUpload(ctx *contextmodel.ReqContext) response.Response {
+	file, upload, done, err := s.uploads.open(ctx.SignedInUser, web.Params(ctx.Req)[":uid"])
+	if err != nil {
+		return uploadErrorResponse(err, "failed to complete support bundle upload")
+	}
+	defer done()
+
+	validation, err := s.validateBundle(ctx.Req.Context(), file, upload.Size, upload.Checksum)
+	if errors.Is(err, ErrInvalidBundle) {
+		return response.Error(http.StatusBadRequest, err.Error(), err)
+	}
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to validate support bundle", err)
+	}
+
+	return response.JSON(http.StatusOK, validation)
+}
+
+// handleCancelUpload removes an upload before it's complete.
+func (s *Service) handleCancelUpload(ctx *contextmodel.ReqContext) response.Response {
+	if err := s.uploads.cancel(ctx.SignedInUser, web.Params(ctx.Req)[":uid"]); err != nil {
+		return uploadErrorResponse(err, "failed to cancel support bundle upload")
+	}
+	return response.Respond(http.StatusOK, "support bundle upload cancelled")
+}
+
+// uploadErrorResponse maps the errors of chunked uploads to their status code.
+func uploadErrorResponse(err error, message string) response.Response {
+	switch {
+	case errors.Is(err, ErrUploadNotFound):
+		return response.Error(http.StatusNotFound, err.Error(), err)
+	case errors.Is(err, ErrUploadTooLarge):
+		return response.Error(http.StatusRequestEntityTooLarge, err.Error(), err)
+	case errors.Is(err, ErrInvalidChunk):
+		return response.Error(http.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, ErrChunkOutOfOrder):
+		return response.Error(http.StatusRequestedRangeNotSatisfiable, err.Error(), err)
+	case errors.Is(err, ErrUploadBusy), errors.Is(err, ErrUploadIncomplete):
+		return response.Error(http.StatusConflict, err.Error(), err)
+	case errors.Is(err, ErrTooManyUploads):
+		return response.Error(http.StatusTooManyRequests, err.Error(), err)
+	default:
+		return response.Error(http.StatusInternalServerError, message, err)
+	}
+}
+
+// handlePreview runs a single collector and returns its redacted output without creating a bundle.
+func (s *Service) handlePreview(ctx *contextmodel.ReqContext) response.Response {
+	uid := web.Params(ctx.Req)[":uid"]
+	preview, err := s.preview(ctx.Req.Context(), uid, ctx.SignedInUser)
+	if errors.Is(err, ErrUnknownCollector) {
+		return response.Error(http.StatusNotFound, err.Error(), err)
+	}
+	if errors.Is(err, ErrCollectorForbidden) || errors.Is(err, ErrCollectorDisabled) {
+		return response.Error(http.StatusForbidden, err.Error(), err)
+	}
+	if errors.Is(err, ErrTooManyBundles) {
+		return response.Error(http.StatusTooManyRequests, "too many support bundles are being created, try again later", err)
+	}
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to preview support bundle collector", err)
+	}
+
+	return response.JSON(http.StatusOK, preview)
+}
+
+// handleGetPresets lists the collector presets, expanded to the collectors available on this instance.
+func (s *Service) handleGetPresets(ctx *contextmodel.ReqContext) response.Response {
+	presets := make(map[string][]string, len(s.presets))
+	for _, name := range s.presetNames() {
+		collectors, err := s.expandPreset(name)
+		if err != nil {
+			return response.Error(http.StatusInternalServerError, "failed to expand support bundle preset", err)
+		}
+		presets[name] = collectors
+	}
+
+	return response.JSON(http.StatusOK, presets)
+}
+
+// templateCommand is the body of the requests creating and updating templates.
+type templateCommand struct {
+	Name       string            `json:"name"`
+	Collectors []string          `json:"collectors"`
+	Tags       map[string]string `json:"tags"`
+}
+
+// handleListTemplates lists the templates of the organization of the signed in user.
+func (s *Service) handleListTemplates(ctx *contextmodel.ReqContext) response.Response {
+	templates, err := s.templates.list(ctx.Req.Context(), ctx.SignedInUser.OrgID)
+	if err != nil {
+		return templateErrorResponse(err, "failed to list support bundle templates")
+	}
+	return response.JSON(http.StatusOK, templates)
+}
+
+func (s *Service) handleGetTemplate(ctx *contextmodel.ReqContext) response.Response {
+	t, err := s.templates.get(ctx.Req.Context(), ctx.SignedInUser.OrgID, web.Params(ctx.Req)[":uid"])
+	if err != nil {
+		return templateErrorResponse(err, "failed to get support bundle template")
+	}
+	return response.JSON(http.StatusOK, t)
+}
+
+func (s *Service) handleCreateTemplate(ctx *contextmodel.ReqContext) response.Response {
+	var c templateCommand
+	if err := web.Bind(ctx.Req, &c); err != nil {
+		return response.Error(http.StatusBadRequest, "failed to parse request", err)
+	}
+
+	t := &bundleTemplate{OrgID: ctx.SignedInUser.OrgID, Name: c.Name, Collectors: c.Collectors, Tags: c.Tags}
+	if err := s.saveTemplate(ctx.Req.Context(), t); err != nil {
+		return templateErrorResponse(err, "failed to create support bundle template")
+	}
+	return response.JSON(http.StatusCreated, t)
+}
+
+func (s *Service) handleUpdateTemplate(ctx *contextmodel.ReqContext) response.Response {
+	var c templateCommand
+	if err := web.Bind(ctx.Req, &c); err != nil {
+		return response.Error(http.StatusBadRequest, "failed to parse request", err)
+	}
+
+	t := &bundleTemplate{UID: web.Params(ctx.Req)[":uid"], OrgID: ctx.SignedInUser.OrgID, Name: c.Name, Collectors: c.Collectors, Tags: c.Tags}
+	if err := s.saveTemplate(ctx.Req.Context(), t); err != nil {
+		return templateErrorResponse(err, "failed to update support bundle template")
+	}
+	return response.JSON(http.StatusOK, t)
+}
+
+func (s *Service) handleDeleteTemplate(ctx *contextmodel.ReqContext) response.Response {
+	if err := s.templates.delete(ctx.Req.Context(), ctx.SignedInUser.OrgID, web.Params(ctx.Req)[":uid"]); err != nil {
+		return templateErrorResponse(err, "failed to delete support bundle template")
+	}
+	return response.Respond(http.StatusOK, "support bundle template deleted")
+}
+
+// templateErrorResponse maps the errors of the template store to their status,
+// the templates of other organizations are reported as not found.
+func templateErrorResponse(err error, message string) response.Response {
+	switch {
+	case errors.Is(err, ErrTemplateNotFound):
+		return response.Error(http.StatusNotFound, "support bundle template not found", err)
+	case errors.Is(err, ErrInvalidTemplate):
+		return response.Error(http.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, ErrTemplateNameTaken):
+		return response.Error(http.StatusConflict, err.Error(), err)
+	default:
+		return response.Error(http.StatusInternalServerError, message, err)
+	}
+}
+
+// handleCreateToken mints a short-lived token that only allows creating a bundle on behalf of the signed in user.
+func (s *Service) handleCreateToken(ctx *contextmodel.ReqContext) response.Response {
+	type tokenResponse struct {
+		Token     string `json:"token"`
+		ExpiresAt int64  `json:"expiresAt"`
+	}
+
+	token, expiresAt, err := s.tokens.mint(ctx.SignedInUser, time.Now())
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "failed to create support bundle token", err)
+	}
+
+	return response.JSON(http.StatusCreated, tokenResponse{Token: token, ExpiresAt: expiresAt.Unix()})
+}
+
+// requireCreationToken authenticates the request with the token in the
+// creationTokenHeader header, and attributes it to the user who minted the token.
+// The token is spent even if the bundle can't be created.
+func (s *Service) requireCreationToken(c *contextmodel.ReqContext) {
+	claims, err := s.tokens.verify(c.Req.Header.Get(creationTokenHeader), time.Now())
+	if err != nil {
+		c.JsonApiErr(http.StatusUnauthorized, "invalid or expired support bundle token", err)
+		return
+	}
+	if err := s.tokens.redeem(c.Req.Context(), claims); err != nil {
+		if errors.Is(err, ErrTokenUsed) {
+			c.JsonApiErr(http.StatusUnauthorized, "support bundle token has already been used", err)
+			return
+		}
+		c.JsonApiErr(http.StatusInternalServerError, "failed to redeem support bundle token", err)
+		return
+	}
+
+	c.SignedInUser = tokenUser(claims)
+	s.log.Info("Support bundle creation authorized by token", "creator", claims.Creator, "orgID", claims.OrgID)
+}
+
+// handleGetCollectors lists the registered collectors along with the ones disabled by the configuration.
 func (s *Service) handleGetCollectors(ctx *contextmodel.ReqContext) response.Response {
-	collectors := make([]supportbundles.Collector, 0, len(s.bundleRegistry.Collectors()))
+	collectors := make([]supportbundles.Collector, 0, len(s.bundleRegistry.Collectors())+len(s.disabledCollectors))
 
-	for _, c := range s.bundleRegistry.Collectors() {
+	registered := s.bundleRegistry.Collectors()
+	for _, c := range registered {
+		c.Disabled = s.isCollectorDisabled(c.UID)
 		collectors = append(collectors, c)
 	}
+	for uid, c := range s.disabledCollectors {
+		if _, ok := registered[uid]; ok {
+			continue
+		}
+		disabled := supportbundles.Collector{UID: uid, DisplayName: uid}
+		if c != nil {
+			disabled = *c
+		}
+		disabled.Disabled = true
+		collectors = append(collectors, disabled)
+	}
+	sort.Slice(collectors, func(i, j int) bool {
+		return collectors[i].UID < collectors[j].UID
+	})
 
 	return response.JSON(http.StatusOK, collectors)
 }
@@ -0,0 +1,295 @@
+package supportbundlesimpl
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+const (
+	formatTarGz = "tar.gz"
+	formatZip   = "zip"
+)
+
+// archiveFormats are the supported bundle archive formats.
+var archiveFormats = []string{formatTarGz, formatZip}
+
+// parseArchiveFormat returns the bundle archive format, falling back to tar.gz for unknown formats.
+func parseArchiveFormat(logger log.Logger, format string) string {
+	switch format {
+	case formatTarGz, formatZip:
+		return format
+	default:
+		logger.Warn("Invalid support bundle format, using default", "format", format, "default", formatTarGz)
+		return formatTarGz
+	}
+}
+
+// parseCompressionLevel returns the compression level, falling back to the default
+// level when it's outside of the range supported by gzip.
+func parseCompressionLevel(logger log.Logger, level int) int {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		logger.Warn("Invalid support bundle compression level, using default", "level", level,
+			"min", gzip.HuffmanOnly, "max", gzip.BestCompression)
+		return gzip.DefaultCompression
+	}
+	return level
+}
+
+// archiveContentType returns the MIME type of bundles archived in format.
+func archiveContentType(format string) string {
+	if format == formatZip {
+		return "application/zip"
+	}
+	return "application/tar+gzip"
+}
+
+// archiveExtension returns the file extension of bundles archived in format,
+// bundles created before the format was recorded are tar.gz archives.
+func archiveExtension(format string) string {
+	if format == "" {
+		format = formatTarGz
+	}
+	return "." + format
+}
+
+// archiveUID returns the UID of the bundle archived in the file called name,
+// and false if name isn't the name of a bundle archive.
+func archiveUID(name string) (string, bool) {
+	for _, format := range archiveFormats {
+		if uid := strings.TrimSuffix(name, archiveExtension(format)); uid != name {
+			return uid, true
+		}
+	}
+	return "", false
+}
+
+// archive writes files to w in the configured archive format.
+func (s *Service) archive(files map[string][]byte, w io.Writer) error {
+	if s.archiveFormat == formatZip {
+		return compressZip(files, w, s.compressionLevel)
+	}
+	return compress(files, w, s.compressionLevel)
+}
+
+// archiveWriter writes files to a bundle archive one at a time, compressing them
+// as they're copied so that they never have to be held in memory as a whole.
+type archiveWriter interface {
+	// add writes a file of size bytes read from r.
+	add(name string, size int64, r io.Reader) error
+	// close writes the end of the archive.
+	close() error
+}
+
+// newArchiveWriter returns a writer of an archive in the configured format to w.
+func (s *Service) newArchiveWriter(w io.Writer) (archiveWriter, error) {
+	if s.archiveFormat == formatZip {
+		return newZipWriter(w, s.compressionLevel), nil
+	}
+	return newTarGzWriter(w, s.compressionLevel)
+}
+
+// addCollectorFile copies a collector file into the archive.
+func addCollectorFile(aw archiveWriter, f collectorFile) error {
+	r, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+	return aw.add(f.name, f.size, r)
+}
+
+// tarGzWriter writes tar > gzip > w.
+type tarGzWriter struct {
+	zr *gzip.Writer
+	tw *tar.Writer
+}
+
+func newTarGzWriter(w io.Writer, level int) (*tarGzWriter, error) {
+	zr, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, err
+	}
+	return &tarGzWriter{zr: zr, tw: tar.NewWriter(zr)}, nil
+}
+
+func (a *tarGzWriter) add(name string, size int64, r io.Reader) error {
+	header := &tar.Header{
+		Name:    filepath.ToSlash("/bundle/" + name),
+		ModTime: time.Now(),
+		Mode:    int64(0o644),
+		Size:    size,
+	}
+	if err := a.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.CopyN(a.tw, r, size)
+	return err
+}
+
+func (a *tarGzWriter) close() error {
+	// produce tar
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	// produce gzip
+	return a.zr.Close()
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func newZipWriter(w io.Writer, level int) *zipWriter {
+	zw := zip.NewWriter(w)
+	zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, level)
+	})
+	return &zipWriter{zw: zw}
+}
+
+func (a *zipWriter) add(name string, size int64, r io.Reader) error {
+	header := &zip.FileHeader{
+		Name:     filepath.ToSlash("bundle/" + name),
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	}
+	header.SetMode(0o644)
+
+	w, err := a.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(w, r, size)
+	return err
+}
+
+func (a *zipWriter) close() error {
+	return a.zw.Close()
+}
+
+func compress(files map[string][]byte, buf io.Writer, level int) error {
+	aw, err := newTarGzWriter(buf, level)
+	if err != nil {
+		return err
+	}
+	return writeArchive(aw, files)
+}
+
+func compressZip(files map[string][]byte, buf io.Writer, level int) error {
+	return writeArchive(newZipWriter(buf, level), files)
+}
+
+// writeArchive adds files to aw and closes it.
+func writeArchive(aw archiveWriter, files map[string][]byte) error {
+	for name, data := range files {
+		if err := aw.add(name, int64(len(data)), bytes.NewReader(data)); err != nil {
+			return err
+		}
+	}
+	return aw.close()
+}
+
+// errStopWalk stops walkArchive without returning an error.
+var errStopWalk = errors.New("stop walking the archive")
+
+// extract returns the files of a bundle archive, keyed by their name inside the bundle
+// directory. An empty format is tar.gz, for bundles created before the format was configurable.
+func extract(format string, data []byte) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := walkArchive(format, bytes.NewReader(data), int64(len(data)), func(name string, r io.Reader, size int64) error {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		files[name] = content
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// walkArchive calls fn with every file of a bundle archive of the given size, named
+// after their path inside the bundle directory. fn can return errStopWalk to stop early.
+func walkArchive(format string, r io.Reader, size int64, fn func(name string, r io.Reader, size int64) error) error {
+	var err error
+	if format == formatZip {
+		err = walkZip(r, size, fn)
+	} else {
+		err = walkTarGz(r, fn)
+	}
+	if errors.Is(err, errStopWalk) {
+		return nil
+	}
+	return err
+}
+
+func walkTarGz(r io.Reader, fn func(name string, r io.Reader, size int64) error) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = zr.Close() }()
+
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := fn(bundleFilename(header.Name), tr, header.Size); err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(r io.Reader, size int64, fn func(name string, r io.Reader, size int64) error) error {
+	// zip archives are read from the end, files on disk can be read in place
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		ra, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = fn(bundleFilename(f.Name), rc, int64(f.UncompressedSize64))
+		_ = rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bundleFilename strips the bundle directory from the name of an archive entry.
+func bundleFilename(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/bundle/")
+}
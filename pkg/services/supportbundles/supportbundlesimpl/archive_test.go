@@ -0,0 +1,77 @@
+package supportbundlesimpl
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+func TestArchive(t *testing.T) {
+	files := map[string][]byte{"basic.json": []byte(`{"version":"10.0.0"}`)}
+
+	t.Run("tar.gz", func(t *testing.T) {
+		s := &Service{archiveFormat: formatTarGz, compressionLevel: gzip.BestSpeed}
+
+		var buf bytes.Buffer
+		require.NoError(t, s.archive(files, &buf))
+		require.Equal(t, map[string][]byte{"/bundle/basic.json": files["basic.json"]}, readBundle(t, buf.Bytes()))
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		s := &Service{archiveFormat: formatZip, compressionLevel: gzip.BestCompression}
+
+		var buf bytes.Buffer
+		require.NoError(t, s.archive(files, &buf))
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		require.Len(t, zr.File, 1)
+		require.Equal(t, "bundle/basic.json", zr.File[0].Name)
+
+		f, err := zr.File[0].Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(f)
+		require.NoError(t, err)
+		require.Equal(t, files["basic.json"], data)
+	})
+}
+
+func TestExtract(t *testing.T) {
+	files := map[string][]byte{
+		"basic.json":      []byte(`{"version":"10.0.0"}`),
+		"manifest.json":   []byte(`{}`),
+		"db.error.txt":    []byte("collector db failed\n"),
+		"empty-file.json": {},
+	}
+
+	for _, format := range []string{formatTarGz, formatZip} {
+		t.Run(format, func(t *testing.T) {
+			s := &Service{archiveFormat: format, compressionLevel: gzip.DefaultCompression}
+
+			var buf bytes.Buffer
+			require.NoError(t, s.archive(files, &buf))
+
+			extracted, err := extract(format, buf.Bytes())
+			require.NoError(t, err)
+			require.Equal(t, files, extracted)
+		})
+	}
+}
+
+func TestParseArchiveConfig(t *testing.T) {
+	logger := log.NewNopLogger()
+
+	require.Equal(t, formatZip, parseArchiveFormat(logger, "zip"))
+	require.Equal(t, formatTarGz, parseArchiveFormat(logger, "rar"))
+
+	require.Equal(t, gzip.BestSpeed, parseCompressionLevel(logger, gzip.BestSpeed))
+	require.Equal(t, gzip.HuffmanOnly, parseCompressionLevel(logger, gzip.HuffmanOnly))
+	require.Equal(t, gzip.DefaultCompression, parseCompressionLevel(logger, 10))
+	require.Equal(t, gzip.DefaultCompression, parseCompressionLevel(logger, -3))
+}
@@ -0,0 +1,100 @@
+package supportbundlesimpl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path"
+	"strings"
+)
+
+// attachmentsDir is the directory of the bundle where attachments are stored.
+const attachmentsDir = "attachments/"
+
+const (
+	defaultMaxAttachments       = 5
+	defaultAttachmentMaxSizeMB  = 50
+	defaultAttachmentsMaxSizeMB = 100
+
+	// attachmentsUploadOverhead is allowed on top of the attachments when
+	// uploading them, for the request and the multipart encoding.
+	attachmentsUploadOverhead = 1024 * 1024
+)
+
+var ErrInvalidAttachments = errors.New("invalid support bundle attachments")
+
+// attachment is a file uploaded by an admin when creating a bundle, e.g. a
+// crash dump. Attachments are stored as uploaded, they aren't redacted.
+type attachment struct {
+	Name string
+	Data []byte
+}
+
+// attachmentReport describes an attachment in the manifest.
+type attachmentReport struct {
+	Filename string `json:"filename"`
+	Size     int    `json:"size_bytes"`
+}
+
+// validateAttachments checks the attachments against the configured limits and
+// that their names are unique.
+func (s *Service) validateAttachments(attachments []attachment) error {
+	if len(attachments) > s.maxAttachments {
+		return fmt.Errorf("%w: at most %d files can be attached", ErrInvalidAttachments, s.maxAttachments)
+	}
+
+	var total int64
+	names := make(map[string]bool, len(attachments))
+	for _, a := range attachments {
+		if a.Name == "" || a.Name == "." || strings.ContainsAny(a.Name, `/\`) {
+			return fmt.Errorf("%w: invalid file name %q", ErrInvalidAttachments, a.Name)
+		}
+		if names[a.Name] {
+			return fmt.Errorf("%w: %s is attached more than once", ErrInvalidAttachments, a.Name)
+		}
+		names[a.Name] = true
+
+		if int64(len(a.Data)) > s.attachmentMaxSize {
+			return fmt.Errorf("%w: %s is larger than %d bytes", ErrInvalidAttachments, a.Name, s.attachmentMaxSize)
+		}
+		total += int64(len(a.Data))
+	}
+	if total > s.attachmentsMaxSize {
+		return fmt.Errorf("%w: the attached files are larger than %d bytes", ErrInvalidAttachments, s.attachmentsMaxSize)
+	}
+	return nil
+}
+
+// attachmentContents returns the contents the attachments add to a bundle, nil if there are none.
+func attachmentContents(attachments []attachment) *bundleContents {
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	contents := &bundleContents{files: make(map[string][]byte, len(attachments))}
+	for _, a := range attachments {
+		filename := path.Join(attachmentsDir, a.Name)
+		contents.files[filename] = a.Data
+		contents.attachments = append(contents.attachments, attachmentReport{Filename: filename, Size: len(a.Data)})
+	}
+	return contents
+}
+
+// readAttachments reads the uploaded attachment files.
+func readAttachments(files []*multipart.FileHeader) ([]attachment, error) {
+	attachments := make([]attachment, 0, len(files))
+	for _, header := range files {
+		f, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment{Name: header.Filename, Data: data})
+	}
+	return attachments, nil
+}
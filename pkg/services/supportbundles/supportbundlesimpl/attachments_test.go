@@ -0,0 +1,99 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestService_create_Attachments(t *testing.T) {
+	ok := newTestCollector("ok", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "ok.txt", FileBytes: []byte("ok")}, nil
+	})
+
+	s := newTestService(t, ok)
+	s.maxAttachments = 2
+	s.attachmentMaxSize = 64
+	s.attachmentsMaxSize = 96
+	usr := &user.SignedInUser{Login: "admin"}
+
+	// attachments aren't redacted
+	config := []byte("password = " + plantedSecret)
+	bundle, err := s.create(context.Background(), usr, createOptions{Attachments: []attachment{
+		{Name: "custom.ini", Data: config},
+		{Name: "crash.dump", Data: []byte{0xff, 0x00}},
+	}})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		b, err := s.store.Get(context.Background(), bundle.UID)
+		return err == nil && b.State == supportbundles.StateComplete
+	}, 5*time.Second, 10*time.Millisecond)
+
+	reader, _, err := s.store.GetReader(context.Background(), bundle.UID)
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	files := readBundle(t, data)
+	require.Equal(t, config, files["/bundle/attachments/custom.ini"])
+	require.Equal(t, []byte{0xff, 0x00}, files["/bundle/attachments/crash.dump"])
+	require.Equal(t, "ok", string(files["/bundle/ok.txt"]))
+
+	var m manifest
+	require.NoError(t, json.Unmarshal(files["/bundle/manifest.json"], &m))
+	require.ElementsMatch(t, []attachmentReport{
+		{Filename: "attachments/custom.ini", Size: len(config)},
+		{Filename: "attachments/crash.dump", Size: 2},
+	}, m.Attachments)
+
+	t.Run("listed attachments can be read from the bundle", func(t *testing.T) {
+		stored, err := s.store.Get(context.Background(), bundle.UID)
+		require.NoError(t, err)
+
+		var content []byte
+		require.NoError(t, s.readBundleFile(context.Background(), stored, "attachments/crash.dump", func(r io.Reader, size int64) error {
+			content, err = io.ReadAll(r)
+			return err
+		}))
+		require.Equal(t, []byte{0xff, 0x00}, content)
+	})
+
+	testCases := []struct {
+		desc        string
+		attachments []attachment
+	}{
+		{
+			desc:        "too many files",
+			attachments: []attachment{{Name: "a", Data: []byte("a")}, {Name: "b", Data: []byte("b")}, {Name: "c", Data: []byte("c")}},
+		},
+		{
+			desc:        "a file larger than the per file limit",
+			attachments: []attachment{{Name: "a", Data: make([]byte, 65)}},
+		},
+		{
+			desc:        "files larger than the total limit",
+			attachments: []attachment{{Name: "a", Data: make([]byte, 64)}, {Name: "b", Data: make([]byte, 64)}},
+		},
+		{
+			desc:        "the same name twice",
+			attachments: []attachment{{Name: "a", Data: []byte("a")}, {Name: "a", Data: []byte("b")}},
+		},
+		{
+			desc:        "a name with a path",
+			attachments: []attachment{{Name: "../manifest.json", Data: []byte("{}")}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run("attachments are rejected with "+tc.desc, func(t *testing.T) {
+			_, err := s.create(context.Background(), usr, createOptions{Attachments: tc.attachments})
+			require.ErrorIs(t, err, ErrInvalidAttachments)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+const (
+	auditActionCreate       = "create"
+	auditActionRemove       = "remove"
+	auditActionDownload     = "download"
+	auditActionDownloadFile = "download-file"
+	auditActionExtend       = "extend"
+
+	defaultAuditRetention = 90 * 24 * time.Hour
+)
+
+// auditEntry records who did what with a bundle.
+type auditEntry struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	User      string    `json:"user"`
+	UserID    int64     `json:"userId,omitempty"`
+	BundleUID string    `json:"bundleUid"`
+	// Collectors are the collectors run when creating the bundle.
+	Collectors []string `json:"collectors,omitempty"`
+	// File is the file downloaded from the bundle, empty when the whole archive is downloaded.
+	File string `json:"file,omitempty"`
+	// Encrypted is set when the bundle archive is encrypted at rest.
+	Encrypted bool `json:"encrypted,omitempty"`
+	// Partial is set when the archive was downloaded while the bundle was still being created.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// auditLog keeps the audit trail of bundles in the KV store. Grafana has no audit
+// subsystem of its own, the entries are also logged so they reach the log pipeline.
+type auditLog struct {
+	kv        *kvstore.NamespacedKVStore
+	retention time.Duration
+	log       log.Logger
+}
+
+// newAuditLog returns nil when disabled, in which case nothing is recorded.
+func newAuditLog(kv kvstore.KVStore, enabled bool, retention time.Duration) *auditLog {
+	if !enabled {
+		return nil
+	}
+	if retention <= 0 {
+		retention = defaultAuditRetention
+	}
+
+	return &auditLog{
+		kv:        kvstore.WithNamespace(kv, 0, "supportbundleaudit"),
+		retention: retention,
+		log:       log.New("supportbundle.audit"),
+	}
+}
+
+// record stores an entry for an action of usr on a bundle. Failures are logged,
+// the action itself has already happened and isn't undone.
+func (a *auditLog) record(ctx context.Context, usr *user.SignedInUser, entry auditEntry) {
+	if a == nil {
+		return
+	}
+
+	entry.Time = time.Now().UTC()
+	if usr != nil {
+		entry.User = usr.Login
+		entry.UserID = usr.UserID
+	}
+	a.log.Info("Support bundle audit", "action", entry.Action, "user", entry.User, "userID", entry.UserID,
+		"uid", entry.BundleUID, "collectors", entry.Collectors, "file", entry.File, "encrypted", entry.Encrypted, "partial", entry.Partial)
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		a.log.Error("Failed to encode support bundle audit entry", "error", err)
+		return
+	}
+	// keys sort chronologically, the random suffix keeps entries recorded at the same time apart
+	key := fmt.Sprintf("%020d-%s", entry.Time.UnixNano(), uuid.NewString())
+	if err := a.kv.Set(ctx, key, string(data)); err != nil {
+		a.log.Error("Failed to record support bundle audit entry", "action", entry.Action, "uid", entry.BundleUID, "error", err)
+	}
+}
+
+// list returns the entries, newest first, of the bundle with the given UID or of
+// every bundle if it's empty. A positive limit returns at most limit entries.
+func (a *auditLog) list(ctx context.Context, bundleUID string, limit int) ([]auditEntry, error) {
+	all, err := a.kv.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]auditEntry, 0)
+	for _, items := range all {
+		for _, data := range items {
+			var entry auditEntry
+			if err := json.Unmarshal([]byte(data), &entry); err != nil {
+				return nil, err
+			}
+			if bundleUID != "" && entry.BundleUID != bundleUID {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// prune removes the entries older than the retention.
+func (a *auditLog) prune(ctx context.Context) error {
+	keys, err := a.kv.Keys(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	cutoff := fmt.Sprintf("%020d", time.Now().Add(-a.retention).UnixNano())
+	for _, k := range keys {
+		if k.Key < cutoff {
+			if err := a.kv.Del(ctx, k.Key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
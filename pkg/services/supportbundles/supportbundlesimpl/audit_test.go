@@ -0,0 +1,81 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestAuditLog(t *testing.T) {
+	require.Nil(t, newAuditLog(nil, false, time.Hour))
+
+	audit := newAuditLog(kvstore.ProvideService(db.InitTestDB(t)), true, time.Hour)
+	admin := &user.SignedInUser{Login: "admin", UserID: 1}
+	ctx := context.Background()
+
+	audit.record(ctx, admin, auditEntry{Action: auditActionCreate, BundleUID: "a", Collectors: []string{"basic", "settings"}})
+	audit.record(ctx, admin, auditEntry{Action: auditActionDownload, BundleUID: "a", Encrypted: true})
+	audit.record(ctx, &user.SignedInUser{Login: "editor", UserID: 2}, auditEntry{Action: auditActionDownloadFile, BundleUID: "b", File: "settings.json"})
+
+	t.Run("lists the entries newest first", func(t *testing.T) {
+		entries, err := audit.list(ctx, "", 0)
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		require.Equal(t, auditActionDownloadFile, entries[0].Action)
+		require.Equal(t, "editor", entries[0].User)
+		require.EqualValues(t, 2, entries[0].UserID)
+		require.Equal(t, "settings.json", entries[0].File)
+		require.Equal(t, auditActionCreate, entries[2].Action)
+		require.Equal(t, []string{"basic", "settings"}, entries[2].Collectors)
+		require.False(t, entries[2].Time.IsZero())
+	})
+
+	t.Run("filters and limits the entries", func(t *testing.T) {
+		entries, err := audit.list(ctx, "a", 0)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		require.Equal(t, auditActionDownload, entries[0].Action)
+		require.True(t, entries[0].Encrypted)
+
+		entries, err = audit.list(ctx, "", 1)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+	})
+
+	t.Run("prunes the entries older than the retention", func(t *testing.T) {
+		audit.retention = time.Nanosecond
+		t.Cleanup(func() { audit.retention = time.Hour })
+		time.Sleep(time.Millisecond)
+
+		require.NoError(t, audit.prune(ctx))
+		entries, err := audit.list(ctx, "", 0)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+}
+
+func TestService_create_Audit(t *testing.T) {
+	s := newTestService(t, newTestCollector("basic", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "basic.json", FileBytes: []byte("{}")}, nil
+	}))
+	s.audit = newAuditLog(kvstore.ProvideService(db.InitTestDB(t)), true, time.Hour)
+
+	bundle, err := s.create(context.Background(), &user.SignedInUser{Login: "admin (token)"}, createOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(s.creationSlots) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	entries, err := s.audit.list(context.Background(), bundle.UID, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, auditActionCreate, entries[0].Action)
+	require.Equal(t, "admin (token)", entries[0].User)
+	require.Equal(t, []string{"basic"}, entries[0].Collectors)
+	require.False(t, entries[0].Encrypted)
+}
@@ -0,0 +1,148 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/ldap"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// authRoleMappingKeys are the settings of auth providers that control how roles are assigned to users.
+var authRoleMappingKeys = []string{
+	"role_attribute_path", "role_attribute_strict", "allow_assign_grafana_admin",
+	"skip_org_role_sync", "org_role", "org_name", "groups_attribute_path", "allowed_groups",
+	"assertion_attribute_role", "role_values_editor", "role_values_admin", "role_values_grafana_admin",
+}
+
+func authConfigCollector(cfg *setting.Cfg) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "auth-config",
+		DisplayName:       "Authentication configuration",
+		Description:       "LDAP, OAuth and SAML settings with secrets redacted, enabled login providers and role mappings",
+		IncludedByDefault: false,
+		Default:           true,
+		Restricted:        true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type ldapServer struct {
+				Host          string                 `json:"host"`
+				Port          int                    `json:"port"`
+				UseSSL        bool                   `json:"use_ssl"`
+				StartTLS      bool                   `json:"start_tls"`
+				SkipVerifySSL bool                   `json:"ssl_skip_verify"`
+				BindDN        string                 `json:"bind_dn"`
+				BindPassword  string                 `json:"bind_password,omitempty"`
+				ClientKey     string                 `json:"client_key,omitempty"`
+				SearchFilter  string                 `json:"search_filter"`
+				SearchBaseDNs []string               `json:"search_base_dns"`
+				Attributes    ldap.AttributeMap      `json:"attributes"`
+				GroupMappings []*ldap.GroupToOrgRole `json:"group_mappings"`
+			}
+			type ldapInfo struct {
+				Enabled    bool         `json:"enabled"`
+				ConfigFile string       `json:"config_file"`
+				Servers    []ldapServer `json:"servers,omitempty"`
+				Error      string       `json:"error,omitempty"`
+			}
+			type authInfo struct {
+				// LoginProviders are the enabled authentication methods.
+				LoginProviders []string `json:"login_providers"`
+				// RoleMappings are the role assignment rules of each provider.
+				RoleMappings map[string]interface{} `json:"role_mappings"`
+				// Sections are the effective [auth] and [auth.*] settings.
+				Sections map[string]map[string]string `json:"sections"`
+				LDAP     ldapInfo                     `json:"ldap"`
+			}
+
+			info := authInfo{
+				LoginProviders: []string{},
+				RoleMappings:   map[string]interface{}{},
+				Sections:       map[string]map[string]string{},
+				LDAP: ldapInfo{
+					Enabled:    cfg.LDAPEnabled,
+					ConfigFile: cfg.LDAPConfigFilePath,
+				},
+			}
+
+			if !cfg.Raw.Section("auth").Key("disable_login_form").MustBool(false) {
+				info.LoginProviders = append(info.LoginProviders, "form")
+			}
+
+			for _, section := range cfg.Raw.Sections() {
+				name := section.Name()
+				if name != "auth" && !strings.HasPrefix(name, "auth.") {
+					continue
+				}
+
+				values := section.KeysHash()
+				info.Sections[name] = redactStringMap(values)
+
+				provider := strings.TrimPrefix(name, "auth.")
+				if name == "auth" || provider == "ldap" || !section.Key("enabled").MustBool(false) {
+					continue
+				}
+				info.LoginProviders = append(info.LoginProviders, provider)
+
+				mapping := map[string]string{}
+				for _, key := range authRoleMappingKeys {
+					if v, ok := values[key]; ok && v != "" {
+						mapping[key] = v
+					}
+				}
+				if len(mapping) > 0 {
+					info.RoleMappings[provider] = redactStringMap(mapping)
+				}
+			}
+
+			if cfg.LDAPEnabled {
+				info.LoginProviders = append(info.LoginProviders, "ldap")
+
+				ldapConfig, err := ldap.GetConfig(cfg)
+				if err != nil {
+					info.LDAP.Error = err.Error()
+				} else if ldapConfig != nil {
+					var mappings []*ldap.GroupToOrgRole
+					for _, server := range ldapConfig.Servers {
+						// the config is cached and shared with the LDAP service, copy instead of modifying it
+						s := ldapServer{
+							Host:          server.Host,
+							Port:          server.Port,
+							UseSSL:        server.UseSSL,
+							StartTLS:      server.StartTLS,
+							SkipVerifySSL: server.SkipVerifySSL,
+							BindDN:        server.BindDN,
+							SearchFilter:  server.SearchFilter,
+							SearchBaseDNs: server.SearchBaseDNs,
+							Attributes:    server.Attr,
+							GroupMappings: server.Groups,
+						}
+						if server.BindPassword != "" {
+							s.BindPassword = redactedValue
+						}
+						if server.ClientKey != "" {
+							s.ClientKey = redactedValue
+						}
+						info.LDAP.Servers = append(info.LDAP.Servers, s)
+						mappings = append(mappings, server.Groups...)
+					}
+					info.RoleMappings["ldap"] = mappings
+				}
+			}
+
+			sort.Strings(info.LoginProviders)
+
+			data, err := json.Marshal(info)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "auth-config.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
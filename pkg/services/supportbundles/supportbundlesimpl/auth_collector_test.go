@@ -0,0 +1,92 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/ini.v1"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestAuthConfigCollector(t *testing.T) {
+	ldapConfigFile := filepath.Join(t.TempDir(), "ldap.toml")
+	require.NoError(t, os.WriteFile(ldapConfigFile, []byte(`
+[[servers]]
+host = "ldap.example.com"
+port = 636
+bind_dn = "cn=admin,dc=example,dc=com"
+bind_password = "`+plantedSecret+`"
+client_key = "`+plantedSecret+`"
+search_filter = "(cn=%s)"
+search_base_dns = ["dc=example,dc=com"]
+
+[[servers.group_mappings]]
+group_dn = "cn=admins,dc=example,dc=com"
+org_role = "Admin"
+`), 0o600))
+
+	raw, err := ini.Load([]byte(`
+[auth]
+disable_login_form = false
+
+[auth.generic_oauth]
+enabled = true
+client_id = grafana
+client_secret = ` + plantedSecret + `
+auth_url = https://admin:` + plantedSecret + `@sso.example.com/authorize
+role_attribute_path = contains(groups[*], 'admin') && 'Admin' || 'Viewer'
+
+[auth.saml]
+enabled = true
+private_key = ` + plantedSecret + `
+certificate = public-certificate
+
+[auth.github]
+enabled = false
+client_secret = ` + plantedSecret + `
+`))
+	require.NoError(t, err)
+
+	cfg := setting.NewCfg()
+	cfg.Raw = raw
+	cfg.LDAPEnabled = true
+	cfg.LDAPConfigFilePath = ldapConfigFile
+
+	item, err := authConfigCollector(cfg).Fn(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "auth-config.json", item.Filename)
+	require.NotContains(t, string(item.FileBytes), plantedSecret)
+	// certificates are public, only the private key is redacted
+	require.Contains(t, string(item.FileBytes), "public-certificate")
+
+	var info struct {
+		LoginProviders []string                     `json:"login_providers"`
+		RoleMappings   map[string]json.RawMessage   `json:"role_mappings"`
+		Sections       map[string]map[string]string `json:"sections"`
+		LDAP           struct {
+			Error   string `json:"error"`
+			Servers []struct {
+				Host         string `json:"host"`
+				BindPassword string `json:"bind_password"`
+			} `json:"servers"`
+		} `json:"ldap"`
+	}
+	require.NoError(t, json.Unmarshal(item.FileBytes, &info))
+
+	require.Equal(t, []string{"form", "generic_oauth", "ldap", "saml"}, info.LoginProviders)
+	require.Equal(t, "grafana", info.Sections["auth.generic_oauth"]["client_id"])
+	require.Equal(t, redactedValue, info.Sections["auth.generic_oauth"]["client_secret"])
+	require.Equal(t, redactedValue, info.Sections["auth.github"]["client_secret"])
+	require.Contains(t, string(info.RoleMappings["generic_oauth"]), "role_attribute_path")
+	require.Contains(t, string(info.RoleMappings["ldap"]), "cn=admins,dc=example,dc=com")
+
+	require.Empty(t, info.LDAP.Error)
+	require.Len(t, info.LDAP.Servers, 1)
+	require.Equal(t, "ldap.example.com", info.LDAP.Servers[0].Host)
+	require.Equal(t, redactedValue, info.LDAP.Servers[0].BindPassword)
+}
@@ -0,0 +1,35 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+func backgroundServicesCollector(tracker *registry.BackgroundServiceTracker) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "background-services",
+		DisplayName:       "Background services",
+		Description:       "The background services of the server, whether they are running and the error they stopped with",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			services := []registry.BackgroundServiceStatus{}
+			if tracker != nil {
+				services = tracker.Statuses()
+			}
+
+			data, err := json.Marshal(services)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "background-services.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/registry"
+)
+
+func TestBackgroundServicesCollector(t *testing.T) {
+	collect := func(t *testing.T, tracker *registry.BackgroundServiceTracker) []registry.BackgroundServiceStatus {
+		t.Helper()
+		item, err := backgroundServicesCollector(tracker).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "background-services.json", item.Filename)
+
+		var services []registry.BackgroundServiceStatus
+		require.NoError(t, json.Unmarshal(item.FileBytes, &services))
+		return services
+	}
+
+	t.Run("reports the state of the services", func(t *testing.T) {
+		tracker := registry.ProvideBackgroundServiceTracker()
+		tracker.SetState("*cleanup.CleanUpService", registry.BackgroundServiceRunning, nil)
+		tracker.SetState("*ngalert.AlertNG", registry.BackgroundServiceRunning, nil)
+		tracker.SetState("*ngalert.AlertNG", registry.BackgroundServiceStopped, errors.New("scheduler failed"))
+		tracker.SetState("*thumbs.dummyService", registry.BackgroundServiceDisabled, nil)
+
+		services := collect(t, tracker)
+		require.Len(t, services, 3)
+
+		require.Equal(t, "*cleanup.CleanUpService", services[0].Name)
+		require.Equal(t, registry.BackgroundServiceRunning, services[0].State)
+		require.NotNil(t, services[0].StartedAt)
+		require.Nil(t, services[0].StoppedAt)
+
+		require.Equal(t, "*ngalert.AlertNG", services[1].Name)
+		require.Equal(t, registry.BackgroundServiceStopped, services[1].State)
+		require.NotNil(t, services[1].StoppedAt)
+		require.Equal(t, "scheduler failed", services[1].Error)
+
+		require.Equal(t, registry.BackgroundServiceDisabled, services[2].State)
+	})
+
+	t.Run("does not fail without a tracker", func(t *testing.T) {
+		require.Empty(t, collect(t, nil))
+	})
+}
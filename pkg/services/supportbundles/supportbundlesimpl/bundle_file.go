@@ -0,0 +1,84 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+var ErrBundleFileNotFound = errors.New("support bundle file not found")
+
+// readBundleFile calls fn with a reader of the named file of a bundle archive and
+// its size. Only the manifest and the files and attachments it lists can be read.
+func (s *Service) readBundleFile(ctx context.Context, bundle *supportbundles.Bundle, name string, fn func(r io.Reader, size int64) error) error {
+	if !bundle.State.HasArchive() {
+		return fmt.Errorf("%w: the bundle has no archive", ErrBundleFileNotFound)
+	}
+	if bundle.UploadedTo != "" {
+		return fmt.Errorf("%w: the bundle was uploaded to %s", ErrBundleFileNotFound, bundle.UploadedTo)
+	}
+
+	if name != manifestFilename {
+		var m manifest
+		err := s.walkBundle(ctx, bundle, func(entry string, r io.Reader, size int64) error {
+			if entry != manifestFilename {
+				return nil
+			}
+			if err := json.NewDecoder(r).Decode(&m); err != nil {
+				return err
+			}
+			return errStopWalk
+		})
+		if err != nil {
+			return err
+		}
+
+		listed := false
+		for _, report := range m.Collectors {
+			listed = listed || report.hasFile(name)
+		}
+		for _, a := range m.Attachments {
+			listed = listed || a.Filename == name
+		}
+		if !listed {
+			return ErrBundleFileNotFound
+		}
+	}
+
+	found := false
+	err := s.walkBundle(ctx, bundle, func(entry string, r io.Reader, size int64) error {
+		if entry != name {
+			return nil
+		}
+		found = true
+		if err := fn(r, size); err != nil {
+			return err
+		}
+		return errStopWalk
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrBundleFileNotFound
+	}
+	return nil
+}
+
+func (s *Service) walkBundle(ctx context.Context, bundle *supportbundles.Bundle, fn func(name string, r io.Reader, size int64) error) error {
+	reader, size, err := s.store.GetReader(ctx, bundle.UID)
+	if err != nil {
+		return fmt.Errorf("failed to read support bundle: %w", err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			s.log.Warn("Failed to close support bundle reader", "uid", bundle.UID, "error", err)
+		}
+	}()
+
+	return walkArchive(bundle.Format, reader, size, fn)
+}
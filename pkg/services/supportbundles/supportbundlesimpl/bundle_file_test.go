@@ -0,0 +1,57 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestService_readBundleFile(t *testing.T) {
+	settings := newTestCollector("settings", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "settings.json", FileBytes: []byte(`{"server":{}}`)}, nil
+	})
+
+	for _, format := range []string{formatTarGz, formatZip} {
+		t.Run(format, func(t *testing.T) {
+			s := newTestService(t, settings)
+			s.archiveFormat = format
+
+			bundle, err := s.create(context.Background(), &user.SignedInUser{Login: "admin"}, createOptions{})
+			require.NoError(t, err)
+			require.Eventually(t, func() bool {
+				b, err := s.get(context.Background(), bundle.UID)
+				return err == nil && b.State == supportbundles.StateComplete
+			}, 5*time.Second, 10*time.Millisecond)
+			bundle, err = s.get(context.Background(), bundle.UID)
+			require.NoError(t, err)
+
+			read := func(name string) (string, error) {
+				var content []byte
+				err := s.readBundleFile(context.Background(), bundle, name, func(r io.Reader, size int64) error {
+					var err error
+					content, err = io.ReadAll(r)
+					require.Equal(t, int64(len(content)), size)
+					return err
+				})
+				return string(content), err
+			}
+
+			content, err := read("settings.json")
+			require.NoError(t, err)
+			require.Equal(t, `{"server":{}}`, content)
+
+			content, err = read(manifestFilename)
+			require.NoError(t, err)
+			require.Contains(t, content, bundle.UID)
+
+			_, err = read("missing.json")
+			require.ErrorIs(t, err, ErrBundleFileNotFound)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package supportbundlesimpl
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// checksumDigest returns the value of the Digest header (RFC 3230) for
+// a hex encoded SHA-256 checksum, or an empty string if it's invalid.
+func checksumDigest(checksum string) string {
+	sum, err := hex.DecodeString(checksum)
+	if err != nil || len(sum) == 0 {
+		return ""
+	}
+	return "sha-256=" + base64.StdEncoding.EncodeToString(sum)
+}
+
+// parseChecksum returns the lowercased hex checksum of either a bare
+// checksum or the first line of a SHA256SUMS file.
+func parseChecksum(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
+
+// checksumETag returns the strong ETag of an archive with the given checksum, or
+// an empty string if it has none.
+func checksumETag(checksum string) string {
+	if checksum == "" {
+		return ""
+	}
+	return `"` + checksum + `"`
+}
+
+// notModified reports whether the conditional headers of r match the archive
+// with the given ETag and modification time, in which case a 304 is returned.
+// If-None-Match takes precedence over If-Modified-Since, as in RFC 7232.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if since := r.Header.Get("If-Modified-Since"); since != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(since)
+		// HTTP dates have a precision of a second
+		return err == nil && !lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	contextmodel "github.com/grafana/grafana/pkg/services/contexthandler/model"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+func TestNotModified(t *testing.T) {
+	lastModified := time.Date(2023, 3, 1, 12, 0, 0, 500, time.UTC)
+	etag := checksumETag("abc123")
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		etag    string
+		want    bool
+	}{
+		{name: "unconditional", etag: etag},
+		{name: "matching etag", headers: map[string]string{"If-None-Match": `"abc123"`}, etag: etag, want: true},
+		{name: "one of the etags matches", headers: map[string]string{"If-None-Match": `"other", W/"abc123"`}, etag: etag, want: true},
+		{name: "any etag", headers: map[string]string{"If-None-Match": "*"}, etag: etag, want: true},
+		{name: "other etag", headers: map[string]string{"If-None-Match": `"other"`}, etag: etag},
+		{name: "no etag", headers: map[string]string{"If-None-Match": `"abc123"`}},
+		{name: "not modified since", headers: map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, etag: etag, want: true},
+		{name: "modified since", headers: map[string]string{"If-Modified-Since": lastModified.Add(-time.Minute).Format(http.TimeFormat)}, etag: etag},
+		{name: "invalid date", headers: map[string]string{"If-Modified-Since": "yesterday"}, etag: etag},
+		{
+			name:    "etag takes precedence",
+			headers: map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified.Format(http.TimeFormat)},
+			etag:    etag,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			require.Equal(t, tt.want, notModified(req, tt.etag, lastModified))
+		})
+	}
+}
+
+func TestService_handleDownload_NotModified(t *testing.T) {
+	s := newTestService(t, newTestCollector("basic", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "basic.json", FileBytes: []byte("{}")}, nil
+	}))
+	usr := &user.SignedInUser{Login: "admin"}
+
+	bundle, err := s.create(context.Background(), usr, createOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(s.creationSlots) == 0 }, 5*time.Second, 10*time.Millisecond)
+	bundle, err = s.get(context.Background(), bundle.UID)
+	require.NoError(t, err)
+	require.NotEmpty(t, bundle.Checksum)
+
+	download := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, rootUrl+"/"+bundle.UID, nil)
+		req = web.SetURLParams(req, map[string]string{":uid": bundle.UID})
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		ctx := &contextmodel.ReqContext{
+			Context:      &web.Context{Req: req, Resp: web.NewResponseWriter(req.Method, rec)},
+			SignedInUser: usr,
+			Logger:       log.NewNopLogger(),
+		}
+		if resp := s.handleDownload(ctx); resp != nil {
+			resp.WriteTo(ctx)
+		}
+		return rec
+	}
+
+	rec := download(nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	require.Equal(t, `"`+bundle.Checksum+`"`, etag)
+	lastModified := rec.Header().Get("Last-Modified")
+	require.NotEmpty(t, lastModified)
+	require.NotZero(t, rec.Body.Len())
+
+	rec = download(map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusNotModified, rec.Code)
+	require.Equal(t, etag, rec.Header().Get("ETag"))
+	require.Zero(t, rec.Body.Len())
+
+	rec = download(map[string]string{"If-Modified-Since": lastModified})
+	require.Equal(t, http.StatusNotModified, rec.Code)
+
+	rec = download(map[string]string{"If-None-Match": `"stale"`})
+	require.Equal(t, http.StatusOK, rec.Code)
+}
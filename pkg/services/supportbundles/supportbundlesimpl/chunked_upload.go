@@ -0,0 +1,325 @@
+package supportbundlesimpl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+const (
+	defaultChunkedUploadExpiry = time.Hour
+	// maxChunkedUploads bounds the uploads in progress, so that abandoned ones can't fill the disk.
+	maxChunkedUploads = 10
+)
+
+var (
+	ErrUploadNotFound   = errors.New("support bundle upload not found")
+	ErrUploadTooLarge   = errors.New("support bundle upload is too large")
+	ErrInvalidChunk     = errors.New("invalid support bundle upload chunk")
+	ErrChunkOutOfOrder  = errors.New("support bundle upload chunk is out of order")
+	ErrUploadBusy       = errors.New("support bundle upload is receiving another chunk")
+	ErrUploadIncomplete = errors.New("support bundle upload is incomplete")
+	ErrTooManyUploads   = errors.New("too many support bundles are being uploaded")
+)
+
+// chunkedUpload is a bundle uploaded in chunks to the validate API.
+type chunkedUpload struct {
+	UID  string `json:"uid"`
+	Size int64  `json:"size"`
+	// Received is how many bytes were received, the next chunk starts there.
+	Received int64 `json:"received"`
+	// ExpiresAt is when the upload is removed unless it receives another chunk.
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Checksum is the expected checksum of the bundle, or the content of a SHA256SUMS file.
+	Checksum string `json:"-"`
+
+	owner string
+	path  string
+	busy  bool
+}
+
+// chunkedUploads keeps the bundles uploaded in chunks, e.g. multi-GB bundles
+// over a flaky connection, until they're complete and validated. The chunks are
+// reassembled in a temporary directory, the uploads not receiving a chunk for
+// expiry are removed. Uploads in progress are lost when Grafana restarts.
+type chunkedUploads struct {
+	maxSize int64
+	expiry  time.Duration
+	now     func() time.Time
+
+	mu      sync.Mutex
+	dir     string
+	uploads map[string]*chunkedUpload
+}
+
+func newChunkedUploads(maxSize int64, expiry time.Duration) *chunkedUploads {
+	if expiry <= 0 {
+		expiry = defaultChunkedUploadExpiry
+	}
+	return &chunkedUploads{
+		maxSize: maxSize,
+		expiry:  expiry,
+		now:     time.Now,
+		uploads: map[string]*chunkedUpload{},
+	}
+}
+
+// create starts the upload of a bundle of the given size by usr.
+func (u *chunkedUploads) create(usr *user.SignedInUser, size int64, checksum string) (chunkedUpload, error) {
+	if size <= 0 {
+		return chunkedUpload{}, fmt.Errorf("%w: the size of the bundle must be positive", ErrInvalidChunk)
+	}
+	if size > u.maxSize {
+		return chunkedUpload{}, fmt.Errorf("%w: bundles must be smaller than %d bytes", ErrUploadTooLarge, u.maxSize)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.removeExpiredLocked()
+	if len(u.uploads) >= maxChunkedUploads {
+		return chunkedUpload{}, ErrTooManyUploads
+	}
+	if u.dir == "" {
+		dir, err := os.MkdirTemp("", "grafana-support-bundle-uploads-")
+		if err != nil {
+			return chunkedUpload{}, err
+		}
+		u.dir = dir
+	}
+
+	upload := &chunkedUpload{
+		UID:       uuid.NewString(),
+		Size:      size,
+		ExpiresAt: u.now().Add(u.expiry),
+		Checksum:  checksum,
+		owner:     userKey(usr),
+	}
+	upload.path = filepath.Join(u.dir, upload.UID)
+	f, err := os.Create(upload.path)
+	if err != nil {
+		return chunkedUpload{}, err
+	}
+	if err := f.Close(); err != nil {
+		return chunkedUpload{}, err
+	}
+
+	u.uploads[upload.UID] = upload
+	return *upload, nil
+}
+
+// get returns the upload of usr with the given UID.
+func (u *chunkedUploads) get(usr *user.SignedInUser, uid string) (chunkedUpload, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	upload, err := u.getLocked(usr, uid)
+	if err != nil {
+		return chunkedUpload{}, err
+	}
+	return *upload, nil
+}
+
+func (u *chunkedUploads) getLocked(usr *user.SignedInUser, uid string) (*chunkedUpload, error) {
+	u.removeExpiredLocked()
+	upload, ok := u.uploads[uid]
+	if !ok || upload.owner != userKey(usr) {
+		return nil, ErrUploadNotFound
+	}
+	return upload, nil
+}
+
+// write writes the chunk read from r, described by the Content-Range header
+// contentRange, to the upload. A chunk may start before the bytes received so
+// far, e.g. when the response to the previous chunk was lost, but not after.
+func (u *chunkedUploads) write(usr *user.SignedInUser, uid, contentRange string, r io.Reader) (chunkedUpload, error) {
+	start, end, total, err := parseContentRange(contentRange)
+	if err != nil {
+		return chunkedUpload{}, err
+	}
+
+	u.mu.Lock()
+	upload, err := u.getLocked(usr, uid)
+	if err != nil {
+		u.mu.Unlock()
+		return chunkedUpload{}, err
+	}
+	switch {
+	case total != upload.Size:
+		u.mu.Unlock()
+		return chunkedUpload{}, fmt.Errorf("%w: the upload was started for %d bytes, not %d", ErrInvalidChunk, upload.Size, total)
+	case start > upload.Received:
+		received := upload.Received
+		u.mu.Unlock()
+		return chunkedUpload{}, fmt.Errorf("%w: the next chunk starts at byte %d", ErrChunkOutOfOrder, received)
+	case upload.busy:
+		u.mu.Unlock()
+		return chunkedUpload{}, ErrUploadBusy
+	}
+	upload.busy = true
+	u.mu.Unlock()
+
+	written, err := writeChunk(upload.path, start, end-start+1, r)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	upload.busy = false
+	upload.ExpiresAt = u.now().Add(u.expiry)
+	// the bytes written are contiguous with the ones received, even if the chunk is cut short
+	if start+written > upload.Received {
+		upload.Received = start + written
+	}
+	return *upload, err
+}
+
+// writeChunk writes n bytes read from r at offset in the file at path and
+// returns how many were written.
+func writeChunk(path string, offset, n int64, r io.Reader) (int64, error) {
+	// the path is the file of an upload created by chunkedUploads, not user input
+	// nolint:gosec
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return 0, err
+	}
+	written, err := io.CopyN(f, r, n)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if errors.Is(err, io.EOF) {
+		err = fmt.Errorf("%w: the chunk is shorter than its Content-Range", ErrInvalidChunk)
+	}
+	return written, err
+}
+
+// open returns the reassembled bundle of a complete upload, which is removed
+// once the returned function is called.
+func (u *chunkedUploads) open(usr *user.SignedInUser, uid string) (*os.File, chunkedUpload, func(), error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	upload, err := u.getLocked(usr, uid)
+	if err != nil {
+		return nil, chunkedUpload{}, nil, err
+	}
+	if upload.busy {
+		return nil, chunkedUpload{}, nil, ErrUploadBusy
+	}
+	if upload.Received < upload.Size {
+		return nil, chunkedUpload{}, nil, fmt.Errorf("%w: %d of %d bytes were received", ErrUploadIncomplete, upload.Received, upload.Size)
+	}
+
+	// the path is the file of an upload created by chunkedUploads, not user input
+	// nolint:gosec
+	f, err := os.Open(upload.path)
+	if err != nil {
+		return nil, chunkedUpload{}, nil, err
+	}
+	// busy until removed, so that no chunk is written while the bundle is validated
+	upload.busy = true
+	return f, *upload, func() {
+		_ = f.Close()
+		u.remove(uid)
+	}, nil
+}
+
+// cancel removes the upload of usr with the given UID.
+func (u *chunkedUploads) cancel(usr *user.SignedInUser, uid string) error {
+	u.mu.Lock()
+	upload, err := u.getLocked(usr, uid)
+	if err == nil && upload.busy {
+		err = ErrUploadBusy
+	}
+	u.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	u.remove(uid)
+	return nil
+}
+
+func (u *chunkedUploads) remove(uid string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if upload, ok := u.uploads[uid]; ok {
+		_ = os.Remove(upload.path)
+		delete(u.uploads, uid)
+	}
+}
+
+// removeExpired removes the uploads that didn't receive a chunk in time.
+func (u *chunkedUploads) removeExpired() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.removeExpiredLocked()
+}
+
+func (u *chunkedUploads) removeExpiredLocked() {
+	now := u.now()
+	for uid, upload := range u.uploads {
+		if !upload.busy && now.After(upload.ExpiresAt) {
+			_ = os.Remove(upload.path)
+			delete(u.uploads, uid)
+		}
+	}
+}
+
+// parseContentRange parses a Content-Range header of the form bytes start-end/total.
+func parseContentRange(header string) (int64, int64, int64, error) {
+	if header == "" {
+		return 0, 0, 0, fmt.Errorf("%w: the Content-Range header is missing", ErrInvalidChunk)
+	}
+	invalid := fmt.Errorf("%w: invalid Content-Range %q, expected bytes start-end/total", ErrInvalidChunk, header)
+
+	spec := strings.TrimSpace(header)
+	if !strings.HasPrefix(spec, "bytes ") {
+		return 0, 0, 0, invalid
+	}
+	spec = strings.TrimPrefix(spec, "bytes ")
+	byteRange, size, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+	first, last, ok := strings.Cut(byteRange, "-")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, 0, invalid
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil {
+		return 0, 0, 0, invalid
+	}
+	total, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return 0, 0, 0, invalid
+	}
+	if start < 0 || end < start || end >= total {
+		return 0, 0, 0, invalid
+	}
+	return start, end, total, nil
+}
+
+// userKey identifies usr, or the API key they're authenticated with.
+func userKey(usr *user.SignedInUser) string {
+	if usr.UserID == 0 {
+		return fmt.Sprintf("api-key:%d", usr.ApiKeyID)
+	}
+	return fmt.Sprintf("user:%d", usr.UserID)
+}
@@ -0,0 +1,166 @@
+package supportbundlesimpl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestChunkedUploads(t *testing.T) {
+	usr := &user.SignedInUser{UserID: 1, Login: "admin"}
+	chunkRange := func(start, end, total int) string {
+		return fmt.Sprintf("bytes %d-%d/%d", start, end, total)
+	}
+	newUploads := func(t *testing.T) *chunkedUploads {
+		u := newChunkedUploads(1024, time.Minute)
+		t.Cleanup(func() { _ = os.RemoveAll(u.dir) })
+		return u
+	}
+
+	t.Run("reassembles the chunks and resumes after a failed chunk", func(t *testing.T) {
+		u := newUploads(t)
+		data := []byte("0123456789abcdef")
+		upload, err := u.create(usr, int64(len(data)), "")
+		require.NoError(t, err)
+
+		upload, err = u.write(usr, upload.UID, chunkRange(0, 5, 16), bytes.NewReader(data[:6]))
+		require.NoError(t, err)
+		require.Equal(t, int64(6), upload.Received)
+
+		// the connection drops after part of the next chunk
+		_, err = u.write(usr, upload.UID, chunkRange(6, 11, 16), iotest.TimeoutReader(iotest.OneByteReader(bytes.NewReader(data[6:12]))))
+		require.Error(t, err)
+		upload, err = u.get(usr, upload.UID)
+		require.NoError(t, err)
+		require.Equal(t, int64(7), upload.Received)
+
+		_, err = u.write(usr, upload.UID, chunkRange(12, 15, 16), bytes.NewReader(data[12:]))
+		require.ErrorIs(t, err, ErrChunkOutOfOrder)
+		_, _, _, err = u.open(usr, upload.UID)
+		require.ErrorIs(t, err, ErrUploadIncomplete)
+
+		// chunks may overlap the bytes already received
+		_, err = u.write(usr, upload.UID, chunkRange(6, 11, 16), bytes.NewReader(data[6:12]))
+		require.NoError(t, err)
+		upload, err = u.write(usr, upload.UID, chunkRange(12, 15, 16), bytes.NewReader(data[12:]))
+		require.NoError(t, err)
+		require.Equal(t, int64(16), upload.Received)
+
+		f, _, done, err := u.open(usr, upload.UID)
+		require.NoError(t, err)
+		content, err := os.ReadFile(f.Name())
+		require.NoError(t, err)
+		require.Equal(t, data, content)
+		done()
+
+		_, err = u.get(usr, upload.UID)
+		require.ErrorIs(t, err, ErrUploadNotFound)
+		_, err = os.Stat(f.Name())
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("enforces the total size", func(t *testing.T) {
+		u := newUploads(t)
+		_, err := u.create(usr, 2048, "")
+		require.ErrorIs(t, err, ErrUploadTooLarge)
+
+		upload, err := u.create(usr, 16, "")
+		require.NoError(t, err)
+		_, err = u.write(usr, upload.UID, chunkRange(0, 31, 32), strings.NewReader(strings.Repeat("a", 32)))
+		require.ErrorIs(t, err, ErrInvalidChunk)
+		_, err = u.write(usr, upload.UID, "", strings.NewReader("a"))
+		require.ErrorIs(t, err, ErrInvalidChunk)
+	})
+
+	t.Run("is only visible to its creator", func(t *testing.T) {
+		u := newUploads(t)
+		upload, err := u.create(usr, 16, "")
+		require.NoError(t, err)
+
+		other := &user.SignedInUser{UserID: 2, Login: "other"}
+		_, err = u.get(other, upload.UID)
+		require.ErrorIs(t, err, ErrUploadNotFound)
+		require.ErrorIs(t, u.cancel(other, upload.UID), ErrUploadNotFound)
+		require.NoError(t, u.cancel(usr, upload.UID))
+	})
+
+	t.Run("removes abandoned uploads", func(t *testing.T) {
+		u := newUploads(t)
+		now := time.Now()
+		u.now = func() time.Time { return now }
+		upload, err := u.create(usr, 16, "")
+		require.NoError(t, err)
+
+		now = now.Add(30 * time.Second)
+		_, err = u.write(usr, upload.UID, chunkRange(0, 3, 16), strings.NewReader("abcd"))
+		require.NoError(t, err)
+
+		// the expiry is counted from the last chunk
+		now = now.Add(45 * time.Second)
+		u.removeExpired()
+		_, err = u.get(usr, upload.UID)
+		require.NoError(t, err)
+
+		now = now.Add(time.Minute)
+		u.removeExpired()
+		_, err = u.get(usr, upload.UID)
+		require.ErrorIs(t, err, ErrUploadNotFound)
+		_, err = os.Stat(upload.path)
+		require.True(t, errors.Is(err, os.ErrNotExist))
+	})
+
+	t.Run("validates the reassembled bundle", func(t *testing.T) {
+		s := newTestService(t, newTestCollector("ok", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			return &supportbundles.SupportItem{Filename: "ok.txt", FileBytes: []byte("hello")}, nil
+		}))
+		bundle, err := s.store.Create(context.Background(), usr, 0)
+		require.NoError(t, err)
+		s.startBundleWork(context.Background(), s.selectCollectors(nil), bundle.UID, nil, "")
+		stored, err := s.get(context.Background(), bundle.UID)
+		require.NoError(t, err)
+		data := stored.TarBytes
+
+		u := newUploads(t)
+		u.maxSize = int64(len(data))
+		upload, err := u.create(usr, int64(len(data)), stored.Checksum)
+		require.NoError(t, err)
+		for start := 0; start < len(data); start += 100 {
+			end := start + 100
+			if end > len(data) {
+				end = len(data)
+			}
+			_, err := u.write(usr, upload.UID, chunkRange(start, end-1, len(data)), bytes.NewReader(data[start:end]))
+			require.NoError(t, err)
+		}
+
+		f, upload, done, err := u.open(usr, upload.UID)
+		require.NoError(t, err)
+		defer done()
+		validation, err := s.validateBundle(context.Background(), f, upload.Size, upload.Checksum)
+		require.NoError(t, err)
+		require.True(t, validation.ChecksumVerified)
+		require.Equal(t, []string{"ok"}, validation.Present)
+	})
+}
+
+func TestParseContentRange(t *testing.T) {
+	start, end, total, err := parseContentRange("bytes 0-1023/4096")
+	require.NoError(t, err)
+	require.Equal(t, []int64{0, 1023, 4096}, []int64{start, end, total})
+
+	for _, header := range []string{"", "0-1023/4096", "bytes 0-1023/*", "bytes */4096", "bytes 10-5/4096", "bytes 0-4096/4096", "bytes -1-5/4096"} {
+		_, _, _, err := parseContentRange(header)
+		require.ErrorIs(t, err, ErrInvalidChunk, header)
+	}
+}
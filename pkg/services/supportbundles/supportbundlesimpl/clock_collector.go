@@ -0,0 +1,139 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+const (
+	// ntpQueryTimeout bounds the NTP query, so that an unreachable server doesn't hold up the bundle.
+	ntpQueryTimeout = 3 * time.Second
+	// ntpEpochOffset is the number of seconds between the NTP epoch, 1900, and the unix epoch.
+	ntpEpochOffset = 2208988800
+)
+
+// clockCollector reports the time and timezone of the server and, when an NTP
+// server is given, the offset of the local clock measured against it. A skewed
+// clock breaks alert evaluation and the validation of tokens.
+func clockCollector(ntpServer string) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "clock",
+		DisplayName:       "Clock",
+		Description:       "The time and timezone of the server and, if an NTP server is configured, the offset of its clock",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type ntpOffset struct {
+				Server string `json:"server"`
+				// Offset is how far ahead of the NTP server the local clock is, negative if it's behind.
+				Offset        string  `json:"offset,omitempty"`
+				OffsetSeconds float64 `json:"offset_seconds"`
+				RoundTrip     string  `json:"round_trip,omitempty"`
+				Error         string  `json:"error,omitempty"`
+			}
+			type clock struct {
+				Time           time.Time  `json:"time"`
+				UTC            time.Time  `json:"utc"`
+				Timezone       string     `json:"timezone"`
+				TimezoneOffset string     `json:"timezone_offset"`
+				NTP            *ntpOffset `json:"ntp,omitempty"`
+			}
+
+			now := time.Now()
+			zone, offset := now.Zone()
+			result := clock{
+				Time:           now,
+				UTC:            now.UTC(),
+				Timezone:       fmt.Sprintf("%s (%s)", time.Local.String(), zone),
+				TimezoneOffset: (time.Duration(offset) * time.Second).String(),
+			}
+
+			if ntpServer != "" {
+				result.NTP = &ntpOffset{Server: ntpServer}
+				skew, rtt, err := queryNTPOffset(ctx, ntpServer)
+				if err != nil {
+					result.NTP.Error = err.Error()
+				} else {
+					result.NTP.Offset = skew.String()
+					result.NTP.OffsetSeconds = skew.Seconds()
+					result.NTP.RoundTrip = rtt.String()
+				}
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "clock.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+// queryNTPOffset sends an SNTP (RFC 4330) request to server, host or host:port,
+// and returns how far ahead of the server the local clock is and the round trip time.
+func queryNTPOffset(ctx context.Context, server string) (time.Duration, time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ntpQueryTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = conn.Close() }()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return 0, 0, err
+	}
+
+	// leap indicator 0, version 4, client mode
+	request := make([]byte, 48)
+	request[0] = 0x23
+	sent := time.Now()
+	if _, err := conn.Write(request); err != nil {
+		return 0, 0, err
+	}
+
+	response := make([]byte, 48)
+	n, err := conn.Read(response)
+	received := time.Now()
+	if err != nil {
+		return 0, 0, err
+	}
+	if n < 48 {
+		return 0, 0, fmt.Errorf("short NTP response of %d bytes", n)
+	}
+	if mode := response[0] & 0x07; mode != 4 {
+		return 0, 0, fmt.Errorf("unexpected NTP response mode %d", mode)
+	}
+	if stratum := response[1]; stratum == 0 {
+		return 0, 0, fmt.Errorf("NTP server sent a kiss-o'-death: %q", response[12:16])
+	}
+
+	serverReceived := ntpTime(response[32:40])
+	serverSent := ntpTime(response[40:48])
+	// the offset of the server clock to the local one, inverted to be the skew of the local clock
+	offset := (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2
+	roundTrip := received.Sub(sent) - serverSent.Sub(serverReceived)
+	return -offset, roundTrip, nil
+}
+
+// ntpTime decodes an NTP timestamp, 32 bits of seconds and 32 bits of fraction.
+func ntpTime(b []byte) time.Time {
+	seconds := int64(binary.BigEndian.Uint32(b[0:4])) - ntpEpochOffset
+	fraction := int64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(seconds, (fraction*int64(time.Second))>>32)
+}
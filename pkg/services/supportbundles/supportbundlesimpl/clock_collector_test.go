@@ -0,0 +1,103 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNTPServer answers SNTP requests with its clock set ahead by skew.
+func fakeNTPServer(t *testing.T, skew time.Duration) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	putTime := func(b []byte, ts time.Time) {
+		binary.BigEndian.PutUint32(b[0:4], uint32(ts.Unix()+ntpEpochOffset))
+		binary.BigEndian.PutUint32(b[4:8], uint32((int64(ts.Nanosecond())<<32)/int64(time.Second)))
+	}
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			response := make([]byte, 48)
+			// version 4, server mode, stratum 1
+			response[0] = 0x24
+			response[1] = 1
+			now := time.Now().Add(skew)
+			putTime(response[32:40], now)
+			putTime(response[40:48], now)
+			_, _ = conn.WriteTo(response, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestClockCollector(t *testing.T) {
+	type ntpOffset struct {
+		Server        string  `json:"server"`
+		OffsetSeconds float64 `json:"offset_seconds"`
+		Error         string  `json:"error"`
+	}
+	type clock struct {
+		Time     time.Time  `json:"time"`
+		Timezone string     `json:"timezone"`
+		NTP      *ntpOffset `json:"ntp"`
+	}
+
+	collect := func(t *testing.T, ntpServer string) clock {
+		t.Helper()
+
+		item, err := clockCollector(ntpServer).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "clock.json", item.Filename)
+
+		var result clock
+		require.NoError(t, json.Unmarshal(item.FileBytes, &result))
+		return result
+	}
+
+	t.Run("reports the time without querying NTP by default", func(t *testing.T) {
+		result := collect(t, "")
+		require.WithinDuration(t, time.Now(), result.Time, time.Minute)
+		require.NotEmpty(t, result.Timezone)
+		require.Nil(t, result.NTP)
+	})
+
+	t.Run("measures the offset against the NTP server", func(t *testing.T) {
+		server := fakeNTPServer(t, 10*time.Second)
+
+		result := collect(t, server)
+		require.NotNil(t, result.NTP)
+		require.Empty(t, result.NTP.Error)
+		require.InDelta(t, -10, result.NTP.OffsetSeconds, 0.5)
+	})
+
+	t.Run("records NTP servers that don't answer", func(t *testing.T) {
+		// a bound socket that never answers
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		item, err := clockCollector(conn.LocalAddr().String()).Fn(ctx)
+		require.NoError(t, err)
+
+		var result clock
+		require.NoError(t, json.Unmarshal(item.FileBytes, &result))
+		require.NotNil(t, result.NTP)
+		require.NotEmpty(t, result.NTP.Error)
+	})
+}
@@ -0,0 +1,68 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+var ErrInvalidCollectorParams = errors.New("invalid support bundle collector parameters")
+
+type collectorParamsKey struct{}
+
+// withCollectorParams makes the parameters set for the collectors of a bundle,
+// by collector UID, available to runCollector.
+func withCollectorParams(ctx context.Context, params map[string]map[string]int64) context.Context {
+	if len(params) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, collectorParamsKey{}, params)
+}
+
+func collectorParamsFromContext(ctx context.Context) map[string]map[string]int64 {
+	params, _ := ctx.Value(collectorParamsKey{}).(map[string]map[string]int64)
+	return params
+}
+
+// validateCollectorParams returns ErrInvalidCollectorParams if a parameter is set
+// for a collector that isn't registered, isn't declared by the collector or is
+// out of its bounds.
+func (s *Service) validateCollectorParams(params map[string]map[string]int64) error {
+	registered := s.bundleRegistry.Collectors()
+
+	uids := make([]string, 0, len(params))
+	for uid := range params {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+
+	for _, uid := range uids {
+		collector, ok := registered[uid]
+		if !ok {
+			return fmt.Errorf("%w: unknown collector %q", ErrInvalidCollectorParams, uid)
+		}
+		declared := make(map[string]supportbundles.CollectorParam, len(collector.Params))
+		for _, p := range collector.Params {
+			declared[p.Name] = p
+		}
+
+		names := make([]string, 0, len(params[uid]))
+		for name := range params[uid] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p, ok := declared[name]
+			if !ok {
+				return fmt.Errorf("%w: collector %q has no parameter %q", ErrInvalidCollectorParams, uid, name)
+			}
+			if v := params[uid][name]; v < p.Min || v > p.Max {
+				return fmt.Errorf("%w: %s.%s must be between %d and %d, got %d", ErrInvalidCollectorParams, uid, name, p.Min, p.Max, v)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestService_create_CollectorParams(t *testing.T) {
+	lines := func(uid string) supportbundles.Collector {
+		c := newTestCollector(uid, func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			return &supportbundles.SupportItem{
+				Filename:  uid + ".txt",
+				FileBytes: []byte(fmt.Sprint(supportbundles.IntParam(ctx, "lines", 10))),
+			}, nil
+		})
+		c.Params = []supportbundles.CollectorParam{{Name: "lines", Default: 10, Min: 1, Max: 100}}
+		return c
+	}
+	s := newTestService(t, lines("tail"), lines("other"))
+	usr := &user.SignedInUser{Login: "admin"}
+
+	t.Run("rejects invalid parameters", func(t *testing.T) {
+		for name, params := range map[string]map[string]map[string]int64{
+			"unknown collector": {"missing": {"lines": 5}},
+			"unknown parameter": {"tail": {"bytes": 5}},
+			"below the minimum": {"tail": {"lines": 0}},
+			"above the maximum": {"tail": {"lines": 101}},
+		} {
+			_, err := s.create(context.Background(), usr, createOptions{Params: params})
+			require.ErrorIs(t, err, ErrInvalidCollectorParams, name)
+		}
+		require.Len(t, s.creationSlots, 0)
+	})
+
+	t.Run("passes the parameters to their collector only", func(t *testing.T) {
+		params := map[string]map[string]int64{"tail": {"lines": 50}}
+		bundle, err := s.create(context.Background(), usr, createOptions{Params: params})
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return len(s.creationSlots) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+		stored, err := s.store.Get(context.Background(), bundle.UID)
+		require.NoError(t, err)
+		require.Equal(t, params, stored.Params)
+
+		files := readBundle(t, stored.TarBytes)
+		require.Equal(t, "50", string(files["/bundle/tail.txt"]))
+		require.Equal(t, "10", string(files["/bundle/other.txt"]))
+	})
+}
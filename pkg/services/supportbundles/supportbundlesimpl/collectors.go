@@ -81,6 +81,56 @@ func basicCollector(cfg *setting.Cfg) supportbundles.Collector {
 	}
 }
 
+// buildInfoCollector only relies on the configuration and the Go runtime so it
+// succeeds even when the database or other services are unavailable.
+func buildInfoCollector(cfg *setting.Cfg) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "build-info",
+		DisplayName:       "Build and runtime information",
+		Description:       "Grafana build information, Go runtime details and a memory statistics snapshot",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type buildInfo struct {
+				Version      string            `json:"version"`       // Version is the version of Grafana instance.
+				Commit       string            `json:"commit"`        // Commit is the commit hash of the Grafana instance.
+				Branch       string            `json:"branch"`        // Branch is the branch the Grafana instance was built from.
+				BuildStamp   time.Time         `json:"build_stamp"`   // BuildStamp is the date the Grafana instance was built.
+				IsEnterprise bool              `json:"is_enterprise"` // IsEnterprise is true for Grafana Enterprise builds.
+				GoVersion    string            `json:"go_version"`    // GoVersion is the version of Go used to build the binary.
+				GoOS         string            `json:"go_os"`         // GoOS is the operating system target used to build the binary.
+				GoArch       string            `json:"go_arch"`       // GoArch is the architecture target used to build the binary.
+				NumCPU       int               `json:"num_cpu"`       // NumCPU is the number of logical CPUs usable by the current process.
+				MemStats     *runtime.MemStats `json:"mem_stats"`     // MemStats is a snapshot of the memory allocator statistics.
+			}
+
+			memstats := &runtime.MemStats{}
+			runtime.ReadMemStats(memstats)
+
+			data, err := json.Marshal(buildInfo{
+				Version:      cfg.BuildVersion,
+				Commit:       cfg.BuildCommit,
+				Branch:       cfg.BuildBranch,
+				BuildStamp:   time.Unix(cfg.BuildStamp, 0).UTC(),
+				IsEnterprise: cfg.IsEnterprise,
+				GoVersion:    runtime.Version(),
+				GoOS:         runtime.GOOS,
+				GoArch:       runtime.GOARCH,
+				NumCPU:       runtime.NumCPU(),
+				MemStats:     memstats,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "build-info.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
 func settingsCollector(settings setting.Provider) supportbundles.Collector {
 	return supportbundles.Collector{
 		UID:               "settings",
@@ -88,6 +138,7 @@ func settingsCollector(settings setting.Provider) supportbundles.Collector {
 		Description:       "Settings of the Grafana instance",
 		IncludedByDefault: false,
 		Default:           true,
+		Restricted:        true,
 		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
 			current := settings.Current()
 			data, err := json.Marshal(current)
@@ -112,8 +163,11 @@ func pluginInfoCollector(pluginStore plugins.Store, pluginSettings pluginsetting
 		Default:           true,
 		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
 			type pluginInfo struct {
-				data  plugins.JSONData
-				Class plugins.Class
+				data plugins.JSONData
+				// ID and Version identify the plugin, e.g. when comparing the plugins of two bundles
+				ID      string
+				Version string
+				Class   plugins.Class
 
 				// App fields
 				IncludedInAppID string
@@ -143,6 +197,8 @@ func pluginInfoCollector(pluginStore plugins.Store, pluginSettings pluginsetting
 
 				pInfo := pluginInfo{
 					data:            plugin.JSONData,
+					ID:              plugin.ID,
+					Version:         plugin.Info.Version,
 					Class:           plugin.Class,
 					IncludedInAppID: plugin.IncludedInAppID,
 					DefaultNavURL:   plugin.DefaultNavURL,
@@ -0,0 +1,58 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestBuildInfoCollector(t *testing.T) {
+	cfg := setting.NewCfg()
+	cfg.BuildVersion = "10.0.0"
+	cfg.BuildCommit = "abc123"
+	cfg.BuildBranch = "main"
+	cfg.BuildStamp = time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC).Unix()
+	cfg.IsEnterprise = true
+
+	collector := buildInfoCollector(cfg)
+	require.Equal(t, "build-info", collector.UID)
+
+	item, err := collector.Fn(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "build-info.json", item.Filename)
+
+	var info struct {
+		Version      string    `json:"version"`
+		Commit       string    `json:"commit"`
+		Branch       string    `json:"branch"`
+		BuildStamp   time.Time `json:"build_stamp"`
+		IsEnterprise bool      `json:"is_enterprise"`
+		GoVersion    string    `json:"go_version"`
+		GoOS         string    `json:"go_os"`
+		GoArch       string    `json:"go_arch"`
+		NumCPU       int       `json:"num_cpu"`
+		MemStats     *struct {
+			HeapAlloc uint64
+			Sys       uint64
+		} `json:"mem_stats"`
+	}
+	require.NoError(t, json.Unmarshal(item.FileBytes, &info))
+	require.Equal(t, "10.0.0", info.Version)
+	require.Equal(t, "abc123", info.Commit)
+	require.Equal(t, "main", info.Branch)
+	require.Equal(t, time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), info.BuildStamp)
+	require.True(t, info.IsEnterprise)
+	require.Equal(t, runtime.Version(), info.GoVersion)
+	require.Equal(t, runtime.GOOS, info.GoOS)
+	require.Equal(t, runtime.GOARCH, info.GoArch)
+	require.Equal(t, runtime.NumCPU(), info.NumCPU)
+	require.NotNil(t, info.MemStats)
+	require.NotZero(t, info.MemStats.HeapAlloc)
+	require.NotZero(t, info.MemStats.Sys)
+}
@@ -0,0 +1,54 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func configReloadCollector(settings setting.Provider) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "config-reload",
+		DisplayName:       "Configuration reload status",
+		Description:       "When the configuration was last reloaded at runtime, whether a reload is pending and its errors",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type reloadStatus struct {
+				Supported  bool              `json:"supported"`
+				Note       string            `json:"note,omitempty"`
+				LastReload *time.Time        `json:"lastReload,omitempty"`
+				Pending    bool              `json:"pending"`
+				Errors     map[string]string `json:"errors,omitempty"`
+			}
+
+			status := reloadStatus{
+				Note: "runtime configuration reload is not supported by this build of Grafana",
+			}
+			if provider, ok := settings.(setting.ReloadStatusProvider); ok {
+				current := provider.ReloadStatus()
+				status = reloadStatus{
+					Supported: true,
+					Pending:   current.Pending,
+					Errors:    current.Errors,
+				}
+				if !current.LastReload.IsZero() {
+					status.LastReload = &current.LastReload
+				}
+			}
+
+			data, err := json.Marshal(status)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "config-reload.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
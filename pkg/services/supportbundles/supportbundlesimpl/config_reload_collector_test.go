@@ -0,0 +1,66 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+type reloadingProvider struct {
+	*setting.OSSImpl
+	status setting.ReloadStatus
+}
+
+func (p reloadingProvider) ReloadStatus() setting.ReloadStatus {
+	return p.status
+}
+
+func TestConfigReloadCollector(t *testing.T) {
+	type reloadStatus struct {
+		Supported  bool              `json:"supported"`
+		Note       string            `json:"note"`
+		LastReload *time.Time        `json:"lastReload"`
+		Pending    bool              `json:"pending"`
+		Errors     map[string]string `json:"errors"`
+	}
+
+	collect := func(t *testing.T, settings setting.Provider) reloadStatus {
+		t.Helper()
+		item, err := configReloadCollector(settings).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "config-reload.json", item.Filename)
+
+		var status reloadStatus
+		require.NoError(t, json.Unmarshal(item.FileBytes, &status))
+		return status
+	}
+
+	t.Run("notes when reloads are unsupported", func(t *testing.T) {
+		status := collect(t, setting.ProvideProvider(setting.NewCfg()))
+		require.False(t, status.Supported)
+		require.NotEmpty(t, status.Note)
+		require.Nil(t, status.LastReload)
+	})
+
+	t.Run("reports the reload status", func(t *testing.T) {
+		lastReload := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+		status := collect(t, reloadingProvider{
+			OSSImpl: setting.ProvideProvider(setting.NewCfg()),
+			status: setting.ReloadStatus{
+				LastReload: lastReload,
+				Pending:    true,
+				Errors:     map[string]string{"auth.saml": "invalid certificate"},
+			},
+		})
+		require.True(t, status.Supported)
+		require.Empty(t, status.Note)
+		require.True(t, status.Pending)
+		require.Equal(t, lastReload, status.LastReload.UTC())
+		require.Equal(t, map[string]string{"auth.saml": "invalid certificate"}, status.Errors)
+	})
+}
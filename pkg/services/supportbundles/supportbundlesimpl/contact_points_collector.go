@@ -0,0 +1,231 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/ngalert"
+	apimodels "github.com/grafana/grafana/pkg/services/ngalert/api/tooling/definitions"
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/notifier"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// urlRegex matches the URLs in a text, e.g. in the error of a failed notification.
+var urlRegex = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// contactPointsCollector reports the contact points of every organization with
+// their redacted settings, the notification policy tree they're routed by and
+// the errors of their last notifications, for alerts that aren't notifying.
+func contactPointsCollector(ng *ngalert.AlertNG) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "contact-points",
+		DisplayName:       "Alerting contact points",
+		Description:       "Unified Alerting contact points with their settings redacted, notification policies and recent notification errors",
+		IncludedByDefault: false,
+		Default:           true,
+		Restricted:        true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type contactPoints struct {
+				Enabled       bool               `json:"enabled"`
+				Note          string             `json:"note,omitempty"`
+				Organizations []orgContactPoints `json:"organizations"`
+			}
+
+			result := contactPoints{Organizations: []orgContactPoints{}}
+			if ng == nil || ng.IsDisabled() {
+				result.Note = "Unified Alerting is disabled on this instance"
+			} else {
+				result.Enabled = true
+
+				configs, err := ng.GetAlertmanagerConfigurations(ctx)
+				if err != nil {
+					return nil, err
+				}
+				receivers := func(orgID int64) ([]apimodels.Receiver, error) {
+					if ng.MultiOrgAlertmanager == nil {
+						return nil, notifier.ErrNoAlertmanagerForOrg
+					}
+					am, err := ng.MultiOrgAlertmanager.AlertmanagerFor(orgID)
+					if err != nil {
+						return nil, err
+					}
+					return am.GetReceivers(ctx), nil
+				}
+				result.Organizations = collectContactPoints(configs, receivers)
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "contact-points.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+type contactPointIntegration struct {
+	UID                   string      `json:"uid"`
+	Type                  string      `json:"type"`
+	DisableResolveMessage bool        `json:"disable_resolve_message"`
+	Settings              interface{} `json:"settings,omitempty"`
+	// SecureFields are the settings stored encrypted, their values aren't reported.
+	SecureFields []string `json:"secure_fields,omitempty"`
+}
+
+type contactPoint struct {
+	Name         string                    `json:"name"`
+	Integrations []contactPointIntegration `json:"integrations"`
+}
+
+type notificationError struct {
+	ContactPoint string    `json:"contact_point"`
+	Integration  string    `json:"integration"`
+	LastAttempt  time.Time `json:"last_attempt"`
+	Duration     string    `json:"duration,omitempty"`
+	Error        string    `json:"error"`
+}
+
+type orgContactPoints struct {
+	OrgID int64 `json:"org_id"`
+	// Default is whether the organization still uses the default configuration.
+	Default            bool                `json:"default"`
+	CreatedAt          time.Time           `json:"created_at"`
+	ContactPoints      []contactPoint      `json:"contact_points"`
+	Policies           *apimodels.Route    `json:"notification_policies,omitempty"`
+	NotificationErrors []notificationError `json:"notification_errors"`
+	// Error is why the configuration or the notification errors couldn't be read.
+	Error string `json:"error,omitempty"`
+}
+
+// collectContactPoints reports the contact points of the latest configuration of
+// every organization, along with the errors of the integrations whose last
+// notification failed as returned by receivers.
+func collectContactPoints(configs []*ngmodels.AlertConfiguration, receivers func(orgID int64) ([]apimodels.Receiver, error)) []orgContactPoints {
+	latest := map[int64]*ngmodels.AlertConfiguration{}
+	for _, c := range configs {
+		if l, ok := latest[c.OrgID]; !ok || c.ID > l.ID {
+			latest[c.OrgID] = c
+		}
+	}
+	orgIDs := make([]int64, 0, len(latest))
+	for orgID := range latest {
+		orgIDs = append(orgIDs, orgID)
+	}
+	sort.Slice(orgIDs, func(i, j int) bool { return orgIDs[i] < orgIDs[j] })
+
+	orgs := make([]orgContactPoints, 0, len(orgIDs))
+	for _, orgID := range orgIDs {
+		c := latest[orgID]
+		org := orgContactPoints{
+			OrgID:              orgID,
+			Default:            c.Default,
+			CreatedAt:          time.Unix(c.CreatedAt, 0).UTC(),
+			ContactPoints:      []contactPoint{},
+			NotificationErrors: []notificationError{},
+		}
+
+		cfg, err := notifier.Load([]byte(c.AlertmanagerConfiguration))
+		if err != nil {
+			org.Error = err.Error()
+			orgs = append(orgs, org)
+			continue
+		}
+		org.Policies = cfg.AlertmanagerConfig.Route
+		for _, r := range cfg.AlertmanagerConfig.Receivers {
+			cp := contactPoint{Name: r.Name, Integrations: []contactPointIntegration{}}
+			for _, gr := range r.GrafanaManagedReceivers {
+				integration := contactPointIntegration{
+					UID:                   gr.UID,
+					Type:                  gr.Type,
+					DisableResolveMessage: gr.DisableResolveMessage,
+				}
+				var settings map[string]interface{}
+				if len(gr.Settings) > 0 && json.Unmarshal(gr.Settings, &settings) == nil {
+					integration.Settings = redactURLPathsIn(redactMap(settings))
+				}
+				for field := range gr.SecureSettings {
+					integration.SecureFields = append(integration.SecureFields, field)
+				}
+				sort.Strings(integration.SecureFields)
+				cp.Integrations = append(cp.Integrations, integration)
+			}
+			org.ContactPoints = append(org.ContactPoints, cp)
+		}
+
+		rcvs, err := receivers(orgID)
+		if err != nil {
+			if !errors.Is(err, notifier.ErrNoAlertmanagerForOrg) {
+				org.Error = err.Error()
+			}
+			orgs = append(orgs, org)
+			continue
+		}
+		for _, rcv := range rcvs {
+			for _, integration := range rcv.Integrations {
+				if integration.LastNotifyAttemptError == "" {
+					continue
+				}
+				ne := notificationError{
+					LastAttempt: time.Time(integration.LastNotifyAttempt).UTC(),
+					Duration:    integration.LastNotifyAttemptDuration,
+					Error:       redactURLPaths(integration.LastNotifyAttemptError),
+				}
+				if rcv.Name != nil {
+					ne.ContactPoint = *rcv.Name
+				}
+				if integration.Name != nil {
+					ne.Integration = *integration.Name
+				}
+				org.NotificationErrors = append(org.NotificationErrors, ne)
+			}
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs
+}
+
+// redactURLPaths replaces the path and query of the URLs in text, which often
+// embed tokens, e.g. https://hooks.slack.com/services/<token>. Only the scheme
+// and host are kept.
+func redactURLPaths(text string) string {
+	return urlRegex.ReplaceAllStringFunc(text, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return redactedValue
+		}
+		if (u.Path == "" || u.Path == "/") && u.RawQuery == "" && u.User == nil {
+			return u.Scheme + "://" + u.Host + u.Path
+		}
+		return u.Scheme + "://" + u.Host + "/" + redactedValue
+	})
+}
+
+// redactURLPathsIn applies redactURLPaths to every string in v.
+func redactURLPathsIn(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = redactURLPathsIn(item)
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactURLPathsIn(item)
+		}
+		return val
+	case string:
+		return redactURLPaths(val)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,124 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/stretchr/testify/require"
+
+	apimodels "github.com/grafana/grafana/pkg/services/ngalert/api/tooling/definitions"
+	ngmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/ngalert/notifier"
+)
+
+func TestContactPointsCollector(t *testing.T) {
+	t.Run("reports that Unified Alerting is disabled", func(t *testing.T) {
+		item, err := contactPointsCollector(nil).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "contact-points.json", item.Filename)
+
+		var result struct {
+			Enabled bool   `json:"enabled"`
+			Note    string `json:"note"`
+		}
+		require.NoError(t, json.Unmarshal(item.FileBytes, &result))
+		require.False(t, result.Enabled)
+		require.NotEmpty(t, result.Note)
+	})
+}
+
+func TestCollectContactPoints(t *testing.T) {
+	config := `{
+		"alertmanager_config": {
+			"route": {"receiver": "slack"},
+			"receivers": [
+				{
+					"name": "slack",
+					"grafana_managed_receiver_configs": [
+						{"uid": "s1", "name": "slack", "type": "slack", "settings": {"url": "https://hooks.slack.com/services/T0/B0/` + plantedSecret + `", "recipient": "#alerts"}, "secureSettings": {"token": "encrypted"}}
+					]
+				},
+				{
+					"name": "ops",
+					"grafana_managed_receiver_configs": [
+						{"uid": "w1", "name": "ops", "type": "webhook", "disableResolveMessage": true, "settings": {"url": "https://hooks.example.com/alert?token=` + plantedSecret + `", "username": "grafana", "password": "` + plantedSecret + `"}},
+						{"uid": "e1", "name": "ops", "type": "email", "settings": {"addresses": "ops@example.com"}}
+					]
+				}
+			]
+		}
+	}`
+	configs := []*ngmodels.AlertConfiguration{
+		{ID: 1, OrgID: 1, AlertmanagerConfiguration: `{"alertmanager_config": {"receivers": [{"name": "old"}]}}`, Default: true},
+		{ID: 3, OrgID: 1, AlertmanagerConfiguration: config, CreatedAt: 1700000000},
+		{ID: 2, OrgID: 2, AlertmanagerConfiguration: "{"},
+	}
+
+	lastAttempt := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	str := func(s string) *string { return &s }
+	receivers := func(orgID int64) ([]apimodels.Receiver, error) {
+		if orgID != 1 {
+			return nil, errors.New("unexpected org")
+		}
+		return []apimodels.Receiver{
+			{Name: str("slack"), Integrations: []*apimodels.Integration{{
+				Name:                   str("slack[0]"),
+				LastNotifyAttempt:      strfmt.DateTime(lastAttempt),
+				LastNotifyAttemptError: `Post "https://hooks.slack.com/services/T0/B0/` + plantedSecret + `": dial tcp: i/o timeout`,
+			}}},
+			{Name: str("ops"), Integrations: []*apimodels.Integration{{Name: str("webhook[0]")}}},
+		}, nil
+	}
+
+	orgs := collectContactPoints(configs, receivers)
+	data, err := json.Marshal(orgs)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), plantedSecret)
+	require.Len(t, orgs, 2)
+
+	org := orgs[0]
+	require.EqualValues(t, 1, org.OrgID)
+	require.False(t, org.Default)
+	require.Empty(t, org.Error)
+	require.Equal(t, "slack", org.Policies.Receiver)
+	require.Len(t, org.ContactPoints, 2)
+
+	slack := org.ContactPoints[0].Integrations[0]
+	require.Equal(t, "slack", slack.Type)
+	require.Equal(t, []string{"token"}, slack.SecureFields)
+	require.Equal(t, map[string]interface{}{"url": "https://hooks.slack.com/" + redactedValue, "recipient": "#alerts"}, slack.Settings)
+
+	ops := org.ContactPoints[1]
+	require.Equal(t, "ops", ops.Name)
+	require.Len(t, ops.Integrations, 2)
+	require.True(t, ops.Integrations[0].DisableResolveMessage)
+	require.Equal(t, map[string]interface{}{
+		"url":      "https://hooks.example.com/" + redactedValue,
+		"username": "grafana",
+		"password": redactedValue,
+	}, ops.Integrations[0].Settings)
+	require.Equal(t, map[string]interface{}{"addresses": "ops@example.com"}, ops.Integrations[1].Settings)
+
+	require.Equal(t, []notificationError{{
+		ContactPoint: "slack",
+		Integration:  "slack[0]",
+		LastAttempt:  lastAttempt,
+		Error:        `Post "https://hooks.slack.com/` + redactedValue + `": dial tcp: i/o timeout`,
+	}}, org.NotificationErrors)
+
+	require.EqualValues(t, 2, orgs[1].OrgID)
+	require.NotEmpty(t, orgs[1].Error)
+
+	t.Run("skips the notification errors of organizations without Alertmanager", func(t *testing.T) {
+		orgs := collectContactPoints(configs[:2], func(int64) ([]apimodels.Receiver, error) {
+			return nil, notifier.ErrNoAlertmanagerForOrg
+		})
+		require.Len(t, orgs, 1)
+		require.Empty(t, orgs[0].Error)
+		require.Empty(t, orgs[0].NotificationErrors)
+	})
+}
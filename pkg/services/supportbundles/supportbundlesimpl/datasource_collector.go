@@ -0,0 +1,110 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/datasources"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+func datasourceCollector(sqlStore db.DB) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "datasources",
+		DisplayName:       "Data sources",
+		Description:       "Configuration of all data sources with credentials redacted",
+		IncludedByDefault: false,
+		Default:           false,
+		Restricted:        true,
+		StreamFn: func(ctx context.Context, fw supportbundles.FileWriter) error {
+			type datasourceInfo struct {
+				ID                int64                  `json:"id"`
+				UID               string                 `json:"uid"`
+				OrgID             int64                  `json:"org_id"`
+				Name              string                 `json:"name"`
+				Type              string                 `json:"type"`
+				Access            datasources.DsAccess   `json:"access"`
+				URL               string                 `json:"url"`
+				User              string                 `json:"user,omitempty"`
+				Password          string                 `json:"password,omitempty"`
+				Database          string                 `json:"database,omitempty"`
+				BasicAuth         bool                   `json:"basic_auth"`
+				BasicAuthUser     string                 `json:"basic_auth_user,omitempty"`
+				BasicAuthPassword string                 `json:"basic_auth_password,omitempty"`
+				WithCredentials   bool                   `json:"with_credentials"`
+				IsDefault         bool                   `json:"is_default"`
+				ReadOnly          bool                   `json:"read_only"`
+				JSONData          map[string]interface{} `json:"json_data,omitempty"`
+				SecureJSONFields  map[string]string      `json:"secure_json_fields,omitempty"`
+			}
+
+			// datasources are written to the bundle one by one while iterating
+			// over the table so that we never hold all of them in memory at once
+			w, err := fw.Create("datasources.json")
+			if err != nil {
+				return err
+			}
+			enc := json.NewEncoder(w)
+			if _, err := io.WriteString(w, "["); err != nil {
+				return err
+			}
+
+			count := 0
+			err = sqlStore.WithDbSession(ctx, func(sess *db.Session) error {
+				return sess.Table("data_source").Asc("org_id", "name").Iterate(new(datasources.DataSource), func(_ int, bean interface{}) error {
+					ds := bean.(*datasources.DataSource)
+
+					info := datasourceInfo{
+						ID:              ds.ID,
+						UID:             ds.UID,
+						OrgID:           ds.OrgID,
+						Name:            ds.Name,
+						Type:            ds.Type,
+						Access:          ds.Access,
+						URL:             redactURLCredentials(ds.URL),
+						User:            ds.User,
+						Database:        ds.Database,
+						BasicAuth:       ds.BasicAuth,
+						BasicAuthUser:   ds.BasicAuthUser,
+						WithCredentials: ds.WithCredentials,
+						IsDefault:       ds.IsDefault,
+						ReadOnly:        ds.ReadOnly,
+					}
+					if ds.Password != "" {
+						info.Password = redactedValue
+					}
+					if ds.BasicAuthPassword != "" {
+						info.BasicAuthPassword = redactedValue
+					}
+					if ds.JsonData != nil {
+						info.JSONData = redactMap(ds.JsonData.MustMap())
+					}
+					if len(ds.SecureJsonData) > 0 {
+						info.SecureJSONFields = make(map[string]string, len(ds.SecureJsonData))
+						for k := range ds.SecureJsonData {
+							info.SecureJSONFields[k] = redactedValue
+						}
+					}
+
+					if count > 0 {
+						if _, err := io.WriteString(w, ","); err != nil {
+							return err
+						}
+					}
+					count++
+					return enc.Encode(info)
+				})
+			})
+			// Iterate reports the end of the result set as sql.ErrNoRows
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			_, err = io.WriteString(w, "]")
+			return err
+		},
+	}
+}
@@ -0,0 +1,58 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/datasources"
+)
+
+func TestDatasourceCollector(t *testing.T) {
+	sqlStore := db.InitTestDB(t)
+
+	require.NoError(t, sqlStore.WithDbSession(context.Background(), func(sess *db.Session) error {
+		for _, name := range []string{"loki", "prometheus"} {
+			_, err := sess.Table("data_source").Insert(&datasources.DataSource{
+				OrgID:          1,
+				UID:            name,
+				Name:           name,
+				Type:           name,
+				Access:         datasources.DS_ACCESS_PROXY,
+				URL:            "https://admin:hunter2@" + name + ".example.com",
+				Password:       "hunter2",
+				JsonData:       simplejson.NewFromAny(map[string]interface{}{"httpHeaderName1": "X-Token", "apiKey": "hunter2"}),
+				SecureJsonData: map[string][]byte{"httpHeaderValue1": []byte("hunter2")},
+				Created:        time.Now(),
+				Updated:        time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	sp := newSpool(t.TempDir(), newRedactor(defaultRedactKeys), -1)
+	require.NoError(t, datasourceCollector(sqlStore).StreamFn(context.Background(), sp))
+	files, _, err := sp.close()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "datasources.json", files[0].name)
+	data, err := files[0].read()
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "hunter2")
+
+	var infos []map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &infos))
+	require.Len(t, infos, 2)
+	require.Equal(t, "loki", infos[0]["name"])
+	require.Equal(t, "https://<redacted>@loki.example.com", infos[0]["url"])
+	require.Equal(t, redactedValue, infos[0]["password"])
+	require.Equal(t, map[string]interface{}{"httpHeaderValue1": redactedValue}, infos[0]["secure_json_fields"])
+}
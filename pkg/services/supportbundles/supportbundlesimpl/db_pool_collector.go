@@ -0,0 +1,93 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+const (
+	dbPoolSamples        = 5
+	dbPoolSampleInterval = time.Second
+)
+
+// dbPoolSample is a snapshot of the database connection pool statistics.
+type dbPoolSample struct {
+	Time               time.Time `json:"time"`
+	MaxOpenConnections int       `json:"max_open_connections"` // MaxOpenConnections is the configured maximum, 0 means unlimited.
+	OpenConnections    int       `json:"open_connections"`
+	InUse              int       `json:"in_use"`
+	Idle               int       `json:"idle"`
+	WaitCount          int64     `json:"wait_count"` // WaitCount is the total number of connections waited for.
+	WaitDurationMs     int64     `json:"wait_duration_ms"`
+	MaxIdleClosed      int64     `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64     `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64     `json:"max_lifetime_closed"`
+}
+
+func dbPoolCollector(sqlStore db.DB) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "db-pool",
+		DisplayName:       "Database connection pool",
+		Description:       "Database connection pool statistics sampled over a few seconds",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			var sqlDB *sql.DB
+			if err := sqlStore.WithDbSession(ctx, func(sess *db.Session) error {
+				sqlDB = sess.DB().DB
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+
+			samples, err := sampleDBPool(ctx, sqlDB, dbPoolSamples, dbPoolSampleInterval)
+			if err != nil {
+				return nil, err
+			}
+
+			data, err := json.Marshal(samples)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "db-pool.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+// sampleDBPool takes n samples of the pool statistics, interval apart, so that transient spikes are visible.
+func sampleDBPool(ctx context.Context, sqlDB *sql.DB, n int, interval time.Duration) ([]dbPoolSample, error) {
+	samples := make([]dbPoolSample, 0, n)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+
+		stats := sqlDB.Stats()
+		samples = append(samples, dbPoolSample{
+			Time:               time.Now().UTC(),
+			MaxOpenConnections: stats.MaxOpenConnections,
+			OpenConnections:    stats.OpenConnections,
+			InUse:              stats.InUse,
+			Idle:               stats.Idle,
+			WaitCount:          stats.WaitCount,
+			WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+			MaxIdleClosed:      stats.MaxIdleClosed,
+			MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+			MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+		})
+	}
+	return samples, nil
+}
@@ -0,0 +1,36 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+)
+
+func TestSampleDBPool(t *testing.T) {
+	sqlStore := db.InitTestDB(t)
+
+	var sqlDB *sql.DB
+	require.NoError(t, sqlStore.WithDbSession(context.Background(), func(sess *db.Session) error {
+		sqlDB = sess.DB().DB
+		return nil
+	}))
+
+	samples, err := sampleDBPool(context.Background(), sqlDB, 3, 10*time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, samples, 3)
+	require.True(t, samples[2].Time.After(samples[0].Time))
+	require.Equal(t, sqlDB.Stats().MaxOpenConnections, samples[0].MaxOpenConnections)
+
+	t.Run("stops sampling once the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := sampleDBPool(ctx, sqlDB, 3, time.Minute)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
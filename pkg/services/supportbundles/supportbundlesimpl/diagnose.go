@@ -0,0 +1,90 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+const (
+	// symptomTimeout bounds each check of the diagnosis, so that a hung
+	// database doesn't hold up the creation of the bundle for long.
+	symptomTimeout = 2 * time.Second
+	// highGoroutineCount is the number of goroutines above which goroutines are
+	// likely leaking or blocked.
+	highGoroutineCount = 10000
+)
+
+// symptom is a problem looked for by diagnose, and the collectors that help
+// investigate it. detect returns why the problem was detected, or false.
+type symptom struct {
+	name       string
+	collectors []string
+	detect     func(ctx context.Context) (string, bool)
+}
+
+// defaultSymptoms are the problems looked for when smart collection is enabled.
+func defaultSymptoms(sql db.DB) []symptom {
+	return []symptom{
+		{
+			name:       "database unreachable",
+			collectors: []string{"db", "db-pool", "migrations"},
+			detect: func(ctx context.Context) (string, bool) {
+				err := sql.WithDbSession(ctx, func(sess *db.Session) error {
+					_, err := sess.Exec("SELECT 1")
+					return err
+				})
+				if err != nil {
+					return fmt.Sprintf("the database didn't respond to a ping: %s", err), true
+				}
+				return "", false
+			},
+		},
+		{
+			name:       "high goroutine count",
+			collectors: []string{"goroutine-profile", "runtime-sampler"},
+			detect: func(ctx context.Context) (string, bool) {
+				if n := runtime.NumGoroutine(); n > highGoroutineCount {
+					return fmt.Sprintf("%d goroutines are running, more than %d", n, highGoroutineCount), true
+				}
+				return "", false
+			},
+		},
+	}
+}
+
+// diagnose looks for the symptoms and returns the collectors to add to a bundle
+// because of the ones detected, leaving out those already in collectors, included
+// by default or that aren't available.
+func (s *Service) diagnose(ctx context.Context, collectors []string) []supportbundles.AutoAddedCollector {
+	selected := make(map[string]bool, len(collectors))
+	for _, uid := range collectors {
+		selected[uid] = true
+	}
+	registered := s.bundleRegistry.Collectors()
+
+	var added []supportbundles.AutoAddedCollector
+	for _, symptom := range s.symptoms {
+		detectCtx, cancel := context.WithTimeout(ctx, symptomTimeout)
+		reason, detected := symptom.detect(detectCtx)
+		cancel()
+		if !detected {
+			continue
+		}
+
+		s.log.Info("Support bundle diagnosis detected a problem", "symptom", symptom.name, "reason", reason)
+		for _, uid := range symptom.collectors {
+			collector, ok := registered[uid]
+			if !ok || selected[uid] || collector.IncludedByDefault || s.isCollectorDisabled(uid) {
+				continue
+			}
+			selected[uid] = true
+			added = append(added, supportbundles.AutoAddedCollector{UID: uid, Reason: reason})
+		}
+	}
+	return added
+}
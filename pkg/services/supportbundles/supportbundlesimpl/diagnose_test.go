@@ -0,0 +1,69 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestService_diagnose(t *testing.T) {
+	item := func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "item.txt", FileBytes: []byte("item")}, nil
+	}
+	optional := func(uid string) supportbundles.Collector {
+		c := newTestCollector(uid, item)
+		c.IncludedByDefault = false
+		return c
+	}
+	s := newTestService(t, newTestCollector("basic", item), optional("db"), optional("db-pool"), optional("goroutine-profile"), optional("tls"))
+	s.disabledCollectors = map[string]*supportbundles.Collector{"db-pool": nil}
+
+	detected := func(reason string) func(context.Context) (string, bool) {
+		return func(context.Context) (string, bool) { return reason, true }
+	}
+	s.symptoms = []symptom{
+		{name: "database unreachable", collectors: []string{"basic", "db", "db-pool", "migrations"}, detect: detected("the database didn't respond")},
+		{name: "healthy", collectors: []string{"tls"}, detect: func(context.Context) (string, bool) { return "", false }},
+		{name: "high goroutine count", collectors: []string{"goroutine-profile", "db"}, detect: detected("too many goroutines")},
+	}
+
+	t.Run("adds the available collectors of detected symptoms", func(t *testing.T) {
+		require.Equal(t, []supportbundles.AutoAddedCollector{
+			{UID: "db", Reason: "the database didn't respond"},
+			{UID: "goroutine-profile", Reason: "too many goroutines"},
+		}, s.diagnose(context.Background(), nil))
+
+		require.Equal(t, []supportbundles.AutoAddedCollector{
+			{UID: "goroutine-profile", Reason: "too many goroutines"},
+		}, s.diagnose(context.Background(), []string{"db"}))
+	})
+
+	t.Run("records the auto-added collectors in the manifest", func(t *testing.T) {
+		bundle, err := s.create(context.Background(), &user.SignedInUser{Login: "admin"}, createOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"basic", "db", "goroutine-profile"}, bundle.Collectors)
+		require.Len(t, bundle.AutoAddedCollectors, 2)
+		require.Eventually(t, func() bool { return len(s.creationSlots) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+		stored, err := s.store.Get(context.Background(), bundle.UID)
+		require.NoError(t, err)
+		var m manifest
+		require.NoError(t, json.Unmarshal(readBundle(t, stored.TarBytes)["/bundle/"+manifestFilename], &m))
+		require.Equal(t, bundle.AutoAddedCollectors, m.AutoAddedCollectors)
+	})
+}
+
+func TestDefaultSymptoms(t *testing.T) {
+	symptoms := defaultSymptoms(db.InitTestDB(t))
+	for _, symptom := range symptoms {
+		_, detected := symptom.detect(context.Background())
+		require.False(t, detected, symptom.name)
+	}
+}
@@ -0,0 +1,242 @@
+package supportbundlesimpl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+var ErrBundleNotComparable = errors.New("support bundle can't be compared")
+
+// diffableFile is the output of a collector that can be compared between two bundles.
+// flatten turns the file into its entries, keyed by a stable identifier.
+type diffableFile struct {
+	collector string
+	filename  string
+	flatten   func(data []byte) (map[string]json.RawMessage, error)
+}
+
+// diffableFiles are the structured outputs compared by diff, binary profiles and
+// free-form files such as logs are left out.
+var diffableFiles = []diffableFile{
+	{collector: "settings", filename: "settings.json", flatten: flattenSettings},
+	{collector: "feature-flags", filename: "feature-flags.json", flatten: flattenList("name")},
+	{collector: "plugins", filename: "plugins.json", flatten: flattenList("ID")},
+}
+
+type diffEntry struct {
+	Key string          `json:"key"`
+	A   json.RawMessage `json:"a,omitempty"`
+	B   json.RawMessage `json:"b,omitempty"`
+}
+
+type fileDiff struct {
+	Collector string `json:"collector"`
+	Filename  string `json:"filename"`
+	// Skipped is why the file wasn't compared, e.g. because a bundle doesn't include it.
+	Skipped string      `json:"skipped,omitempty"`
+	Added   []diffEntry `json:"added"`
+	Removed []diffEntry `json:"removed"`
+	Changed []diffEntry `json:"changed"`
+}
+
+// bundleDiff compares bundle B to bundle A: entries only in B are added, entries only in A are removed.
+type bundleDiff struct {
+	A                  string     `json:"a"`
+	B                  string     `json:"b"`
+	AGrafanaVersion    string     `json:"aGrafanaVersion"`
+	BGrafanaVersion    string     `json:"bGrafanaVersion"`
+	Files              []fileDiff `json:"files"`
+	HasDifferences     bool       `json:"hasDifferences"`
+	ComparedCollectors []string   `json:"comparedCollectors"`
+}
+
+// diffContents are the manifest and the diffable files of a bundle.
+type diffContents struct {
+	manifest manifest
+	files    map[string][]byte
+}
+
+// diff compares the settings, feature flags and plugins recorded in two bundles.
+func (s *Service) diff(ctx context.Context, a, b *supportbundles.Bundle) (*bundleDiff, error) {
+	contentsA, err := s.readDiffContents(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	contentsB, err := s.readDiffContents(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &bundleDiff{
+		A:                  a.UID,
+		B:                  b.UID,
+		AGrafanaVersion:    contentsA.manifest.GrafanaVersion,
+		BGrafanaVersion:    contentsB.manifest.GrafanaVersion,
+		Files:              make([]fileDiff, 0, len(diffableFiles)),
+		ComparedCollectors: make([]string, 0, len(diffableFiles)),
+	}
+	for _, file := range diffableFiles {
+		d := compareFile(file, contentsA, contentsB)
+		if d.Skipped == "" {
+			result.ComparedCollectors = append(result.ComparedCollectors, file.collector)
+		}
+		result.HasDifferences = result.HasDifferences || len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Changed) > 0
+		result.Files = append(result.Files, d)
+	}
+
+	return result, nil
+}
+
+// readDiffContents reads the manifest and the diffable files of a bundle in a single pass over its archive.
+func (s *Service) readDiffContents(ctx context.Context, bundle *supportbundles.Bundle) (*diffContents, error) {
+	if !bundle.State.HasArchive() {
+		return nil, fmt.Errorf("%w: bundle %s has no archive", ErrBundleNotComparable, bundle.UID)
+	}
+	if bundle.UploadedTo != "" {
+		return nil, fmt.Errorf("%w: bundle %s was uploaded to %s", ErrBundleNotComparable, bundle.UID, bundle.UploadedTo)
+	}
+
+	wanted := map[string]bool{manifestFilename: true}
+	for _, file := range diffableFiles {
+		wanted[file.filename] = true
+	}
+
+	contents := &diffContents{files: map[string][]byte{}}
+	err := s.walkBundle(ctx, bundle, func(name string, r io.Reader, size int64) error {
+		if !wanted[name] {
+			return nil
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		contents.files[name] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(contents.files[manifestFilename], &contents.manifest); err != nil {
+		// bundles created before the manifest was added don't record which collectors succeeded
+		return nil, fmt.Errorf("%w: bundle %s has no manifest", ErrBundleNotComparable, bundle.UID)
+	}
+	return contents, nil
+}
+
+// collected returns the output of a collector if it succeeded in the bundle.
+func (c *diffContents) collected(file diffableFile) ([]byte, bool) {
+	for _, report := range c.manifest.Collectors {
+		if report.UID == file.collector && report.hasFile(file.filename) && report.Success && !report.Truncated {
+			data, ok := c.files[file.filename]
+			return data, ok
+		}
+	}
+	return nil, false
+}
+
+func compareFile(file diffableFile, a, b *diffContents) fileDiff {
+	d := fileDiff{
+		Collector: file.collector,
+		Filename:  file.filename,
+		Added:     []diffEntry{},
+		Removed:   []diffEntry{},
+		Changed:   []diffEntry{},
+	}
+
+	dataA, okA := a.collected(file)
+	dataB, okB := b.collected(file)
+	switch {
+	case !okA && !okB:
+		d.Skipped = "neither bundle includes the complete output of the collector"
+		return d
+	case !okA:
+		d.Skipped = "bundle a doesn't include the complete output of the collector"
+		return d
+	case !okB:
+		d.Skipped = "bundle b doesn't include the complete output of the collector"
+		return d
+	}
+
+	entriesA, err := file.flatten(dataA)
+	if err != nil {
+		d.Skipped = fmt.Sprintf("failed to read bundle a: %s", err)
+		return d
+	}
+	entriesB, err := file.flatten(dataB)
+	if err != nil {
+		d.Skipped = fmt.Sprintf("failed to read bundle b: %s", err)
+		return d
+	}
+
+	for key, valueA := range entriesA {
+		valueB, ok := entriesB[key]
+		if !ok {
+			d.Removed = append(d.Removed, diffEntry{Key: key, A: valueA})
+		} else if !bytes.Equal(valueA, valueB) {
+			d.Changed = append(d.Changed, diffEntry{Key: key, A: valueA, B: valueB})
+		}
+	}
+	for key, valueB := range entriesB {
+		if _, ok := entriesA[key]; !ok {
+			d.Added = append(d.Added, diffEntry{Key: key, B: valueB})
+		}
+	}
+	for _, entries := range [][]diffEntry{d.Added, d.Removed, d.Changed} {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	}
+
+	return d
+}
+
+// flattenSettings keys the settings by section.key.
+func flattenSettings(data []byte) (map[string]json.RawMessage, error) {
+	var sections map[string]map[string]string
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return nil, err
+	}
+
+	entries := map[string]json.RawMessage{}
+	for section, keys := range sections {
+		for key, value := range keys {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			entries[section+"."+key] = encoded
+		}
+	}
+	return entries, nil
+}
+
+// flattenList keys the objects of a JSON array by their field. The objects are
+// re-encoded so that their fields are sorted and equal objects compare equal.
+func flattenList(field string) func(data []byte) (map[string]json.RawMessage, error) {
+	return func(data []byte) (map[string]json.RawMessage, error) {
+		var list []map[string]any
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+
+		entries := make(map[string]json.RawMessage, len(list))
+		for _, entry := range list {
+			key, ok := entry[field].(string)
+			if !ok || key == "" {
+				return nil, fmt.Errorf("an entry has no %s", field)
+			}
+			encoded, err := json.Marshal(entry)
+			if err != nil {
+				return nil, err
+			}
+			entries[key] = encoded
+		}
+		return entries, nil
+	}
+}
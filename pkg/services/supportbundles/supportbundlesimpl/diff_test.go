@@ -0,0 +1,119 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestService_diff(t *testing.T) {
+	outputs := map[string]string{}
+	fileCollector := func(uid, filename string) supportbundles.Collector {
+		return newTestCollector(uid, func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			if outputs[uid] == "" {
+				return nil, errors.New("collector failed")
+			}
+			return &supportbundles.SupportItem{Filename: filename, FileBytes: []byte(outputs[uid])}, nil
+		})
+	}
+	s := newTestService(t,
+		fileCollector("settings", "settings.json"),
+		fileCollector("feature-flags", "feature-flags.json"),
+		fileCollector("plugins", "plugins.json"),
+		fileCollector("basic", "basic.json"),
+	)
+
+	createBundle := func(t *testing.T, collectors ...string) *supportbundles.Bundle {
+		t.Helper()
+		bundle, err := s.create(context.Background(), &user.SignedInUser{Login: "admin"}, createOptions{Collectors: collectors})
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			b, err := s.store.Get(context.Background(), bundle.UID)
+			return err == nil && b.State.HasArchive()
+		}, 5*time.Second, 10*time.Millisecond)
+		require.Eventually(t, func() bool { return len(s.creationSlots) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+		stored, err := s.store.Get(context.Background(), bundle.UID)
+		require.NoError(t, err)
+		return stored
+	}
+
+	outputs["settings"] = `{"server":{"http_port":"3000","domain":"localhost"},"log":{"level":"info"}}`
+	outputs["feature-flags"] = `[{"name":"publicDashboards","enabled":false,"source":"default"},{"name":"topnav","enabled":true,"source":"config"}]`
+	outputs["plugins"] = `[{"ID":"grafana-clock-panel","Version":"2.1.0","Enabled":true}]`
+	outputs["basic"] = `{"version":"9.3.0"}`
+	before := createBundle(t, "settings", "feature-flags", "plugins", "basic")
+
+	outputs["settings"] = `{"server":{"http_port":"3001","domain":"localhost"},"log":{"level":"info"},"auth":{"disable_login_form":"true"}}`
+	outputs["feature-flags"] = `[{"source":"default","enabled":true,"name":"publicDashboards"}]`
+	outputs["plugins"] = `[{"ID":"grafana-clock-panel","Version":"2.1.0","Enabled":true},{"ID":"grafana-polystat-panel","Version":"1.2.0","Enabled":true}]`
+	outputs["basic"] = `{"version":"9.4.0"}`
+	after := createBundle(t, "settings", "feature-flags", "plugins", "basic")
+
+	t.Run("compares the structured collectors", func(t *testing.T) {
+		diff, err := s.diff(context.Background(), before, after)
+		require.NoError(t, err)
+		require.True(t, diff.HasDifferences)
+		require.Equal(t, []string{"settings", "feature-flags", "plugins"}, diff.ComparedCollectors)
+
+		files := map[string]fileDiff{}
+		for _, f := range diff.Files {
+			files[f.Collector] = f
+		}
+		require.NotContains(t, files, "basic")
+
+		settings := files["settings"]
+		require.Equal(t, []diffEntry{{Key: "auth.disable_login_form", B: json.RawMessage(`"true"`)}}, settings.Added)
+		require.Empty(t, settings.Removed)
+		require.Equal(t, []diffEntry{{Key: "server.http_port", A: json.RawMessage(`"3000"`), B: json.RawMessage(`"3001"`)}}, settings.Changed)
+
+		flags := files["feature-flags"]
+		require.Empty(t, flags.Added)
+		require.Len(t, flags.Removed, 1)
+		require.Equal(t, "topnav", flags.Removed[0].Key)
+		// the order of the fields doesn't matter
+		require.Len(t, flags.Changed, 1)
+		require.Equal(t, "publicDashboards", flags.Changed[0].Key)
+		require.JSONEq(t, `{"name":"publicDashboards","enabled":true,"source":"default"}`, string(flags.Changed[0].B))
+
+		plugins := files["plugins"]
+		require.Len(t, plugins.Added, 1)
+		require.Equal(t, "grafana-polystat-panel", plugins.Added[0].Key)
+		require.Empty(t, plugins.Removed)
+		require.Empty(t, plugins.Changed)
+	})
+
+	t.Run("identical bundles have no differences", func(t *testing.T) {
+		diff, err := s.diff(context.Background(), after, after)
+		require.NoError(t, err)
+		require.False(t, diff.HasDifferences)
+	})
+
+	t.Run("skips the collectors that failed in a bundle", func(t *testing.T) {
+		outputs["feature-flags"], outputs["plugins"] = "", ""
+		partial := createBundle(t, "settings", "feature-flags", "plugins")
+
+		diff, err := s.diff(context.Background(), before, partial)
+		require.NoError(t, err)
+		require.Equal(t, []string{"settings"}, diff.ComparedCollectors)
+		for _, f := range diff.Files {
+			if f.Collector != "settings" {
+				require.Equal(t, "bundle b doesn't include the complete output of the collector", f.Skipped)
+			}
+		}
+	})
+
+	t.Run("bundles without an archive can't be compared", func(t *testing.T) {
+		pending := *after
+		pending.State = supportbundles.StatePending
+		_, err := s.diff(context.Background(), before, &pending)
+		require.ErrorIs(t, err, ErrBundleNotComparable)
+	})
+}
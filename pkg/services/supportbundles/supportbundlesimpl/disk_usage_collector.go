@@ -0,0 +1,111 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// diskUsage is the size of the filesystem backing a path, in bytes. Available
+// is what unprivileged users can still write, it may be lower than Free.
+type diskUsage struct {
+	Total     uint64
+	Free      uint64
+	Available uint64
+}
+
+// diskUsageCollector reports the space left on the filesystems backing the
+// paths Grafana writes to.
+func diskUsageCollector(cfg *setting.Cfg) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "disk-usage",
+		DisplayName:       "Disk usage",
+		Description:       "Free and used space of the filesystems backing the data, database, provisioning and support bundle paths",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type pathUsage struct {
+				Name           string  `json:"name"`
+				Path           string  `json:"path"`
+				Size           *int64  `json:"size_bytes,omitempty"` // Size is set for files.
+				TotalBytes     uint64  `json:"total_bytes"`
+				UsedBytes      uint64  `json:"used_bytes"`
+				FreeBytes      uint64  `json:"free_bytes"`
+				AvailableBytes uint64  `json:"available_bytes"`
+				UsedPercent    float64 `json:"used_percent"`
+				Error          string  `json:"error,omitempty"`
+			}
+
+			paths := []struct{ name, path string }{
+				{name: "data", path: cfg.DataPath},
+			}
+			if dbPath, ok := sqlitePath(cfg); ok {
+				paths = append(paths, struct{ name, path string }{name: "sqlite", path: dbPath})
+			}
+			paths = append(paths, struct{ name, path string }{name: "provisioning", path: cfg.ProvisioningPath})
+			if cfg.SectionWithEnvOverrides("support_bundles").Key("storage").MustString("kvstore") == "filesystem" {
+				paths = append(paths, struct{ name, path string }{name: "support-bundles", path: bundleStoragePath(cfg)})
+			}
+
+			usages := make([]pathUsage, 0, len(paths))
+			for _, p := range paths {
+				if p.path == "" {
+					continue
+				}
+
+				usage := pathUsage{Name: p.name, Path: p.path}
+				info, err := os.Stat(p.path)
+				if err == nil && !info.IsDir() {
+					size := info.Size()
+					usage.Size = &size
+				}
+				if err == nil {
+					var disk diskUsage
+					if disk, err = statDisk(p.path); err == nil {
+						usage.TotalBytes = disk.Total
+						usage.FreeBytes = disk.Free
+						usage.AvailableBytes = disk.Available
+						usage.UsedBytes = disk.Total - disk.Free
+						if disk.Total > 0 {
+							usage.UsedPercent = float64(usage.UsedBytes) * 100 / float64(disk.Total)
+						}
+					}
+				}
+				if err != nil {
+					usage.Error = err.Error()
+				}
+				usages = append(usages, usage)
+			}
+
+			data, err := json.Marshal(usages)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "disk-usage.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+// sqlitePath returns the path of the SQLite database file, resolved the same
+// way as by the SQL store, if SQLite is used.
+func sqlitePath(cfg *setting.Cfg) (string, bool) {
+	section := cfg.Raw.Section("database")
+	if section.Key("type").String() != migrator.SQLite {
+		return "", false
+	}
+
+	path := section.Key("path").MustString("data/grafana.db")
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.DataPath, path)
+	}
+	return path, true
+}
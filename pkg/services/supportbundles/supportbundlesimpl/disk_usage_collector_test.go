@@ -0,0 +1,62 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestDiskUsageCollector(t *testing.T) {
+	type pathUsage struct {
+		Name       string `json:"name"`
+		Path       string `json:"path"`
+		Size       *int64 `json:"size_bytes"`
+		TotalBytes uint64 `json:"total_bytes"`
+		Error      string `json:"error"`
+	}
+
+	dataPath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dataPath, "grafana.db"), []byte("sqlite"), 0o600))
+
+	cfg := setting.NewCfg()
+	cfg.DataPath = dataPath
+	cfg.ProvisioningPath = filepath.Join(dataPath, "missing")
+	cfg.Raw.Section("database").Key("type").SetValue("sqlite3")
+	cfg.Raw.Section("database").Key("path").SetValue("grafana.db")
+	cfg.Raw.Section("support_bundles").Key("storage").SetValue("filesystem")
+
+	item, err := diskUsageCollector(cfg).Fn(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "disk-usage.json", item.Filename)
+
+	var usages []pathUsage
+	require.NoError(t, json.Unmarshal(item.FileBytes, &usages))
+	require.Len(t, usages, 4)
+
+	require.Equal(t, "data", usages[0].Name)
+	require.Equal(t, dataPath, usages[0].Path)
+	require.Nil(t, usages[0].Size)
+
+	require.Equal(t, "sqlite", usages[1].Name)
+	require.Equal(t, filepath.Join(dataPath, "grafana.db"), usages[1].Path)
+	require.NotNil(t, usages[1].Size)
+	require.Equal(t, int64(6), *usages[1].Size)
+
+	require.Equal(t, "provisioning", usages[2].Name)
+	require.NotEmpty(t, usages[2].Error)
+
+	require.Equal(t, "support-bundles", usages[3].Name)
+	require.Equal(t, filepath.Join(dataPath, "support-bundles"), usages[3].Path)
+
+	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		require.Empty(t, usages[0].Error)
+		require.NotZero(t, usages[0].TotalBytes)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package supportbundlesimpl
+
+import "errors"
+
+var errDiskUsageUnsupported = errors.New("disk usage is not supported on this platform")
+
+func statDisk(path string) (diskUsage, error) {
+	return diskUsage{}, errDiskUsageUnsupported
+}
@@ -0,0 +1,22 @@
+//go:build linux || darwin || freebsd
+
+package supportbundlesimpl
+
+import (
+	"syscall"
+)
+
+func statDisk(path string) (diskUsage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return diskUsage{}, err
+	}
+
+	//nolint:unconvert // the field types differ between platforms
+	blockSize := uint64(stat.Bsize)
+	return diskUsage{
+		Total:     uint64(stat.Blocks) * blockSize,
+		Free:      uint64(stat.Bfree) * blockSize,
+		Available: uint64(stat.Bavail) * blockSize,
+	}, nil
+}
@@ -0,0 +1,27 @@
+package supportbundlesimpl
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+func statDisk(path string) (diskUsage, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return diskUsage{}, err
+	}
+
+	var usage diskUsage
+	ret, _, err := getDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&usage.Available)),
+		uintptr(unsafe.Pointer(&usage.Total)),
+		uintptr(unsafe.Pointer(&usage.Free)),
+	)
+	if ret == 0 {
+		return diskUsage{}, err
+	}
+	return usage, nil
+}
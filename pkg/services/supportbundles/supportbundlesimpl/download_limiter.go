@@ -0,0 +1,57 @@
+package supportbundlesimpl
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+// downloadLimiter throttles bundle downloads with a token bucket per user, so
+// that a single user can't saturate the bandwidth by pulling bundles repeatedly.
+type downloadLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// newDownloadLimiter allows perHour downloads per user and hour, all of which
+// can happen at once. It returns nil, disabling the limit, if perHour isn't positive.
+func newDownloadLimiter(perHour int) *downloadLimiter {
+	if perHour <= 0 {
+		return nil
+	}
+
+	return &downloadLimiter{
+		limit:    rate.Every(time.Hour / time.Duration(perHour)),
+		burst:    perHour,
+		limiters: map[string]*rate.Limiter{},
+	}
+}
+
+// allow reports whether usr can download a bundle at now, and otherwise how long until they can.
+func (l *downloadLimiter) allow(usr *user.SignedInUser, now time.Time) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	key := userKey(usr)
+	l.mu.Lock()
+	limiter, ok := l.limiters[key]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[key] = limiter
+	}
+	l.mu.Unlock()
+
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
@@ -0,0 +1,55 @@
+package supportbundlesimpl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestDownloadLimiter(t *testing.T) {
+	t.Run("disabled when the rate isn't positive", func(t *testing.T) {
+		l := newDownloadLimiter(0)
+		require.Nil(t, l)
+
+		ok, _ := l.allow(&user.SignedInUser{UserID: 1}, time.Now())
+		require.True(t, ok)
+	})
+
+	t.Run("throttles each user separately", func(t *testing.T) {
+		l := newDownloadLimiter(2)
+		now := time.Now()
+		alice := &user.SignedInUser{UserID: 1}
+		bob := &user.SignedInUser{UserID: 2}
+
+		for i := 0; i < 2; i++ {
+			ok, _ := l.allow(alice, now)
+			require.True(t, ok)
+		}
+
+		ok, wait := l.allow(alice, now)
+		require.False(t, ok)
+		require.Equal(t, 30*time.Minute, wait)
+
+		ok, _ = l.allow(bob, now)
+		require.True(t, ok)
+
+		// a rejected download doesn't consume a token
+		ok, _ = l.allow(alice, now.Add(30*time.Minute))
+		require.True(t, ok)
+	})
+
+	t.Run("api keys are throttled by key", func(t *testing.T) {
+		l := newDownloadLimiter(1)
+		now := time.Now()
+
+		ok, _ := l.allow(&user.SignedInUser{ApiKeyID: 1}, now)
+		require.True(t, ok)
+		ok, _ = l.allow(&user.SignedInUser{ApiKeyID: 2}, now)
+		require.True(t, ok)
+		ok, _ = l.allow(&user.SignedInUser{ApiKeyID: 1}, now)
+		require.False(t, ok)
+	})
+}
@@ -0,0 +1,86 @@
+package supportbundlesimpl
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+type collectorEstimate struct {
+	UID     string `json:"uid"`
+	Bytes   int    `json:"bytes"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// bundleEstimate is the result of a dry run: what a bundle with the same collectors would weigh.
+type bundleEstimate struct {
+	Collectors []collectorEstimate `json:"collectors"`
+	// TotalBytes is the uncompressed size of the bundle content.
+	TotalBytes int64 `json:"totalBytes"`
+	// ArchiveBytes is the size of the compressed archive.
+	ArchiveBytes int64 `json:"archiveBytes"`
+}
+
+// countingWriter discards what is written to it and counts the bytes.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// estimate runs the collectors like a bundle creation would, but discards their
+// output and returns its size instead of persisting anything.
+func (s *Service) estimate(ctx context.Context, collectors []string, usr *user.SignedInUser) (*bundleEstimate, error) {
+	if err := s.validateCollectors(collectors); err != nil {
+		return nil, err
+	}
+
+	// a dry run is as expensive as creating a bundle
+	select {
+	case s.creationSlots <- struct{}{}:
+	default:
+		return nil, ErrTooManyBundles
+	}
+	defer func() { <-s.creationSlots }()
+
+	ctx, cancel := context.WithTimeout(ctx, bundleCreationTimeout)
+	defer cancel()
+
+	selected, _, err := s.authorizeCollectors(ctx, usr, s.selectCollectors(collectors))
+	if err != nil {
+		return nil, err
+	}
+
+	files, reports := s.collect(ctx, selected, func(int, string) {}, nil)
+
+	manifest, err := s.manifest("", "", reports, nil)
+	if err != nil {
+		return nil, err
+	}
+	files[manifestFilename] = manifest
+
+	estimate := &bundleEstimate{Collectors: make([]collectorEstimate, 0, len(reports))}
+	for _, report := range reports {
+		estimate.Collectors = append(estimate.Collectors, collectorEstimate{
+			UID:     report.UID,
+			Bytes:   report.Size,
+			Success: report.Success,
+			Error:   report.Error,
+		})
+	}
+	for _, data := range files {
+		estimate.TotalBytes += int64(len(data))
+	}
+
+	archive := &countingWriter{}
+	if err := s.archive(files, archive); err != nil {
+		return nil, err
+	}
+	estimate.ArchiveBytes = archive.n
+
+	return estimate, nil
+}
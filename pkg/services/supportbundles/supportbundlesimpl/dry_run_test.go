@@ -0,0 +1,42 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestService_estimate(t *testing.T) {
+	ok := newTestCollector("ok", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "ok.txt", FileBytes: make([]byte, 1024)}, nil
+	})
+	failing := newTestCollector("failing", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return nil, errors.New("boom")
+	})
+
+	s := newTestService(t, ok, failing)
+
+	estimate, err := s.estimate(context.Background(), nil, &user.SignedInUser{Login: "admin"})
+	require.NoError(t, err)
+
+	require.Len(t, estimate.Collectors, 2)
+	require.Equal(t, "failing", estimate.Collectors[0].UID)
+	require.False(t, estimate.Collectors[0].Success)
+	require.Equal(t, collectorEstimate{UID: "ok", Bytes: 1024, Success: true}, estimate.Collectors[1])
+	require.Greater(t, estimate.TotalBytes, int64(1024))
+	require.Positive(t, estimate.ArchiveBytes)
+
+	// nothing is persisted and the creation slot is released
+	bundles, _, err := s.list(context.Background(), listQuery{})
+	require.NoError(t, err)
+	require.Empty(t, bundles)
+	require.Empty(t, s.creationSlots)
+
+	_, err = s.estimate(context.Background(), []string{"unknown"}, &user.SignedInUser{Login: "admin"})
+	require.ErrorIs(t, err, ErrUnknownCollector)
+}
@@ -0,0 +1,149 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// egressTestTimeout bounds the whole connectivity test, proxy included.
+const egressTestTimeout = 10 * time.Second
+
+// proxyEnvVars are the environment variables Go reads the outbound proxy from.
+var proxyEnvVars = []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy", "NO_PROXY", "no_proxy"}
+
+// egressCollector reports how outbound connections leave Grafana, for failing
+// data source queries and plugin downloads. When testConnectivity is set, a
+// request is sent to testURL, grafana.com if empty, through the proxy Grafana
+// would use.
+func egressCollector(cfg *setting.Cfg, testConnectivity bool, testURL string) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "egress",
+		DisplayName:       "Outbound proxy and connectivity",
+		Description:       "Proxy environment variables and outbound proxy settings with credentials redacted and, if enabled, an outbound connectivity test",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type secureSocksProxy struct {
+				Enabled      bool   `json:"enabled"`
+				ProxyAddress string `json:"proxy_address,omitempty"`
+				ServerName   string `json:"server_name,omitempty"`
+				RootCA       string `json:"root_ca_cert,omitempty"`
+				ClientCert   string `json:"client_cert,omitempty"`
+				// ClientKeySet is whether the path of the client key is set.
+				ClientKeySet bool `json:"client_key_set"`
+			}
+			type connectivityTest struct {
+				URL string `json:"url"`
+				// Proxy is the proxy the request was sent through, empty when sent directly.
+				Proxy      string `json:"proxy,omitempty"`
+				Success    bool   `json:"success"`
+				StatusCode int    `json:"status_code,omitempty"`
+				Duration   string `json:"duration"`
+				Error      string `json:"error,omitempty"`
+			}
+			type egress struct {
+				// Environment are the proxy variables that are set, credentials redacted.
+				Environment             map[string]string `json:"environment"`
+				SecureSocksProxy        secureSocksProxy  `json:"secure_socks_datasource_proxy"`
+				GrafanaComURL           string            `json:"grafana_com_url"`
+				GrafanaComAPIURL        string            `json:"grafana_com_api_url"`
+				PluginCatalogURL        string            `json:"plugin_catalog_url"`
+				PluginAdminEnabled      bool              `json:"plugin_admin_enabled"`
+				PluginAdminManageRemote bool              `json:"plugin_admin_external_manage_enabled"`
+				ConnectivityTest        *connectivityTest `json:"connectivity_test,omitempty"`
+			}
+
+			result := egress{
+				Environment: map[string]string{},
+				SecureSocksProxy: secureSocksProxy{
+					Enabled:      cfg.SecureSocksDSProxy.Enabled,
+					ProxyAddress: cfg.SecureSocksDSProxy.ProxyAddress,
+					ServerName:   cfg.SecureSocksDSProxy.ServerName,
+					RootCA:       cfg.SecureSocksDSProxy.RootCA,
+					ClientCert:   cfg.SecureSocksDSProxy.ClientCert,
+					ClientKeySet: cfg.SecureSocksDSProxy.ClientKey != "",
+				},
+				GrafanaComURL:           cfg.GrafanaComURL,
+				GrafanaComAPIURL:        cfg.GrafanaComAPIURL,
+				PluginCatalogURL:        cfg.PluginCatalogURL,
+				PluginAdminEnabled:      cfg.PluginAdminEnabled,
+				PluginAdminManageRemote: cfg.PluginAdminExternalManageEnabled,
+			}
+			for _, name := range proxyEnvVars {
+				if value, ok := os.LookupEnv(name); ok {
+					result.Environment[name] = redactURLCredentials(value)
+				}
+			}
+
+			// only test the connectivity if explicitly allowed, it's an outbound connection
+			if testConnectivity {
+				if testURL == "" {
+					testURL = cfg.GrafanaComURL
+				}
+				test := &connectivityTest{URL: redactURLCredentials(testURL)}
+				start := time.Now()
+				proxy, status, err := checkEgress(ctx, testURL)
+				test.Duration = time.Since(start).Round(time.Millisecond).String()
+				if proxy != nil {
+					test.Proxy = redactURLCredentials(proxy.String())
+				}
+				if err != nil {
+					test.Error = redactURLCredentials(err.Error())
+				} else {
+					test.Success, test.StatusCode = true, status
+				}
+				result.ConnectivityTest = test
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "egress.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+// checkEgress sends a HEAD request to target through the proxy set in the
+// environment, like the HTTP clients of Grafana. Any response, whatever its
+// status, means the target can be reached.
+func checkEgress(ctx context.Context, target string) (*url.URL, int, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, 0, err
+	}
+	// read on every test, unlike http.ProxyFromEnvironment which reads the environment once
+	proxy, err := httpproxy.FromEnvironment().ProxyFunc()(u)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, egressTestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return proxy, 0, err
+	}
+
+	transport := &http.Transport{Proxy: http.ProxyURL(proxy)}
+	defer transport.CloseIdleConnections()
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return proxy, 0, err
+	}
+	_ = resp.Body.Close()
+	return proxy, resp.StatusCode, nil
+}
@@ -0,0 +1,49 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// defaultMaxRetention is how long after their creation bundles can be kept at most
+// by extending their expiry.
+const defaultMaxRetention = 30 * 24 * time.Hour
+
+var ErrInvalidExtension = errors.New("invalid support bundle expiry extension")
+
+// extend pushes the expiry of a bundle out by the given duration, counted from
+// now if the bundle has already expired, so that an ongoing investigation isn't
+// interrupted by the cleanup. Bundles can't be kept longer than maxRetention after
+// their creation.
+func (s *Service) extend(ctx context.Context, uid string, by time.Duration) (*supportbundles.Bundle, error) {
+	if by <= 0 {
+		return nil, fmt.Errorf("%w: the extension must be positive", ErrInvalidExtension)
+	}
+
+	bundle, err := s.store.Get(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve support bundle with UID %s: %w", uid, err)
+	}
+
+	from := time.Unix(bundle.ExpiresAt, 0)
+	if now := time.Now(); from.Before(now) {
+		from = now
+	}
+	expiresAt := from.Add(by)
+	if limit := time.Unix(bundle.CreatedAt, 0).Add(s.maxRetention); expiresAt.After(limit) {
+		return nil, fmt.Errorf("%w: bundles can be kept at most %s after their creation, until %s",
+			ErrInvalidExtension, s.maxRetention, limit.UTC().Format(time.RFC3339))
+	}
+
+	if err := s.store.UpdateMetadata(ctx, uid, func(b *supportbundles.Bundle) {
+		b.ExpiresAt = expiresAt.Unix()
+	}); err != nil {
+		return nil, err
+	}
+	bundle.ExpiresAt = expiresAt.Unix()
+	return bundle, nil
+}
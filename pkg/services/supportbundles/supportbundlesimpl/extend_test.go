@@ -0,0 +1,61 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestService_extend(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	s.maxRetention = 10 * 24 * time.Hour
+
+	bundle, err := s.store.Create(ctx, &user.SignedInUser{Login: "admin"}, 24*time.Hour)
+	require.NoError(t, err)
+
+	t.Run("pushes the expiry out", func(t *testing.T) {
+		extended, err := s.extend(ctx, bundle.UID, 48*time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, bundle.ExpiresAt+int64((48*time.Hour).Seconds()), extended.ExpiresAt)
+
+		stored, err := s.store.Get(ctx, bundle.UID)
+		require.NoError(t, err)
+		require.Equal(t, extended.ExpiresAt, stored.ExpiresAt)
+	})
+
+	t.Run("rejects extensions past the maximum retention", func(t *testing.T) {
+		_, err := s.extend(ctx, bundle.UID, 8*24*time.Hour)
+		require.ErrorIs(t, err, ErrInvalidExtension)
+
+		stored, err := s.store.Get(ctx, bundle.UID)
+		require.NoError(t, err)
+		require.Equal(t, bundle.ExpiresAt+int64((48*time.Hour).Seconds()), stored.ExpiresAt)
+	})
+
+	t.Run("rejects non positive extensions", func(t *testing.T) {
+		_, err := s.extend(ctx, bundle.UID, 0)
+		require.ErrorIs(t, err, ErrInvalidExtension)
+	})
+
+	t.Run("extends expired bundles from now", func(t *testing.T) {
+		require.NoError(t, s.store.UpdateMetadata(ctx, bundle.UID, func(b *supportbundles.Bundle) {
+			b.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+		}))
+
+		extended, err := s.extend(ctx, bundle.UID, time.Hour)
+		require.NoError(t, err)
+		require.InDelta(t, time.Now().Add(time.Hour).Unix(), extended.ExpiresAt, 5)
+	})
+
+	t.Run("fails for unknown bundles", func(t *testing.T) {
+		_, err := s.extend(ctx, "unknown", time.Hour)
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrInvalidExtension)
+	})
+}
@@ -0,0 +1,76 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+const (
+	featureFlagSourceDefault = "default"
+	featureFlagSourceConfig  = "config"
+)
+
+func featureFlagCollector(features *featuremgmt.FeatureManager) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "feature-flags",
+		DisplayName:       "Feature flags",
+		Description:       "Every feature flag, whether it is enabled and where its value comes from",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type featureFlag struct {
+				Name    string `json:"name"`
+				Enabled bool   `json:"enabled"`
+				Source  string `json:"source"` // Source is config when the value was set in the configuration, default otherwise.
+				State   string `json:"state,omitempty"`
+			}
+
+			flags := []featureFlag{}
+			if features != nil {
+				seen := map[string]bool{}
+				add := func(name, state string) {
+					if seen[name] {
+						return
+					}
+					seen[name] = true
+
+					source := featureFlagSourceDefault
+					if features.IsConfigured(name) {
+						source = featureFlagSourceConfig
+					}
+					flags = append(flags, featureFlag{
+						Name:    name,
+						Enabled: features.IsEnabled(name),
+						Source:  source,
+						State:   state,
+					})
+				}
+
+				for _, flag := range features.GetFlags() {
+					add(flag.Name, flag.State.String())
+				}
+				// flags enabled without being registered, e.g. in tests
+				for name := range features.GetEnabled(ctx) {
+					add(name, "")
+				}
+			}
+			sort.Slice(flags, func(i, j int) bool {
+				return flags[i].Name < flags[j].Name
+			})
+
+			data, err := json.Marshal(flags)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "feature-flags.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
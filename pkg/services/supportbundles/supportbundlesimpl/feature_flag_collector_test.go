@@ -0,0 +1,42 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+)
+
+func TestFeatureFlagCollector(t *testing.T) {
+	type featureFlag struct {
+		Name    string `json:"name"`
+		Enabled bool   `json:"enabled"`
+		Source  string `json:"source"`
+	}
+
+	collect := func(t *testing.T, features *featuremgmt.FeatureManager) []featureFlag {
+		t.Helper()
+		item, err := featureFlagCollector(features).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "feature-flags.json", item.Filename)
+
+		var flags []featureFlag
+		require.NoError(t, json.Unmarshal(item.FileBytes, &flags))
+		return flags
+	}
+
+	t.Run("records enabled flags", func(t *testing.T) {
+		flags := collect(t, featuremgmt.WithFeatures("b", "a", "c", false))
+		require.Equal(t, []featureFlag{
+			{Name: "a", Enabled: true, Source: featureFlagSourceDefault},
+			{Name: "b", Enabled: true, Source: featureFlagSourceDefault},
+		}, flags)
+	})
+
+	t.Run("does not fail without a feature manager", func(t *testing.T) {
+		require.Empty(t, collect(t, nil))
+	})
+}
@@ -0,0 +1,119 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// httpServerCollector reports how the HTTP server of Grafana is configured, for
+// 502s behind reverse proxies and rejected uploads. The values are read from the
+// configuration, nothing is sent over the network.
+func httpServerCollector(cfg *setting.Cfg) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "http-server",
+		DisplayName:       "HTTP server",
+		Description:       "Protocol, listen address, timeouts, limits and compression of the Grafana HTTP server",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type timeouts struct {
+				// Read is the read_timeout, zero means requests never time out.
+				Read string `json:"read"`
+				// Write and Idle aren't configurable, the server never times them out.
+				Write string `json:"write"`
+				Idle  string `json:"idle"`
+			}
+			type dataProxy struct {
+				Timeout               string `json:"timeout"`
+				DialTimeout           string `json:"dial_timeout"`
+				TLSHandshakeTimeout   string `json:"tls_handshake_timeout"`
+				ExpectContinueTimeout string `json:"expect_continue_timeout"`
+				IdleConnTimeout       string `json:"idle_conn_timeout"`
+				KeepAlive             string `json:"keep_alive"`
+				MaxConnsPerHost       int    `json:"max_conns_per_host"`
+				MaxIdleConns          int    `json:"max_idle_connections"`
+				// ResponseLimit is the maximum size of the responses of data sources in bytes, zero means unlimited.
+				ResponseLimit int64 `json:"response_limit"`
+			}
+			type httpServer struct {
+				Protocol string `json:"protocol"`
+				// Address is where the server listens, host:port or the socket path.
+				Address          string   `json:"address"`
+				SocketMode       string   `json:"socket_mode,omitempty"`
+				SocketGid        *int     `json:"socket_gid,omitempty"`
+				CertFile         string   `json:"cert_file,omitempty"`
+				RootURL          string   `json:"root_url"`
+				ServeFromSubPath bool     `json:"serve_from_sub_path"`
+				EnforceDomain    bool     `json:"enforce_domain"`
+				Timeouts         timeouts `json:"timeouts"`
+				// MaxHeaderBytes isn't configurable, the server uses the default of Go.
+				MaxHeaderBytes int       `json:"max_header_bytes"`
+				EnableGzip     bool      `json:"enable_gzip"`
+				RouterLogging  bool      `json:"router_logging"`
+				DataProxy      dataProxy `json:"data_proxy"`
+			}
+
+			seconds := func(s int) string {
+				return (time.Duration(s) * time.Second).String()
+			}
+			result := httpServer{
+				Protocol:         string(cfg.Protocol),
+				RootURL:          redactURLCredentials(cfg.AppURL),
+				ServeFromSubPath: cfg.ServeFromSubPath,
+				EnforceDomain:    cfg.EnforceDomain,
+				Timeouts: timeouts{
+					Read:  cfg.ReadTimeout.String(),
+					Write: time.Duration(0).String(),
+					Idle:  time.Duration(0).String(),
+				},
+				MaxHeaderBytes: http.DefaultMaxHeaderBytes,
+				EnableGzip:     cfg.EnableGzip,
+				RouterLogging:  cfg.RouterLogging,
+				DataProxy: dataProxy{
+					Timeout:               seconds(cfg.DataProxyTimeout),
+					DialTimeout:           seconds(cfg.DataProxyDialTimeout),
+					TLSHandshakeTimeout:   seconds(cfg.DataProxyTLSHandshakeTimeout),
+					ExpectContinueTimeout: seconds(cfg.DataProxyExpectContinueTimeout),
+					IdleConnTimeout:       seconds(cfg.DataProxyIdleConnTimeout),
+					KeepAlive:             seconds(cfg.DataProxyKeepAlive),
+					MaxConnsPerHost:       cfg.DataProxyMaxConnsPerHost,
+					MaxIdleConns:          cfg.DataProxyMaxIdleConns,
+					ResponseLimit:         cfg.ResponseLimit,
+				},
+			}
+
+			switch cfg.Protocol {
+			case setting.SocketScheme:
+				result.Address = cfg.SocketPath
+				result.SocketMode = fmt.Sprintf("%#o", cfg.SocketMode)
+				gid := cfg.SocketGid
+				result.SocketGid = &gid
+			case setting.HTTPSScheme, setting.HTTP2Scheme:
+				result.CertFile = cfg.CertFile
+				fallthrough
+			default:
+				// the same as the server, which accepts IPv6 addresses in brackets
+				host := strings.TrimSuffix(strings.TrimPrefix(cfg.HTTPAddr, "["), "]")
+				result.Address = net.JoinHostPort(host, cfg.HTTPPort)
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "http-server.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
@@ -0,0 +1,86 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestHTTPServerCollector(t *testing.T) {
+	type httpServer struct {
+		Protocol   string `json:"protocol"`
+		Address    string `json:"address"`
+		SocketMode string `json:"socket_mode"`
+		SocketGid  *int   `json:"socket_gid"`
+		CertFile   string `json:"cert_file"`
+		RootURL    string `json:"root_url"`
+		Timeouts   struct {
+			Read string `json:"read"`
+		} `json:"timeouts"`
+		MaxHeaderBytes int  `json:"max_header_bytes"`
+		EnableGzip     bool `json:"enable_gzip"`
+		DataProxy      struct {
+			Timeout       string `json:"timeout"`
+			ResponseLimit int64  `json:"response_limit"`
+		} `json:"data_proxy"`
+	}
+
+	collect := func(t *testing.T, cfg *setting.Cfg) httpServer {
+		t.Helper()
+
+		item, err := httpServerCollector(cfg).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "http-server.json", item.Filename)
+		require.NotContains(t, string(item.FileBytes), plantedSecret)
+
+		var result httpServer
+		require.NoError(t, json.Unmarshal(item.FileBytes, &result))
+		return result
+	}
+
+	t.Run("reports the address, timeouts and limits", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.Protocol = setting.HTTP2Scheme
+		cfg.HTTPAddr = "[::1]"
+		cfg.HTTPPort = "3443"
+		cfg.CertFile = "/etc/grafana/grafana.crt"
+		cfg.AppURL = "https://admin:" + plantedSecret + "@grafana.example.com/"
+		cfg.ReadTimeout = 30 * time.Second
+		cfg.EnableGzip = true
+		cfg.DataProxyTimeout = 60
+		cfg.ResponseLimit = 1024
+
+		result := collect(t, cfg)
+		require.Equal(t, "h2", result.Protocol)
+		require.Equal(t, "[::1]:3443", result.Address)
+		require.Equal(t, "/etc/grafana/grafana.crt", result.CertFile)
+		require.NotContains(t, result.RootURL, "admin")
+		require.Equal(t, "30s", result.Timeouts.Read)
+		require.Equal(t, http.DefaultMaxHeaderBytes, result.MaxHeaderBytes)
+		require.True(t, result.EnableGzip)
+		require.Equal(t, "1m0s", result.DataProxy.Timeout)
+		require.EqualValues(t, 1024, result.DataProxy.ResponseLimit)
+		require.Nil(t, result.SocketGid)
+	})
+
+	t.Run("reports the socket", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.Protocol = setting.SocketScheme
+		cfg.SocketPath = "/run/grafana/grafana.sock"
+		cfg.SocketMode = 0660
+		cfg.SocketGid = -1
+
+		result := collect(t, cfg)
+		require.Equal(t, "socket", result.Protocol)
+		require.Equal(t, "/run/grafana/grafana.sock", result.Address)
+		require.Equal(t, "0660", result.SocketMode)
+		require.Equal(t, -1, *result.SocketGid)
+		require.Empty(t, result.CertFile)
+	})
+}
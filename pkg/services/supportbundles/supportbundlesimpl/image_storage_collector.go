@@ -0,0 +1,172 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// imageStorageReachabilityTimeout bounds the connection to the image storage.
+const imageStorageReachabilityTimeout = 5 * time.Second
+
+// imageStorageCollector reports where the images of alert notifications and
+// snapshots are uploaded to and the CDN settings, for broken panel images. When
+// testReachability is set, it also checks that the storage can be connected to.
+// Nothing is uploaded.
+func imageStorageCollector(cfg *setting.Cfg, testReachability bool) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "image-storage",
+		DisplayName:       "Image storage and CDN",
+		Description:       "External image storage, snapshot and CDN settings with credentials redacted and, if enabled, whether the storage is reachable",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type reachabilityTest struct {
+				Target  string `json:"target"`
+				Success bool   `json:"success"`
+				Error   string `json:"error,omitempty"`
+			}
+			type externalImageStorage struct {
+				// Provider is empty when images aren't uploaded anywhere.
+				Provider string `json:"provider"`
+				// Settings are the settings of the provider, credentials redacted.
+				Settings         map[string]string `json:"settings,omitempty"`
+				LocalImagesDir   string            `json:"local_images_dir"`
+				ReachabilityTest *reachabilityTest `json:"reachability_test,omitempty"`
+			}
+			type imageStorage struct {
+				CDNURL               string               `json:"cdn_url,omitempty"`
+				ExternalImageStorage externalImageStorage `json:"external_image_storage"`
+				SnapshotsEnabled     bool                 `json:"snapshots_enabled"`
+				ExternalSnapshots    bool                 `json:"external_snapshots_enabled"`
+				ExternalSnapshotURL  string               `json:"external_snapshot_url,omitempty"`
+				SnapshotPublicMode   bool                 `json:"snapshot_public_mode"`
+				// AlertingScreenshots and AlertingScreenshotsUpload are whether unified alerting
+				// captures images of panels and uploads them to the external image storage.
+				AlertingScreenshots       bool `json:"alerting_screenshots_capture"`
+				AlertingScreenshotsUpload bool `json:"alerting_screenshots_upload_external_image_storage"`
+			}
+
+			provider := cfg.ImageUploadProvider
+			result := imageStorage{
+				ExternalImageStorage: externalImageStorage{
+					Provider:       provider,
+					LocalImagesDir: cfg.ImagesDir,
+				},
+				SnapshotsEnabled:          cfg.SnapshotEnabled,
+				ExternalSnapshots:         cfg.ExternalEnabled,
+				ExternalSnapshotURL:       redactURLCredentials(cfg.ExternalSnapshotUrl),
+				SnapshotPublicMode:        cfg.SnapshotPublicMode,
+				AlertingScreenshots:       cfg.UnifiedAlerting.Screenshots.Capture,
+				AlertingScreenshotsUpload: cfg.UnifiedAlerting.Screenshots.UploadExternalImageStorage,
+			}
+			if cfg.CDNRootURL != nil {
+				result.CDNURL = redactURLCredentials(cfg.CDNRootURL.String())
+			}
+
+			var section map[string]string
+			if provider != "" {
+				section = cfg.Raw.Section("external_image_storage." + provider).KeysHash()
+				result.ExternalImageStorage.Settings = redactStringMap(section)
+			}
+
+			// only test the reachability if explicitly allowed, it's an outbound connection
+			if testReachability && provider != "" {
+				target, err := imageStorageTarget(cfg, provider, section)
+				test := &reachabilityTest{Target: redactURLCredentials(target)}
+				if err == nil {
+					err = checkImageStorage(ctx, provider, target)
+				}
+				if err != nil {
+					test.Error = err.Error()
+				} else {
+					test.Success = true
+				}
+				result.ExternalImageStorage.ReachabilityTest = test
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "image-storage.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+// imageStorageTarget returns the URL images are uploaded to with the given
+// provider, or the directory they're written to for the local provider.
+func imageStorageTarget(cfg *setting.Cfg, provider string, section map[string]string) (string, error) {
+	switch provider {
+	case "local":
+		return cfg.ImagesDir, nil
+	case "s3":
+		if section["endpoint"] != "" {
+			return section["endpoint"], nil
+		}
+		if section["bucket_url"] != "" {
+			return section["bucket_url"], nil
+		}
+		if section["region"] != "" {
+			return fmt.Sprintf("https://s3.%s.amazonaws.com", section["region"]), nil
+		}
+		return "https://s3.amazonaws.com", nil
+	case "gcs":
+		return "https://storage.googleapis.com", nil
+	case "azure_blob":
+		if section["account_name"] == "" {
+			return "", fmt.Errorf("account_name is not set")
+		}
+		return fmt.Sprintf("https://%s.blob.core.windows.net", section["account_name"]), nil
+	case "webdav":
+		if section["url"] == "" {
+			return "", fmt.Errorf("url is not set")
+		}
+		return section["url"], nil
+	default:
+		return "", fmt.Errorf("unsupported provider %q", provider)
+	}
+}
+
+// checkImageStorage checks that the images directory of the local provider is a
+// directory, or that a TCP connection can be made to the host of the target URL.
+func checkImageStorage(ctx context.Context, provider string, target string) error {
+	if provider == "local" {
+		info, err := os.Stat(target)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", target)
+		}
+		return nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%s has no host", redactURLCredentials(target))
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, imageStorageReachabilityTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(dialCtx, "tcp", net.JoinHostPort(u.Hostname(), portOrDefault(u.Scheme, u.Port())))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,104 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestImageStorageCollector(t *testing.T) {
+	type reachabilityTest struct {
+		Target  string `json:"target"`
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+	type imageStorage struct {
+		CDNURL               string `json:"cdn_url"`
+		ExternalImageStorage struct {
+			Provider         string            `json:"provider"`
+			Settings         map[string]string `json:"settings"`
+			ReachabilityTest *reachabilityTest `json:"reachability_test"`
+		} `json:"external_image_storage"`
+		ExternalSnapshotURL string `json:"external_snapshot_url"`
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	collect := func(t *testing.T, cfg *setting.Cfg, testReachability bool) imageStorage {
+		t.Helper()
+
+		item, err := imageStorageCollector(cfg, testReachability).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "image-storage.json", item.Filename)
+		require.NotContains(t, string(item.FileBytes), plantedSecret)
+
+		var result imageStorage
+		require.NoError(t, json.Unmarshal(item.FileBytes, &result))
+		return result
+	}
+
+	newCfg := func(t *testing.T, provider string, keys map[string]string) *setting.Cfg {
+		t.Helper()
+
+		cfg := setting.NewCfg()
+		cfg.ImageUploadProvider = provider
+		for k, v := range keys {
+			cfg.Raw.Section("external_image_storage." + provider).Key(k).SetValue(v)
+		}
+		return cfg
+	}
+
+	t.Run("reports the settings with credentials redacted", func(t *testing.T) {
+		cfg := newCfg(t, "s3", map[string]string{
+			"bucket":     "images",
+			"region":     "eu-west-1",
+			"access_key": plantedSecret,
+			"secret_key": plantedSecret,
+		})
+		var err error
+		cfg.CDNRootURL, err = url.Parse("https://cdn.example.com/grafana")
+		require.NoError(t, err)
+		cfg.ExternalSnapshotUrl = "https://user:" + plantedSecret + "@snapshots.example.com"
+
+		result := collect(t, cfg, false)
+		require.Equal(t, "https://cdn.example.com/grafana", result.CDNURL)
+		require.Equal(t, "s3", result.ExternalImageStorage.Provider)
+		require.Equal(t, map[string]string{
+			"bucket":     "images",
+			"region":     "eu-west-1",
+			"access_key": redactedValue,
+			"secret_key": redactedValue,
+		}, result.ExternalImageStorage.Settings)
+		require.Nil(t, result.ExternalImageStorage.ReachabilityTest)
+	})
+
+	t.Run("tests the reachability of the storage when enabled", func(t *testing.T) {
+		cfg := newCfg(t, "webdav", map[string]string{"url": "http://user:" + plantedSecret + "@" + l.Addr().String() + "/images"})
+
+		test := collect(t, cfg, true).ExternalImageStorage.ReachabilityTest
+		require.NotNil(t, test)
+		require.True(t, test.Success, test.Error)
+	})
+
+	t.Run("records unreachable storages", func(t *testing.T) {
+		cfg := newCfg(t, "local", nil)
+		cfg.ImagesDir = t.TempDir() + "/missing"
+
+		test := collect(t, cfg, true).ExternalImageStorage.ReachabilityTest
+		require.NotNil(t, test)
+		require.False(t, test.Success)
+		require.NotEmpty(t, test.Error)
+	})
+
+	t.Run("doesn't test the reachability without a provider", func(t *testing.T) {
+		require.Nil(t, collect(t, setting.NewCfg(), true).ExternalImageStorage.ReachabilityTest)
+	})
+}
@@ -0,0 +1,78 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// instanceStatsCollector counts the main resources of the instance with
+// aggregate queries only, so that it stays fast on large databases.
+func instanceStatsCollector(sql db.DB) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "instance-stats",
+		DisplayName:       "Instance statistics",
+		Description:       "Number of organizations, users, teams, dashboards, folders, data sources and alert rules",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type instanceStats struct {
+				Orgs            int64 `json:"orgs"`
+				Users           int64 `json:"users"`
+				ServiceAccounts int64 `json:"service_accounts"`
+				Teams           int64 `json:"teams"`
+				Dashboards      int64 `json:"dashboards"`
+				Folders         int64 `json:"folders"`
+				Datasources     int64 `json:"datasources"`
+				AlertRules      int64 `json:"alert_rules"`
+			}
+
+			dialect := sql.GetDialect()
+			stats := instanceStats{}
+			counts := []struct {
+				table string
+				where string
+				count *int64
+			}{
+				{table: "org", count: &stats.Orgs},
+				{table: "user", where: "is_service_account = " + dialect.BooleanStr(false), count: &stats.Users},
+				{table: "user", where: "is_service_account = " + dialect.BooleanStr(true), count: &stats.ServiceAccounts},
+				{table: "team", count: &stats.Teams},
+				{table: "dashboard", where: "is_folder = " + dialect.BooleanStr(false), count: &stats.Dashboards},
+				{table: "dashboard", where: "is_folder = " + dialect.BooleanStr(true), count: &stats.Folders},
+				{table: "data_source", count: &stats.Datasources},
+				{table: "alert_rule", count: &stats.AlertRules},
+			}
+
+			err := sql.WithDbSession(ctx, func(sess *db.Session) error {
+				for _, c := range counts {
+					query := sess.Table(c.table)
+					if c.where != "" {
+						query = query.Where(c.where)
+					}
+					count, err := query.Count()
+					if err != nil {
+						return err
+					}
+					*c.count = count
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			data, err := json.Marshal(stats)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "stats.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
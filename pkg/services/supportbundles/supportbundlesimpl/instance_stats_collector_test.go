@@ -0,0 +1,44 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/dashboards"
+	"github.com/grafana/grafana/pkg/services/team"
+)
+
+func TestInstanceStatsCollector(t *testing.T) {
+	sqlStore := db.InitTestDB(t)
+	require.NoError(t, sqlStore.WithDbSession(context.Background(), func(sess *db.Session) error {
+		now := time.Now()
+		if _, err := sess.Insert(&team.Team{OrgID: 1, Name: "team", Created: now, Updated: now}); err != nil {
+			return err
+		}
+		for i, isFolder := range []bool{true, false, false} {
+			dashboard := &dashboards.Dashboard{OrgID: 1, UID: string(rune('a' + i)), Slug: "d", Title: string(rune('a' + i)),
+				IsFolder: isFolder, Created: now, Updated: now}
+			if _, err := sess.Insert(dashboard); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	item, err := instanceStatsCollector(sqlStore).Fn(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "stats.json", item.Filename)
+
+	var stats map[string]int64
+	require.NoError(t, json.Unmarshal(item.FileBytes, &stats))
+	require.Equal(t, int64(1), stats["teams"])
+	require.Equal(t, int64(2), stats["dashboards"])
+	require.Equal(t, int64(1), stats["folders"])
+	require.Equal(t, int64(0), stats["datasources"])
+	require.Equal(t, int64(0), stats["alert_rules"])
+}
@@ -0,0 +1,155 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// defaultCollectorEstimate is how long collectors that haven't run yet are expected to take.
+const defaultCollectorEstimate = time.Second
+
+// job is the status of the asynchronous collection of a bundle. Jobs are
+// identified by the UID of their bundle, their state is persisted with it.
+type job struct {
+	ID               string               `json:"id"`
+	State            supportbundles.State `json:"state"`
+	Done             bool                 `json:"done"`
+	Progress         int                  `json:"progress"`
+	CurrentCollector string               `json:"currentCollector,omitempty"`
+	// ETA is when the job is expected to be done in unix seconds, only set while it's pending.
+	ETA int64 `json:"eta,omitempty"`
+}
+
+func newJob(bundle *supportbundles.Bundle) *job {
+	j := &job{
+		ID:               bundle.UID,
+		State:            bundle.State,
+		Done:             bundle.State != supportbundles.StatePending,
+		Progress:         bundle.Progress,
+		CurrentCollector: bundle.CurrentCollector,
+	}
+	if !j.Done {
+		// jobs taking longer than estimated are expected to be done any time now
+		j.ETA = bundle.EstimatedCompletedAt
+		if now := time.Now().Unix(); j.ETA < now {
+			j.ETA = now
+		}
+	}
+	return j
+}
+
+// startJob collects the bundle in the background and uploads it to uploadURL,
+// if set. origin is the request that created the bundle, nil if there's none,
+// and params are the parameters of the collectors, by collector UID.
+//
+// The caller must hold a creation slot, it's released once the collection is
+// done. It returns false if the bundle is already being collected, the caller
+// must then release the slot itself.
+func (s *Service) startJob(uid string, collectors []supportbundles.Collector, base *bundleContents, uploadURL string, origin *requestOrigin, params map[string]map[string]int64) (time.Time, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), bundleCreationTimeout)
+	ctx = withRequestOrigin(ctx, origin)
+	ctx = withCollectorParams(ctx, params)
+	if !s.trackPending(uid, cancel) {
+		cancel()
+		return time.Time{}, false
+	}
+
+	eta := time.Now().Add(s.estimateDuration(collectors))
+	if err := s.store.UpdateMetadata(ctx, uid, func(b *supportbundles.Bundle) {
+		b.State = supportbundles.StatePending
+		b.Progress = 0
+		b.EstimatedCompletedAt = eta.Unix()
+		b.CompletedAt = 0
+		b.Duration = 0
+		// the archive of a retried bundle is rewritten once collected, there's no
+		// need to rewrite it with every progress update meanwhile
+		b.TarBytes = nil
+	}); err != nil {
+		s.log.Warn("Failed to mark support bundle as pending", "uid", uid, "error", err)
+	}
+
+	go s.collectInBackground(ctx, cancel, uid, collectors, base, uploadURL)
+	return eta, true
+}
+
+// estimateDuration returns how long the collectors are expected to take, based
+// on how long they took the last time they ran.
+func (s *Service) estimateDuration(collectors []supportbundles.Collector) time.Duration {
+	s.durationsMu.Lock()
+	defer s.durationsMu.Unlock()
+
+	var total, longest time.Duration
+	for _, collector := range collectors {
+		d, ok := s.lastDurations[collector.UID]
+		if !ok {
+			d = defaultCollectorEstimate
+		}
+		total += d
+		if d > longest {
+			longest = d
+		}
+	}
+
+	workers := s.collectorWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	// the collectors are spread over the workers, but can't finish before the slowest one
+	if perWorker := total / time.Duration(workers); perWorker > longest {
+		return perWorker
+	}
+	return longest
+}
+
+// recordDuration records how long a collector took for later estimates.
+func (s *Service) recordDuration(uid string, d time.Duration) {
+	s.durationsMu.Lock()
+	defer s.durationsMu.Unlock()
+
+	if s.lastDurations == nil {
+		s.lastDurations = map[string]time.Duration{}
+	}
+	s.lastDurations[uid] = d
+}
+
+// failOrphanedJobs marks the pending bundles that no job has been collecting
+// for longer than bundleCreationTimeout as failed, e.g. because Grafana
+// restarted mid-collection. Pending bundles can't be removed, they would be stuck
+// forever otherwise. Jobs only run in the process that started them, the
+// timeout leaves the jobs of other instances sharing the store alone.
+func (s *Service) failOrphanedJobs(ctx context.Context) {
+	bundles, _, err := s.list(ctx, listQuery{})
+	if err != nil {
+		s.log.Error("failed to list bundles to find orphaned jobs", "error", err)
+		return
+	}
+
+	now := time.Now()
+	s.cancelMu.Lock()
+	defer s.cancelMu.Unlock()
+	for _, b := range bundles {
+		if _, running := s.cancelFuncs[b.UID]; running || !isOrphaned(b, now) {
+			continue
+		}
+		s.log.Warn("Marking orphaned support bundle as failed", "uid", b.UID)
+		if err := s.store.Update(ctx, b.UID, supportbundles.StateError, nil); err != nil {
+			s.log.Error("failed to mark orphaned bundle as failed", "uid", b.UID, "error", err)
+		}
+	}
+}
+
+// isOrphaned reports whether a pending bundle should have been done by now.
+// Jobs time out after bundleCreationTimeout, counted from the creation of the
+// bundle or, when it was retried, from the last estimate of its completion.
+func isOrphaned(b supportbundles.Bundle, now time.Time) bool {
+	if b.State != supportbundles.StatePending {
+		return false
+	}
+	started := b.CreatedAt
+	if b.EstimatedCompletedAt > started {
+		started = b.EstimatedCompletedAt
+	}
+	return now.Sub(time.Unix(started, 0)) > bundleCreationTimeout
+}
@@ -0,0 +1,138 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestService_jobs(t *testing.T) {
+	release := make(chan struct{})
+	blocking := newTestCollector("blocking", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		<-release
+		return &supportbundles.SupportItem{Filename: "blocking.txt", FileBytes: []byte("done")}, nil
+	})
+
+	s := newTestService(t, blocking)
+	s.defaultCollectorTimeout = time.Minute
+
+	bundle, err := s.create(context.Background(), &user.SignedInUser{Login: "admin"}, createOptions{})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, bundle.EstimatedCompletedAt, time.Now().Unix())
+
+	stored, err := s.get(context.Background(), bundle.UID)
+	require.NoError(t, err)
+	require.Equal(t, bundle.EstimatedCompletedAt, stored.EstimatedCompletedAt)
+
+	pending := newJob(stored)
+	require.Equal(t, bundle.UID, pending.ID)
+	require.False(t, pending.Done)
+	require.NotZero(t, pending.ETA)
+
+	close(release)
+	require.Eventually(t, func() bool {
+		b, err := s.get(context.Background(), bundle.UID)
+		require.NoError(t, err)
+		return newJob(b).Done
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stored, err = s.get(context.Background(), bundle.UID)
+	require.NoError(t, err)
+	done := newJob(stored)
+	require.Equal(t, supportbundles.StateComplete, done.State)
+	require.Zero(t, done.ETA)
+
+	t.Run("late jobs are expected to be done any time now", func(t *testing.T) {
+		job := newJob(&supportbundles.Bundle{State: supportbundles.StatePending, EstimatedCompletedAt: 1})
+		require.GreaterOrEqual(t, job.ETA, time.Now().Add(-time.Second).Unix())
+	})
+}
+
+// collectingStore marks every bundle it creates as already being collected.
+type collectingStore struct {
+	bundleStore
+	s *Service
+}
+
+func (c *collectingStore) Create(ctx context.Context, usr *user.SignedInUser, retention time.Duration) (*supportbundles.Bundle, error) {
+	bundle, err := c.bundleStore.Create(ctx, usr, retention)
+	if err == nil {
+		c.s.trackPending(bundle.UID, func() {})
+	}
+	return bundle, err
+}
+
+func TestService_create_ReleasesSlotWhenNotStarted(t *testing.T) {
+	s := newTestService(t)
+	s.store = &collectingStore{bundleStore: s.store, s: s}
+
+	bundle, err := s.create(context.Background(), &user.SignedInUser{Login: "admin"}, createOptions{})
+	require.NoError(t, err)
+	require.Zero(t, bundle.EstimatedCompletedAt)
+	require.Len(t, s.creationSlots, 0)
+}
+
+func TestService_estimateDuration(t *testing.T) {
+	s := newTestService(t)
+	s.collectorWorkers = 2
+	s.recordDuration("slow", 10*time.Second)
+	s.recordDuration("fast", 2*time.Second)
+
+	collectors := func(uids ...string) []supportbundles.Collector {
+		c := make([]supportbundles.Collector, 0, len(uids))
+		for _, uid := range uids {
+			c = append(c, newTestCollector(uid, nil))
+		}
+		return c
+	}
+
+	// bounded by the slowest collector
+	require.Equal(t, 10*time.Second, s.estimateDuration(collectors("slow", "fast", "unknown")))
+	// collectors that never ran are expected to take defaultCollectorEstimate
+	require.Equal(t, defaultCollectorEstimate, s.estimateDuration(collectors("unknown")))
+
+	s.collectorWorkers = 1
+	require.Equal(t, 12*time.Second+defaultCollectorEstimate, s.estimateDuration(collectors("slow", "fast", "unknown")))
+}
+
+func TestService_failOrphanedJobs(t *testing.T) {
+	s := newTestService(t)
+	usr := &user.SignedInUser{Login: "admin"}
+	stale := time.Now().Add(-bundleCreationTimeout - time.Minute).Unix()
+
+	create := func(t *testing.T, update func(b *supportbundles.Bundle)) string {
+		t.Helper()
+		b, err := s.store.Create(context.Background(), usr, 0)
+		require.NoError(t, err)
+		require.NoError(t, s.store.UpdateMetadata(context.Background(), b.UID, update))
+		return b.UID
+	}
+
+	orphaned := create(t, func(b *supportbundles.Bundle) { b.CreatedAt = stale })
+	running := create(t, func(b *supportbundles.Bundle) { b.CreatedAt = stale })
+	s.trackPending(running, func() {})
+	// possibly being collected by another instance
+	recent := create(t, func(b *supportbundles.Bundle) {})
+	retried := create(t, func(b *supportbundles.Bundle) {
+		b.CreatedAt = stale
+		b.EstimatedCompletedAt = time.Now().Unix()
+	})
+
+	s.failOrphanedJobs(context.Background())
+
+	for uid, state := range map[string]supportbundles.State{
+		orphaned: supportbundles.StateError,
+		running:  supportbundles.StatePending,
+		recent:   supportbundles.StatePending,
+		retried:  supportbundles.StatePending,
+	} {
+		b, err := s.get(context.Background(), uid)
+		require.NoError(t, err)
+		require.Equal(t, state, b.State, uid)
+	}
+}
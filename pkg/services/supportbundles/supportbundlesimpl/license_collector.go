@@ -0,0 +1,111 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/licensing"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// activeUserWindow is how recently a user must have been seen to use a seat.
+const activeUserWindow = 30 * 24 * time.Hour
+
+// licenseExpiryWarning is how long before the license expires a note is added.
+const licenseExpiryWarning = 30 * 24 * time.Hour
+
+// hasLicensing returns whether license is provided by an enterprise licensing
+// service, the open source one has no license to report.
+func hasLicensing(license licensing.Licensing) bool {
+	if license == nil {
+		return false
+	}
+	_, oss := license.(*licensing.OSSLicensingService)
+	return !oss
+}
+
+// licenseCollector reports the edition, state and expiry of the license, the
+// enterprise features it enables and the users using seats. Only what the
+// licensing service exposes is read, never the license token.
+func licenseCollector(license licensing.Licensing, sql db.DB) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "license",
+		DisplayName:       "License",
+		Description:       "License edition, state, expiry, seat usage and enabled enterprise features, without the license token",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type seats struct {
+				Users int64 `json:"users"`
+				// ActiveUsers are the users seen in the last 30 days.
+				ActiveUsers int64 `json:"active_users"`
+			}
+			type licenseStatus struct {
+				Edition    string     `json:"edition"`
+				State      string     `json:"state,omitempty"`
+				ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+				Expired    bool       `json:"expired"`
+				LicenseURL string     `json:"license_url"`
+				Seats      seats      `json:"seats"`
+				// Features are the enterprise features enabled by the license.
+				Features []string `json:"features"`
+				Notes    []string `json:"notes"`
+			}
+
+			now := time.Now()
+			result := licenseStatus{
+				Edition:    license.Edition(),
+				State:      license.StateInfo(),
+				LicenseURL: license.LicenseURL(true),
+				Features:   []string{},
+				Notes:      []string{},
+			}
+			if expiry := license.Expiry(); expiry > 0 {
+				expiresAt := time.Unix(expiry, 0).UTC()
+				result.ExpiresAt = &expiresAt
+				result.Expired = expiresAt.Before(now)
+				switch {
+				case result.Expired:
+					result.Notes = append(result.Notes, "the license has expired")
+				case expiresAt.Before(now.Add(licenseExpiryWarning)):
+					result.Notes = append(result.Notes, "the license expires in less than 30 days")
+				}
+			} else {
+				result.Notes = append(result.Notes, "no valid license is installed")
+			}
+			for feature, enabled := range license.EnabledFeatures() {
+				if enabled {
+					result.Features = append(result.Features, feature)
+				}
+			}
+			sort.Strings(result.Features)
+
+			dialect := sql.GetDialect()
+			err := sql.WithDbSession(ctx, func(sess *db.Session) error {
+				users := "is_service_account = " + dialect.BooleanStr(false) + " AND is_disabled = " + dialect.BooleanStr(false)
+				var err error
+				if result.Seats.Users, err = sess.Table("user").Where(users).Count(); err != nil {
+					return err
+				}
+				result.Seats.ActiveUsers, err = sess.Table("user").Where(users).And("last_seen_at > ?", now.Add(-activeUserWindow)).Count()
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "license.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
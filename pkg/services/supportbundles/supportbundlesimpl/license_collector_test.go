@@ -0,0 +1,95 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/licensing"
+	"github.com/grafana/grafana/pkg/services/licensing/licensingtest"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestLicenseCollector(t *testing.T) {
+	type licenseStatus struct {
+		Edition   string     `json:"edition"`
+		State     string     `json:"state"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		Expired   bool       `json:"expired"`
+		Seats     struct {
+			Users       int64 `json:"users"`
+			ActiveUsers int64 `json:"active_users"`
+		} `json:"seats"`
+		Features []string `json:"features"`
+		Notes    []string `json:"notes"`
+	}
+
+	sqlStore := db.InitTestDB(t)
+	now := time.Now()
+	require.NoError(t, sqlStore.WithDbSession(context.Background(), func(sess *db.Session) error {
+		for _, u := range []*user.User{
+			{OrgID: 1, Login: "active", Email: "active", LastSeenAt: now.Add(-time.Hour)},
+			{OrgID: 1, Login: "inactive", Email: "inactive", LastSeenAt: now.Add(-60 * 24 * time.Hour)},
+			{OrgID: 1, Login: "disabled", Email: "disabled", LastSeenAt: now, IsDisabled: true},
+			{OrgID: 1, Login: "sa-1-ci", Email: "sa-1-ci", LastSeenAt: now, IsServiceAccount: true},
+		} {
+			u.Created, u.Updated = now, now
+			if _, err := sess.Insert(u); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	collect := func(t *testing.T, license licensing.Licensing) licenseStatus {
+		t.Helper()
+
+		item, err := licenseCollector(license, sqlStore).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "license.json", item.Filename)
+
+		var result licenseStatus
+		require.NoError(t, json.Unmarshal(item.FileBytes, &result))
+		return result
+	}
+	fakeLicense := func(expiry int64) *licensingtest.FakeLicensing {
+		license := licensingtest.NewFakeLicensing()
+		license.On("Edition").Return("Enterprise")
+		license.On("StateInfo").Return("Licensed")
+		license.On("LicenseURL", true).Return("/admin/licensing")
+		license.On("Expiry").Return(expiry)
+		license.On("EnabledFeatures").Return(map[string]bool{"reports": true, "saml": true, "whitelabeling": false})
+		return license
+	}
+
+	t.Run("reports the license and the seats in use", func(t *testing.T) {
+		expiry := now.Add(365 * 24 * time.Hour).Unix()
+		result := collect(t, fakeLicense(expiry))
+		require.Equal(t, "Enterprise", result.Edition)
+		require.Equal(t, "Licensed", result.State)
+		require.NotNil(t, result.ExpiresAt)
+		require.Equal(t, expiry, result.ExpiresAt.Unix())
+		require.False(t, result.Expired)
+		require.Equal(t, []string{"reports", "saml"}, result.Features)
+		require.Equal(t, int64(2), result.Seats.Users)
+		require.Equal(t, int64(1), result.Seats.ActiveUsers)
+		require.Empty(t, result.Notes)
+	})
+
+	t.Run("notes expired licenses", func(t *testing.T) {
+		result := collect(t, fakeLicense(now.Add(-time.Hour).Unix()))
+		require.True(t, result.Expired)
+		require.Equal(t, []string{"the license has expired"}, result.Notes)
+	})
+
+	t.Run("is registered with enterprise licensing only", func(t *testing.T) {
+		require.False(t, hasLicensing(nil))
+		require.False(t, hasLicensing(&licensing.OSSLicensingService{Cfg: setting.NewCfg()}))
+		require.True(t, hasLicensing(fakeLicense(0)))
+	})
+}
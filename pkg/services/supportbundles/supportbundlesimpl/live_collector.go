@@ -0,0 +1,76 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/grafana/grafana/pkg/services/live"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// maxLiveChannels caps how many channels are listed, the busiest first, as
+// large instances can have tens of thousands of them.
+const maxLiveChannels = 1000
+
+type liveStatsProvider interface {
+	NodeStats() live.NodeStats
+}
+
+func liveCollector(liveService liveStatsProvider) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "live",
+		DisplayName:       "Grafana Live",
+		Description:       "Grafana Live connections, channels and their number of subscribers on this instance",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type liveChannel struct {
+				Channel     string `json:"channel"`
+				Subscribers int    `json:"subscribers"`
+			}
+			type liveStats struct {
+				Clients       int           `json:"clients"`
+				Users         int           `json:"users"`
+				Subscriptions int           `json:"subscriptions"`
+				TotalChannels int           `json:"total_channels"`
+				Channels      []liveChannel `json:"channels"`
+				// Truncated is set when only the busiest channels are listed.
+				Truncated bool `json:"truncated,omitempty"`
+			}
+
+			stats := liveStats{Channels: []liveChannel{}}
+			if liveService != nil {
+				current := liveService.NodeStats()
+				stats.Clients = current.Clients
+				stats.Users = current.Users
+				stats.Subscriptions = current.Subscriptions
+				stats.TotalChannels = len(current.Channels)
+
+				for channel, subscribers := range current.Channels {
+					stats.Channels = append(stats.Channels, liveChannel{Channel: channel, Subscribers: subscribers})
+				}
+				sort.Slice(stats.Channels, func(i, j int) bool {
+					if stats.Channels[i].Subscribers != stats.Channels[j].Subscribers {
+						return stats.Channels[i].Subscribers > stats.Channels[j].Subscribers
+					}
+					return stats.Channels[i].Channel < stats.Channels[j].Channel
+				})
+				if len(stats.Channels) > maxLiveChannels {
+					stats.Channels = stats.Channels[:maxLiveChannels]
+					stats.Truncated = true
+				}
+			}
+
+			data, err := json.Marshal(stats)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "live.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
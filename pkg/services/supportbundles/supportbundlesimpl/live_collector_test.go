@@ -0,0 +1,73 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/live"
+)
+
+type fakeLiveStats live.NodeStats
+
+func (f fakeLiveStats) NodeStats() live.NodeStats {
+	return live.NodeStats(f)
+}
+
+func TestLiveCollector(t *testing.T) {
+	type liveStats struct {
+		Clients       int `json:"clients"`
+		Subscriptions int `json:"subscriptions"`
+		TotalChannels int `json:"total_channels"`
+		Channels      []struct {
+			Channel     string `json:"channel"`
+			Subscribers int    `json:"subscribers"`
+		} `json:"channels"`
+		Truncated bool `json:"truncated"`
+	}
+
+	collect := func(t *testing.T, provider liveStatsProvider) liveStats {
+		t.Helper()
+		item, err := liveCollector(provider).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "live.json", item.Filename)
+
+		var stats liveStats
+		require.NoError(t, json.Unmarshal(item.FileBytes, &stats))
+		return stats
+	}
+
+	t.Run("lists the busiest channels first", func(t *testing.T) {
+		stats := collect(t, fakeLiveStats{
+			Clients:       3,
+			Subscriptions: 4,
+			Channels:      map[string]int{"1/grafana/dashboard/uid/a": 1, "1/stream/testdata/random": 3},
+		})
+		require.Equal(t, 3, stats.Clients)
+		require.Equal(t, 4, stats.Subscriptions)
+		require.Equal(t, 2, stats.TotalChannels)
+		require.False(t, stats.Truncated)
+		require.Equal(t, "1/stream/testdata/random", stats.Channels[0].Channel)
+		require.Equal(t, 3, stats.Channels[0].Subscribers)
+	})
+
+	t.Run("caps the number of channels", func(t *testing.T) {
+		channels := map[string]int{}
+		for i := 0; i < maxLiveChannels+10; i++ {
+			channels[fmt.Sprintf("1/grafana/dashboard/uid/%d", i)] = 1
+		}
+
+		stats := collect(t, fakeLiveStats{Channels: channels})
+		require.Equal(t, maxLiveChannels+10, stats.TotalChannels)
+		require.Len(t, stats.Channels, maxLiveChannels)
+		require.True(t, stats.Truncated)
+	})
+
+	t.Run("does not fail without Grafana Live", func(t *testing.T) {
+		stats := collect(t, (*live.GrafanaLive)(nil))
+		require.Empty(t, stats.Channels)
+	})
+}
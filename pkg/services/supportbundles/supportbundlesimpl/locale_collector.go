@@ -0,0 +1,156 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	pref "github.com/grafana/grafana/pkg/services/preference"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// maxReportedOrgs caps the organizations whose preferences are listed.
+const maxReportedOrgs = 500
+
+// localeCollector reports the default timezone, week start and language, the
+// date formats and the preferences set by every organization, for
+// graphs whose times are off. The timezone of the server is reported too, as
+// the browser timezone is used by default.
+func localeCollector(cfg *setting.Cfg, sql db.DB, prefService pref.Service) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "locale",
+		DisplayName:       "Timezone and locale",
+		Description:       "Default and organization timezone, week start and language preferences, date formats and the server timezone",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type orgPreferences struct {
+				OrgID int64  `json:"org_id"`
+				Name  string `json:"name"`
+				// Preferences are the ones set for the organization, empty values
+				// fall back to the defaults.
+				Preferences localePreferences `json:"preferences"`
+				// UserTimezones and TeamTimezones are how many users and teams of the
+				// organization override the timezone.
+				UserTimezones int64 `json:"user_timezones"`
+				TeamTimezones int64 `json:"team_timezones"`
+			}
+			type server struct {
+				Timezone string `json:"timezone"`
+				// TZ is the TZ environment variable, empty when the timezone of the system is used.
+				TZ        string `json:"tz,omitempty"`
+				UTCOffset string `json:"utc_offset"`
+				Time      string `json:"time"`
+			}
+			type locale struct {
+				Defaults         localePreferences   `json:"defaults"`
+				UseBrowserLocale bool                `json:"use_browser_locale"`
+				DateFormats      setting.DateFormats `json:"date_formats"`
+				Server           server              `json:"server"`
+				Orgs             []orgPreferences    `json:"orgs"`
+				Notes            []string            `json:"notes"`
+			}
+
+			now := time.Now()
+			_, offset := now.Zone()
+			result := locale{
+				UseBrowserLocale: cfg.DateFormats.UseBrowserLocale,
+				DateFormats:      cfg.DateFormats,
+				Server: server{
+					Timezone:  time.Local.String(),
+					TZ:        os.Getenv("TZ"),
+					UTCOffset: (time.Duration(offset) * time.Second).String(),
+					Time:      now.Format(time.RFC3339),
+				},
+				Orgs:  []orgPreferences{},
+				Notes: []string{},
+			}
+			if defaults := prefService.GetDefaults(); defaults != nil {
+				result.Defaults = localePreferencesOf(defaults)
+			}
+
+			var orgs []struct {
+				ID   int64  `xorm:"id"`
+				Name string `xorm:"name"`
+			}
+			var overrides []struct {
+				OrgID int64 `xorm:"org_id"`
+				Users int64 `xorm:"users"`
+				Teams int64 `xorm:"teams"`
+			}
+			err := sql.WithDbSession(ctx, func(sess *db.Session) error {
+				if err := sess.Table("org").Cols("id", "name").Asc("id").Limit(maxReportedOrgs + 1).Find(&orgs); err != nil {
+					return err
+				}
+				return sess.SQL(`SELECT org_id,
+					SUM(CASE WHEN user_id > 0 THEN 1 ELSE 0 END) AS users,
+					SUM(CASE WHEN team_id > 0 THEN 1 ELSE 0 END) AS teams
+					FROM preferences WHERE timezone <> '' GROUP BY org_id`).Find(&overrides)
+			})
+			if err != nil {
+				return nil, err
+			}
+			if len(orgs) > maxReportedOrgs {
+				result.Notes = append(result.Notes, fmt.Sprintf("only the first %d organizations are listed", maxReportedOrgs))
+				orgs = orgs[:maxReportedOrgs]
+			}
+
+			overridesByOrg := make(map[int64]int, len(overrides))
+			for i, o := range overrides {
+				overridesByOrg[o.OrgID] = i
+			}
+			for _, o := range orgs {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				org := orgPreferences{OrgID: o.ID, Name: o.Name}
+				p, err := prefService.Get(ctx, &pref.GetPreferenceQuery{OrgID: o.ID})
+				if err != nil {
+					return nil, err
+				}
+				org.Preferences = localePreferencesOf(p)
+				if i, ok := overridesByOrg[o.ID]; ok {
+					org.UserTimezones = overrides[i].Users
+					org.TeamTimezones = overrides[i].Teams
+				}
+				result.Orgs = append(result.Orgs, org)
+			}
+
+			if result.Defaults.Timezone == "" || result.Defaults.Timezone == "browser" {
+				result.Notes = append(result.Notes, "times are shown in the timezone of the browser unless the organization, team, user or dashboard sets another one")
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "locale.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+// localePreferences are the timezone, week start and language of preferences.
+type localePreferences struct {
+	Timezone  string `json:"timezone"`
+	WeekStart string `json:"week_start"`
+	Language  string `json:"language"`
+}
+
+func localePreferencesOf(p *pref.Preference) localePreferences {
+	result := localePreferences{Timezone: p.Timezone}
+	if p.WeekStart != nil {
+		result.WeekStart = *p.WeekStart
+	}
+	if p.JSONData != nil {
+		result.Language = p.JSONData.Language
+	}
+	return result
+}
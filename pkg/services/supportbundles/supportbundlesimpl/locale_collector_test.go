@@ -0,0 +1,97 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	pref "github.com/grafana/grafana/pkg/services/preference"
+	"github.com/grafana/grafana/pkg/services/preference/prefimpl"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestLocaleCollector(t *testing.T) {
+	type org struct {
+		Id      int64
+		Version int
+		Name    string
+		Created time.Time
+		Updated time.Time
+	}
+	type locale struct {
+		Defaults struct {
+			Timezone  string `json:"timezone"`
+			WeekStart string `json:"week_start"`
+			Language  string `json:"language"`
+		} `json:"defaults"`
+		DateFormats struct {
+			FullDate string `json:"fullDate"`
+		} `json:"date_formats"`
+		Server struct {
+			Timezone string `json:"timezone"`
+		} `json:"server"`
+		Orgs []struct {
+			OrgID       int64  `json:"org_id"`
+			Name        string `json:"name"`
+			Preferences struct {
+				Timezone  string `json:"timezone"`
+				WeekStart string `json:"week_start"`
+			} `json:"preferences"`
+			UserTimezones int64 `json:"user_timezones"`
+			TeamTimezones int64 `json:"team_timezones"`
+		} `json:"orgs"`
+		Notes []string `json:"notes"`
+	}
+
+	cfg := setting.NewCfg()
+	cfg.DateFormats.FullDate = "YYYY-MM-DD HH:mm:ss"
+	cfg.DateFormats.DefaultTimezone = "utc"
+	cfg.DateFormats.DefaultWeekStart = "monday"
+	cfg.DefaultLanguage = "fr-FR"
+
+	sqlStore := db.InitTestDB(t)
+	prefService := prefimpl.ProvideService(sqlStore, cfg, featuremgmt.WithFeatures(featuremgmt.FlagInternationalization))
+	now := time.Now()
+	require.NoError(t, sqlStore.WithDbSession(context.Background(), func(sess *db.Session) error {
+		for _, o := range []*org{{Id: 10, Name: "Ops"}, {Id: 20, Name: "Sales"}} {
+			o.Created, o.Updated = now, now
+			if _, err := sess.Table("org").Insert(o); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	ctx := context.Background()
+	require.NoError(t, prefService.Save(ctx, &pref.SavePreferenceCommand{OrgID: 10, Timezone: "Europe/Paris", WeekStart: "sunday"}))
+	require.NoError(t, prefService.Save(ctx, &pref.SavePreferenceCommand{OrgID: 10, UserID: 1, Timezone: "America/New_York"}))
+	require.NoError(t, prefService.Save(ctx, &pref.SavePreferenceCommand{OrgID: 10, UserID: 2, Theme: "dark"}))
+	require.NoError(t, prefService.Save(ctx, &pref.SavePreferenceCommand{OrgID: 10, TeamID: 1, Timezone: "Asia/Tokyo"}))
+
+	item, err := localeCollector(cfg, sqlStore, prefService).Fn(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "locale.json", item.Filename)
+
+	var result locale
+	require.NoError(t, json.Unmarshal(item.FileBytes, &result))
+	require.Equal(t, "utc", result.Defaults.Timezone)
+	require.Equal(t, "monday", result.Defaults.WeekStart)
+	require.Equal(t, "fr-FR", result.Defaults.Language)
+	require.Equal(t, "YYYY-MM-DD HH:mm:ss", result.DateFormats.FullDate)
+	require.NotEmpty(t, result.Server.Timezone)
+	require.Empty(t, result.Notes)
+
+	require.Len(t, result.Orgs, 2)
+	require.Equal(t, int64(10), result.Orgs[0].OrgID)
+	require.Equal(t, "Europe/Paris", result.Orgs[0].Preferences.Timezone)
+	require.Equal(t, "sunday", result.Orgs[0].Preferences.WeekStart)
+	require.Equal(t, int64(1), result.Orgs[0].UserTimezones)
+	require.Equal(t, int64(1), result.Orgs[0].TeamTimezones)
+	require.Equal(t, "Sales", result.Orgs[1].Name)
+	require.Empty(t, result.Orgs[1].Preferences.Timezone)
+	require.Zero(t, result.Orgs[1].UserTimezones)
+}
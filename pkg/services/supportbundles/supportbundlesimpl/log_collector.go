@@ -0,0 +1,181 @@
+package supportbundlesimpl
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+const defaultLogTailLines = 10000
+
+// maxLogTailLines bounds the lines parameter, unless log_tail_lines is set higher.
+const maxLogTailLines = 100000
+
+func logTailCollector(cfg *setting.Cfg) supportbundles.Collector {
+	n := cfg.SectionWithEnvOverrides("support_bundles").Key("log_tail_lines").MustInt(defaultLogTailLines)
+	maxLines := int64(maxLogTailLines)
+	if int64(n) > maxLines {
+		maxLines = int64(n)
+	}
+
+	return supportbundles.Collector{
+		UID:               "log-tail",
+		DisplayName:       "Server logs",
+		Description:       "The most recent lines of the Grafana server log file, with secrets redacted",
+		IncludedByDefault: false,
+		Default:           true,
+		Params: []supportbundles.CollectorParam{
+			{Name: "lines", Description: "Number of log lines to collect", Default: int64(n), Min: 1, Max: maxLines},
+		},
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			path, ok := logFilePath(cfg)
+			if !ok {
+				// logging to the console only, there is no file to tail
+				return nil, nil
+			}
+
+			lines, err := tailLogFiles(ctx, path, int(supportbundles.IntParam(ctx, "lines", int64(n))))
+			if err != nil {
+				return nil, err
+			}
+
+			// secrets are redacted line by line along with the output of every other collector
+			return &supportbundles.SupportItem{
+				Filename:  "grafana.log",
+				FileBytes: []byte(strings.Join(lines, "")),
+			}, nil
+		},
+	}
+}
+
+// logFilePath returns the path of the log file, if Grafana logs to a file.
+func logFilePath(cfg *setting.Cfg) (string, bool) {
+	modes := strings.FieldsFunc(cfg.Raw.Section("log").Key("mode").MustString("console"), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	for _, mode := range modes {
+		if mode == "file" {
+			return cfg.Raw.Section("log.file").Key("file_name").MustString(filepath.Join(cfg.LogsPath, "grafana.log")), true
+		}
+	}
+	return "", false
+}
+
+// tailLogFiles returns the last n lines logged to path. When the current file
+// is shorter than that, the remaining lines are read from the rotated files,
+// newest first.
+func tailLogFiles(ctx context.Context, path string, n int) ([]string, error) {
+	lines, err := tailLogFile(path, n)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	rotated, err := rotatedLogFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range rotated {
+		if len(lines) >= n {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		previous, err := tailLogFile(file, n-len(lines))
+		if err != nil {
+			return nil, err
+		}
+		lines = append(previous, lines...)
+	}
+
+	return lines, nil
+}
+
+// rotatedLogFiles returns the rotated copies of the log file at path, most recently modified first.
+func rotatedLogFiles(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	modTimes := make(map[string]int64, len(matches))
+	files := make([]string, 0, len(matches))
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		modTimes[match] = info.ModTime().UnixNano()
+		files = append(files, match)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return modTimes[files[i]] > modTimes[files[j]]
+	})
+	return files, nil
+}
+
+// tailLogFile returns the last n lines of a log file, decompressing gzipped files.
+func tailLogFile(path string, n int) ([]string, error) {
+	// the path is read from the server configuration, not from user input
+	// nolint:gosec
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = gz.Close() }()
+		r = gz
+	}
+
+	return tailLines(r, n)
+}
+
+// tailLines returns the last n lines read from r, keeping their line endings.
+func tailLines(r io.Reader, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	ring := make([]string, 0, n)
+	next := 0
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n"
+			}
+			if len(ring) < n {
+				ring = append(ring, line)
+			} else {
+				ring[next] = line
+				next = (next + 1) % n
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return append(ring[next:], ring[:next]...), nil
+}
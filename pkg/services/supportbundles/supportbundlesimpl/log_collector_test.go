@@ -0,0 +1,100 @@
+package supportbundlesimpl
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestLogTailCollector(t *testing.T) {
+	newCfg := func(t *testing.T, mode string, lines int) (*setting.Cfg, string) {
+		t.Helper()
+		cfg := setting.NewCfg()
+		cfg.LogsPath = t.TempDir()
+		cfg.Raw.Section("log").Key("mode").SetValue(mode)
+		cfg.Raw.Section("support_bundles").Key("log_tail_lines").SetValue(fmt.Sprint(lines))
+		return cfg, filepath.Join(cfg.LogsPath, "grafana.log")
+	}
+
+	writeLines := func(t *testing.T, path string, from, to int, modTime time.Time) {
+		t.Helper()
+		var sb strings.Builder
+		for i := from; i <= to; i++ {
+			fmt.Fprintf(&sb, "line %d\n", i)
+		}
+
+		f, err := os.Create(path)
+		require.NoError(t, err)
+		if strings.HasSuffix(path, ".gz") {
+			gz := gzip.NewWriter(f)
+			_, err = gz.Write([]byte(sb.String()))
+			require.NoError(t, err)
+			require.NoError(t, gz.Close())
+		} else {
+			_, err = f.WriteString(sb.String())
+			require.NoError(t, err)
+		}
+		require.NoError(t, f.Close())
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	t.Run("tails the current log file", func(t *testing.T) {
+		cfg, path := newCfg(t, "console file", 3)
+		writeLines(t, path, 1, 10, time.Now())
+
+		item, err := logTailCollector(cfg).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "grafana.log", item.Filename)
+		require.Equal(t, "line 8\nline 9\nline 10\n", string(item.FileBytes))
+	})
+
+	t.Run("tails the number of lines set for the bundle", func(t *testing.T) {
+		cfg, path := newCfg(t, "file", 3)
+		writeLines(t, path, 1, 10, time.Now())
+
+		ctx := supportbundles.WithParams(context.Background(), map[string]int64{"lines": 2})
+		item, err := logTailCollector(cfg).Fn(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "line 9\nline 10\n", string(item.FileBytes))
+	})
+
+	t.Run("reads rotated and compressed files when the current one is short", func(t *testing.T) {
+		cfg, path := newCfg(t, "file", 5)
+		now := time.Now()
+		writeLines(t, path+".2023-01-01.001.gz", 1, 10, now.Add(-2*time.Hour))
+		writeLines(t, path+".2023-01-02.001", 11, 12, now.Add(-time.Hour))
+		writeLines(t, path, 13, 14, now)
+
+		item, err := logTailCollector(cfg).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "line 10\nline 11\nline 12\nline 13\nline 14\n", string(item.FileBytes))
+	})
+
+	t.Run("skips when logging to the console only", func(t *testing.T) {
+		cfg, _ := newCfg(t, "console", 3)
+
+		item, err := logTailCollector(cfg).Fn(context.Background())
+		require.NoError(t, err)
+		require.Nil(t, item)
+	})
+
+	t.Run("secrets are redacted", func(t *testing.T) {
+		cfg, path := newCfg(t, "file", 10)
+		require.NoError(t, os.WriteFile(path, []byte("logger=sqlstore password="+plantedSecret+"\n"), 0600))
+
+		s := newTestService(t, logTailCollector(cfg))
+		files, _ := s.collect(context.Background(), s.selectCollectors([]string{"log-tail"}), func(int, string) {}, nil)
+		require.Contains(t, files, "grafana.log")
+		require.NotContains(t, string(files["grafana.log"]), plantedSecret)
+	})
+}
@@ -0,0 +1,101 @@
+package supportbundlesimpl
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+const manifestFilename = "manifest.json"
+
+// collectorReport describes the outcome of running a single collector.
+type collectorReport struct {
+	UID string `json:"uid"`
+	// Filename is the file written by the collector, the first one for collectors
+	// writing several, which are all listed in Files.
+	Filename string   `json:"filename,omitempty"`
+	Files    []string `json:"files,omitempty"`
+	Success  bool     `json:"success"`
+	Error    string   `json:"error,omitempty"`
+	// Size is the size of all the files of the collector.
+	Size int `json:"size_bytes"`
+	// Truncated is set when the output was cut short, or left out, to respect the size limits.
+	Truncated  bool  `json:"truncated,omitempty"`
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// skippedCollectorReport describes a requested collector that wasn't run.
+type skippedCollectorReport struct {
+	UID    string `json:"uid"`
+	Reason string `json:"reason"`
+}
+
+// filenames returns the files written by the collector.
+func (r collectorReport) filenames() []string {
+	if len(r.Files) > 0 {
+		return r.Files
+	}
+	if r.Filename != "" {
+		return []string{r.Filename}
+	}
+	return nil
+}
+
+// hasFile reports whether the collector wrote the file with the given name.
+func (r collectorReport) hasFile(name string) bool {
+	for _, filename := range r.filenames() {
+		if filename == name {
+			return true
+		}
+	}
+	return false
+}
+
+// manifest is the machine-readable table of contents written to every bundle.
+type manifest struct {
+	BundleUID      string    `json:"bundle_uid,omitempty"`
+	Creator        string    `json:"creator"`
+	CreatedAt      time.Time `json:"created_at"`
+	GrafanaVersion string    `json:"grafana_version"`
+	// Truncated is set when the output of at least one collector was truncated.
+	Truncated  bool              `json:"truncated,omitempty"`
+	Collectors []collectorReport `json:"collectors"`
+	// Attachments are the files attached to the bundle when it was created.
+	Attachments []attachmentReport `json:"attachments,omitempty"`
+	// AutoAddedCollectors are the collectors added because of the problems detected
+	// when the bundle was created, see smart_collection.
+	AutoAddedCollectors []supportbundles.AutoAddedCollector `json:"auto_added_collectors,omitempty"`
+	// SkippedCollectors are the requested collectors left out because the creator
+	// isn't allowed to run them.
+	SkippedCollectors []skippedCollectorReport `json:"skipped_collectors,omitempty"`
+	// Partial is set when the bundle was downloaded while it was still being
+	// created, PendingCollectors are then the collectors that hadn't finished.
+	Partial           bool     `json:"partial,omitempty"`
+	PendingCollectors []string `json:"pending_collectors,omitempty"`
+}
+
+func (s *Service) manifest(bundleUID, creator string, reports []collectorReport, attachments []attachmentReport) ([]byte, error) {
+	return json.Marshal(s.newManifest(bundleUID, creator, reports, attachments))
+}
+
+func (s *Service) newManifest(bundleUID, creator string, reports []collectorReport, attachments []attachmentReport) manifest {
+	if reports == nil {
+		reports = []collectorReport{}
+	}
+
+	truncated := false
+	for _, report := range reports {
+		truncated = truncated || report.Truncated
+	}
+
+	return manifest{
+		BundleUID:      bundleUID,
+		Creator:        creator,
+		CreatedAt:      time.Now().UTC(),
+		GrafanaVersion: s.cfg.BuildVersion,
+		Truncated:      truncated,
+		Collectors:     reports,
+		Attachments:    attachments,
+	}
+}
@@ -0,0 +1,56 @@
+package supportbundlesimpl
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	metricsNamespace = "grafana"
+	metricsSubsystem = "support_bundles"
+)
+
+type metrics struct {
+	bundlesCreated    prometheus.Counter
+	bundlesFailed     *prometheus.CounterVec
+	bundleDuration    prometheus.Histogram
+	collectorDuration *prometheus.HistogramVec
+	bundlesPending    prometheus.Gauge
+}
+
+func newMetrics(r prometheus.Registerer) *metrics {
+	return &metrics{
+		bundlesCreated: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "created_total",
+			Help:      "Number of support bundles requested.",
+		}),
+		bundlesFailed: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "failed_total",
+			Help:      "Number of support bundles that failed, by final state.",
+		}, []string{"state"}),
+		bundleDuration: promauto.With(r).NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "creation_duration_seconds",
+			Help:      "Time taken to create a support bundle, whatever its final state.",
+			Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200},
+		}),
+		collectorDuration: promauto.With(r).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "collector_duration_seconds",
+			Help:      "Time taken by a support bundle collector to run.",
+			Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300},
+		}, []string{"collector"}),
+		bundlesPending: promauto.With(r).NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "pending",
+			Help:      "Number of support bundles currently being created.",
+		}),
+	}
+}
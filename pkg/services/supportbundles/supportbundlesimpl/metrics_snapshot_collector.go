@@ -0,0 +1,49 @@
+package supportbundlesimpl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// metricsSnapshotCollector writes the metrics of the gatherer in the Prometheus
+// text format, as served by /metrics. gatherer may be nil.
+func metricsSnapshotCollector(gatherer prometheus.Gatherer) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "metrics-snapshot",
+		DisplayName:       "Metrics snapshot",
+		Description:       "The metrics exposed by Grafana on /metrics at the time of the collection",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			if gatherer == nil {
+				return nil, errors.New("the metrics registry can't be gathered")
+			}
+
+			// failing collectors don't prevent the other metrics from being gathered
+			families, gatherErr := gatherer.Gather()
+
+			var buf bytes.Buffer
+			enc := expfmt.NewEncoder(&buf, expfmt.FmtText)
+			for _, family := range families {
+				if err := enc.Encode(family); err != nil {
+					return nil, err
+				}
+			}
+			if gatherErr != nil {
+				fmt.Fprintf(&buf, "# failed to gather some metrics: %s\n", gatherErr)
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "metrics.txt",
+				FileBytes: buf.Bytes(),
+			}, nil
+		},
+	}
+}
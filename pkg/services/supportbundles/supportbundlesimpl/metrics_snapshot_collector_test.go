@@ -0,0 +1,45 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// failingCollector is a prometheus collector that fails to collect.
+type failingCollector struct{}
+
+func (failingCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (failingCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.NewInvalidMetric(prometheus.NewDesc("broken", "Always fails", nil, nil), errors.New("boom"))
+}
+
+func TestMetricsSnapshotCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Namespace: "grafana", Name: "test_total", Help: "A test counter"})
+	counter.Add(3)
+	registry.MustRegister(counter)
+
+	item, err := metricsSnapshotCollector(registry).Fn(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "metrics.txt", item.Filename)
+	require.Contains(t, string(item.FileBytes), "# TYPE grafana_test_total counter\ngrafana_test_total 3\n")
+
+	t.Run("metrics are written even if some fail", func(t *testing.T) {
+		registry.MustRegister(failingCollector{})
+
+		item, err := metricsSnapshotCollector(registry).Fn(context.Background())
+		require.NoError(t, err)
+		require.Contains(t, string(item.FileBytes), "grafana_test_total 3")
+		require.Contains(t, string(item.FileBytes), "# failed to gather some metrics")
+	})
+
+	t.Run("a missing gatherer fails", func(t *testing.T) {
+		_, err := metricsSnapshotCollector(nil).Fn(context.Background())
+		require.Error(t, err)
+	})
+}
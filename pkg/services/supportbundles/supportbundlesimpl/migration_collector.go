@@ -0,0 +1,89 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+func migrationStatusCollector(sql db.DB) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "migrations",
+		DisplayName:       "Database migration status",
+		Description:       "Applied and failed database migrations from the migration log",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type migrationInfo struct {
+				MigrationID string    `json:"migration_id"`
+				Error       string    `json:"error,omitempty"`
+				Timestamp   time.Time `json:"timestamp"`
+			}
+			type migrationStatus struct {
+				TableExists     bool            `json:"table_exists"`    // TableExists is false on installs that never ran migrations.
+				Applied         []migrationInfo `json:"applied"`         // Applied are the successful migrations in the order they ran.
+				Failed          []migrationInfo `json:"failed"`          // Failed are the migrations recorded as unsuccessful.
+				LastAppliedID   string          `json:"last_applied_id"` // LastAppliedID is the most recently applied migration.
+				LastAppliedTime *time.Time      `json:"last_applied_at"` // LastAppliedTime is when the most recent migration was applied.
+				Note            string          `json:"note,omitempty"`  // Note explains why the migration log is empty.
+			}
+
+			status := migrationStatus{
+				Applied: []migrationInfo{},
+				Failed:  []migrationInfo{},
+			}
+
+			err := sql.WithDbSession(ctx, func(sess *db.Session) error {
+				exists, err := sess.IsTableExist("migration_log")
+				status.TableExists = exists
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			logItems := make([]migrator.MigrationLog, 0)
+			if !status.TableExists {
+				status.Note = "the migration_log table does not exist, database migrations have never run"
+			} else {
+				err = sql.WithDbSession(ctx, func(sess *db.Session) error {
+					return sess.Table("migration_log").Asc("id").Find(&logItems)
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			for _, logItem := range logItems {
+				info := migrationInfo{
+					MigrationID: logItem.MigrationID,
+					Error:       logItem.Error,
+					Timestamp:   logItem.Timestamp.UTC(),
+				}
+
+				if !logItem.Success {
+					status.Failed = append(status.Failed, info)
+					continue
+				}
+
+				status.Applied = append(status.Applied, info)
+				status.LastAppliedID = info.MigrationID
+				status.LastAppliedTime = &info.Timestamp
+			}
+
+			data, err := json.Marshal(status)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "migrations.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
@@ -0,0 +1,59 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+)
+
+func TestMigrationStatusCollector(t *testing.T) {
+	sqlStore := db.InitTestDB(t)
+
+	failed := &migrator.MigrationLog{
+		MigrationID: "support bundle test failing migration",
+		SQL:         "ALTER TABLE nope",
+		Success:     false,
+		Error:       "table nope does not exist",
+		Timestamp:   time.Now(),
+	}
+	require.NoError(t, sqlStore.WithDbSession(context.Background(), func(sess *db.Session) error {
+		_, err := sess.Table("migration_log").Insert(failed)
+		return err
+	}))
+	t.Cleanup(func() {
+		_ = sqlStore.WithDbSession(context.Background(), func(sess *db.Session) error {
+			_, err := sess.Exec("DELETE FROM migration_log WHERE migration_id = ?", failed.MigrationID)
+			return err
+		})
+	})
+
+	item, err := migrationStatusCollector(sqlStore).Fn(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "migrations.json", item.Filename)
+
+	var status struct {
+		TableExists bool `json:"table_exists"`
+		Applied     []struct {
+			MigrationID string `json:"migration_id"`
+		} `json:"applied"`
+		Failed []struct {
+			MigrationID string `json:"migration_id"`
+			Error       string `json:"error"`
+		} `json:"failed"`
+		LastAppliedID string `json:"last_applied_id"`
+	}
+	require.NoError(t, json.Unmarshal(item.FileBytes, &status))
+
+	require.True(t, status.TableExists)
+	require.NotEmpty(t, status.Applied)
+	require.Equal(t, status.Applied[len(status.Applied)-1].MigrationID, status.LastAppliedID)
+	require.Len(t, status.Failed, 1)
+	require.Equal(t, failed.MigrationID, status.Failed[0].MigrationID)
+	require.Equal(t, failed.Error, status.Failed[0].Error)
+}
@@ -11,6 +11,13 @@ const (
 	ActionDelete = "support.bundles:delete"
 )
 
+var (
+	// ScopeCollectorsProvider provides the scopes restricted collectors are checked against,
+	// e.g. support.bundles.collectors:uid:auth-config.
+	ScopeCollectorsProvider = accesscontrol.NewScopeProvider("support.bundles.collectors")
+	ScopeCollectorsAll      = ScopeCollectorsProvider.GetResourceAllScope()
+)
+
 var (
 	bundleReaderRole = accesscontrol.RoleDTO{
 		Name:        "fixed:support.bundles:reader",
@@ -29,10 +36,21 @@ var (
 		Group:       "Support bundles",
 		Permissions: []accesscontrol.Permission{
 			{Action: ActionRead},
-			{Action: ActionCreate},
+			{Action: ActionCreate, Scope: ScopeCollectorsAll},
 			{Action: ActionDelete},
 		},
 	}
+
+	bundleDiagnosticsWriterRole = accesscontrol.RoleDTO{
+		Name:        "fixed:support.bundles:diagnostics_writer",
+		DisplayName: "Support bundle diagnostics writer",
+		Description: "Create, list and download support bundles without restricted collectors",
+		Group:       "Support bundles",
+		Permissions: []accesscontrol.Permission{
+			{Action: ActionRead},
+			{Action: ActionCreate},
+		},
+	}
 )
 
 func (s *Service) declareFixedRoles(ac accesscontrol.Service) error {
@@ -50,5 +68,11 @@ func (s *Service) declareFixedRoles(ac accesscontrol.Service) error {
 		Grants: grants,
 	}
 
-	return ac.DeclareFixedRoles(bundleWriter, bundleReader)
+	// not granted to any basic role, it is meant to be assigned to users
+	// that should only collect diagnostics that don't touch on secrets
+	bundleDiagnosticsWriter := accesscontrol.RoleRegistration{
+		Role: bundleDiagnosticsWriterRole,
+	}
+
+	return ac.DeclareFixedRoles(bundleWriter, bundleReader, bundleDiagnosticsWriter)
 }
@@ -0,0 +1,247 @@
+package supportbundlesimpl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+const (
+	etcPath = "/etc"
+	// maxNetworkFileSize caps what is read of resolv.conf and hosts.
+	maxNetworkFileSize = 64 * 1024
+	// maxNetworkInterfaces caps the interfaces reported, hosts running many containers have hundreds.
+	maxNetworkInterfaces = 64
+	// maxReverseLookups caps the reverse DNS lookups of the addresses of the interfaces.
+	maxReverseLookups = 16
+	// dnsLookupTimeout bounds each DNS lookup, so that an unreachable resolver doesn't hold up the bundle.
+	dnsLookupTimeout = 2 * time.Second
+)
+
+// containerMarkers are found in the cgroup of processes running in a container
+// or, with cgroup v2, in their mounts, e.g. the resolv.conf of the container.
+var containerMarkers = []string{"docker", "kubepods", "kubelet", "containerd", "libpod", "lxc"}
+
+// networkCollector reports the hostname, network interfaces and DNS configuration
+// of the host, for data sources that can't be reached because their name doesn't
+// resolve. etcDir and procSelf are /etc and /proc/self, the files are read from
+// the container Grafana runs in, if any, which may differ from the host's.
+func networkCollector(etcDir, procSelf string, resolver *net.Resolver) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "network",
+		DisplayName:       "Network and DNS",
+		Description:       "Hostname, network interfaces and their addresses, reverse DNS and the resolv.conf and hosts files",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type address struct {
+				Address string `json:"address"`
+				// Reverse are the names the address resolves to.
+				Reverse      []string `json:"reverse,omitempty"`
+				ReverseError string   `json:"reverse_error,omitempty"`
+			}
+			type networkInterface struct {
+				Name         string    `json:"name"`
+				Index        int       `json:"index"`
+				MTU          int       `json:"mtu"`
+				Flags        string    `json:"flags"`
+				HardwareAddr string    `json:"hardware_addr,omitempty"`
+				Addresses    []address `json:"addresses"`
+				Error        string    `json:"error,omitempty"`
+			}
+			type resolvConf struct {
+				Nameservers []string `json:"nameservers"`
+				Search      []string `json:"search"`
+				Options     []string `json:"options"`
+			}
+			type network struct {
+				Hostname          string             `json:"hostname"`
+				HostnameAddresses []string           `json:"hostname_addresses,omitempty"`
+				HostnameError     string             `json:"hostname_error,omitempty"`
+				Interfaces        []networkInterface `json:"interfaces"`
+				InterfacesError   string             `json:"interfaces_error,omitempty"`
+				// Container is whether Grafana seems to run in a container, whose
+				// DNS configuration may differ from the host's.
+				Container bool `json:"container"`
+				// GoResolver is the GODEBUG netdns setting picking the DNS resolver, empty for the default.
+				GoResolver string            `json:"go_resolver,omitempty"`
+				ResolvConf *resolvConf       `json:"resolv_conf,omitempty"`
+				Files      map[string]string `json:"files"`
+				FileErrors map[string]string `json:"file_errors,omitempty"`
+				Notes      []string          `json:"notes"`
+			}
+
+			result := network{
+				Interfaces: []networkInterface{},
+				Files:      map[string]string{},
+				FileErrors: map[string]string{},
+				Notes:      []string{},
+				Container:  inContainer(procSelf),
+			}
+			for _, setting := range strings.Split(os.Getenv("GODEBUG"), ",") {
+				if strings.HasPrefix(setting, "netdns=") {
+					result.GoResolver = strings.TrimPrefix(setting, "netdns=")
+				}
+			}
+
+			hostname, err := os.Hostname()
+			if err != nil {
+				result.HostnameError = err.Error()
+			} else {
+				result.Hostname = hostname
+				lookupCtx, cancel := context.WithTimeout(ctx, dnsLookupTimeout)
+				result.HostnameAddresses, err = resolver.LookupHost(lookupCtx, hostname)
+				cancel()
+				if err != nil {
+					result.HostnameError = err.Error()
+				}
+			}
+
+			interfaces, err := net.Interfaces()
+			if err != nil {
+				result.InterfacesError = err.Error()
+			}
+			if len(interfaces) > maxNetworkInterfaces {
+				result.Notes = append(result.Notes, fmt.Sprintf("only the first %d network interfaces are listed", maxNetworkInterfaces))
+				interfaces = interfaces[:maxNetworkInterfaces]
+			}
+			lookups := 0
+			for _, iface := range interfaces {
+				ni := networkInterface{
+					Name:         iface.Name,
+					Index:        iface.Index,
+					MTU:          iface.MTU,
+					Flags:        iface.Flags.String(),
+					HardwareAddr: iface.HardwareAddr.String(),
+					Addresses:    []address{},
+				}
+				addrs, err := iface.Addrs()
+				if err != nil {
+					ni.Error = err.Error()
+				}
+				for _, addr := range addrs {
+					a := address{Address: addr.String()}
+					ip, _, err := net.ParseCIDR(addr.String())
+					if err == nil && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && lookups < maxReverseLookups {
+						lookups++
+						lookupCtx, cancel := context.WithTimeout(ctx, dnsLookupTimeout)
+						a.Reverse, err = resolver.LookupAddr(lookupCtx, ip.String())
+						cancel()
+						if err != nil {
+							a.ReverseError = err.Error()
+						}
+					}
+					ni.Addresses = append(ni.Addresses, a)
+				}
+				result.Interfaces = append(result.Interfaces, ni)
+			}
+			if lookups == maxReverseLookups {
+				result.Notes = append(result.Notes, fmt.Sprintf("reverse DNS lookups are limited to %d addresses", maxReverseLookups))
+			}
+
+			for _, name := range []string{"resolv.conf", "hosts", "nsswitch.conf"} {
+				content, truncated, err := readCapped(filepath.Join(etcDir, name), maxNetworkFileSize)
+				if err != nil {
+					result.FileErrors[name] = err.Error()
+					continue
+				}
+				result.Files[name] = string(content)
+				if truncated {
+					result.Notes = append(result.Notes, fmt.Sprintf("%s is cut to its first %d bytes", name, maxNetworkFileSize))
+				}
+				if name == "resolv.conf" {
+					nameservers, search, options := parseResolvConf(content)
+					result.ResolvConf = &resolvConf{Nameservers: nameservers, Search: search, Options: options}
+				}
+			}
+			if result.Container {
+				result.Notes = append(result.Notes, "Grafana runs in a container, the files are the container's and may differ from the host's")
+			}
+			if result.ResolvConf != nil && len(result.ResolvConf.Nameservers) == 0 {
+				result.Notes = append(result.Notes, "resolv.conf has no nameserver, names are resolved through the local host")
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "network.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+// readCapped returns up to max bytes of the file at path, and whether it's longer.
+func readCapped(path string, max int64) ([]byte, bool, error) {
+	// the path is one of the system files read by the network collector
+	// nolint:gosec
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = f.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(f, max+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(data)) > max {
+		return data[:max], true, nil
+	}
+	return data, false, nil
+}
+
+// parseResolvConf returns the name servers, search domains and options of a resolv.conf file.
+func parseResolvConf(content []byte) ([]string, []string, []string) {
+	nameservers, search, options := []string{}, []string{}, []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			nameservers = append(nameservers, fields[1])
+		case "search", "domain":
+			search = append(search, fields[1:]...)
+		case "options":
+			options = append(options, fields[1:]...)
+		}
+	}
+	return nameservers, search, options
+}
+
+// inContainer reports whether the process seems to run in a container, based on
+// its cgroup and mounts read from procSelf.
+func inContainer(procSelf string) bool {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return true
+	}
+	for _, name := range []string{"cgroup", "mountinfo"} {
+		// mountinfo is larger, the mounts of the container come first
+		data, _, err := readCapped(filepath.Join(procSelf, name), maxNetworkFileSize)
+		if err != nil {
+			continue
+		}
+		for _, marker := range containerMarkers {
+			if bytes.Contains(data, []byte(marker)) {
+				return true
+			}
+		}
+	}
+	return false
+}
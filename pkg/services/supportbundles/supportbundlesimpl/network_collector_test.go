@@ -0,0 +1,95 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkCollector(t *testing.T) {
+	type network struct {
+		Hostname   string `json:"hostname"`
+		Interfaces []struct {
+			Name      string `json:"name"`
+			Addresses []struct {
+				Address string `json:"address"`
+			} `json:"addresses"`
+		} `json:"interfaces"`
+		Container  bool `json:"container"`
+		ResolvConf *struct {
+			Nameservers []string `json:"nameservers"`
+			Search      []string `json:"search"`
+			Options     []string `json:"options"`
+		} `json:"resolv_conf"`
+		Files      map[string]string `json:"files"`
+		FileErrors map[string]string `json:"file_errors"`
+		Notes      []string          `json:"notes"`
+	}
+
+	// DNS lookups fail right away instead of reaching out to the network
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, errors.New("no DNS in tests")
+		},
+	}
+	collect := func(t *testing.T, etcDir, procSelf string) network {
+		t.Helper()
+
+		item, err := networkCollector(etcDir, procSelf, resolver).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "network.json", item.Filename)
+
+		var result network
+		require.NoError(t, json.Unmarshal(item.FileBytes, &result))
+		return result
+	}
+	writeFile := func(t *testing.T, path, content string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+
+	t.Run("reports the interfaces and DNS configuration", func(t *testing.T) {
+		t.Setenv("KUBERNETES_SERVICE_HOST", "")
+		etcDir, procSelf := t.TempDir(), t.TempDir()
+		writeFile(t, filepath.Join(etcDir, "resolv.conf"), "# generated\nnameserver 10.0.0.2\nnameserver 10.0.0.3\nsearch svc.cluster.local cluster.local\noptions ndots:5\n")
+		writeFile(t, filepath.Join(etcDir, "hosts"), "127.0.0.1 localhost\n")
+		writeFile(t, filepath.Join(procSelf, "cgroup"), "0::/\n")
+
+		result := collect(t, etcDir, procSelf)
+		hostname, err := os.Hostname()
+		require.NoError(t, err)
+		require.Equal(t, hostname, result.Hostname)
+		require.NotEmpty(t, result.Interfaces)
+		require.False(t, result.Container)
+
+		require.NotNil(t, result.ResolvConf)
+		require.Equal(t, []string{"10.0.0.2", "10.0.0.3"}, result.ResolvConf.Nameservers)
+		require.Equal(t, []string{"svc.cluster.local", "cluster.local"}, result.ResolvConf.Search)
+		require.Equal(t, []string{"ndots:5"}, result.ResolvConf.Options)
+		require.Equal(t, "127.0.0.1 localhost\n", result.Files["hosts"])
+		require.Contains(t, result.FileErrors, "nsswitch.conf")
+	})
+
+	t.Run("caps the files and detects containers", func(t *testing.T) {
+		t.Setenv("KUBERNETES_SERVICE_HOST", "")
+		etcDir, procSelf := t.TempDir(), t.TempDir()
+		writeFile(t, filepath.Join(etcDir, "hosts"), strings.Repeat("10.0.0.1 host.example.com\n", maxNetworkFileSize))
+		writeFile(t, filepath.Join(procSelf, "cgroup"), "0::/\n")
+		writeFile(t, filepath.Join(procSelf, "mountinfo"), "1 0 0:1 /docker/containers/abc/resolv.conf /etc/resolv.conf rw - ext4 /dev/sda1 rw\n")
+
+		result := collect(t, etcDir, procSelf)
+		require.True(t, result.Container)
+		require.Len(t, result.Files["hosts"], maxNetworkFileSize)
+		require.Nil(t, result.ResolvConf)
+		require.Contains(t, result.FileErrors, "resolv.conf")
+		require.Len(t, result.Notes, 2)
+	})
+}
@@ -0,0 +1,106 @@
+package supportbundlesimpl
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/supportbundles/bundleregistry"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// registerOfflineCollectors registers the collectors that only depend on the
+// configuration and the database, and can therefore run without a Grafana server.
+func (s *Service) registerOfflineCollectors(cfg *setting.Cfg, sql db.DB, settings setting.Provider) {
+	section := cfg.SectionWithEnvOverrides("support_bundles")
+	s.registerCollector(basicCollector(cfg))
+	s.registerCollector(buildInfoCollector(cfg))
+	s.registerCollector(authConfigCollector(cfg))
+	s.registerCollector(settingsCollector(settings))
+	s.registerCollector(configReloadCollector(settings))
+	s.registerCollector(dbCollector(sql))
+	s.registerCollector(migrationStatusCollector(sql))
+	s.registerCollector(dbPoolCollector(sql))
+	s.registerCollector(instanceStatsCollector(sql))
+	s.registerCollector(queryHistoryCollector(sql, section.Key("query_history_include_queries").MustBool(false)))
+	s.registerCollector(annotationsCollector(sql))
+	s.registerCollector(datasourceCollector(sql))
+	s.registerCollector(serviceAccountsCollector(sql))
+	s.registerCollector(sessionsCollector(cfg, sql))
+	s.registerCollector(logTailCollector(cfg))
+	s.registerCollector(provisioningCollector(cfg))
+	s.registerCollector(tlsCollector(cfg))
+	s.registerCollector(proxyConfigCollector(cfg))
+	s.registerCollector(httpServerCollector(cfg))
+	s.registerCollector(diskUsageCollector(cfg))
+	s.registerCollector(smtpCollector(cfg, section.Key("smtp_connection_test").MustBool(false)))
+	s.registerCollector(imageStorageCollector(cfg, section.Key("image_storage_reachability_test").MustBool(false)))
+	s.registerCollector(clockCollector(section.Key("clock_ntp_server").MustString("")))
+	s.registerCollector(egressCollector(cfg, section.Key("egress_connectivity_test").MustBool(false),
+		section.Key("egress_test_url").MustString("")))
+	s.registerCollector(secretsStatusCollector(cfg, sql))
+	s.registerCollector(networkCollector(etcPath, procSelfPath, net.DefaultResolver))
+}
+
+// OfflineBundleExtension returns the file extension of bundles created by CreateOfflineBundle.
+func OfflineBundleExtension(cfg *setting.Cfg) string {
+	return parseArchiveFormat(log.New("supportbundle.offline"), cfg.SectionWithEnvOverrides("support_bundles").Key("format").MustString(formatTarGz))
+}
+
+// CreateOfflineBundle writes a support bundle to w without a running Grafana
+// server, e.g. from grafana-cli when the instance fails to start. Only
+// collectors that don't depend on server services are available; the
+// requested collectors that aren't are returned as skipped.
+func CreateOfflineBundle(ctx context.Context, cfg *setting.Cfg, sql db.DB, settings setting.Provider,
+	registry *bundleregistry.Service, collectors []string, w io.Writer) ([]string, error) {
+	section := cfg.SectionWithEnvOverrides("support_bundles")
+	logger := log.New("supportbundle.offline")
+	s := &Service{
+		cfg:                     cfg,
+		bundleRegistry:          registry,
+		log:                     logger,
+		defaultCollectorTimeout: section.Key("collector_timeout").MustDuration(defaultCollectorTimeout),
+		collectorTimeouts:       readCollectorTimeouts(logger, cfg),
+		redactor:                newRedactor(util.SplitString(section.Key("redact_keys").MustString(strings.Join(defaultRedactKeys, ",")))),
+		archiveFormat:           parseArchiveFormat(logger, section.Key("format").MustString(formatTarGz)),
+		compressionLevel:        parseCompressionLevel(logger, section.Key("compression_level").MustInt(gzip.DefaultCompression)),
+		maxSize:                 section.Key("max_size").MustInt64(defaultMaxSizeMB) * 1024 * 1024,
+		collectorMaxSize:        section.Key("collector_max_size").MustInt64(defaultCollectorMaxSizeMB) * 1024 * 1024,
+		collectorWorkers:        section.Key("collector_workers").MustInt(defaultCollectorWorkers),
+		disabledCollectors:      readDisabledCollectors(cfg),
+		// there is no metrics endpoint to scrape when running offline
+		metrics: newMetrics(prometheus.NewRegistry()),
+	}
+	s.registerOfflineCollectors(cfg, sql, settings)
+
+	available := make([]string, 0, len(collectors))
+	skipped := make([]string, 0)
+	for _, uid := range collectors {
+		if _, ok := registry.Collectors()[uid]; ok {
+			available = append(available, uid)
+		} else {
+			skipped = append(skipped, uid)
+		}
+	}
+
+	files, reports := s.collect(ctx, s.selectCollectors(available), func(progress int, currentCollector string) {
+		if currentCollector != "" {
+			s.log.Info("Collecting support bundle item", "collector", currentCollector, "progress", progress)
+		}
+	}, nil)
+
+	manifest, err := s.manifest("", "grafana-cli", reports, nil)
+	if err != nil {
+		return skipped, err
+	}
+	files[manifestFilename] = manifest
+
+	return skipped, s.archive(files, w)
+}
@@ -0,0 +1,55 @@
+package supportbundlesimpl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/supportbundles/bundleregistry"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestCreateOfflineBundle(t *testing.T) {
+	cfg := setting.NewCfg()
+	cfg.DataPath = t.TempDir()
+	cfg.LogsPath = t.TempDir()
+	cfg.BuildVersion = "10.0.0"
+
+	registry := bundleregistry.ProvideService()
+	var buf bytes.Buffer
+	skipped, err := CreateOfflineBundle(context.Background(), cfg, db.InitTestDB(t), setting.ProvideProvider(cfg),
+		registry, []string{"basic", "build-info", "goroutine-profile"}, &buf)
+	require.NoError(t, err)
+
+	// the profiles need the running server
+	assert.Equal(t, []string{"goroutine-profile"}, skipped)
+	assert.Contains(t, registry.Collectors(), "db")
+	assert.NotContains(t, registry.Collectors(), "goroutine-profile")
+	assert.Equal(t, formatTarGz, OfflineBundleExtension(cfg))
+
+	files := readBundle(t, buf.Bytes())
+	require.Contains(t, files, "/bundle/basic.json")
+	require.Contains(t, files, "/bundle/build-info.json")
+
+	var basic struct {
+		Version string `json:"version"`
+	}
+	require.NoError(t, json.Unmarshal(files["/bundle/basic.json"], &basic))
+	assert.Equal(t, "10.0.0", basic.Version)
+
+	var m manifest
+	require.NoError(t, json.Unmarshal(files["/bundle/"+manifestFilename], &m))
+	assert.Equal(t, "grafana-cli", m.Creator)
+	assert.Equal(t, "10.0.0", m.GrafanaVersion)
+	uids := make([]string, 0, len(m.Collectors))
+	for _, report := range m.Collectors {
+		assert.True(t, report.Success, report.UID)
+		uids = append(uids, report.UID)
+	}
+	assert.ElementsMatch(t, []string{"basic", "build-info"}, uids)
+}
@@ -0,0 +1,234 @@
+package supportbundlesimpl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+const (
+	procSelfPath = "/proc/self"
+	cgroupPath   = "/sys/fs/cgroup"
+)
+
+// resourceLimit is a soft and hard limit of the process, -1 when unlimited.
+type resourceLimit struct {
+	Soft int64 `json:"soft"`
+	Hard int64 `json:"hard"`
+}
+
+// osLimitsCollector reports the resource limits of the Grafana process, the cgroup
+// limits of its container and how many files it has open, for "too many open
+// files" crashes. procSelf and cgroupRoot are /proc/self and /sys/fs/cgroup, the
+// values missing on other platforms than Linux are reported as errors.
+func osLimitsCollector(procSelf, cgroupRoot string) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "os-limits",
+		DisplayName:       "OS resource limits",
+		Description:       "Open files and processes limits, container memory and CPU limits and open file descriptors",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type cgroupLimits struct {
+				Version int `json:"version"`
+				// The limits are -1 when unlimited and missing when unknown.
+				MemoryLimitBytes *int64   `json:"memory_limit_bytes,omitempty"`
+				MemoryUsageBytes *int64   `json:"memory_usage_bytes,omitempty"`
+				CPULimitCores    *float64 `json:"cpu_limit_cores,omitempty"`
+				PidsLimit        *int64   `json:"pids_limit,omitempty"`
+			}
+			type osLimits struct {
+				OS         string `json:"os"`
+				NumCPU     int    `json:"num_cpu"`
+				GoMaxProcs int    `json:"gomaxprocs"`
+
+				OpenFilesLimit      *resourceLimit `json:"open_files_limit,omitempty"`
+				OpenFilesLimitError string         `json:"open_files_limit_error,omitempty"`
+				ProcessesLimit      *resourceLimit `json:"processes_limit,omitempty"`
+				ProcessesLimitError string         `json:"processes_limit_error,omitempty"`
+
+				OpenFileDescriptors      *int     `json:"open_file_descriptors,omitempty"`
+				OpenFileDescriptorsError string   `json:"open_file_descriptors_error,omitempty"`
+				OpenFilesUsedPercent     *float64 `json:"open_files_used_percent,omitempty"`
+
+				Cgroup      *cgroupLimits `json:"cgroup,omitempty"`
+				CgroupError string        `json:"cgroup_error,omitempty"`
+			}
+
+			result := osLimits{
+				OS:         runtime.GOOS,
+				NumCPU:     runtime.NumCPU(),
+				GoMaxProcs: runtime.GOMAXPROCS(0),
+			}
+
+			procLimits, procErr := readProcLimits(filepath.Join(procSelf, "limits"))
+			if limit, err := openFilesLimit(); err == nil {
+				result.OpenFilesLimit = &limit
+			} else if limit, ok := procLimits["Max open files"]; ok {
+				result.OpenFilesLimit = &limit
+			} else {
+				result.OpenFilesLimitError = err.Error()
+			}
+			if limit, ok := procLimits["Max processes"]; ok {
+				result.ProcessesLimit = &limit
+			} else if procErr != nil {
+				result.ProcessesLimitError = procErr.Error()
+			} else {
+				result.ProcessesLimitError = "not reported by the kernel"
+			}
+
+			if entries, err := os.ReadDir(filepath.Join(procSelf, "fd")); err == nil {
+				open := len(entries)
+				result.OpenFileDescriptors = &open
+				if limit := result.OpenFilesLimit; limit != nil && limit.Soft > 0 {
+					used := float64(open) * 100 / float64(limit.Soft)
+					result.OpenFilesUsedPercent = &used
+				}
+			} else {
+				result.OpenFileDescriptorsError = err.Error()
+			}
+
+			if version, ok := cgroupVersion(cgroupRoot); ok {
+				cg := &cgroupLimits{Version: version}
+				if version == 2 {
+					cg.MemoryLimitBytes = readCgroupInt(filepath.Join(cgroupRoot, "memory.max"))
+					cg.MemoryUsageBytes = readCgroupInt(filepath.Join(cgroupRoot, "memory.current"))
+					cg.CPULimitCores = readCgroupV2CPU(filepath.Join(cgroupRoot, "cpu.max"))
+					cg.PidsLimit = readCgroupInt(filepath.Join(cgroupRoot, "pids.max"))
+				} else {
+					cg.MemoryLimitBytes = readCgroupInt(filepath.Join(cgroupRoot, "memory", "memory.limit_in_bytes"))
+					cg.MemoryUsageBytes = readCgroupInt(filepath.Join(cgroupRoot, "memory", "memory.usage_in_bytes"))
+					cg.CPULimitCores = readCgroupV1CPU(filepath.Join(cgroupRoot, "cpu"))
+					cg.PidsLimit = readCgroupInt(filepath.Join(cgroupRoot, "pids", "pids.max"))
+				}
+				result.Cgroup = cg
+			} else {
+				result.CgroupError = "no cgroup filesystem found at " + cgroupRoot
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "os-limits.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+// readProcLimits parses the limits file of a process, whose lines are the name of
+// the limit, its soft and hard values and its unit.
+func readProcLimits(path string) (map[string]resourceLimit, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := map[string]resourceLimit{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// the units column is missing for the limits without a unit
+		for i := 1; i+1 < len(fields); i++ {
+			soft, softOK := parseLimit(fields[i])
+			hard, hardOK := parseLimit(fields[i+1])
+			if softOK && hardOK {
+				limits[strings.Join(fields[:i], " ")] = resourceLimit{Soft: soft, Hard: hard}
+				break
+			}
+		}
+	}
+	return limits, scanner.Err()
+}
+
+// parseLimit parses a limit written by the kernel, unlimited and max are -1.
+func parseLimit(s string) (int64, bool) {
+	if s == "unlimited" || s == "max" {
+		return -1, true
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	// cgroup v1 writes a page aligned math.MaxInt64 for unlimited memory
+	if v >= math.MaxInt64-1<<20 {
+		return -1, true
+	}
+	return int64(v), true
+}
+
+// cgroupVersion returns the version of the cgroup hierarchy mounted at root.
+func cgroupVersion(root string) (int, bool) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		return 2, true
+	}
+	if _, err := os.Stat(filepath.Join(root, "memory")); err == nil {
+		return 1, true
+	}
+	return 0, false
+}
+
+// readCgroupInt returns nil when the file is missing, as in the root cgroup of a host.
+func readCgroupInt(path string) *int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	v, ok := parseLimit(strings.TrimSpace(string(data)))
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+// readCgroupV2CPU reads cpu.max, the quota and the period in microseconds.
+func readCgroupV2CPU(path string) *float64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	quota, period, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	return cpuCores(quota, period)
+}
+
+// readCgroupV1CPU reads cpu.cfs_quota_us and cpu.cfs_period_us, -1 is no quota.
+func readCgroupV1CPU(dir string) *float64 {
+	quota, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_quota_us"))
+	if err != nil {
+		return nil
+	}
+	period, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_period_us"))
+	if err != nil {
+		return nil
+	}
+	return cpuCores(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func cpuCores(quota, period string) *float64 {
+	unlimited := -1.0
+	if quota == "max" || quota == "-1" {
+		return &unlimited
+	}
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil {
+		return nil
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return nil
+	}
+	cores := q / p
+	return &cores
+}
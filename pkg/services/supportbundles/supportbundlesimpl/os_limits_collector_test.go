@@ -0,0 +1,115 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOSLimitsCollector(t *testing.T) {
+	type osLimits struct {
+		OS                  string         `json:"os"`
+		OpenFilesLimit      *resourceLimit `json:"open_files_limit"`
+		ProcessesLimit      *resourceLimit `json:"processes_limit"`
+		ProcessesLimitError string         `json:"processes_limit_error"`
+		OpenFileDescriptors *int           `json:"open_file_descriptors"`
+		Cgroup              *struct {
+			Version          int      `json:"version"`
+			MemoryLimitBytes *int64   `json:"memory_limit_bytes"`
+			MemoryUsageBytes *int64   `json:"memory_usage_bytes"`
+			CPULimitCores    *float64 `json:"cpu_limit_cores"`
+			PidsLimit        *int64   `json:"pids_limit"`
+		} `json:"cgroup"`
+		CgroupError string `json:"cgroup_error"`
+	}
+
+	writeFiles := func(t *testing.T, dir string, files map[string]string) {
+		t.Helper()
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+			require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		}
+	}
+	collect := func(t *testing.T, procSelf, cgroupRoot string) osLimits {
+		t.Helper()
+
+		item, err := osLimitsCollector(procSelf, cgroupRoot).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "os-limits.json", item.Filename)
+
+		var result osLimits
+		require.NoError(t, json.Unmarshal(item.FileBytes, &result))
+		return result
+	}
+
+	procSelf := t.TempDir()
+	writeFiles(t, procSelf, map[string]string{
+		"limits": `Limit                     Soft Limit           Hard Limit           Units
+Max cpu time              unlimited            unlimited            seconds
+Max processes             4096                 unlimited            processes
+Max open files            1024                 524288               files
+Max realtime timeout      unlimited            unlimited            us
+`,
+		"fd/0": "", "fd/1": "", "fd/2": "",
+	})
+
+	t.Run("reads the process limits and open files", func(t *testing.T) {
+		result := collect(t, procSelf, t.TempDir())
+		require.Equal(t, runtime.GOOS, result.OS)
+		require.Equal(t, &resourceLimit{Soft: 4096, Hard: -1}, result.ProcessesLimit)
+		require.NotNil(t, result.OpenFilesLimit)
+		require.Equal(t, 3, *result.OpenFileDescriptors)
+		require.Nil(t, result.Cgroup)
+		require.NotEmpty(t, result.CgroupError)
+	})
+
+	t.Run("reads cgroup v2 limits", func(t *testing.T) {
+		cgroupRoot := t.TempDir()
+		writeFiles(t, cgroupRoot, map[string]string{
+			"cgroup.controllers": "cpu memory pids\n",
+			"memory.max":         "536870912\n",
+			"memory.current":     "104857600\n",
+			"cpu.max":            "150000 100000\n",
+			"pids.max":           "max\n",
+		})
+
+		cg := collect(t, procSelf, cgroupRoot).Cgroup
+		require.NotNil(t, cg)
+		require.Equal(t, 2, cg.Version)
+		require.EqualValues(t, 536870912, *cg.MemoryLimitBytes)
+		require.EqualValues(t, 104857600, *cg.MemoryUsageBytes)
+		require.Equal(t, 1.5, *cg.CPULimitCores)
+		require.EqualValues(t, -1, *cg.PidsLimit)
+	})
+
+	t.Run("reads cgroup v1 limits", func(t *testing.T) {
+		cgroupRoot := t.TempDir()
+		writeFiles(t, cgroupRoot, map[string]string{
+			"memory/memory.limit_in_bytes": "9223372036854771712\n",
+			"memory/memory.usage_in_bytes": "2048\n",
+			"cpu/cpu.cfs_quota_us":         "-1\n",
+			"cpu/cpu.cfs_period_us":        "100000\n",
+		})
+
+		cg := collect(t, procSelf, cgroupRoot).Cgroup
+		require.NotNil(t, cg)
+		require.Equal(t, 1, cg.Version)
+		require.EqualValues(t, -1, *cg.MemoryLimitBytes)
+		require.EqualValues(t, 2048, *cg.MemoryUsageBytes)
+		require.Equal(t, -1.0, *cg.CPULimitCores)
+		require.Nil(t, cg.PidsLimit)
+	})
+
+	t.Run("reports what can't be read", func(t *testing.T) {
+		result := collect(t, filepath.Join(t.TempDir(), "missing"), t.TempDir())
+		require.Nil(t, result.ProcessesLimit)
+		require.NotEmpty(t, result.ProcessesLimitError)
+		require.Nil(t, result.OpenFileDescriptors)
+	})
+}
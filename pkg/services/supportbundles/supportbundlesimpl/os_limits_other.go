@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd
+
+package supportbundlesimpl
+
+import "errors"
+
+var errOSLimitsUnsupported = errors.New("resource limits are not supported on this platform")
+
+func openFilesLimit() (resourceLimit, error) {
+	return resourceLimit{}, errOSLimitsUnsupported
+}
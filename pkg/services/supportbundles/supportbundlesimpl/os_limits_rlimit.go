@@ -0,0 +1,26 @@
+//go:build linux || darwin || freebsd
+
+package supportbundlesimpl
+
+import (
+	"math"
+	"syscall"
+)
+
+func openFilesLimit() (resourceLimit, error) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return resourceLimit{}, err
+	}
+
+	//nolint:unconvert // the field types differ between platforms
+	return resourceLimit{Soft: rlimitValue(uint64(limit.Cur)), Hard: rlimitValue(uint64(limit.Max))}, nil
+}
+
+// rlimitValue returns -1 for RLIM_INFINITY, which is the largest value of the field type.
+func rlimitValue(v uint64) int64 {
+	if v >= math.MaxInt64 {
+		return -1
+	}
+	return int64(v)
+}
@@ -0,0 +1,135 @@
+package supportbundlesimpl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// partialBundleHeader is set on the responses serving bundles still being created.
+const partialBundleHeader = "X-Grafana-Support-Bundle-Partial"
+
+var ErrPartialBundleNotAvailable = errors.New("support bundle is not being created by this instance")
+
+// partialBundle is the output of the collectors of a bundle being created that
+// are done, so that it can be downloaded before the collection completes.
+type partialBundle struct {
+	mu      sync.Mutex
+	base    *bundleContents
+	pending map[string]bool
+	order   []string
+	files   map[string]collectorFile
+	reports []collectorReport
+}
+
+func newPartialBundle(collectors []supportbundles.Collector, base *bundleContents) *partialBundle {
+	p := &partialBundle{
+		base:    base,
+		pending: make(map[string]bool, len(collectors)),
+		order:   make([]string, 0, len(collectors)),
+		files:   map[string]collectorFile{},
+	}
+	for _, collector := range collectors {
+		p.pending[collector.UID] = true
+		p.order = append(p.order, collector.UID)
+	}
+	return p
+}
+
+// add records the output of a collector that is done. It's safe to call while
+// the partial bundle is being read, the files are read when it is.
+func (p *partialBundle) add(report collectorReport, files []collectorFile) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.pending, report.UID)
+	for _, f := range files {
+		p.files[f.name] = f
+	}
+	p.reports = append(p.reports, report)
+}
+
+// snapshot returns the files and reports of the collectors done so far, merged
+// into the base of the bundle, and the UIDs of the collectors still running.
+func (p *partialBundle) snapshot() (map[string][]byte, []collectorReport, []attachmentReport, []string) {
+	p.mu.Lock()
+	spooled := make([]collectorFile, 0, len(p.files))
+	for _, f := range p.files {
+		spooled = append(spooled, f)
+	}
+	reports := append([]collectorReport(nil), p.reports...)
+	pending := make([]string, 0, len(p.pending))
+	for _, uid := range p.order {
+		if p.pending[uid] {
+			pending = append(pending, uid)
+		}
+	}
+	p.mu.Unlock()
+
+	files := make(map[string][]byte, len(spooled))
+	for _, f := range spooled {
+		data, err := f.read()
+		if err != nil {
+			// the spool is removed once the bundle is archived, which may race with the snapshot
+			continue
+		}
+		files[f.name] = data
+	}
+
+	var attachments []attachmentReport
+	if p.base != nil {
+		files, reports = p.base.merge(files, reports)
+		attachments = p.base.attachments
+	}
+	return files, reports, attachments, pending
+}
+
+// trackPartial makes the output of the collectors of a bundle available as they
+// finish, until untrackPartial is called.
+func (s *Service) trackPartial(uid string, collectors []supportbundles.Collector, base *bundleContents) *partialBundle {
+	p := newPartialBundle(collectors, base)
+
+	s.partialsMu.Lock()
+	defer s.partialsMu.Unlock()
+	s.partials[uid] = p
+	return p
+}
+
+func (s *Service) untrackPartial(uid string) {
+	s.partialsMu.Lock()
+	defer s.partialsMu.Unlock()
+	delete(s.partials, uid)
+}
+
+// partialArchive archives the output of the collectors of a bundle being created
+// that are done so far. Its manifest is marked as partial and lists the collectors
+// that are still running.
+func (s *Service) partialArchive(bundle *supportbundles.Bundle) ([]byte, error) {
+	s.partialsMu.Lock()
+	p, ok := s.partials[bundle.UID]
+	s.partialsMu.Unlock()
+	if !ok {
+		return nil, ErrPartialBundleNotAvailable
+	}
+
+	files, reports, attachments, pending := p.snapshot()
+	m := s.newManifest(bundle.UID, bundle.Creator, reports, attachments)
+	m.AutoAddedCollectors = bundle.AutoAddedCollectors
+	m.SkippedCollectors = skippedCollectorReports(bundle.SkippedCollectors)
+	m.Partial = true
+	m.PendingCollectors = pending
+	manifest, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	files[manifestFilename] = manifest
+
+	var buf bytes.Buffer
+	if err := s.archive(files, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
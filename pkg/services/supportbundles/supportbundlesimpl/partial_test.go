@@ -0,0 +1,104 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestService_partialArchive(t *testing.T) {
+	release := make(chan struct{})
+	fast := newTestCollector("a-fast", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "fast.txt", FileBytes: []byte("fast")}, nil
+	})
+	slow := newTestCollector("b-slow", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		<-release
+		return &supportbundles.SupportItem{Filename: "slow.txt", FileBytes: []byte("slow")}, nil
+	})
+	s := newTestService(t, fast, slow)
+	s.defaultCollectorTimeout = 5 * time.Second
+	s.maxAttachments = 1
+	s.attachmentMaxSize = 64
+	s.attachmentsMaxSize = 64
+
+	bundle, err := s.create(context.Background(), &user.SignedInUser{Login: "admin"}, createOptions{
+		Attachments: []attachment{{Name: "notes.txt", Data: []byte("notes")}},
+	})
+	require.NoError(t, err)
+
+	var files map[string][]byte
+	require.Eventually(t, func() bool {
+		archive, err := s.partialArchive(bundle)
+		if err != nil {
+			return false
+		}
+		files = readBundle(t, archive)
+		return files["/bundle/fast.txt"] != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Equal(t, "notes", string(files["/bundle/attachments/notes.txt"]))
+	require.NotContains(t, files, "/bundle/slow.txt")
+
+	var m manifest
+	require.NoError(t, json.Unmarshal(files["/bundle/"+manifestFilename], &m))
+	require.True(t, m.Partial)
+	require.Equal(t, []string{"b-slow"}, m.PendingCollectors)
+	require.Len(t, m.Collectors, 1)
+	require.Equal(t, "a-fast", m.Collectors[0].UID)
+	require.Len(t, m.Attachments, 1)
+
+	close(release)
+	require.Eventually(t, func() bool { return len(s.creationSlots) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	_, err = s.partialArchive(bundle)
+	require.ErrorIs(t, err, ErrPartialBundleNotAvailable)
+
+	stored, err := s.store.Get(context.Background(), bundle.UID)
+	require.NoError(t, err)
+	complete := readBundle(t, stored.TarBytes)
+	require.Contains(t, complete, "/bundle/slow.txt")
+	var completeManifest manifest
+	require.NoError(t, json.Unmarshal(complete["/bundle/"+manifestFilename], &completeManifest))
+	require.False(t, completeManifest.Partial)
+	require.Empty(t, completeManifest.PendingCollectors)
+}
+
+func TestPartialBundle_concurrentReads(t *testing.T) {
+	collectors := make([]supportbundles.Collector, 50)
+	for i := range collectors {
+		collectors[i] = supportbundles.Collector{UID: fmt.Sprintf("collector-%02d", i)}
+	}
+	p := newPartialBundle(collectors, nil)
+
+	var wg sync.WaitGroup
+	for i := range collectors {
+		wg.Add(1)
+		go func(uid string) {
+			defer wg.Done()
+			p.add(collectorReport{UID: uid, Filename: uid + ".txt", Success: true}, []collectorFile{{name: uid + ".txt", data: []byte(uid), size: int64(len(uid))}})
+		}(collectors[i].UID)
+	}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			files, reports, _, pending := p.snapshot()
+			require.Len(t, files, len(reports))
+			require.Equal(t, len(collectors), len(reports)+len(pending))
+		}()
+	}
+	wg.Wait()
+
+	files, reports, _, pending := p.snapshot()
+	require.Len(t, files, len(collectors))
+	require.Len(t, reports, len(collectors))
+	require.Empty(t, pending)
+}
@@ -0,0 +1,117 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+const pluginHealthCheckTimeout = 10 * time.Second
+
+func pluginHealthCollector(pluginStore plugins.Store, pluginClient plugins.Client, checkTimeout time.Duration) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "plugin-health",
+		DisplayName:       "Plugin health checks",
+		Description:       "Result of the health check of every backend plugin",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type pluginHealth struct {
+				PluginID string `json:"plugin_id"`
+				Type     string `json:"type"`
+				Status   string `json:"status"`
+				Message  string `json:"message,omitempty"`
+			}
+
+			var backendPlugins []plugins.PluginDTO
+			for _, p := range pluginStore.Plugins(ctx) {
+				if p.Backend {
+					backendPlugins = append(backendPlugins, p)
+				}
+			}
+
+			// health checks run concurrently so that one hung plugin doesn't delay the others
+			results := make([]pluginHealth, len(backendPlugins))
+			var wg sync.WaitGroup
+			for i, p := range backendPlugins {
+				wg.Add(1)
+				go func(i int, p plugins.PluginDTO) {
+					defer wg.Done()
+					status, message := checkPluginHealth(ctx, pluginClient, p.ID, checkTimeout)
+					results[i] = pluginHealth{
+						PluginID: p.ID,
+						Type:     string(p.Type),
+						Status:   status,
+						Message:  message,
+					}
+				}(i, p)
+			}
+			wg.Wait()
+
+			sort.Slice(results, func(i, j int) bool {
+				return results[i].PluginID < results[j].PluginID
+			})
+
+			data, err := json.Marshal(results)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "plugin-health.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+// checkPluginHealth returns the health status and message of a plugin. Plugins that don't
+// answer within timeout, even if they ignore context cancellation, are reported as unknown.
+func checkPluginHealth(ctx context.Context, pluginClient plugins.Client, pluginID string, timeout time.Duration) (string, string) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type checkResult struct {
+		res *backend.CheckHealthResult
+		err error
+	}
+	done := make(chan checkResult, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- checkResult{err: fmt.Errorf("health check panicked: %v", r)}
+			}
+		}()
+
+		res, err := pluginClient.CheckHealth(ctx, &backend.CheckHealthRequest{
+			PluginContext: backend.PluginContext{
+				OrgID:    1,
+				PluginID: pluginID,
+			},
+			Headers: map[string]string{},
+		})
+		done <- checkResult{res: res, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return backend.HealthStatusUnknown.String(), fmt.Sprintf("health check timed out after %s", timeout)
+	case result := <-done:
+		if result.err != nil {
+			return backend.HealthStatusUnknown.String(), result.err.Error()
+		}
+		if result.res == nil {
+			return backend.HealthStatusUnknown.String(), "plugin returned no health check result"
+		}
+		return result.res.Status.String(), result.res.Message
+	}
+}
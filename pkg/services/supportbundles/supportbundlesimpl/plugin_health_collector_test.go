@@ -0,0 +1,55 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+)
+
+func TestPluginHealthCollector(t *testing.T) {
+	pluginStore := plugins.FakePluginStore{
+		PluginList: []plugins.PluginDTO{
+			{JSONData: plugins.JSONData{ID: "healthy", Type: plugins.DataSource, Backend: true}},
+			{JSONData: plugins.JSONData{ID: "hung", Type: plugins.DataSource, Backend: true}},
+			{JSONData: plugins.JSONData{ID: "failing", Type: plugins.App, Backend: true}},
+			{JSONData: plugins.JSONData{ID: "frontend-only", Type: plugins.Panel}},
+		},
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	pluginClient := &clienttest.TestClient{
+		CheckHealthFunc: func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			switch req.PluginContext.PluginID {
+			case "healthy":
+				return &backend.CheckHealthResult{Status: backend.HealthStatusOk, Message: "all good"}, nil
+			case "hung":
+				// ignores context cancellation on purpose
+				<-release
+				return &backend.CheckHealthResult{Status: backend.HealthStatusOk}, nil
+			default:
+				return nil, errors.New("plugin crashed")
+			}
+		},
+	}
+
+	item, err := pluginHealthCollector(pluginStore, pluginClient, 50*time.Millisecond).Fn(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "plugin-health.json", item.Filename)
+
+	var results []map[string]string
+	require.NoError(t, json.Unmarshal(item.FileBytes, &results))
+	require.Equal(t, []map[string]string{
+		{"plugin_id": "failing", "type": "app", "status": "UNKNOWN", "message": "plugin crashed"},
+		{"plugin_id": "healthy", "type": "datasource", "status": "OK", "message": "all good"},
+		{"plugin_id": "hung", "type": "datasource", "status": "UNKNOWN", "message": "health check timed out after 50ms"},
+	}, results)
+}
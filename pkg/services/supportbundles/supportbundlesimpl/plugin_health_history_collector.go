@@ -0,0 +1,110 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana/pkg/plugins/manager/process"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// pluginEventSource is implemented by the plugin process manager.
+type pluginEventSource interface {
+	Events() []process.Event
+}
+
+// pluginHealthHistoryCollector reports the recent starts, crashes and restarts of
+// backend plugin processes. The history is only as long as the one retained by events.
+func pluginHealthHistoryCollector(events pluginEventSource) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "plugin-health-history",
+		DisplayName:       "Plugin crash history",
+		Description:       "Recent crashes and restarts of backend plugin processes, and why they happened",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type pluginEvent struct {
+				PluginID string    `json:"plugin_id"`
+				Type     string    `json:"type"`
+				Time     time.Time `json:"time"`
+				Reason   string    `json:"reason,omitempty"`
+			}
+			type pluginStability struct {
+				PluginID       string     `json:"plugin_id"`
+				Starts         int        `json:"starts"`
+				Crashes        int        `json:"crashes"`
+				Restarts       int        `json:"restarts"`
+				FailedRestarts int        `json:"failed_restarts"`
+				LastCrash      *time.Time `json:"last_crash,omitempty"`
+				LastFailure    string     `json:"last_failure,omitempty"`
+			}
+			type healthHistory struct {
+				// Retained is false when the lifecycle events of plugins aren't available.
+				Retained bool              `json:"retained"`
+				Note     string            `json:"note"`
+				Plugins  []pluginStability `json:"plugins"`
+				// Events are the lifecycle events, newest first.
+				Events []pluginEvent `json:"events"`
+			}
+
+			history := healthHistory{Plugins: []pluginStability{}, Events: []pluginEvent{}}
+			if events == nil {
+				history.Note = "the plugin process manager doesn't retain lifecycle events"
+			} else {
+				history.Retained = true
+				history.Note = "only the most recent events since Grafana started are retained"
+
+				byPlugin := map[string]*pluginStability{}
+				for _, e := range events.Events() {
+					stability, ok := byPlugin[e.PluginID]
+					if !ok {
+						stability = &pluginStability{PluginID: e.PluginID}
+						byPlugin[e.PluginID] = stability
+					}
+					switch e.Type {
+					case process.EventStarted:
+						stability.Starts++
+					case process.EventExited:
+						crashedAt := e.Time.UTC()
+						stability.Crashes++
+						stability.LastCrash = &crashedAt
+					case process.EventRestarted:
+						stability.Restarts++
+					case process.EventRestartFailed:
+						stability.FailedRestarts++
+						stability.LastFailure = e.Reason
+					}
+
+					history.Events = append(history.Events, pluginEvent{
+						PluginID: e.PluginID,
+						Type:     string(e.Type),
+						Time:     e.Time.UTC(),
+						Reason:   e.Reason,
+					})
+				}
+
+				for _, stability := range byPlugin {
+					history.Plugins = append(history.Plugins, *stability)
+				}
+				sort.Slice(history.Plugins, func(i, j int) bool {
+					return history.Plugins[i].PluginID < history.Plugins[j].PluginID
+				})
+				sort.SliceStable(history.Events, func(i, j int) bool {
+					return history.Events[i].Time.After(history.Events[j].Time)
+				})
+			}
+
+			data, err := json.Marshal(history)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "plugin-health-history.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
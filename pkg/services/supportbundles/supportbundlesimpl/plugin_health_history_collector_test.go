@@ -0,0 +1,86 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins/manager/process"
+)
+
+type fakePluginEvents []process.Event
+
+func (f fakePluginEvents) Events() []process.Event {
+	return f
+}
+
+func TestPluginHealthHistoryCollector(t *testing.T) {
+	type healthHistory struct {
+		Retained bool   `json:"retained"`
+		Note     string `json:"note"`
+		Plugins  []struct {
+			PluginID       string     `json:"plugin_id"`
+			Starts         int        `json:"starts"`
+			Crashes        int        `json:"crashes"`
+			Restarts       int        `json:"restarts"`
+			FailedRestarts int        `json:"failed_restarts"`
+			LastCrash      *time.Time `json:"last_crash"`
+			LastFailure    string     `json:"last_failure"`
+		} `json:"plugins"`
+		Events []struct {
+			PluginID string `json:"plugin_id"`
+			Type     string `json:"type"`
+		} `json:"events"`
+	}
+
+	collect := func(t *testing.T, events pluginEventSource) healthHistory {
+		t.Helper()
+		item, err := pluginHealthHistoryCollector(events).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "plugin-health-history.json", item.Filename)
+
+		var history healthHistory
+		require.NoError(t, json.Unmarshal(item.FileBytes, &history))
+		return history
+	}
+
+	t.Run("events aren't retained", func(t *testing.T) {
+		history := collect(t, nil)
+		require.False(t, history.Retained)
+		require.NotEmpty(t, history.Note)
+		require.Empty(t, history.Plugins)
+		require.Empty(t, history.Events)
+	})
+
+	t.Run("events", func(t *testing.T) {
+		start := time.Now().Add(-time.Hour)
+		history := collect(t, fakePluginEvents{
+			{PluginID: "grafana-clock-panel", Type: process.EventStarted, Time: start},
+			{PluginID: "redis-datasource", Type: process.EventStarted, Time: start},
+			{PluginID: "redis-datasource", Type: process.EventExited, Time: start.Add(time.Minute), Reason: "the plugin process exited unexpectedly"},
+			{PluginID: "redis-datasource", Type: process.EventRestartFailed, Time: start.Add(2 * time.Minute), Reason: "fork/exec: no such file or directory"},
+			{PluginID: "redis-datasource", Type: process.EventRestarted, Time: start.Add(3 * time.Minute)},
+		})
+		require.True(t, history.Retained)
+
+		require.Len(t, history.Plugins, 2)
+		require.Equal(t, "grafana-clock-panel", history.Plugins[0].PluginID)
+		require.Equal(t, 1, history.Plugins[0].Starts)
+		require.Zero(t, history.Plugins[0].Crashes)
+		require.Nil(t, history.Plugins[0].LastCrash)
+
+		redis := history.Plugins[1]
+		require.Equal(t, 1, redis.Starts)
+		require.Equal(t, 1, redis.Crashes)
+		require.Equal(t, 1, redis.Restarts)
+		require.Equal(t, 1, redis.FailedRestarts)
+		require.Equal(t, start.Add(time.Minute).Unix(), redis.LastCrash.Unix())
+		require.Equal(t, "fork/exec: no such file or directory", redis.LastFailure)
+
+		require.Len(t, history.Events, 5)
+		require.Equal(t, "restarted", history.Events[0].Type)
+	})
+}
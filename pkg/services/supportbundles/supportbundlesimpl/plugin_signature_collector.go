@@ -0,0 +1,124 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/services/pluginsettings"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// pluginSignatureCollector reports the signature of every plugin, whether it may
+// be loaded unsigned, and the plugins that weren't loaded because of their signature.
+func pluginSignatureCollector(cfg *setting.Cfg, pluginStore plugins.Store, pluginErrors plugins.ErrorResolver, pluginSettings pluginsettings.Service) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "plugin-signatures",
+		DisplayName:       "Plugin signatures",
+		Description:       "Signature status and class of the plugins, and the plugins rejected because of their signature",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type pluginSignature struct {
+				ID              string `json:"id"`
+				Type            string `json:"type"`
+				Version         string `json:"version"`
+				Class           string `json:"class"`
+				Signature       string `json:"signature"`
+				SignatureType   string `json:"signature_type,omitempty"`
+				SignatureOrg    string `json:"signature_org,omitempty"`
+				UnsignedAllowed bool   `json:"unsigned_allowed"`
+				// EnabledInOrgs are the organizations the app plugin is enabled in, nil for other plugins.
+				EnabledInOrgs []int64 `json:"enabled_in_orgs,omitempty"`
+			}
+			type rejectedPlugin struct {
+				ID              string `json:"id"`
+				ErrorCode       string `json:"error_code"`
+				UnsignedAllowed bool   `json:"unsigned_allowed"`
+			}
+			type signatures struct {
+				// AllowLoadingUnsignedPlugins is the allow_loading_unsigned_plugins setting.
+				AllowLoadingUnsignedPlugins []string          `json:"allow_loading_unsigned_plugins"`
+				BySignature                 map[string]int    `json:"by_signature"`
+				Plugins                     []pluginSignature `json:"plugins"`
+				// Rejected are the plugins that weren't loaded, usually because of their signature.
+				Rejected []rejectedPlugin `json:"rejected"`
+				// SettingsError is set when it couldn't be told which orgs app plugins are enabled in.
+				SettingsError string `json:"settings_error,omitempty"`
+			}
+
+			result := signatures{
+				AllowLoadingUnsignedPlugins: []string{},
+				BySignature:                 map[string]int{},
+				Plugins:                     []pluginSignature{},
+				Rejected:                    []rejectedPlugin{},
+			}
+			allowed := map[string]bool{}
+			for _, id := range cfg.PluginsAllowUnsigned {
+				if id != "" {
+					result.AllowLoadingUnsignedPlugins = append(result.AllowLoadingUnsignedPlugins, id)
+					allowed[id] = true
+				}
+			}
+
+			// app plugins are enabled per org, org 0 returns the settings of every org
+			enabledIn := map[string][]int64{}
+			settings, err := pluginSettings.GetPluginSettings(ctx, &pluginsettings.GetArgs{OrgID: 0})
+			if err != nil {
+				result.SettingsError = err.Error()
+			}
+			for _, s := range settings {
+				if s.Enabled {
+					enabledIn[s.PluginID] = append(enabledIn[s.PluginID], s.OrgID)
+				}
+			}
+
+			for _, p := range pluginStore.Plugins(ctx) {
+				signature := pluginSignature{
+					ID:              p.ID,
+					Type:            string(p.Type),
+					Version:         p.Info.Version,
+					Class:           string(p.Class),
+					Signature:       string(p.Signature),
+					SignatureType:   string(p.SignatureType),
+					SignatureOrg:    p.SignatureOrg,
+					UnsignedAllowed: allowed[p.ID],
+				}
+				if p.IsApp() {
+					signature.EnabledInOrgs = enabledIn[p.ID]
+					sort.Slice(signature.EnabledInOrgs, func(i, j int) bool {
+						return signature.EnabledInOrgs[i] < signature.EnabledInOrgs[j]
+					})
+				}
+				result.Plugins = append(result.Plugins, signature)
+				result.BySignature[signature.Signature]++
+			}
+			sort.Slice(result.Plugins, func(i, j int) bool {
+				return result.Plugins[i].ID < result.Plugins[j].ID
+			})
+
+			for _, e := range pluginErrors.PluginErrors() {
+				result.Rejected = append(result.Rejected, rejectedPlugin{
+					ID:              e.PluginID,
+					ErrorCode:       string(e.ErrorCode),
+					UnsignedAllowed: allowed[e.PluginID],
+				})
+			}
+			sort.Slice(result.Rejected, func(i, j int) bool {
+				return result.Rejected[i].ID < result.Rejected[j].ID
+			})
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "plugin-signatures.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
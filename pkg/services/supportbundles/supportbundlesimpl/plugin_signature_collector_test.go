@@ -0,0 +1,86 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/services/pluginsettings"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+type fakePluginErrors []*plugins.Error
+
+func (f fakePluginErrors) PluginErrors() []*plugins.Error {
+	return f
+}
+
+func TestPluginSignatureCollector(t *testing.T) {
+	cfg := setting.NewCfg()
+	cfg.PluginsAllowUnsigned = []string{"my-panel", "broken-datasource", ""}
+
+	pluginStore := plugins.FakePluginStore{
+		PluginList: []plugins.PluginDTO{
+			{JSONData: plugins.JSONData{ID: "prometheus", Type: plugins.DataSource}, Class: plugins.Core, Signature: plugins.SignatureInternal},
+			{JSONData: plugins.JSONData{ID: "my-panel", Type: plugins.Panel}, Class: plugins.External, Signature: plugins.SignatureUnsigned},
+			{
+				JSONData:      plugins.JSONData{ID: "my-app", Type: plugins.App, Info: plugins.Info{Version: "1.2.0"}},
+				Class:         plugins.External,
+				Signature:     plugins.SignatureValid,
+				SignatureType: plugins.CommunitySignature,
+				SignatureOrg:  "Example",
+			},
+		},
+	}
+	pluginSettings := &pluginsettings.FakePluginSettings{Plugins: map[string]*pluginsettings.DTO{
+		"my-app": {PluginID: "my-app", OrgID: 2, Enabled: true},
+	}}
+	pluginErrors := fakePluginErrors{
+		{PluginID: "broken-datasource", ErrorCode: "signatureModified"},
+	}
+
+	item, err := pluginSignatureCollector(cfg, pluginStore, pluginErrors, pluginSettings).Fn(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "plugin-signatures.json", item.Filename)
+
+	var result struct {
+		AllowLoadingUnsignedPlugins []string       `json:"allow_loading_unsigned_plugins"`
+		BySignature                 map[string]int `json:"by_signature"`
+		Plugins                     []struct {
+			ID              string  `json:"id"`
+			Class           string  `json:"class"`
+			Signature       string  `json:"signature"`
+			SignatureType   string  `json:"signature_type"`
+			UnsignedAllowed bool    `json:"unsigned_allowed"`
+			EnabledInOrgs   []int64 `json:"enabled_in_orgs"`
+		} `json:"plugins"`
+		Rejected []struct {
+			ID              string `json:"id"`
+			ErrorCode       string `json:"error_code"`
+			UnsignedAllowed bool   `json:"unsigned_allowed"`
+		} `json:"rejected"`
+	}
+	require.NoError(t, json.Unmarshal(item.FileBytes, &result))
+
+	require.Equal(t, []string{"my-panel", "broken-datasource"}, result.AllowLoadingUnsignedPlugins)
+	require.Equal(t, map[string]int{"internal": 1, "unsigned": 1, "valid": 1}, result.BySignature)
+
+	require.Len(t, result.Plugins, 3)
+	app, panel, core := result.Plugins[0], result.Plugins[1], result.Plugins[2]
+	require.Equal(t, "my-app", app.ID)
+	require.Equal(t, "community", app.SignatureType)
+	require.Equal(t, []int64{2}, app.EnabledInOrgs)
+	require.Equal(t, "my-panel", panel.ID)
+	require.Equal(t, "unsigned", panel.Signature)
+	require.True(t, panel.UnsignedAllowed)
+	require.Nil(t, panel.EnabledInOrgs)
+	require.Equal(t, "prometheus", core.ID)
+	require.Equal(t, "core", core.Class)
+
+	require.Len(t, result.Rejected, 1)
+	require.Equal(t, "signatureModified", result.Rejected[0].ErrorCode)
+	require.True(t, result.Rejected[0].UnsignedAllowed)
+}
@@ -0,0 +1,210 @@
+package supportbundlesimpl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+const defaultCPUProfileDuration = 30 * time.Second
+
+// maxCPUProfileDuration bounds the duration parameter, unless cpu_profile_duration is set higher.
+const maxCPUProfileDuration = 2 * time.Minute
+
+// maxCPUProfileDeadlineMargin bounds how long before the collector timeout the
+// CPU profile is stopped, a profile returned after the timeout is discarded.
+const maxCPUProfileDeadlineMargin = time.Second
+
+var (
+	errProfileTooLarge      = errors.New("profile exceeds the maximum allowed size")
+	errCPUProfileInProgress = errors.New("a CPU profile is already being collected")
+	// cpuProfileMu ensures only one CPU profile is collected at a time process-wide.
+	cpuProfileMu sync.Mutex
+)
+
+// limitedBuffer is a bytes.Buffer that refuses writes past limit bytes.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int64
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 && int64(b.Len()+len(p)) > b.limit {
+		remaining := b.limit - int64(b.Len())
+		if remaining <= 0 {
+			return 0, errProfileTooLarge
+		}
+		n, _ := b.Buffer.Write(p[:remaining])
+		return n, errProfileTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// ctxWriter stops writing once ctx is done, so that a profile still being
+// written when its collector is cancelled stops at the next write instead of
+// running to completion in the background.
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w ctxWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
+// profileCollectors are the collectors of the goroutine, heap and CPU profiles.
+func profileCollectors(cfg *setting.Cfg) []supportbundles.Collector {
+	maxGoroutineDumpSize := cfg.SectionWithEnvOverrides("support_bundles").Key("goroutine_dump_max_size_mb").MustInt64(50) * 1024 * 1024
+	return []supportbundles.Collector{
+		goroutineCollector(maxGoroutineDumpSize),
+		heapProfileCollector(),
+		cpuProfileCollector(cfg),
+	}
+}
+
+func goroutineCollector(maxSize int64) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "goroutine-profile",
+		DisplayName:       "Goroutine dump",
+		Description:       "Stack traces of all current goroutines, useful to diagnose hangs and deadlocks",
+		IncludedByDefault: false,
+		Default:           false,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			buf := &limitedBuffer{limit: maxSize}
+			done := make(chan error, 1)
+
+			go func() {
+				done <- pprof.Lookup("goroutine").WriteTo(ctxWriter{ctx: ctx, w: buf}, 2)
+			}()
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case err := <-done:
+				if errors.Is(err, errProfileTooLarge) {
+					buf.Buffer.WriteString(fmt.Sprintf("\n\n... goroutine dump truncated at %d bytes\n", maxSize))
+				} else if err != nil {
+					return nil, err
+				}
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "goroutine.txt",
+				FileBytes: buf.Bytes(),
+			}, nil
+		},
+	}
+}
+
+func heapProfileCollector() supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "heap-profile",
+		DisplayName:       "Heap profile",
+		Description:       "Heap memory profile in pprof format, useful to investigate memory growth",
+		IncludedByDefault: false,
+		Default:           false,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			var buf bytes.Buffer
+			done := make(chan error, 1)
+
+			go func() {
+				// run a garbage collection so the profile reflects live objects
+				runtime.GC()
+				done <- pprof.Lookup("heap").WriteTo(ctxWriter{ctx: ctx, w: &buf}, 0)
+			}()
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case err := <-done:
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "heap.pprof",
+				FileBytes: buf.Bytes(),
+			}, nil
+		},
+	}
+}
+
+func cpuProfileCollector(cfg *setting.Cfg) supportbundles.Collector {
+	duration := cfg.SectionWithEnvOverrides("support_bundles").Key("cpu_profile_duration").MustDuration(defaultCPUProfileDuration)
+	if duration <= 0 {
+		duration = defaultCPUProfileDuration
+	}
+	maxSeconds := int64(maxCPUProfileDuration.Seconds())
+	if s := int64(duration.Seconds()); s > maxSeconds {
+		maxSeconds = s
+	}
+
+	return supportbundles.Collector{
+		UID:               "cpu-profile",
+		DisplayName:       "CPU profile",
+		Description:       fmt.Sprintf("CPU profile in pprof format sampled over %s by default", duration),
+		IncludedByDefault: false,
+		Default:           false,
+		Params: []supportbundles.CollectorParam{
+			{Name: "duration", Description: "Seconds to sample the CPU for", Default: int64(duration.Seconds()), Min: 1, Max: maxSeconds},
+		},
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			if !cpuProfileMu.TryLock() {
+				return nil, errCPUProfileInProgress
+			}
+			defer cpuProfileMu.Unlock()
+
+			var buf bytes.Buffer
+			if err := pprof.StartCPUProfile(&buf); err != nil {
+				// another CPU profile was started outside of support bundles, e.g. through the pprof endpoint
+				return nil, fmt.Errorf("%w: %s", errCPUProfileInProgress, err)
+			}
+
+			sampling := duration
+			if seconds := supportbundles.IntParam(ctx, "duration", 0); seconds > 0 {
+				sampling = time.Duration(seconds) * time.Second
+			}
+			filename := "cpu.pprof"
+			if deadline, ok := ctx.Deadline(); ok {
+				remaining := time.Until(deadline)
+				margin := remaining / 10
+				if margin > maxCPUProfileDeadlineMargin {
+					margin = maxCPUProfileDeadlineMargin
+				}
+				// stop early enough for the partial profile to make it into the bundle
+				if remaining-margin < sampling {
+					sampling = remaining - margin
+					filename = "cpu.partial.pprof"
+				}
+			}
+			timer := time.NewTimer(sampling)
+			defer timer.Stop()
+
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				// keep whatever was sampled before the bundle got cancelled
+				filename = "cpu.partial.pprof"
+			}
+			pprof.StopCPUProfile()
+
+			return &supportbundles.SupportItem{
+				Filename:  filename,
+				FileBytes: buf.Bytes(),
+			}, nil
+		},
+	}
+}
@@ -0,0 +1,161 @@
+package supportbundlesimpl
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/supportbundles/bundleregistry"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestProfileCollectors(t *testing.T) {
+	cfg := setting.NewCfg()
+	cfg.Raw.Section("support_bundles").Key("cpu_profile_duration").SetValue("1s")
+
+	registry := bundleregistry.ProvideService()
+	for _, collector := range profileCollectors(cfg) {
+		registry.RegisterSupportItemCollector(collector)
+	}
+
+	files := map[string]string{
+		"goroutine-profile": "goroutine.txt",
+		"heap-profile":      "heap.pprof",
+		"cpu-profile":       "cpu.pprof",
+	}
+	collectors := registry.Collectors()
+	require.Len(t, collectors, len(files))
+	for uid, filename := range files {
+		collector, ok := collectors[uid]
+		require.True(t, ok, uid)
+		require.False(t, collector.IncludedByDefault, uid)
+
+		item, err := collector.Fn(context.Background())
+		require.NoError(t, err, uid)
+		require.Equal(t, filename, item.Filename)
+		require.NotEmpty(t, item.FileBytes, uid)
+	}
+}
+
+func TestGoroutineCollector(t *testing.T) {
+	t.Run("dumps the stack of every goroutine", func(t *testing.T) {
+		item, err := goroutineCollector(0).Fn(context.Background())
+		require.NoError(t, err)
+		require.Contains(t, string(item.FileBytes), "TestGoroutineCollector")
+	})
+
+	t.Run("truncates large dumps", func(t *testing.T) {
+		item, err := goroutineCollector(64).Fn(context.Background())
+		require.NoError(t, err)
+		require.Contains(t, string(item.FileBytes), "goroutine dump truncated at 64 bytes")
+	})
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	buf := &limitedBuffer{limit: 4}
+	n, err := buf.Write([]byte("abc"))
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	n, err = buf.Write([]byte("def"))
+	require.ErrorIs(t, err, errProfileTooLarge)
+	require.Equal(t, 1, n)
+
+	n, err = buf.Write([]byte("ghi"))
+	require.ErrorIs(t, err, errProfileTooLarge)
+	require.Equal(t, 0, n)
+	require.Equal(t, "abcd", buf.String())
+}
+
+func TestHeapProfileCollector(t *testing.T) {
+	t.Run("writes a gzipped pprof profile", func(t *testing.T) {
+		item, err := heapProfileCollector().Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "heap.pprof", item.Filename)
+		// pprof profiles are gzip compressed protocol buffers
+		require.Equal(t, []byte{0x1f, 0x8b}, item.FileBytes[:2])
+	})
+
+	t.Run("stops when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := heapProfileCollector().Fn(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestCtxWriter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var buf bytes.Buffer
+	w := ctxWriter{ctx: ctx, w: &buf}
+
+	_, err := w.Write([]byte("before"))
+	require.NoError(t, err)
+	cancel()
+	n, err := w.Write([]byte("after"))
+	require.ErrorIs(t, err, context.Canceled)
+	require.Zero(t, n)
+	require.Equal(t, "before", buf.String())
+}
+
+func TestCPUProfileCollector(t *testing.T) {
+	cfg := setting.NewCfg()
+	cfg.Raw.Section("support_bundles").Key("cpu_profile_duration").SetValue("10s")
+	collector := cpuProfileCollector(cfg)
+
+	t.Run("registers the duration parameter", func(t *testing.T) {
+		require.Equal(t, "cpu-profile", collector.UID)
+		require.Equal(t, []supportbundles.CollectorParam{
+			{Name: "duration", Description: "Seconds to sample the CPU for", Default: 10, Min: 1, Max: int64(maxCPUProfileDuration.Seconds())},
+		}, collector.Params)
+	})
+
+	t.Run("samples for the requested duration", func(t *testing.T) {
+		ctx := supportbundles.WithParams(context.Background(), map[string]int64{"duration": 1})
+		start := time.Now()
+		item, err := collector.Fn(ctx)
+		require.NoError(t, err)
+		require.Less(t, time.Since(start), 5*time.Second)
+		require.Equal(t, "cpu.pprof", item.Filename)
+		require.Equal(t, []byte{0x1f, 0x8b}, item.FileBytes[:2])
+	})
+
+	t.Run("keeps the partial profile when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		time.AfterFunc(100*time.Millisecond, cancel)
+		item, err := collector.Fn(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "cpu.partial.pprof", item.Filename)
+		require.NotEmpty(t, item.FileBytes)
+	})
+
+	t.Run("stops before the collector times out", func(t *testing.T) {
+		s := newTestService(t, collector)
+		s.collectorTimeouts["cpu-profile"] = 500 * time.Millisecond
+
+		bundle, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+		require.NoError(t, err)
+
+		data, state, err := bundleArchive(context.Background(), s, s.selectCollectors([]string{"cpu-profile"}), bundle.UID, nil)
+		require.NoError(t, err)
+		require.Equal(t, supportbundles.StateComplete, state)
+
+		files := readBundle(t, data)
+		require.Contains(t, files, "/bundle/cpu.partial.pprof")
+		require.Equal(t, []byte{0x1f, 0x8b}, files["/bundle/cpu.partial.pprof"][:2])
+		require.NotContains(t, files, "/bundle/cpu-profile.error.txt")
+	})
+
+	t.Run("refuses concurrent profiles", func(t *testing.T) {
+		cpuProfileMu.Lock()
+		defer cpuProfileMu.Unlock()
+		_, err := collector.Fn(context.Background())
+		require.ErrorIs(t, err, errCPUProfileInProgress)
+	})
+}
@@ -0,0 +1,98 @@
+package supportbundlesimpl
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// presetAllCollectors expands to every available collector.
+const presetAllCollectors = "*"
+
+var ErrUnknownPreset = errors.New("unknown support bundle preset")
+
+// defaultPresets are named collector lists, so that users don't have to know the
+// collector UIDs. They can be overridden, and others added, in [support_bundles.presets].
+var defaultPresets = map[string][]string{
+	"minimal":  {"basic", "build-info", "settings"},
+	"alerting": {"basic", "build-info", "settings", "feature-flags", "alerting-state", "contact-points", "annotations-stats", "db"},
+	"auth":     {"basic", "build-info", "settings", "auth-config", "auth-ldap", "service-accounts", "tls", "proxy-config"},
+	"full":     {presetAllCollectors},
+}
+
+// readPresets returns the default presets along with the ones configured in the
+// [support_bundles.presets] section, which take precedence, e.g. `network = basic,tls`.
+func readPresets(cfg *setting.Cfg) map[string][]string {
+	presets := make(map[string][]string, len(defaultPresets))
+	for name, collectors := range defaultPresets {
+		presets[name] = collectors
+	}
+	for _, key := range cfg.Raw.Section("support_bundles.presets").Keys() {
+		presets[key.Name()] = util.SplitString(key.Value())
+	}
+	return presets
+}
+
+// expandPreset returns the collectors of a preset. The collectors of the preset
+// that aren't available, e.g. because they're disabled, are left out.
+func (s *Service) expandPreset(name string) ([]string, error) {
+	members, ok := s.presets[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, the available presets are %s", ErrUnknownPreset, name, strings.Join(s.presetNames(), ", "))
+	}
+
+	registered := s.bundleRegistry.Collectors()
+	collectors := make([]string, 0, len(members))
+	for _, uid := range members {
+		if uid == presetAllCollectors {
+			collectors = collectors[:0]
+			for uid := range registered {
+				if !s.isCollectorDisabled(uid) {
+					collectors = append(collectors, uid)
+				}
+			}
+			sort.Strings(collectors)
+			break
+		}
+		if _, ok := registered[uid]; ok && !s.isCollectorDisabled(uid) {
+			collectors = append(collectors, uid)
+		}
+	}
+	return collectors, nil
+}
+
+func (s *Service) presetNames() []string {
+	names := make([]string, 0, len(s.presets))
+	for name := range s.presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withPreset adds the collectors of a preset to the requested collectors, if any is given.
+func (s *Service) withPreset(preset string, collectors []string) ([]string, error) {
+	if preset == "" {
+		return collectors, nil
+	}
+
+	expanded, err := s.expandPreset(preset)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(expanded))
+	for _, uid := range expanded {
+		seen[uid] = true
+	}
+	for _, uid := range collectors {
+		if !seen[uid] {
+			expanded = append(expanded, uid)
+			seen[uid] = true
+		}
+	}
+	return expanded, nil
+}
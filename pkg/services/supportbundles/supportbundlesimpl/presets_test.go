@@ -0,0 +1,72 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestReadPresets(t *testing.T) {
+	cfg := setting.NewCfg()
+	cfg.Raw.Section("support_bundles.presets").Key("minimal").SetValue("basic")
+	cfg.Raw.Section("support_bundles.presets").Key("network").SetValue("basic, tls")
+
+	presets := readPresets(cfg)
+	require.Equal(t, []string{"basic"}, presets["minimal"])
+	require.Equal(t, []string{"basic", "tls"}, presets["network"])
+	require.Equal(t, defaultPresets["alerting"], presets["alerting"])
+	require.Equal(t, []string{"basic", "build-info", "settings"}, defaultPresets["minimal"])
+}
+
+func TestService_create_Preset(t *testing.T) {
+	item := func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "item.txt", FileBytes: []byte("item")}, nil
+	}
+	optional := func(uid string) supportbundles.Collector {
+		c := newTestCollector(uid, item)
+		c.IncludedByDefault = false
+		return c
+	}
+	s := newTestService(t, optional("basic"), optional("settings"), optional("tls"), optional("db"))
+	s.disabledCollectors = map[string]*supportbundles.Collector{"db": nil}
+	s.presets = map[string][]string{
+		"network": {"basic", "tls", "auth-ldap"},
+		"full":    {presetAllCollectors},
+	}
+
+	t.Run("expands presets to the available collectors", func(t *testing.T) {
+		collectors, err := s.expandPreset("network")
+		require.NoError(t, err)
+		require.Equal(t, []string{"basic", "tls"}, collectors)
+
+		collectors, err = s.expandPreset("full")
+		require.NoError(t, err)
+		require.Equal(t, []string{"basic", "settings", "tls"}, collectors)
+	})
+
+	t.Run("rejects unknown presets", func(t *testing.T) {
+		_, err := s.create(context.Background(), &user.SignedInUser{Login: "admin"}, createOptions{Preset: "networking"})
+		require.ErrorIs(t, err, ErrUnknownPreset)
+		require.Contains(t, err.Error(), `"networking", the available presets are full, network`)
+	})
+
+	t.Run("records the expanded collectors", func(t *testing.T) {
+		bundle, err := s.create(context.Background(), &user.SignedInUser{Login: "admin"}, createOptions{
+			Preset:     "network",
+			Collectors: []string{"settings", "basic"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"basic", "settings", "tls"}, bundle.Collectors)
+		require.Eventually(t, func() bool { return len(s.creationSlots) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+		stored, err := s.store.Get(context.Background(), bundle.UID)
+		require.NoError(t, err)
+		require.Equal(t, []string{"basic", "settings", "tls"}, stored.Collectors)
+	})
+}
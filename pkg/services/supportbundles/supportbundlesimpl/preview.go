@@ -0,0 +1,89 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"unicode/utf8"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+var ErrCollectorForbidden = errors.New("not allowed to run the support bundle collector")
+
+// collectorPreview is the redacted output of a single collector, as it would be written to a bundle.
+type collectorPreview struct {
+	UID      string `json:"uid"`
+	Filename string `json:"filename,omitempty"`
+	Success  bool   `json:"success"`
+	Error    string `json:"error,omitempty"`
+	// Content is the output of the collector, base64 encoded when it isn't valid UTF-8 text.
+	Content   string `json:"content"`
+	Encoding  string `json:"encoding,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+	// Files are the files of collectors writing several, Filename and Content are then the first one.
+	Files []filePreview `json:"files,omitempty"`
+}
+
+// filePreview is a file of a collector writing several.
+type filePreview struct {
+	Filename string `json:"filename"`
+	Content  string `json:"content"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// previewContent returns data as text, or base64 encoded along with its encoding
+// when it isn't valid UTF-8 text.
+func previewContent(data []byte) (string, string) {
+	if utf8.Valid(data) {
+		return string(data), ""
+	}
+	return base64.StdEncoding.EncodeToString(data), "base64"
+}
+
+// preview runs a single collector and returns its redacted output without persisting anything.
+func (s *Service) preview(ctx context.Context, uid string, usr *user.SignedInUser) (*collectorPreview, error) {
+	if err := s.validateCollectors([]string{uid}); err != nil {
+		return nil, err
+	}
+
+	allowed, _, err := s.authorizeCollectors(ctx, usr, []supportbundles.Collector{s.bundleRegistry.Collectors()[uid]})
+	if err != nil {
+		return nil, err
+	}
+	if len(allowed) == 0 {
+		return nil, ErrCollectorForbidden
+	}
+
+	// running a collector can be as expensive as creating a bundle
+	select {
+	case s.creationSlots <- struct{}{}:
+	default:
+		return nil, ErrTooManyBundles
+	}
+	defer func() { <-s.creationSlots }()
+
+	ctx, cancel := context.WithTimeout(ctx, bundleCreationTimeout)
+	defer cancel()
+
+	files, reports := s.collect(ctx, allowed, func(int, string) {}, nil)
+	report := reports[0]
+
+	preview := &collectorPreview{
+		UID:       report.UID,
+		Filename:  report.Filename,
+		Success:   report.Success,
+		Error:     report.Error,
+		Truncated: report.Truncated,
+	}
+	if report.Success {
+		preview.Content, preview.Encoding = previewContent(files[report.Filename])
+		for _, name := range report.Files {
+			file := filePreview{Filename: name}
+			file.Content, file.Encoding = previewContent(files[name])
+			preview.Files = append(preview.Files, file)
+		}
+	}
+	return preview, nil
+}
@@ -0,0 +1,75 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/accesscontrol/acimpl"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestService_preview(t *testing.T) {
+	secret := newTestCollector("secret", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "secret.json", FileBytes: []byte(`{"password":"` + plantedSecret + `"}`)}, nil
+	})
+	binary := newTestCollector("binary", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "binary.pprof", FileBytes: []byte{0xff, 0xfe}}, nil
+	})
+	failing := newTestCollector("failing", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return nil, errors.New("boom")
+	})
+	restricted := newTestCollector("restricted", secret.Fn)
+	restricted.Restricted = true
+
+	s := newTestService(t, secret, binary, failing, restricted)
+	admin := &user.SignedInUser{Login: "admin"}
+
+	t.Run("returns the redacted output", func(t *testing.T) {
+		preview, err := s.preview(context.Background(), "secret", admin)
+		require.NoError(t, err)
+		require.True(t, preview.Success)
+		require.Equal(t, "secret.json", preview.Filename)
+		require.NotContains(t, preview.Content, plantedSecret)
+		require.Contains(t, preview.Content, redactedValue)
+		require.Empty(t, s.creationSlots)
+	})
+
+	t.Run("encodes binary output", func(t *testing.T) {
+		preview, err := s.preview(context.Background(), "binary", admin)
+		require.NoError(t, err)
+		require.Equal(t, "base64", preview.Encoding)
+		require.Equal(t, "//4=", preview.Content)
+	})
+
+	t.Run("reports collector errors", func(t *testing.T) {
+		preview, err := s.preview(context.Background(), "failing", admin)
+		require.NoError(t, err)
+		require.False(t, preview.Success)
+		require.Contains(t, preview.Error, "boom")
+		require.Empty(t, preview.Content)
+	})
+
+	t.Run("unknown collector", func(t *testing.T) {
+		_, err := s.preview(context.Background(), "unknown", admin)
+		require.ErrorIs(t, err, ErrUnknownCollector)
+	})
+
+	t.Run("restricted collectors require the collector scope", func(t *testing.T) {
+		s.accessControl = acimpl.ProvideAccessControl(setting.NewCfg())
+		t.Cleanup(func() { s.accessControl = nil })
+
+		usr := &user.SignedInUser{Login: "editor", OrgID: 1, Permissions: map[int64]map[string][]string{1: {ActionCreate: {}}}}
+		_, err := s.preview(context.Background(), "restricted", usr)
+		require.ErrorIs(t, err, ErrCollectorForbidden)
+
+		usr.Permissions[1][ActionCreate] = []string{ScopeCollectorsProvider.GetResourceScopeUID("restricted")}
+		preview, err := s.preview(context.Background(), "restricted", usr)
+		require.NoError(t, err)
+		require.True(t, preview.Success)
+	})
+}
@@ -0,0 +1,124 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// provisioningDirs are the provisioning directories inventoried, relative to the provisioning path.
+var provisioningDirs = []string{"datasources", "dashboards", "alerting", "plugins"}
+
+// provisioningMaxBytes caps the size of the provisioning files read, the
+// remaining files are listed without their content.
+const provisioningMaxBytes = 5 * 1024 * 1024
+
+// provisioningRedactor also masks secureJsonData entirely, as the names of
+// its fields, e.g. httpHeaderValue1, don't tell whether they hold secrets.
+var provisioningRedactor = newRedactor(append([]string{"secure_json_data", "securejsondata"}, defaultRedactKeys...))
+
+func provisioningCollector(cfg *setting.Cfg) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "provisioning",
+		DisplayName:       "Provisioning files",
+		Description:       "Data source, dashboard, alerting and plugin provisioning files with secrets redacted",
+		IncludedByDefault: false,
+		Default:           true,
+		Restricted:        true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type provisioningFile struct {
+				Directory string          `json:"directory"`
+				Name      string          `json:"name"`
+				Size      int64           `json:"size_bytes"`
+				Content   json.RawMessage `json:"content,omitempty"`
+				Error     string          `json:"error,omitempty"`
+				Skipped   bool            `json:"skipped,omitempty"` // Skipped is set once the size cap is reached.
+			}
+
+			if cfg.ProvisioningPath == "" {
+				return nil, nil
+			}
+
+			files := []provisioningFile{}
+			var total int64
+			for _, dir := range provisioningDirs {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+
+				// only the files at the root of each directory are provisioned, subdirectories are not read
+				entries, err := os.ReadDir(filepath.Join(cfg.ProvisioningPath, dir))
+				if err != nil {
+					if os.IsNotExist(err) {
+						continue
+					}
+					return nil, err
+				}
+
+				for _, entry := range entries {
+					ext := strings.ToLower(filepath.Ext(entry.Name()))
+					if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+						continue
+					}
+
+					file := provisioningFile{Directory: dir, Name: entry.Name()}
+					info, err := entry.Info()
+					if err != nil {
+						file.Error = err.Error()
+						files = append(files, file)
+						continue
+					}
+					file.Size = info.Size()
+
+					if total+file.Size > provisioningMaxBytes {
+						file.Skipped = true
+						files = append(files, file)
+						continue
+					}
+					total += file.Size
+
+					content, err := readProvisioningFile(filepath.Join(cfg.ProvisioningPath, dir, entry.Name()))
+					if err != nil {
+						file.Error = err.Error()
+					}
+					file.Content = content
+					files = append(files, file)
+				}
+			}
+
+			data, err := json.Marshal(files)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "provisioning.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+// readProvisioningFile parses a provisioning file and returns its redacted content as JSON.
+func readProvisioningFile(path string) (json.RawMessage, error) {
+	// the path is read from the server configuration, not from user input
+	// nolint:gosec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed interface{}
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(provisioningRedactor.redactValue(parsed))
+}
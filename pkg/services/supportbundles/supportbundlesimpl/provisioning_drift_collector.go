@@ -0,0 +1,199 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+const (
+	// maxProvisionedDashboardSize caps the size of the dashboard files compared,
+	// larger files are reported without being compared.
+	maxProvisionedDashboardSize = 20 * 1024 * 1024
+	// maxReportedDrift caps the drifted dashboards listed.
+	maxReportedDrift = 500
+)
+
+// provisioningUIUpdatesSource is implemented by the provisioning service.
+type provisioningUIUpdatesSource interface {
+	GetAllowUIUpdatesFromConfig(name string) bool
+}
+
+// provisioningDriftCollector compares the provisioned dashboards stored in the
+// database with their files, for edits that keep disappearing because they are
+// overwritten when the files are provisioned again. Nothing is written, neither
+// to the files nor to the database.
+func provisioningDriftCollector(sql db.DB, provisioning provisioningUIUpdatesSource) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "provisioning-drift",
+		DisplayName:       "Dashboard provisioning drift",
+		Description:       "Provisioned dashboards edited in Grafana or whose file changed or is missing since they were provisioned",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type provisionedDashboard struct {
+				Provisioner      string    `xorm:"name"`
+				Path             string    `xorm:"external_id"`
+				CheckSum         string    `xorm:"check_sum"`
+				ProvisionedAt    int64     `xorm:"provisioned_at"`
+				DashboardID      int64     `xorm:"dashboard_id"`
+				OrgID            int64     `xorm:"org_id"`
+				UID              string    `xorm:"uid"`
+				Title            string    `xorm:"title"`
+				Version          int       `xorm:"version"`
+				DashboardUpdated time.Time `xorm:"dashboard_updated"`
+			}
+			type drift struct {
+				Provisioner string `json:"provisioner"`
+				Path        string `json:"path"`
+				OrgID       int64  `json:"org_id"`
+				UID         string `json:"uid"`
+				Title       string `json:"title"`
+				Version     int    `json:"version"`
+				// ProvisionedAt is the modification time of the file when it was last provisioned.
+				ProvisionedAt  time.Time `json:"provisioned_at"`
+				UpdatedAt      time.Time `json:"updated_at"`
+				AllowUIUpdates bool      `json:"allow_ui_updates"`
+				// ModifiedInUI is set when the stored dashboard was saved after it was
+				// provisioned and differs from the file.
+				ModifiedInUI bool `json:"modified_in_ui"`
+				// FileChanged is set when the file changed since it was provisioned.
+				FileChanged   bool   `json:"file_changed"`
+				MissingOnDisk bool   `json:"missing_on_disk"`
+				Error         string `json:"error,omitempty"`
+				Note          string `json:"note,omitempty"`
+			}
+			type provisioningDrift struct {
+				Checked int      `json:"checked"`
+				Drifted int      `json:"drifted"`
+				Drift   []drift  `json:"drift"`
+				Notes   []string `json:"notes"`
+			}
+
+			var dashboards []provisionedDashboard
+			err := sql.WithDbSession(ctx, func(sess *db.Session) error {
+				return sess.SQL(`SELECT dp.name, dp.external_id, dp.check_sum, dp.updated AS provisioned_at,
+					d.id AS dashboard_id, d.org_id, d.uid, d.title, d.version, d.updated AS dashboard_updated
+					FROM dashboard_provisioning AS dp
+					INNER JOIN dashboard AS d ON d.id = dp.dashboard_id
+					ORDER BY dp.name, dp.external_id`).Find(&dashboards)
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			result := provisioningDrift{Checked: len(dashboards), Drift: []drift{}, Notes: []string{}}
+			for _, d := range dashboards {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+
+				entry := drift{
+					Provisioner:   d.Provisioner,
+					Path:          d.Path,
+					OrgID:         d.OrgID,
+					UID:           d.UID,
+					Title:         d.Title,
+					Version:       d.Version,
+					ProvisionedAt: time.Unix(d.ProvisionedAt, 0).UTC(),
+					UpdatedAt:     d.DashboardUpdated.UTC(),
+				}
+				if provisioning != nil {
+					entry.AllowUIUpdates = provisioning.GetAllowUIUpdatesFromConfig(d.Provisioner)
+				}
+
+				content, truncated, err := readCapped(d.Path, maxProvisionedDashboardSize)
+				switch {
+				case err != nil:
+					entry.MissingOnDisk = true
+					entry.Error = err.Error()
+				case truncated:
+					entry.Error = "the file is too large to be compared"
+				default:
+					checkSum, err := util.Md5SumString(string(content))
+					if err != nil {
+						return nil, err
+					}
+					entry.FileChanged = checkSum != d.CheckSum
+				}
+
+				// provisioning stores the modification time of the file as the update
+				// time of the dashboard, saving it in Grafana sets the current time
+				if d.DashboardUpdated.Unix() > d.ProvisionedAt && !entry.MissingOnDisk && entry.Error == "" {
+					entry.ModifiedInUI, err = dashboardDiffersFromFile(ctx, sql, d.DashboardID, content)
+					if err != nil {
+						entry.Error = err.Error()
+					}
+				}
+
+				switch {
+				case entry.ModifiedInUI && entry.FileChanged:
+					entry.Note = "the dashboard was edited in Grafana and its file changed, the edits are overwritten when the provider next reads its files"
+				case entry.ModifiedInUI:
+					entry.Note = "the dashboard was edited in Grafana, the edits are overwritten the next time its file changes"
+				case entry.FileChanged:
+					entry.Note = "the file changed since the dashboard was provisioned, see provisioning-errors if it isn't updated"
+				case entry.MissingOnDisk:
+					entry.Note = "the file can't be read, the dashboard is deleted when the provider next reads its files unless deletion is disabled"
+				case entry.Error == "":
+					continue
+				}
+
+				result.Drifted++
+				if len(result.Drift) < maxReportedDrift {
+					result.Drift = append(result.Drift, entry)
+				}
+			}
+			if result.Drifted > maxReportedDrift {
+				result.Notes = append(result.Notes, fmt.Sprintf("only the first %d drifted dashboards are listed", maxReportedDrift))
+			}
+			result.Notes = append(result.Notes, "the files are read from the paths recorded when the dashboards were provisioned, as seen by Grafana")
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "provisioning-drift.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+// dashboardDiffersFromFile reports whether the stored model of the dashboard
+// differs from its file, ignoring the fields set when the dashboard is saved.
+func dashboardDiffersFromFile(ctx context.Context, sql db.DB, dashboardID int64, file []byte) (bool, error) {
+	var stored string
+	err := sql.WithDbSession(ctx, func(sess *db.Session) error {
+		_, err := sess.Table("dashboard").Where("id = ?", dashboardID).Cols("data").Get(&stored)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var fromFile, fromDB map[string]interface{}
+	if err := json.Unmarshal(file, &fromFile); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal([]byte(stored), &fromDB); err != nil {
+		return false, err
+	}
+	for _, field := range []string{"id", "version"} {
+		delete(fromFile, field)
+		delete(fromDB, field)
+	}
+	// a UID is generated when the file has none
+	if _, ok := fromFile["uid"]; !ok {
+		delete(fromDB, "uid")
+	}
+	return !reflect.DeepEqual(fromFile, fromDB), nil
+}
@@ -0,0 +1,102 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/dashboards"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+type fakeUIUpdates map[string]bool
+
+func (f fakeUIUpdates) GetAllowUIUpdatesFromConfig(name string) bool {
+	return f[name]
+}
+
+func TestProvisioningDriftCollector(t *testing.T) {
+	type provisioningDrift struct {
+		Checked int `json:"checked"`
+		Drifted int `json:"drifted"`
+		Drift   []struct {
+			UID            string `json:"uid"`
+			AllowUIUpdates bool   `json:"allow_ui_updates"`
+			ModifiedInUI   bool   `json:"modified_in_ui"`
+			FileChanged    bool   `json:"file_changed"`
+			MissingOnDisk  bool   `json:"missing_on_disk"`
+			Note           string `json:"note"`
+		} `json:"drift"`
+	}
+
+	dir := t.TempDir()
+	provisionedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	sqlStore := db.InitTestDB(t)
+	provision := func(uid, file, stored string, updated time.Time) {
+		t.Helper()
+
+		path := filepath.Join(dir, uid+".json")
+		if file != "" {
+			require.NoError(t, os.WriteFile(path, []byte(file), 0600))
+		}
+		checkSum, err := util.Md5SumString(file)
+		require.NoError(t, err)
+		if uid == "file-changed" {
+			checkSum, err = util.Md5SumString("previous content")
+			require.NoError(t, err)
+		}
+		data, err := simplejson.NewJson([]byte(stored))
+		require.NoError(t, err)
+
+		require.NoError(t, sqlStore.WithDbSession(context.Background(), func(sess *db.Session) error {
+			dashboard := &dashboards.Dashboard{OrgID: 1, UID: uid, Slug: uid, Title: uid, Data: data, Version: 2,
+				Created: provisionedAt, Updated: updated}
+			if _, err := sess.Insert(dashboard); err != nil {
+				return err
+			}
+			_, err := sess.Insert(&dashboards.DashboardProvisioning{DashboardID: dashboard.ID, Name: "default",
+				ExternalID: path, CheckSum: checkSum, Updated: provisionedAt.Unix()})
+			return err
+		}))
+	}
+	file := func(uid string) string { return `{"uid": "` + uid + `", "title": "Provisioned", "panels": []}` }
+	edited := func(uid string) string {
+		return `{"id": 1, "uid": "` + uid + `", "title": "Edited", "panels": [], "version": 2}`
+	}
+
+	provision("in-sync", file("in-sync"), `{"id": 1, "uid": "in-sync", "title": "Provisioned", "panels": [], "version": 1}`, provisionedAt)
+	provision("saved-unchanged", file("saved-unchanged"), `{"id": 2, "uid": "saved-unchanged", "title": "Provisioned", "panels": [], "version": 2}`, time.Now())
+	provision("edited", file("edited"), edited("edited"), time.Now())
+	provision("file-changed", file("file-changed"), file("file-changed"), provisionedAt)
+	provision("missing", "", file("missing"), provisionedAt)
+
+	item, err := provisioningDriftCollector(sqlStore, fakeUIUpdates{"default": true}).Fn(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "provisioning-drift.json", item.Filename)
+
+	var result provisioningDrift
+	require.NoError(t, json.Unmarshal(item.FileBytes, &result))
+	require.Equal(t, 5, result.Checked)
+	require.Equal(t, 3, result.Drifted)
+
+	drifted := map[string]int{}
+	for i, d := range result.Drift {
+		drifted[d.UID] = i
+		require.True(t, d.AllowUIUpdates)
+		require.NotEmpty(t, d.Note)
+	}
+	require.NotContains(t, drifted, "in-sync")
+	require.NotContains(t, drifted, "saved-unchanged")
+	require.True(t, result.Drift[drifted["edited"]].ModifiedInUI)
+	require.False(t, result.Drift[drifted["edited"]].FileChanged)
+	require.True(t, result.Drift[drifted["file-changed"]].FileChanged)
+	require.False(t, result.Drift[drifted["file-changed"]].ModifiedInUI)
+	require.True(t, result.Drift[drifted["missing"]].MissingOnDisk)
+}
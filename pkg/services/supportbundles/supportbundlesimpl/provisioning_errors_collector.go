@@ -0,0 +1,62 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/provisioning/dashboards"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// provisioningErrorSource is implemented by the provisioning service.
+type provisioningErrorSource interface {
+	GetDashboardProvisioningErrors() []dashboards.FileError
+}
+
+// provisioningErrorsCollector reports the provisioned dashboard files that
+// failed to load or save the last time they were read, e.g. because of invalid JSON.
+func provisioningErrorsCollector(provisioning provisioningErrorSource) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "provisioning-errors",
+		DisplayName:       "Provisioning errors",
+		Description:       "Provisioned dashboard files that failed to load, and why",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type fileError struct {
+				Provisioner string    `json:"provisioner"`
+				Path        string    `json:"path"`
+				Error       string    `json:"error"`
+				Time        time.Time `json:"time"`
+			}
+			type provisioningErrors struct {
+				Note   string      `json:"note"`
+				Errors []fileError `json:"errors"`
+			}
+
+			result := provisioningErrors{
+				Note:   "only the errors of the last time each dashboard provider read its files are reported, see the provisioning collector for the provider configuration",
+				Errors: []fileError{},
+			}
+			for _, e := range provisioning.GetDashboardProvisioningErrors() {
+				result.Errors = append(result.Errors, fileError{
+					Provisioner: e.Provisioner,
+					Path:        e.Path,
+					Error:       e.Error,
+					Time:        e.Time.UTC(),
+				})
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "provisioning-errors.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
@@ -0,0 +1,55 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/provisioning/dashboards"
+)
+
+type fakeProvisioningErrors []dashboards.FileError
+
+func (f fakeProvisioningErrors) GetDashboardProvisioningErrors() []dashboards.FileError {
+	return f
+}
+
+func TestProvisioningErrorsCollector(t *testing.T) {
+	type provisioningErrors struct {
+		Errors []struct {
+			Provisioner string `json:"provisioner"`
+			Path        string `json:"path"`
+			Error       string `json:"error"`
+		} `json:"errors"`
+	}
+
+	collect := func(t *testing.T, source provisioningErrorSource) provisioningErrors {
+		t.Helper()
+		item, err := provisioningErrorsCollector(source).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "provisioning-errors.json", item.Filename)
+
+		var result provisioningErrors
+		require.NoError(t, json.Unmarshal(item.FileBytes, &result))
+		return result
+	}
+
+	t.Run("no errors", func(t *testing.T) {
+		result := collect(t, fakeProvisioningErrors(nil))
+		require.NotNil(t, result.Errors)
+		require.Empty(t, result.Errors)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		result := collect(t, fakeProvisioningErrors{
+			{Provisioner: "default", Path: "/etc/grafana/dashboards/broken.json", Error: "failed to load dashboard: invalid character '}'", Time: time.Now()},
+		})
+		require.Len(t, result.Errors, 1)
+		require.Equal(t, "default", result.Errors[0].Provisioner)
+		require.Equal(t, "/etc/grafana/dashboards/broken.json", result.Errors[0].Path)
+		require.Contains(t, result.Errors[0].Error, "invalid character")
+	})
+}
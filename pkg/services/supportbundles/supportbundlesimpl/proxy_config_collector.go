@@ -0,0 +1,183 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// forwardedHeaders are the headers reverse proxies set to describe the original request.
+var forwardedHeaders = []string{"X-Forwarded-Host", "X-Forwarded-Proto", "X-Forwarded-Port", "X-Forwarded-Prefix", "X-Forwarded-Server"}
+
+// clientAddressHeaders carry the address of the client, only whether they're set is reported.
+var clientAddressHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Real-Ip"}
+
+// requestOrigin is how the request that created a bundle reached Grafana.
+type requestOrigin struct {
+	Host    string
+	TLS     bool
+	Headers map[string]string
+}
+
+type requestOriginKey struct{}
+
+func newRequestOrigin(r *http.Request) *requestOrigin {
+	origin := &requestOrigin{Host: r.Host, TLS: r.TLS != nil, Headers: map[string]string{}}
+	for _, header := range forwardedHeaders {
+		if value := r.Header.Get(header); value != "" {
+			origin.Headers[header] = value
+		}
+	}
+	for _, header := range clientAddressHeaders {
+		if r.Header.Get(header) != "" {
+			origin.Headers[header] = "(set)"
+		}
+	}
+	return origin
+}
+
+// withRequestOrigin makes the origin of the request that created a bundle available to its collectors.
+func withRequestOrigin(ctx context.Context, origin *requestOrigin) context.Context {
+	if origin == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, requestOriginKey{}, origin)
+}
+
+func requestOriginFromContext(ctx context.Context) *requestOrigin {
+	origin, _ := ctx.Value(requestOriginKey{}).(*requestOrigin)
+	return origin
+}
+
+// externalURL returns the scheme and host the client used, as reported by the reverse proxy if any.
+func (o *requestOrigin) externalURL() (string, string) {
+	scheme := "http"
+	if o.TLS {
+		scheme = "https"
+	}
+	if proto := o.Headers["X-Forwarded-Proto"]; proto != "" {
+		scheme = strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0]))
+	}
+
+	host := o.Host
+	if forwarded := o.Headers["X-Forwarded-Host"]; forwarded != "" {
+		host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return scheme, strings.ToLower(host)
+}
+
+// portOrDefault returns port, or the default port of scheme if it's empty.
+func portOrDefault(scheme, port string) string {
+	if port != "" {
+		return port
+	}
+	if scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// proxyConfigCollector reports the settings that build the URLs Grafana redirects
+// to, and whether they match how the request creating the bundle reached Grafana.
+func proxyConfigCollector(cfg *setting.Cfg) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "proxy-config",
+		DisplayName:       "Reverse proxy configuration",
+		Description:       "root_url, domain and sub path settings, compared to the headers set by the reverse proxy",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type request struct {
+				Host             string            `json:"host"`
+				TLS              bool              `json:"tls"`
+				ForwardedHeaders map[string]string `json:"forwarded_headers"`
+				ExternalURL      string            `json:"external_url"`
+			}
+			type proxyConfig struct {
+				RootURL          string `json:"root_url"`
+				SubPath          string `json:"sub_path"`
+				Domain           string `json:"domain"`
+				ServeFromSubPath bool   `json:"serve_from_sub_path"`
+				EnforceDomain    bool   `json:"enforce_domain"`
+				Protocol         string `json:"protocol"`
+				// Request is nil when the bundle wasn't created from an HTTP request, e.g. on a schedule.
+				Request *request `json:"request,omitempty"`
+				// Matches is whether root_url has the scheme, host and port the request was sent to.
+				Matches *bool    `json:"matches,omitempty"`
+				Notes   []string `json:"notes"`
+			}
+
+			config := proxyConfig{
+				RootURL:          cfg.AppURL,
+				SubPath:          cfg.AppSubURL,
+				Domain:           cfg.Domain,
+				ServeFromSubPath: cfg.ServeFromSubPath,
+				EnforceDomain:    cfg.EnforceDomain,
+				Protocol:         string(cfg.Protocol),
+				Notes:            []string{},
+			}
+
+			rootURL, err := url.Parse(cfg.AppURL)
+			if err != nil {
+				config.Notes = append(config.Notes, fmt.Sprintf("root_url is not a valid URL: %s", err))
+				rootURL = &url.URL{}
+			}
+			rootHost := strings.ToLower(rootURL.Hostname())
+
+			if rootHost != "" && !strings.EqualFold(rootHost, cfg.Domain) {
+				config.Notes = append(config.Notes, fmt.Sprintf("domain %q doesn't match the host of root_url %q, %%(domain)s in root_url is usually what's intended", cfg.Domain, rootHost))
+			}
+			if cfg.ServeFromSubPath && cfg.AppSubURL == "" {
+				config.Notes = append(config.Notes, "serve_from_sub_path is enabled but root_url has no sub path")
+			}
+
+			if origin := requestOriginFromContext(ctx); origin != nil {
+				scheme, host := origin.externalURL()
+				config.Request = &request{
+					Host:             origin.Host,
+					TLS:              origin.TLS,
+					ForwardedHeaders: origin.Headers,
+					ExternalURL:      scheme + "://" + host,
+				}
+
+				hostname, port := host, origin.Headers["X-Forwarded-Port"]
+				if h, p, err := net.SplitHostPort(host); err == nil {
+					hostname, port = h, p
+				}
+				port = portOrDefault(scheme, port)
+				rootPort := portOrDefault(rootURL.Scheme, rootURL.Port())
+				matches := rootURL.Scheme == scheme && rootHost == hostname && rootPort == port
+				config.Matches = &matches
+
+				if rootHost != "" && rootHost != hostname {
+					config.Notes = append(config.Notes, fmt.Sprintf("root_url points to %s but the request was sent to %s, login redirects and OAuth callbacks will go to the wrong host", rootHost, hostname))
+				} else if rootHost != "" && rootPort != port {
+					config.Notes = append(config.Notes, fmt.Sprintf("root_url uses port %s but the request was sent to port %s", rootPort, port))
+				}
+				if rootURL.Scheme != "" && rootURL.Scheme != scheme {
+					config.Notes = append(config.Notes, fmt.Sprintf("root_url uses %s but the request was sent over %s, e.g. because the reverse proxy terminates TLS without root_url being updated", rootURL.Scheme, scheme))
+				}
+				if prefix := origin.Headers["X-Forwarded-Prefix"]; prefix != "" && strings.TrimSuffix(prefix, "/") != cfg.AppSubURL {
+					config.Notes = append(config.Notes, fmt.Sprintf("the reverse proxy forwards the prefix %s but the sub path of root_url is %q", prefix, cfg.AppSubURL))
+				}
+			}
+
+			data, err := json.Marshal(config)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "proxy-config.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
@@ -0,0 +1,97 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestProxyConfigCollector(t *testing.T) {
+	type proxyConfig struct {
+		RootURL string `json:"root_url"`
+		Domain  string `json:"domain"`
+		Request *struct {
+			Host             string            `json:"host"`
+			TLS              bool              `json:"tls"`
+			ForwardedHeaders map[string]string `json:"forwarded_headers"`
+			ExternalURL      string            `json:"external_url"`
+		} `json:"request"`
+		Matches *bool    `json:"matches"`
+		Notes   []string `json:"notes"`
+	}
+
+	newCfg := func(rootURL, domain string) *setting.Cfg {
+		cfg := setting.NewCfg()
+		cfg.AppURL = rootURL
+		cfg.Domain = domain
+		return cfg
+	}
+
+	collect := func(t *testing.T, cfg *setting.Cfg, origin *requestOrigin) proxyConfig {
+		t.Helper()
+		item, err := proxyConfigCollector(cfg).Fn(withRequestOrigin(context.Background(), origin))
+		require.NoError(t, err)
+		require.Equal(t, "proxy-config.json", item.Filename)
+
+		var config proxyConfig
+		require.NoError(t, json.Unmarshal(item.FileBytes, &config))
+		return config
+	}
+
+	t.Run("without a request", func(t *testing.T) {
+		config := collect(t, newCfg("http://localhost:3000/", "localhost"), nil)
+		require.Equal(t, "http://localhost:3000/", config.RootURL)
+		require.Nil(t, config.Request)
+		require.Nil(t, config.Matches)
+		require.Empty(t, config.Notes)
+	})
+
+	t.Run("request matching root_url", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "https://grafana.example.com/api/support-bundles", nil)
+		req.TLS = &tls.ConnectionState{}
+
+		config := collect(t, newCfg("https://grafana.example.com/", "grafana.example.com"), newRequestOrigin(req))
+		require.NotNil(t, config.Request)
+		require.Equal(t, "https://grafana.example.com", config.Request.ExternalURL)
+		require.True(t, *config.Matches)
+		require.Empty(t, config.Notes)
+	})
+
+	t.Run("reverse proxy not matching root_url", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "http://10.0.0.1:3000/api/support-bundles", nil)
+		req.Header.Set("X-Forwarded-Host", "grafana.example.com")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Prefix", "/grafana")
+		req.Header.Set("X-Forwarded-For", "192.168.1.10")
+
+		config := collect(t, newCfg("http://localhost:3000/", "localhost"), newRequestOrigin(req))
+		require.Equal(t, "10.0.0.1:3000", config.Request.Host)
+		require.Equal(t, "https://grafana.example.com", config.Request.ExternalURL)
+		require.Equal(t, "(set)", config.Request.ForwardedHeaders["X-Forwarded-For"])
+		require.False(t, *config.Matches)
+		require.Len(t, config.Notes, 3)
+		require.Contains(t, config.Notes[0], "root_url points to localhost but the request was sent to grafana.example.com")
+		require.Contains(t, config.Notes[1], "root_url uses http but the request was sent over https")
+		require.Contains(t, config.Notes[2], "prefix /grafana")
+	})
+
+	t.Run("port not matching root_url", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "http://grafana.example.com/api/support-bundles", nil)
+
+		config := collect(t, newCfg("http://grafana.example.com:3000/", "grafana.example.com"), newRequestOrigin(req))
+		require.False(t, *config.Matches)
+		require.Equal(t, []string{"root_url uses port 3000 but the request was sent to port 80"}, config.Notes)
+	})
+
+	t.Run("domain not matching root_url", func(t *testing.T) {
+		config := collect(t, newCfg("http://grafana.example.com/", "localhost"), nil)
+		require.Len(t, config.Notes, 1)
+		require.Contains(t, config.Notes[0], `domain "localhost" doesn't match`)
+	})
+}
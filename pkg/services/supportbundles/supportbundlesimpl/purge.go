@@ -0,0 +1,43 @@
+package supportbundlesimpl
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// purgeResult summarizes the removal of every bundle.
+type purgeResult struct {
+	Removed []string `json:"removed"`
+	// Skipped are the bundles that weren't removed, the pending ones and those whose removal failed.
+	Skipped []skippedBundle `json:"skipped"`
+}
+
+type skippedBundle struct {
+	UID    string `json:"uid"`
+	Reason string `json:"reason"`
+}
+
+// purge removes every bundle that isn't being created anymore, one by one with
+// remove. A failed removal doesn't stop the others, it is reported in the result.
+func (s *Service) purge(ctx context.Context) (*purgeResult, error) {
+	bundles, _, err := s.list(ctx, listQuery{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &purgeResult{Removed: []string{}, Skipped: []skippedBundle{}}
+	for _, b := range bundles {
+		if b.State == supportbundles.StatePending {
+			result.Skipped = append(result.Skipped, skippedBundle{UID: b.UID, Reason: "the bundle is still being created"})
+			continue
+		}
+		if err := s.remove(ctx, b.UID); err != nil {
+			s.log.Error("failed to purge bundle", "uid", b.UID, "error", err)
+			result.Skipped = append(result.Skipped, skippedBundle{UID: b.UID, Reason: err.Error()})
+			continue
+		}
+		result.Removed = append(result.Removed, b.UID)
+	}
+	return result, nil
+}
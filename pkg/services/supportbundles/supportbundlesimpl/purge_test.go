@@ -0,0 +1,93 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	contextmodel "github.com/grafana/grafana/pkg/services/contexthandler/model"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+// failingRemoveStore fails to remove the bundle with the given UID.
+type failingRemoveStore struct {
+	bundleStore
+	uid string
+}
+
+func (s *failingRemoveStore) Remove(ctx context.Context, uid string) error {
+	if uid == s.uid {
+		return errors.New("disk is read-only")
+	}
+	return s.bundleStore.Remove(ctx, uid)
+}
+
+func TestService_handlePurge(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	usr := &user.SignedInUser{Login: "admin"}
+
+	newBundle := func(state supportbundles.State) string {
+		b, err := s.store.Create(ctx, usr, time.Hour)
+		require.NoError(t, err)
+		require.NoError(t, s.store.UpdateMetadata(ctx, b.UID, func(b *supportbundles.Bundle) {
+			b.State = state
+		}))
+		return b.UID
+	}
+	complete := newBundle(supportbundles.StateComplete)
+	failed := newBundle(supportbundles.StateError)
+	pending := newBundle(supportbundles.StatePending)
+	readOnly := newBundle(supportbundles.StateComplete)
+	s.store = &failingRemoveStore{bundleStore: s.store, uid: readOnly}
+
+	req := httptest.NewRequest(http.MethodDelete, rootUrl, nil)
+	rec := httptest.NewRecorder()
+	reqCtx := &contextmodel.ReqContext{
+		Context:      &web.Context{Req: req, Resp: web.NewResponseWriter(req.Method, rec)},
+		SignedInUser: usr,
+		Logger:       log.NewNopLogger(),
+	}
+	s.handlePurge(reqCtx).WriteTo(reqCtx)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var result struct {
+		RemovedCount int             `json:"removedCount"`
+		SkippedCount int             `json:"skippedCount"`
+		Removed      []string        `json:"removed"`
+		Skipped      []skippedBundle `json:"skipped"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	require.Equal(t, 2, result.RemovedCount)
+	require.ElementsMatch(t, []string{complete, failed}, result.Removed)
+	require.Equal(t, 2, result.SkippedCount)
+	require.Len(t, result.Skipped, 2)
+	for _, skipped := range result.Skipped {
+		switch skipped.UID {
+		case pending:
+			require.Contains(t, skipped.Reason, "being created")
+		case readOnly:
+			require.Contains(t, skipped.Reason, "read-only")
+		default:
+			t.Fatalf("unexpected skipped bundle %s", skipped.UID)
+		}
+	}
+
+	for _, uid := range []string{complete, failed} {
+		_, err := s.store.Get(ctx, uid)
+		require.Error(t, err)
+	}
+	for _, uid := range []string{pending, readOnly} {
+		_, err := s.store.Get(ctx, uid)
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,133 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+const (
+	// maxQueryHistoryGroups caps the data sources and users reported, busiest first.
+	maxQueryHistoryGroups = 100
+	// maxQueryHistoryQueries caps the recent queries included when includeQueries is set.
+	maxQueryHistoryQueries   = 100
+	queryHistoryRecentWindow = 24 * time.Hour
+)
+
+// queryHistoryCollector reports aggregate statistics of the query history.
+// The queries themselves are only included when includeQueries is set, as
+// they may contain sensitive data.
+func queryHistoryCollector(sql db.DB, includeQueries bool) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "query-history",
+		DisplayName:       "Query history",
+		Description:       "Number of queries in the query history per data source and user",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type datasourceQueries struct {
+				DatasourceUID string `xorm:"datasource_uid" json:"datasource_uid"`
+				Queries       int64  `xorm:"total" json:"queries"`
+			}
+			type userQueries struct {
+				UserID  int64 `xorm:"created_by" json:"user_id"`
+				Queries int64 `xorm:"total" json:"queries"`
+			}
+			type recentQuery struct {
+				DatasourceUID string `xorm:"datasource_uid" json:"datasource_uid"`
+				CreatedBy     int64  `xorm:"created_by" json:"user_id"`
+				CreatedAt     int64  `xorm:"created_at" json:"created_at"`
+				Queries       string `xorm:"queries" json:"queries"`
+			}
+			type queryHistoryStats struct {
+				TableExists   bool                `json:"table_exists"`
+				Note          string              `json:"note,omitempty"`
+				Total         int64               `json:"total"`
+				Recent        int64               `json:"last_24h"`
+				Starred       int64               `json:"starred"`
+				Oldest        *time.Time          `json:"oldest,omitempty"`
+				Newest        *time.Time          `json:"newest,omitempty"`
+				ByDatasource  []datasourceQueries `json:"by_datasource"`
+				ByUser        []userQueries       `json:"by_user"`
+				RecentQueries []recentQuery       `json:"recent_queries,omitempty"`
+			}
+
+			stats := queryHistoryStats{
+				ByDatasource: []datasourceQueries{},
+				ByUser:       []userQueries{},
+			}
+
+			err := sql.WithDbSession(ctx, func(sess *db.Session) error {
+				exists, err := sess.IsTableExist("query_history")
+				if err != nil || !exists {
+					return err
+				}
+				stats.TableExists = true
+				// query errors aren't stored, only the queries that were run
+				stats.Note = "the query history does not record query errors"
+
+				if stats.Total, err = sess.Table("query_history").Count(); err != nil {
+					return err
+				}
+				since := time.Now().Add(-queryHistoryRecentWindow).Unix()
+				if stats.Recent, err = sess.Table("query_history").Where("created_at >= ?", since).Count(); err != nil {
+					return err
+				}
+				if starExists, err := sess.IsTableExist("query_history_star"); err != nil {
+					return err
+				} else if starExists {
+					if stats.Starred, err = sess.Table("query_history_star").Count(); err != nil {
+						return err
+					}
+				}
+
+				var bounds struct {
+					Oldest int64 `xorm:"oldest"`
+					Newest int64 `xorm:"newest"`
+				}
+				if _, err := sess.SQL("SELECT MIN(created_at) AS oldest, MAX(created_at) AS newest FROM query_history").Get(&bounds); err != nil {
+					return err
+				}
+				if stats.Total > 0 {
+					oldest, newest := time.Unix(bounds.Oldest, 0).UTC(), time.Unix(bounds.Newest, 0).UTC()
+					stats.Oldest, stats.Newest = &oldest, &newest
+				}
+
+				if err := sess.Table("query_history").Select("datasource_uid, COUNT(*) AS total").
+					GroupBy("datasource_uid").OrderBy("total DESC").Limit(maxQueryHistoryGroups).Find(&stats.ByDatasource); err != nil {
+					return err
+				}
+				if err := sess.Table("query_history").Select("created_by, COUNT(*) AS total").
+					GroupBy("created_by").OrderBy("total DESC").Limit(maxQueryHistoryGroups).Find(&stats.ByUser); err != nil {
+					return err
+				}
+
+				if includeQueries {
+					stats.RecentQueries = []recentQuery{}
+					return sess.Table("query_history").Cols("datasource_uid", "created_by", "created_at", "queries").
+						Desc("created_at").Limit(maxQueryHistoryQueries).Find(&stats.RecentQueries)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			if !stats.TableExists {
+				stats.Note = "the query_history table does not exist"
+			}
+
+			data, err := json.Marshal(stats)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "query-history.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
@@ -0,0 +1,110 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/queryhistory"
+)
+
+func TestQueryHistoryCollector(t *testing.T) {
+	type recentQuery struct {
+		DatasourceUID string `json:"datasource_uid"`
+		Queries       string `json:"queries"`
+	}
+	type queryHistoryStats struct {
+		TableExists  bool  `json:"table_exists"`
+		Total        int64 `json:"total"`
+		Recent       int64 `json:"last_24h"`
+		ByDatasource []struct {
+			DatasourceUID string `json:"datasource_uid"`
+			Queries       int64  `json:"queries"`
+		} `json:"by_datasource"`
+		ByUser []struct {
+			UserID  int64 `json:"user_id"`
+			Queries int64 `json:"queries"`
+		} `json:"by_user"`
+		RecentQueries []recentQuery `json:"recent_queries"`
+	}
+
+	sqlStore := db.InitTestDB(t)
+	now := time.Now()
+	entries := []struct {
+		datasource string
+		user       int64
+		createdAt  time.Time
+	}{
+		{datasource: "prometheus", user: 1, createdAt: now.Add(-48 * time.Hour)},
+		{datasource: "prometheus", user: 2, createdAt: now.Add(-time.Hour)},
+		{datasource: "loki", user: 2, createdAt: now},
+	}
+	require.NoError(t, sqlStore.WithDbSession(context.Background(), func(sess *db.Session) error {
+		for i, e := range entries {
+			_, err := sess.Insert(&queryhistory.QueryHistory{
+				UID: string(rune('a' + i)), OrgID: 1, DatasourceUID: e.datasource, CreatedBy: e.user, CreatedAt: e.createdAt.Unix(),
+				Queries: simplejson.NewFromAny([]map[string]string{{"expr": "secret_metric"}}),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	collect := func(t *testing.T, includeQueries bool) queryHistoryStats {
+		t.Helper()
+
+		item, err := queryHistoryCollector(sqlStore, includeQueries).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "query-history.json", item.Filename)
+
+		var stats queryHistoryStats
+		require.NoError(t, json.Unmarshal(item.FileBytes, &stats))
+		return stats
+	}
+
+	t.Run("reports aggregates only by default", func(t *testing.T) {
+		stats := collect(t, false)
+		require.True(t, stats.TableExists)
+		require.Equal(t, int64(3), stats.Total)
+		require.Equal(t, int64(2), stats.Recent)
+
+		require.Len(t, stats.ByDatasource, 2)
+		require.Equal(t, "prometheus", stats.ByDatasource[0].DatasourceUID)
+		require.Equal(t, int64(2), stats.ByDatasource[0].Queries)
+		require.Len(t, stats.ByUser, 2)
+		require.Equal(t, int64(2), stats.ByUser[0].UserID)
+		require.Equal(t, int64(2), stats.ByUser[0].Queries)
+
+		require.Nil(t, stats.RecentQueries)
+	})
+
+	t.Run("includes the most recent queries when enabled", func(t *testing.T) {
+		stats := collect(t, true)
+		require.Len(t, stats.RecentQueries, 3)
+		require.Equal(t, "loki", stats.RecentQueries[0].DatasourceUID)
+		require.Contains(t, stats.RecentQueries[0].Queries, "secret_metric")
+	})
+
+	t.Run("a missing table is reported", func(t *testing.T) {
+		rename := func(from, to string) {
+			require.NoError(t, sqlStore.WithDbSession(context.Background(), func(sess *db.Session) error {
+				_, err := sess.Exec("ALTER TABLE " + from + " RENAME TO " + to)
+				return err
+			}))
+		}
+		// the test database is shared, the table must be restored
+		rename("query_history", "query_history_renamed")
+		t.Cleanup(func() { rename("query_history_renamed", "query_history") })
+
+		stats := collect(t, false)
+		require.False(t, stats.TableExists)
+		require.Zero(t, stats.Total)
+	})
+}
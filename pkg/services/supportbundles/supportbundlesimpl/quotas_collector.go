@@ -0,0 +1,196 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// maxQuotaOrgs is how many organizations and users with custom quotas the quotas
+// collector reports at most, the oldest ones first.
+const maxQuotaOrgs = 100
+
+// quotasCollector reports the configured quotas, the limits in effect for the
+// instance, its organizations and the users with custom quotas, and how much of
+// them is used, for the "quota reached" errors. The counts are read from the
+// database so that they are there even when quotas are disabled.
+func quotasCollector(cfg *setting.Cfg, sql db.DB, quotaService quota.Service) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "quotas",
+		DisplayName:       "Quotas",
+		Description:       "Configured and effective org, user and global quotas and their usage",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type usage struct {
+				Target string `json:"target"`
+				// Limit is -1 for unlimited targets.
+				Limit   int64 `json:"limit"`
+				Used    int64 `json:"used"`
+				Reached bool  `json:"reached"`
+			}
+			type counts struct {
+				Dashboards  int64 `json:"dashboards"`
+				DataSources int64 `json:"data_sources"`
+				APIKeys     int64 `json:"api_keys"`
+				AlertRules  int64 `json:"alert_rules"`
+			}
+			type orgQuotas struct {
+				OrgID  int64   `json:"org_id"`
+				Name   string  `json:"name"`
+				Counts counts  `json:"counts"`
+				Quotas []usage `json:"quotas,omitempty"`
+			}
+			type userQuotas struct {
+				UserID int64   `json:"user_id"`
+				Quotas []usage `json:"quotas"`
+			}
+			type quotas struct {
+				Enabled bool   `json:"enabled"`
+				Note    string `json:"note,omitempty"`
+				// Configured are the default limits of the [quota] section, per scope and target.
+				Configured map[string]map[string]int64 `json:"configured"`
+				Counts     counts                      `json:"counts"`
+				Global     []usage                     `json:"global,omitempty"`
+				Orgs       []orgQuotas                 `json:"orgs"`
+				// Users are only the users with custom quotas, the others have the configured user quotas.
+				Users     []userQuotas `json:"users,omitempty"`
+				Truncated bool         `json:"truncated,omitempty"`
+			}
+
+			result := quotas{
+				Enabled: cfg.Quota.Enabled,
+				Configured: map[string]map[string]int64{
+					string(quota.OrgScope): {
+						"org_user":    cfg.Quota.Org.User,
+						"data_source": cfg.Quota.Org.DataSource,
+						"dashboard":   cfg.Quota.Org.Dashboard,
+						"api_key":     cfg.Quota.Org.ApiKey,
+						"alert_rule":  cfg.Quota.Org.AlertRule,
+					},
+					string(quota.UserScope): {
+						"org_user": cfg.Quota.User.Org,
+					},
+					string(quota.GlobalScope): {
+						"org":         cfg.Quota.Global.Org,
+						"user":        cfg.Quota.Global.User,
+						"data_source": cfg.Quota.Global.DataSource,
+						"dashboard":   cfg.Quota.Global.Dashboard,
+						"api_key":     cfg.Quota.Global.ApiKey,
+						"session":     cfg.Quota.Global.Session,
+						"alert_rule":  cfg.Quota.Global.AlertRule,
+						"file":        cfg.Quota.Global.File,
+					},
+				},
+				Orgs: []orgQuotas{},
+			}
+
+			var userIDs []int64
+			perOrg := map[int64]*counts{}
+			err := sql.WithDbSession(ctx, func(sess *db.Session) error {
+				var orgs []struct {
+					ID   int64  `xorm:"id"`
+					Name string `xorm:"name"`
+				}
+				if err := sess.Table("org").Cols("id", "name").OrderBy("id").Limit(maxQuotaOrgs + 1).Find(&orgs); err != nil {
+					return err
+				}
+				if len(orgs) > maxQuotaOrgs {
+					orgs, result.Truncated = orgs[:maxQuotaOrgs], true
+				}
+				for _, o := range orgs {
+					result.Orgs = append(result.Orgs, orgQuotas{OrgID: o.ID, Name: o.Name})
+					perOrg[o.ID] = &counts{}
+				}
+
+				// the same rows the usage reporters of the services count
+				dashboards := fmt.Sprintf("dashboard WHERE is_folder = %s", sql.GetDialect().BooleanStr(false))
+				for table, field := range map[string]func(*counts) *int64{
+					dashboards:    func(c *counts) *int64 { return &c.Dashboards },
+					"data_source": func(c *counts) *int64 { return &c.DataSources },
+					"api_key":     func(c *counts) *int64 { return &c.APIKeys },
+					"alert_rule":  func(c *counts) *int64 { return &c.AlertRules },
+				} {
+					var rows []struct {
+						OrgID int64 `xorm:"org_id"`
+						Count int64 `xorm:"count"`
+					}
+					if err := sess.SQL("SELECT org_id, COUNT(*) AS count FROM " + table + " GROUP BY org_id").Find(&rows); err != nil {
+						return err
+					}
+					for _, r := range rows {
+						*field(&result.Counts) += r.Count
+						if c, ok := perOrg[r.OrgID]; ok {
+							*field(c) = r.Count
+						}
+					}
+				}
+
+				return sess.Table("quota").Where("user_id > 0").Distinct("user_id").OrderBy("user_id").
+					Limit(maxQuotaOrgs).Find(&userIDs)
+			})
+			if err != nil {
+				return nil, err
+			}
+			for i := range result.Orgs {
+				result.Orgs[i].Counts = *perOrg[result.Orgs[i].OrgID]
+			}
+
+			effective := func(scope quota.Scope, id int64) ([]usage, error) {
+				dtos, err := quotaService.GetQuotasByScope(ctx, scope, id)
+				if err != nil {
+					return nil, err
+				}
+				usages := make([]usage, 0, len(dtos))
+				for _, dto := range dtos {
+					usages = append(usages, usage{
+						Target:  dto.Target,
+						Limit:   dto.Limit,
+						Used:    dto.Used,
+						Reached: dto.Limit >= 0 && dto.Used >= dto.Limit,
+					})
+				}
+				sort.Slice(usages, func(i, j int) bool { return usages[i].Target < usages[j].Target })
+				return usages, nil
+			}
+
+			result.Global, err = effective(quota.GlobalScope, 0)
+			switch {
+			case errors.Is(err, quota.ErrDisabled):
+				result.Note = "Quotas are disabled on this instance, only the configuration and the counts are reported"
+			case err != nil:
+				return nil, err
+			default:
+				for i := range result.Orgs {
+					if result.Orgs[i].Quotas, err = effective(quota.OrgScope, result.Orgs[i].OrgID); err != nil {
+						return nil, err
+					}
+				}
+				for _, id := range userIDs {
+					usages, err := effective(quota.UserScope, id)
+					if err != nil {
+						return nil, err
+					}
+					result.Users = append(result.Users, userQuotas{UserID: id, Quotas: usages})
+				}
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "quotas.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
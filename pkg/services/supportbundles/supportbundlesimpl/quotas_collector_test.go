@@ -0,0 +1,176 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/services/quota/quotatest"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+type fakeQuotaByScope struct {
+	*quotatest.FakeQuotaService
+	quotas map[quota.Scope]map[int64][]quota.QuotaDTO
+	err    error
+}
+
+func (f *fakeQuotaByScope) GetQuotasByScope(ctx context.Context, scope quota.Scope, id int64) ([]quota.QuotaDTO, error) {
+	return f.quotas[scope][id], f.err
+}
+
+func TestQuotasCollector(t *testing.T) {
+	type org struct {
+		Id      int64
+		Version int
+		Name    string
+		Created time.Time
+		Updated time.Time
+	}
+	type apiKey struct {
+		Id      int64
+		OrgId   int64
+		Name    string
+		Key     string
+		Role    string
+		Created time.Time
+		Updated time.Time
+	}
+	type quotaRow struct {
+		Id      int64
+		UserId  int64
+		Target  string
+		Limit   int64
+		Created time.Time
+		Updated time.Time
+	}
+	type usage struct {
+		Target  string `json:"target"`
+		Limit   int64  `json:"limit"`
+		Used    int64  `json:"used"`
+		Reached bool   `json:"reached"`
+	}
+	type quotas struct {
+		Enabled    bool                        `json:"enabled"`
+		Note       string                      `json:"note"`
+		Configured map[string]map[string]int64 `json:"configured"`
+		Counts     struct {
+			APIKeys int64 `json:"api_keys"`
+		} `json:"counts"`
+		Global []usage `json:"global"`
+		Orgs   []struct {
+			OrgID  int64  `json:"org_id"`
+			Name   string `json:"name"`
+			Counts struct {
+				APIKeys int64 `json:"api_keys"`
+			} `json:"counts"`
+			Quotas []usage `json:"quotas"`
+		} `json:"orgs"`
+		Users []struct {
+			UserID int64   `json:"user_id"`
+			Quotas []usage `json:"quotas"`
+		} `json:"users"`
+	}
+
+	sqlStore := db.InitTestDB(t)
+	now := time.Now()
+	require.NoError(t, sqlStore.WithDbSession(context.Background(), func(sess *db.Session) error {
+		if _, err := sess.Table("org").Insert(&org{Id: 10, Name: "Ops", Created: now, Updated: now}); err != nil {
+			return err
+		}
+		for _, k := range []*apiKey{
+			{OrgId: 10, Name: "first", Key: "1", Role: "Viewer", Created: now, Updated: now},
+			{OrgId: 10, Name: "second", Key: "2", Role: "Viewer", Created: now, Updated: now},
+		} {
+			if _, err := sess.Table("api_key").Insert(k); err != nil {
+				return err
+			}
+		}
+		_, err := sess.Table("quota").Insert(&quotaRow{UserId: 3, Target: "org_user", Limit: 1, Created: now, Updated: now})
+		return err
+	}))
+
+	collect := func(t *testing.T, cfg *setting.Cfg, quotaService quota.Service) quotas {
+		t.Helper()
+
+		item, err := quotasCollector(cfg, sqlStore, quotaService).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "quotas.json", item.Filename)
+
+		// as written to the bundle
+		var result quotas
+		require.NoError(t, json.Unmarshal(newRedactor(defaultRedactKeys).redactSecrets(item.Filename, item.FileBytes), &result))
+		return result
+	}
+	orgByID := func(t *testing.T, result quotas, id int64) int {
+		t.Helper()
+		for i, o := range result.Orgs {
+			if o.OrgID == id {
+				return i
+			}
+		}
+		t.Fatalf("org %d not reported", id)
+		return -1
+	}
+
+	t.Run("reports the configuration and the counts when quotas are disabled", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.Quota.Org.ApiKey = 5
+		cfg.Quota.Global.Dashboard = -1
+
+		result := collect(t, cfg, &fakeQuotaByScope{err: quota.ErrDisabled})
+		require.False(t, result.Enabled)
+		require.NotEmpty(t, result.Note)
+		require.EqualValues(t, 5, result.Configured["org"]["api_key"])
+		require.EqualValues(t, -1, result.Configured["global"]["dashboard"])
+		require.EqualValues(t, 2, result.Counts.APIKeys)
+		require.Empty(t, result.Global)
+		require.Empty(t, result.Users)
+
+		ops := result.Orgs[orgByID(t, result, 10)]
+		require.Equal(t, "Ops", ops.Name)
+		require.EqualValues(t, 2, ops.Counts.APIKeys)
+		require.Empty(t, ops.Quotas)
+	})
+
+	t.Run("reports the effective quotas and whether they are reached", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.Quota.Enabled = true
+
+		result := collect(t, cfg, &fakeQuotaByScope{quotas: map[quota.Scope]map[int64][]quota.QuotaDTO{
+			quota.GlobalScope: {0: {
+				{Target: "user", Limit: -1, Used: 40},
+				{Target: "dashboard", Limit: 100, Used: 12},
+			}},
+			quota.OrgScope: {10: {
+				{OrgId: 10, Target: "api_key", Limit: 2, Used: 2},
+			}},
+			quota.UserScope: {3: {
+				{UserId: 3, Target: "org_user", Limit: 1, Used: 0},
+			}},
+		}})
+		require.True(t, result.Enabled)
+		require.Empty(t, result.Note)
+		require.Equal(t, []usage{
+			{Target: "dashboard", Limit: 100, Used: 12},
+			{Target: "user", Limit: -1, Used: 40},
+		}, result.Global)
+		require.Equal(t, []usage{{Target: "api_key", Limit: 2, Used: 2, Reached: true}}, result.Orgs[orgByID(t, result, 10)].Quotas)
+		require.Len(t, result.Users, 1)
+		require.EqualValues(t, 3, result.Users[0].UserID)
+		require.Equal(t, []usage{{Target: "org_user", Limit: 1}}, result.Users[0].Quotas)
+	})
+
+	t.Run("fails when the quotas can't be retrieved", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.Quota.Enabled = true
+
+		_, err := quotasCollector(cfg, sqlStore, &fakeQuotaByScope{err: context.DeadlineExceeded}).Fn(context.Background())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
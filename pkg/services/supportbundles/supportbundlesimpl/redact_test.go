@@ -0,0 +1,166 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+const plantedSecret = "s3cr3t-planted-value"
+
+func TestRedactor_redactSecrets(t *testing.T) {
+	r := newRedactor(defaultRedactKeys)
+
+	t.Run("redacts nested JSON keys and URL credentials", func(t *testing.T) {
+		data, err := json.Marshal(map[string]interface{}{
+			"security": map[string]string{"admin_password": plantedSecret, "admin_user": "admin"},
+			"auth.generic_oauth": map[string]interface{}{
+				"client_secret": plantedSecret,
+				"nested":        []interface{}{map[string]interface{}{"api_token": plantedSecret}},
+			},
+			"database": map[string]string{"url": "postgres://grafana:" + plantedSecret + "@localhost:5432/grafana"},
+		})
+		require.NoError(t, err)
+
+		redacted := string(r.redactSecrets("settings.json", data))
+		require.NotContains(t, redacted, plantedSecret)
+		require.Contains(t, redacted, `"admin_user":"admin"`)
+		require.Contains(t, redacted, "postgres://<redacted>@localhost:5432/grafana")
+	})
+
+	t.Run("redacts assignments in text files", func(t *testing.T) {
+		text := strings.Join([]string{
+			"[smtp]",
+			"password = " + plantedSecret,
+			"bind_password: '" + plantedSecret + "'",
+			"host = localhost:25",
+			"proxy = http://user:" + plantedSecret + "@proxy",
+			"logger=sqlstore password=" + plantedSecret,
+			`{"apiKey": "` + plantedSecret + `"}`,
+			"data_keys_cache_ttl = 15m",
+		}, "\n")
+
+		redacted := r.redactSecrets("ldap.md", []byte(text))
+		require.NotContains(t, string(redacted), plantedSecret)
+		require.Contains(t, string(redacted), "host = localhost:25")
+		require.Contains(t, string(redacted), "logger=sqlstore password=<redacted>")
+		require.Contains(t, string(redacted), "data_keys_cache_ttl = 15m")
+	})
+
+	t.Run("uses the configured keys", func(t *testing.T) {
+		r := newRedactor([]string{"passphrase"})
+		redacted := r.redactSecrets("custom.txt", []byte("passphrase = "+plantedSecret))
+		require.NotContains(t, string(redacted), plantedSecret)
+	})
+
+	t.Run("leaves binary profiles untouched", func(t *testing.T) {
+		data := []byte("token = " + plantedSecret)
+		require.Equal(t, data, r.redactSecrets("heap.pprof", data))
+	})
+}
+
+func TestRedactor_isSensitiveKey(t *testing.T) {
+	r := newRedactor(defaultRedactKeys)
+
+	for _, key := range []string{"password", "admin_password", "bind-password", "client_secret", "secretKey",
+		"access_token", "auth.token", "apiKey", "API_KEY", "x-api-key", "private_key", "privateKey", "tlsClientKey",
+		"basicAuthPassword", "credentials", "aws_credential"} {
+		require.True(t, r.isSensitiveKey(key), key)
+	}
+	for _, key := range []string{"api_keys", "data_keys", "data_keys_cache_ttl", "certificates", "keyId",
+		"tokens", "active_unseen_rotated_tokens", "keystone", "tokenizer", "secretary"} {
+		require.False(t, r.isSensitiveKey(key), key)
+	}
+}
+
+// TestRedactor_keepsCollectorMetadata feeds output shaped like the one of the
+// collectors reporting about secrets without holding any through the redaction
+// of every collector output.
+func TestRedactor_keepsCollectorMetadata(t *testing.T) {
+	r := newRedactor(defaultRedactKeys)
+	redact := func(t *testing.T, data string) map[string]interface{} {
+		t.Helper()
+		var v map[string]interface{}
+		require.NoError(t, json.Unmarshal(r.redactSecrets("output.json", []byte(data)), &v))
+		return v
+	}
+
+	t.Run("tls", func(t *testing.T) {
+		v := redact(t, `{"protocol":"https","files":[{"source":"server.cert_file","path":"/etc/grafana/grafana.crt",
+			"certificates":[{"subject":"CN=grafana","not_after":"2030-01-02T03:04:05Z","expired":false,"expiring_soon":false}]}]}`)
+		file := v["files"].([]interface{})[0].(map[string]interface{})
+		certificate := file["certificates"].([]interface{})[0].(map[string]interface{})
+		require.Equal(t, "2030-01-02T03:04:05Z", certificate["not_after"])
+		require.Equal(t, false, certificate["expired"])
+	})
+
+	t.Run("secrets status", func(t *testing.T) {
+		v := redact(t, `{"current_provider":"secretKey.v1","data_keys_cache_ttl":"15m0s","default_secret_key":true,
+			"data_keys":{"total":3,"active":1,"recent":[{"id":"abc","active":true}]}}`)
+		require.Equal(t, "15m0s", v["data_keys_cache_ttl"])
+		require.Equal(t, true, v["default_secret_key"])
+		require.EqualValues(t, 3, v["data_keys"].(map[string]interface{})["total"])
+	})
+
+	t.Run("service accounts", func(t *testing.T) {
+		v := redact(t, `{"service_accounts":[{"login":"sa-1-ci","tokens":2}],
+			"api_keys":[{"name":"legacy","expires_at":"2030-01-02T03:04:05Z","expired":false,"revoked":false}]}`)
+		require.EqualValues(t, 2, v["service_accounts"].([]interface{})[0].(map[string]interface{})["tokens"])
+		apiKey := v["api_keys"].([]interface{})[0].(map[string]interface{})
+		require.Equal(t, "2030-01-02T03:04:05Z", apiKey["expires_at"])
+		require.Equal(t, false, apiKey["revoked"])
+	})
+
+	t.Run("sessions", func(t *testing.T) {
+		v := redact(t, `{"settings":{"token_rotation_interval_minutes":10},"counts":{"active":4,"active_unseen_rotated_tokens":1}}`)
+		require.EqualValues(t, 10, v["settings"].(map[string]interface{})["token_rotation_interval_minutes"])
+		require.EqualValues(t, 1, v["counts"].(map[string]interface{})["active_unseen_rotated_tokens"])
+	})
+
+	t.Run("quotas", func(t *testing.T) {
+		v := redact(t, `{"enabled":true,"counts":{"dashboards":4,"api_keys":2}}`)
+		require.EqualValues(t, 2, v["counts"].(map[string]interface{})["api_keys"])
+	})
+
+	t.Run("values that can hold a secret are still redacted", func(t *testing.T) {
+		v := redact(t, `{"token":"`+plantedSecret+`","api_key":{"value":"`+plantedSecret+`"},"password":["`+plantedSecret+`"],"token_set":true}`)
+		require.Equal(t, redactedValue, v["token"])
+		require.Equal(t, redactedValue, v["api_key"])
+		require.Equal(t, redactedValue, v["password"])
+		require.Equal(t, true, v["token_set"])
+	})
+}
+
+func TestService_bundle_PlantedSecretNeverInBundle(t *testing.T) {
+	leaky := newTestCollector("leaky", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		data, err := json.Marshal(map[string]map[string]string{
+			"security": {"secret_key": plantedSecret},
+		})
+		return &supportbundles.SupportItem{Filename: "settings.json", FileBytes: data}, err
+	})
+	leakyText := newTestCollector("leaky-text", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "config.ini", FileBytes: []byte("client_secret = " + plantedSecret)}, nil
+	})
+
+	leakyError := newTestCollector("leaky-error", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return nil, fmt.Errorf("failed to connect to postgres://grafana:%s@db:5432/grafana", plantedSecret)
+	})
+
+	s := newTestService(t, leaky, leakyText, leakyError)
+	bundle, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+
+	data, _, err := bundleArchive(context.Background(), s, s.selectCollectors(nil), bundle.UID, nil)
+	require.NoError(t, err)
+
+	for name, content := range readBundle(t, data) {
+		require.NotContains(t, string(content), plantedSecret, name)
+	}
+}
@@ -0,0 +1,75 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/infra/remotecache"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func remoteCacheCollector(cfg *setting.Cfg, cache *remotecache.RemoteCache) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "remote-cache",
+		DisplayName:       "Remote cache",
+		Description:       "The remote cache backend, whether it is reachable and its hits and misses",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type remoteCacheStatus struct {
+				Type       string `json:"type"`
+				Connection string `json:"connection,omitempty"`
+				Prefix     string `json:"prefix,omitempty"`
+				Encryption bool   `json:"encryption"`
+				Connected  bool   `json:"connected"`
+				Error      string `json:"error,omitempty"`
+				Hits       int64  `json:"hits"`
+				Misses     int64  `json:"misses"`
+			}
+
+			status := remoteCacheStatus{}
+			if opts := cfg.RemoteCacheOptions; opts != nil {
+				status.Type = opts.Name
+				status.Connection = redactConnStr(opts.ConnStr)
+				status.Prefix = opts.Prefix
+				status.Encryption = opts.Encryption
+			}
+
+			if cache != nil {
+				if err := cache.Ping(ctx); err != nil {
+					status.Error = err.Error()
+				} else {
+					status.Connected = true
+				}
+				stats := cache.Stats()
+				status.Hits = stats.Hits
+				status.Misses = stats.Misses
+			}
+
+			data, err := json.Marshal(status)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "remote-cache.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+// redactConnStr masks the credentials of a cache connection string, either a
+// comma separated list of key=value options or a URL.
+func redactConnStr(connStr string) string {
+	options := strings.Split(connStr, ",")
+	for i, option := range options {
+		key, _, ok := strings.Cut(option, "=")
+		if ok && defaultRedactor.isSensitiveKey(strings.TrimSpace(key)) {
+			options[i] = key + "=" + redactedValue
+		}
+	}
+	return redactURLCredentials(strings.Join(options, ","))
+}
@@ -0,0 +1,96 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/rendering"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// renderTestTimeout bounds the test render, so that a hung renderer doesn't use up the collector timeout.
+const renderTestTimeout = 30 * time.Second
+
+// renderTestPath is rendered to check the renderer end-to-end. It's a static file that
+// doesn't require authentication, so that the test doesn't depend on the data of the instance.
+const renderTestPath = "robots.txt"
+
+func renderingCollector(cfg *setting.Cfg, renderService rendering.Service) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "rendering",
+		DisplayName:       "Image renderer",
+		Description:       "Whether the image renderer is configured and reachable, its version and the result of a test render",
+		IncludedByDefault: false,
+		Default:           false,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type testRender struct {
+				Success    bool   `json:"success"`
+				Error      string `json:"error,omitempty"`
+				DurationMs int64  `json:"duration_ms"`
+			}
+			type renderingStatus struct {
+				// Mode is remote when a renderer service is configured, plugin when the
+				// renderer plugin is installed and none otherwise.
+				Mode        string      `json:"mode"`
+				ServerURL   string      `json:"server_url,omitempty"`
+				CallbackURL string      `json:"callback_url"`
+				Available   bool        `json:"available"`
+				Version     string      `json:"version,omitempty"`
+				TestRender  *testRender `json:"test_render,omitempty"`
+			}
+
+			status := renderingStatus{
+				Mode:        "none",
+				ServerURL:   redactURLCredentials(cfg.RendererUrl),
+				CallbackURL: redactURLCredentials(cfg.RendererCallbackUrl),
+				Available:   renderService.IsAvailable(ctx),
+				Version:     renderService.Version(),
+			}
+			switch {
+			case cfg.RendererUrl != "":
+				status.Mode = "remote"
+			case status.Available:
+				status.Mode = "plugin"
+			}
+
+			if status.Available {
+				ctx, cancel := context.WithTimeout(ctx, renderTestTimeout)
+				defer cancel()
+
+				start := time.Now()
+				result, err := renderService.Render(ctx, rendering.Opts{
+					AuthOpts: rendering.AuthOpts{OrgID: 1, OrgRole: org.RoleViewer},
+					ErrorOpts: rendering.ErrorOpts{
+						ErrorConcurrentLimitReached: true,
+						ErrorRenderUnavailable:      true,
+					},
+					TimeoutOpts:     rendering.TimeoutOpts{Timeout: renderTestTimeout / 2},
+					Width:           100,
+					Height:          100,
+					Path:            renderTestPath,
+					ConcurrentLimit: cfg.RendererConcurrentRequestLimit,
+				}, nil)
+				status.TestRender = &testRender{Success: err == nil, DurationMs: time.Since(start).Milliseconds()}
+				if err != nil {
+					status.TestRender.Error = err.Error()
+				} else if result != nil && result.FilePath != "" {
+					_ = os.Remove(result.FilePath)
+				}
+			}
+
+			data, err := json.Marshal(status)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "rendering.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
@@ -0,0 +1,83 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/rendering"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestRenderingCollector(t *testing.T) {
+	type renderingStatus struct {
+		Mode       string `json:"mode"`
+		ServerURL  string `json:"server_url"`
+		Available  bool   `json:"available"`
+		Version    string `json:"version"`
+		TestRender *struct {
+			Success bool   `json:"success"`
+			Error   string `json:"error"`
+		} `json:"test_render"`
+	}
+
+	collect := func(t *testing.T, cfg *setting.Cfg, renderService rendering.Service) renderingStatus {
+		t.Helper()
+		item, err := renderingCollector(cfg, renderService).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "rendering.json", item.Filename)
+
+		var status renderingStatus
+		require.NoError(t, json.Unmarshal(item.FileBytes, &status))
+		return status
+	}
+
+	t.Run("renders a test image and removes it", func(t *testing.T) {
+		rendered := filepath.Join(t.TempDir(), "test.png")
+		require.NoError(t, os.WriteFile(rendered, []byte("png"), 0o600))
+
+		renderService := rendering.NewMockService(gomock.NewController(t))
+		renderService.EXPECT().IsAvailable(gomock.Any()).Return(true)
+		renderService.EXPECT().Version().Return("3.6.0")
+		renderService.EXPECT().Render(gomock.Any(), gomock.Any(), nil).Return(&rendering.RenderResult{FilePath: rendered}, nil)
+
+		cfg := setting.NewCfg()
+		cfg.RendererUrl = "http://user:" + plantedSecret + "@renderer:8081/render"
+		status := collect(t, cfg, renderService)
+
+		require.Equal(t, "remote", status.Mode)
+		require.NotContains(t, status.ServerURL, plantedSecret)
+		require.True(t, status.Available)
+		require.Equal(t, "3.6.0", status.Version)
+		require.True(t, status.TestRender.Success)
+		require.NoFileExists(t, rendered)
+	})
+
+	t.Run("records failed test renders", func(t *testing.T) {
+		renderService := rendering.NewMockService(gomock.NewController(t))
+		renderService.EXPECT().IsAvailable(gomock.Any()).Return(true)
+		renderService.EXPECT().Version().Return("3.6.0")
+		renderService.EXPECT().Render(gomock.Any(), gomock.Any(), nil).Return(nil, errors.New("timeout"))
+
+		status := collect(t, setting.NewCfg(), renderService)
+		require.Equal(t, "plugin", status.Mode)
+		require.False(t, status.TestRender.Success)
+		require.Equal(t, "timeout", status.TestRender.Error)
+	})
+
+	t.Run("skips the test render without a renderer", func(t *testing.T) {
+		renderService := rendering.NewMockService(gomock.NewController(t))
+		renderService.EXPECT().IsAvailable(gomock.Any()).Return(false)
+		renderService.EXPECT().Version().Return("")
+
+		status := collect(t, setting.NewCfg(), renderService)
+		require.Equal(t, "none", status.Mode)
+		require.Nil(t, status.TestRender)
+	})
+}
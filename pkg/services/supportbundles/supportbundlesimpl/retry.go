@@ -0,0 +1,109 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+var (
+	ErrBundlePending      = errors.New("support bundle is still being created")
+	ErrBundleNotRetryable = errors.New("support bundle has no failed collectors to retry")
+)
+
+// retry runs the collectors that failed in a partial or failed bundle again and
+// merges their output into the existing archive. Collectors that are no longer
+// registered, disabled, or that usr isn't allowed to run, keep their previous outcome.
+func (s *Service) retry(ctx context.Context, uid string, usr *user.SignedInUser) (*supportbundles.Bundle, error) {
+	bundle, err := s.store.Get(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve support bundle with UID %s: %w", uid, err)
+	}
+
+	switch bundle.State {
+	case supportbundles.StatePending:
+		return nil, ErrBundlePending
+	case supportbundles.StatePartial, supportbundles.StateError:
+	default:
+		return nil, fmt.Errorf("%w: the bundle is %s", ErrBundleNotRetryable, bundle.State)
+	}
+
+	base, err := s.readContents(ctx, bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	registered := s.bundleRegistry.Collectors()
+	failed := make([]supportbundles.Collector, 0)
+	for _, report := range base.reports {
+		if collector, ok := registered[report.UID]; ok && !report.Success && !s.isCollectorDisabled(report.UID) {
+			failed = append(failed, collector)
+		}
+	}
+	collectors, _, err := s.authorizeCollectors(ctx, usr, failed)
+	if err != nil {
+		return nil, err
+	}
+	if len(collectors) == 0 {
+		return nil, ErrBundleNotRetryable
+	}
+
+	select {
+	case s.creationSlots <- struct{}{}:
+	default:
+		return nil, ErrTooManyBundles
+	}
+
+	eta, ok := s.startJob(uid, collectors, base, "", nil, bundle.Params)
+	if !ok {
+		<-s.creationSlots
+		return nil, ErrBundlePending
+	}
+	bundle.State = supportbundles.StatePending
+	bundle.Progress = 0
+	bundle.EstimatedCompletedAt = eta.Unix()
+	bundle.CompletedAt = 0
+	bundle.Duration = 0
+	bundle.TarBytes = nil
+
+	return bundle, nil
+}
+
+// readContents reads the files and collector reports of an existing bundle from its archive.
+func (s *Service) readContents(ctx context.Context, bundle *supportbundles.Bundle) (*bundleContents, error) {
+	reader, _, err := s.store.GetReader(ctx, bundle.UID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the bundle archive could not be read: %s", ErrBundleNotRetryable, err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			s.log.Warn("Failed to close support bundle reader", "uid", bundle.UID, "error", err)
+		}
+	}()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: the bundle has no archive", ErrBundleNotRetryable)
+	}
+
+	files, err := extract(bundle.Format, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract support bundle archive: %w", err)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(files[manifestFilename], &m); err != nil {
+		// bundles created before the manifest was added don't record the outcome of their collectors
+		return nil, fmt.Errorf("%w: the bundle has no manifest", ErrBundleNotRetryable)
+	}
+
+	return &bundleContents{files: files, reports: m.Collectors, attachments: m.Attachments}, nil
+}
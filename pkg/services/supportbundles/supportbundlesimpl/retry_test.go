@@ -0,0 +1,81 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestService_retry(t *testing.T) {
+	var attempts int32
+	flaky := newTestCollector("flaky", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			return nil, errors.New("database is down")
+		}
+		return &supportbundles.SupportItem{Filename: "flaky.txt", FileBytes: []byte("recovered")}, nil
+	})
+	ok := newTestCollector("ok", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "ok.txt", FileBytes: []byte("ok")}, nil
+	})
+
+	s := newTestService(t, flaky, ok)
+	usr := &user.SignedInUser{Login: "admin"}
+
+	waitForState := func(t *testing.T, uid string, state supportbundles.State) {
+		t.Helper()
+		require.Eventually(t, func() bool {
+			b, err := s.store.Get(context.Background(), uid)
+			return err == nil && b.State == state
+		}, 5*time.Second, 10*time.Millisecond)
+		require.Eventually(t, func() bool { return len(s.creationSlots) == 0 }, 5*time.Second, 10*time.Millisecond)
+	}
+
+	bundle, err := s.create(context.Background(), usr, createOptions{})
+	require.NoError(t, err)
+	waitForState(t, bundle.UID, supportbundles.StatePartial)
+
+	retried, err := s.retry(context.Background(), bundle.UID, usr)
+	require.NoError(t, err)
+	require.Equal(t, supportbundles.StatePending, retried.State)
+	waitForState(t, bundle.UID, supportbundles.StateComplete)
+	require.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+
+	reader, _, err := s.store.GetReader(context.Background(), bundle.UID)
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	files := readBundle(t, data)
+	require.Equal(t, "recovered", string(files["/bundle/flaky.txt"]))
+	require.Equal(t, "ok", string(files["/bundle/ok.txt"]))
+	require.NotContains(t, files, "/bundle/flaky.error.txt")
+
+	var m manifest
+	require.NoError(t, json.Unmarshal(files["/bundle/manifest.json"], &m))
+	require.Len(t, m.Collectors, 2)
+	for _, report := range m.Collectors {
+		require.True(t, report.Success, report.UID)
+	}
+
+	t.Run("complete bundles can't be retried", func(t *testing.T) {
+		_, err := s.retry(context.Background(), bundle.UID, usr)
+		require.ErrorIs(t, err, ErrBundleNotRetryable)
+	})
+
+	t.Run("pending bundles can't be retried", func(t *testing.T) {
+		pending, err := s.store.Create(context.Background(), usr, 0)
+		require.NoError(t, err)
+
+		_, err = s.retry(context.Background(), pending.UID, usr)
+		require.ErrorIs(t, err, ErrBundlePending)
+	})
+}
@@ -0,0 +1,121 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+const (
+	defaultRuntimeSampleCount    = 5
+	defaultRuntimeSampleInterval = 2 * time.Second
+	maxRuntimeSampleCount        = 60
+	minRuntimeSampleInterval     = 100 * time.Millisecond
+	maxRuntimeSampleInterval     = 10 * time.Second
+)
+
+// runtimeSamplerSettings reads the number of samples and the interval between them,
+// capped so that the collector can't hold up a bundle for more than 10 minutes.
+func runtimeSamplerSettings(cfg *setting.Cfg) (int, time.Duration) {
+	section := cfg.SectionWithEnvOverrides("support_bundles")
+
+	count := section.Key("runtime_sample_count").MustInt(defaultRuntimeSampleCount)
+	if count <= 0 {
+		count = defaultRuntimeSampleCount
+	} else if count > maxRuntimeSampleCount {
+		count = maxRuntimeSampleCount
+	}
+
+	interval := section.Key("runtime_sample_interval").MustDuration(defaultRuntimeSampleInterval)
+	if interval < minRuntimeSampleInterval {
+		interval = minRuntimeSampleInterval
+	} else if interval > maxRuntimeSampleInterval {
+		interval = maxRuntimeSampleInterval
+	}
+
+	return count, interval
+}
+
+func runtimeSamplerCollector(cfg *setting.Cfg) supportbundles.Collector {
+	count, interval := runtimeSamplerSettings(cfg)
+
+	return supportbundles.Collector{
+		UID:               "runtime-sampler",
+		DisplayName:       "Runtime samples",
+		Description:       fmt.Sprintf("Goroutine and thread counts and GC pauses sampled %d times every %s, to spot goroutine leaks and GC pressure", count, interval),
+		IncludedByDefault: false,
+		Default:           false,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type sample struct {
+				Time       time.Time `json:"time"`
+				Goroutines int       `json:"goroutines"`
+				// Threads is the number of OS threads created by the runtime.
+				Threads        int   `json:"threads"`
+				NumGC          int64 `json:"num_gc"`
+				GCPauseTotalNs int64 `json:"gc_pause_total_ns"`
+				// GCPausesNs are the pauses of the collections since the previous sample, most recent first.
+				GCPausesNs []int64 `json:"gc_pauses_ns"`
+			}
+			type samples struct {
+				IntervalMs int64    `json:"interval_ms"`
+				Samples    []sample `json:"samples"`
+				// Partial is set when the bundle was cancelled or timed out before every sample was taken.
+				Partial bool `json:"partial,omitempty"`
+			}
+
+			result := samples{IntervalMs: interval.Milliseconds(), Samples: make([]sample, 0, count)}
+			threads := pprof.Lookup("threadcreate")
+			var previousNumGC int64
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+		sampling:
+			for i := 0; i < count; i++ {
+				if i > 0 {
+					select {
+					case <-ticker.C:
+					case <-ctx.Done():
+						result.Partial = true
+						break sampling
+					}
+				}
+
+				var gc debug.GCStats
+				debug.ReadGCStats(&gc)
+				pauses := make([]int64, 0)
+				if i > 0 {
+					for j := 0; j < len(gc.Pause) && int64(j) < gc.NumGC-previousNumGC; j++ {
+						pauses = append(pauses, gc.Pause[j].Nanoseconds())
+					}
+				}
+				previousNumGC = gc.NumGC
+
+				result.Samples = append(result.Samples, sample{
+					Time:           time.Now().UTC(),
+					Goroutines:     runtime.NumGoroutine(),
+					Threads:        threads.Count(),
+					NumGC:          gc.NumGC,
+					GCPauseTotalNs: gc.PauseTotal.Nanoseconds(),
+					GCPausesNs:     pauses,
+				})
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "runtime-samples.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
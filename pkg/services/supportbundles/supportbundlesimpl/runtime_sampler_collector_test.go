@@ -0,0 +1,87 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestRuntimeSamplerSettings(t *testing.T) {
+	testCases := []struct {
+		count, interval string
+		wantCount       int
+		wantInterval    time.Duration
+	}{
+		{wantCount: defaultRuntimeSampleCount, wantInterval: defaultRuntimeSampleInterval},
+		{count: "3", interval: "500ms", wantCount: 3, wantInterval: 500 * time.Millisecond},
+		{count: "1000", interval: "1h", wantCount: maxRuntimeSampleCount, wantInterval: maxRuntimeSampleInterval},
+		{count: "-1", interval: "1ms", wantCount: defaultRuntimeSampleCount, wantInterval: minRuntimeSampleInterval},
+	}
+	for _, tc := range testCases {
+		cfg := setting.NewCfg()
+		section := cfg.Raw.Section("support_bundles")
+		if tc.count != "" {
+			section.Key("runtime_sample_count").SetValue(tc.count)
+		}
+		if tc.interval != "" {
+			section.Key("runtime_sample_interval").SetValue(tc.interval)
+		}
+
+		count, interval := runtimeSamplerSettings(cfg)
+		require.Equal(t, tc.wantCount, count)
+		require.Equal(t, tc.wantInterval, interval)
+	}
+}
+
+func TestRuntimeSamplerCollector(t *testing.T) {
+	type samples struct {
+		IntervalMs int64 `json:"interval_ms"`
+		Samples    []struct {
+			Goroutines int   `json:"goroutines"`
+			Threads    int   `json:"threads"`
+			GCPausesNs []int `json:"gc_pauses_ns"`
+		} `json:"samples"`
+		Partial bool `json:"partial"`
+	}
+
+	cfg := setting.NewCfg()
+	cfg.Raw.Section("support_bundles").Key("runtime_sample_count").SetValue("3")
+	cfg.Raw.Section("support_bundles").Key("runtime_sample_interval").SetValue("100ms")
+	collector := runtimeSamplerCollector(cfg)
+	require.Equal(t, "runtime-sampler", collector.UID)
+
+	t.Run("takes every sample", func(t *testing.T) {
+		item, err := collector.Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "runtime-samples.json", item.Filename)
+
+		var result samples
+		require.NoError(t, json.Unmarshal(item.FileBytes, &result))
+		require.EqualValues(t, 100, result.IntervalMs)
+		require.False(t, result.Partial)
+		require.Len(t, result.Samples, 3)
+		for _, s := range result.Samples {
+			require.Positive(t, s.Goroutines)
+			require.Positive(t, s.Threads)
+			require.NotNil(t, s.GCPausesNs)
+		}
+	})
+
+	t.Run("keeps the samples taken before the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		item, err := collector.Fn(ctx)
+		require.NoError(t, err)
+
+		var result samples
+		require.NoError(t, json.Unmarshal(item.FileBytes, &result))
+		require.True(t, result.Partial)
+		require.Len(t, result.Samples, 1)
+	})
+}
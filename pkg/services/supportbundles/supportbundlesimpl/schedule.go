@@ -0,0 +1,63 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// scheduledBundleCreator is the creator recorded on bundles generated on a schedule.
+const scheduledBundleCreator = "scheduler"
+
+// parseSchedule parses a standard cron expression. It returns nil, disabling
+// scheduled bundles, when expr is empty or invalid.
+func parseSchedule(logger log.Logger, expr string) cron.Schedule {
+	if expr == "" {
+		return nil
+	}
+
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		logger.Error("Invalid support bundle schedule, scheduled bundles are disabled", "schedule", expr, "error", err)
+		return nil
+	}
+	return schedule
+}
+
+// runSchedule creates a bundle every time the schedule fires until ctx is done.
+func (s *Service) runSchedule(ctx context.Context) {
+	for {
+		timer := time.NewTimer(time.Until(s.schedule.Next(time.Now())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.createScheduled(ctx)
+		}
+	}
+}
+
+func (s *Service) createScheduled(ctx context.Context) {
+	if s.lastScheduledUID != "" {
+		bundle, err := s.store.Get(ctx, s.lastScheduledUID)
+		if err == nil && bundle.State == supportbundles.StatePending {
+			s.log.Warn("Previous scheduled support bundle is still pending, skipping run", "uid", s.lastScheduledUID)
+			return
+		}
+	}
+
+	// the schedule is set up by the operator, so every configured collector may run
+	bundle, err := s.create(ctx, internalCreator(scheduledBundleCreator), createOptions{Collectors: s.scheduleCollectors})
+	if err != nil {
+		s.log.Error("Failed to create scheduled support bundle", "error", err)
+		return
+	}
+
+	s.lastScheduledUID = bundle.UID
+	s.log.Info("Created scheduled support bundle", "uid", bundle.UID)
+}
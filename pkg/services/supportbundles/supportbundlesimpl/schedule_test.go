@@ -0,0 +1,59 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+func TestParseSchedule(t *testing.T) {
+	logger := log.NewNopLogger()
+
+	require.Nil(t, parseSchedule(logger, ""))
+	require.Nil(t, parseSchedule(logger, "every night"))
+
+	schedule := parseSchedule(logger, "0 2 * * *")
+	require.NotNil(t, schedule)
+	from := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, time.Date(2023, 1, 2, 2, 0, 0, 0, time.UTC), schedule.Next(from))
+}
+
+func TestService_createScheduled(t *testing.T) {
+	release := make(chan struct{})
+	blocking := newTestCollector("blocking", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		<-release
+		return &supportbundles.SupportItem{Filename: "blocking.txt", FileBytes: []byte("done")}, nil
+	})
+
+	s := newTestService(t, blocking)
+	s.creationSlots = make(chan struct{}, 2)
+
+	s.createScheduled(context.Background())
+	first := s.lastScheduledUID
+	require.NotEmpty(t, first)
+
+	bundle, err := s.store.Get(context.Background(), first)
+	require.NoError(t, err)
+	require.Equal(t, scheduledBundleCreator, bundle.Creator)
+
+	// the previous scheduled bundle is still pending
+	s.createScheduled(context.Background())
+	require.Equal(t, first, s.lastScheduledUID)
+	bundles, _, err := s.list(context.Background(), listQuery{})
+	require.NoError(t, err)
+	require.Len(t, bundles, 1)
+
+	close(release)
+	require.Eventually(t, func() bool {
+		b, err := s.store.Get(context.Background(), first)
+		return err == nil && b.State == supportbundles.StateComplete
+	}, 5*time.Second, 10*time.Millisecond)
+
+	s.createScheduled(context.Background())
+	require.NotEqual(t, first, s.lastScheduledUID)
+}
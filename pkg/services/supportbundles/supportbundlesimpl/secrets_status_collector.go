@@ -0,0 +1,161 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/kmsproviders"
+	"github.com/grafana/grafana/pkg/services/secrets"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// defaultSecretKey is the secret_key of the default configuration.
+const defaultSecretKey = "SW2YcwTIb9zpOOhoPsMm"
+
+// maxReportedDataKeys is how many of the most recent data keys are listed.
+const maxReportedDataKeys = 20
+
+// secretsStatusCollector reports how secrets are encrypted and the data keys they
+// are encrypted with, for secrets that can no longer be decrypted. Neither the
+// secret key nor the data keys are read, only their metadata.
+func secretsStatusCollector(cfg *setting.Cfg, sql db.DB) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "secrets-status",
+		DisplayName:       "Secrets encryption",
+		Description:       "Encryption provider, envelope encryption and data key metadata, without any key material",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type dataKey struct {
+				ID       string    `xorm:"name" json:"id"`
+				Label    string    `xorm:"label" json:"label"`
+				Scope    string    `xorm:"scope" json:"scope"`
+				Provider string    `xorm:"provider" json:"provider"`
+				Active   bool      `xorm:"active" json:"active"`
+				Created  time.Time `xorm:"created" json:"created"`
+			}
+			type providerKeys struct {
+				Provider string `json:"provider"`
+				Total    int    `json:"total"`
+				Active   int    `json:"active"`
+				// Configured is whether the provider is available, secrets encrypted
+				// with the data keys of an unavailable provider can't be decrypted.
+				Configured bool `json:"configured"`
+			}
+			type dataKeys struct {
+				Total  int `json:"total"`
+				Active int `json:"active"`
+				// ByProvider is a list rather than a map keyed by provider, the
+				// secretKey provider would be redacted as a key of the bundle.
+				ByProvider []*providerKeys `json:"by_provider"`
+				// Recent are the most recently created data keys, newest first.
+				Recent []dataKey `json:"recent"`
+			}
+			type secretsStatus struct {
+				CurrentProvider           string                       `json:"current_provider"`
+				CurrentProviderConfigured bool                         `json:"current_provider_configured"`
+				AvailableProviders        []string                     `json:"available_providers"`
+				ProviderSettings          map[string]map[string]string `json:"provider_settings,omitempty"`
+				EnvelopeEncryption        bool                         `json:"envelope_encryption_enabled"`
+				DEKCacheTTL               string                       `json:"dek_cache_ttl"`
+				// UsesDefaultSecret is whether secret_key is still the value of the default configuration.
+				UsesDefaultSecret bool     `json:"uses_default_secret"`
+				DEKStatus         dataKeys `json:"dek_status"`
+				Notes             []string `json:"notes"`
+			}
+
+			security := cfg.SectionWithEnvOverrides("security")
+			current := kmsproviders.NormalizeProviderID(secrets.ProviderID(
+				security.Key("encryption_provider").MustString(kmsproviders.Default)))
+			available := map[string]bool{kmsproviders.Default: true}
+			for _, id := range strings.Fields(security.Key("available_encryption_providers").MustString("")) {
+				available[string(kmsproviders.NormalizeProviderID(secrets.ProviderID(id)))] = true
+			}
+
+			result := secretsStatus{
+				CurrentProvider:           string(current),
+				CurrentProviderConfigured: available[string(current)],
+				AvailableProviders:        make([]string, 0, len(available)),
+				ProviderSettings:          map[string]map[string]string{},
+				EnvelopeEncryption:        cfg.IsFeatureToggleEnabled == nil || !cfg.IsFeatureToggleEnabled(featuremgmt.FlagDisableEnvelopeEncryption),
+				DEKCacheTTL:               cfg.SectionWithEnvOverrides("security.encryption").Key("data_keys_cache_ttl").MustDuration(15 * time.Minute).String(),
+				UsesDefaultSecret:         cfg.SecretKey == defaultSecretKey,
+				DEKStatus:                 dataKeys{ByProvider: []*providerKeys{}, Recent: []dataKey{}},
+				Notes:                     []string{},
+			}
+			for id := range available {
+				result.AvailableProviders = append(result.AvailableProviders, id)
+				if id == kmsproviders.Default {
+					continue
+				}
+				if section, err := cfg.Raw.GetSection("security.encryption." + id); err == nil {
+					result.ProviderSettings[id] = redactStringMap(section.KeysHash())
+				}
+			}
+			sort.Strings(result.AvailableProviders)
+
+			if !result.CurrentProviderConfigured {
+				result.Notes = append(result.Notes, fmt.Sprintf("the current encryption provider %s isn't in available_encryption_providers", current))
+			}
+			if !result.EnvelopeEncryption && current != kmsproviders.Default {
+				result.Notes = append(result.Notes, "envelope encryption is disabled, the encryption provider is only used with envelope encryption")
+			}
+			if result.UsesDefaultSecret {
+				result.Notes = append(result.Notes, "secret_key is the default value, it should be changed before storing secrets")
+			}
+
+			var keys []*dataKey
+			err := sql.WithDbSession(ctx, func(sess *db.Session) error {
+				return sess.Table("data_keys").Cols("name", "label", "scope", "provider", "active", "created").
+					Desc("created").Find(&keys)
+			})
+			if err != nil {
+				return nil, err
+			}
+			byProvider := map[string]*providerKeys{}
+			for _, k := range keys {
+				provider := string(kmsproviders.NormalizeProviderID(secrets.ProviderID(k.Provider)))
+				p, ok := byProvider[provider]
+				if !ok {
+					p = &providerKeys{Provider: provider, Configured: available[provider]}
+					byProvider[provider] = p
+					result.DEKStatus.ByProvider = append(result.DEKStatus.ByProvider, p)
+				}
+				p.Total++
+				result.DEKStatus.Total++
+				if k.Active {
+					p.Active++
+					result.DEKStatus.Active++
+				}
+				if len(result.DEKStatus.Recent) < maxReportedDataKeys {
+					result.DEKStatus.Recent = append(result.DEKStatus.Recent, *k)
+				}
+			}
+			sort.Slice(result.DEKStatus.ByProvider, func(i, j int) bool {
+				return result.DEKStatus.ByProvider[i].Provider < result.DEKStatus.ByProvider[j].Provider
+			})
+			for _, p := range result.DEKStatus.ByProvider {
+				if !p.Configured {
+					result.Notes = append(result.Notes, fmt.Sprintf("%d data keys were encrypted with %s, which isn't available, the secrets encrypted with them can't be decrypted", p.Total, p.Provider))
+				}
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "secrets-status.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
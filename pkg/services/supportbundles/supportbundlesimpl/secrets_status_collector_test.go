@@ -0,0 +1,120 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/secrets"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestSecretsStatusCollector(t *testing.T) {
+	type providerKeys struct {
+		Provider   string `json:"provider"`
+		Total      int    `json:"total"`
+		Active     int    `json:"active"`
+		Configured bool   `json:"configured"`
+	}
+	type secretsStatus struct {
+		CurrentProvider           string                       `json:"current_provider"`
+		CurrentProviderConfigured bool                         `json:"current_provider_configured"`
+		AvailableProviders        []string                     `json:"available_providers"`
+		ProviderSettings          map[string]map[string]string `json:"provider_settings"`
+		EnvelopeEncryption        bool                         `json:"envelope_encryption_enabled"`
+		DEKCacheTTL               string                       `json:"dek_cache_ttl"`
+		UsesDefaultSecret         bool                         `json:"uses_default_secret"`
+		DEKStatus                 struct {
+			Total      int             `json:"total"`
+			Active     int             `json:"active"`
+			ByProvider []*providerKeys `json:"by_provider"`
+			Recent     []struct {
+				ID       string `json:"id"`
+				Provider string `json:"provider"`
+			} `json:"recent"`
+		} `json:"dek_status"`
+		Notes []string `json:"notes"`
+	}
+
+	sqlStore := db.InitTestDB(t)
+	now := time.Now()
+	require.NoError(t, sqlStore.WithDbSession(context.Background(), func(sess *db.Session) error {
+		for _, k := range []*secrets.DataKey{
+			{Id: "old", Label: "2023-01-01/root@secretKey.v1", Scope: "root", Provider: "secretKey", Created: now.Add(-48 * time.Hour)},
+			{Id: "current", Label: "2023-01-02/root@secretKey.v1", Scope: "root", Provider: "secretKey.v1", Active: true, Created: now.Add(-time.Hour)},
+			{Id: "kms", Label: "2023-01-02/root@awskms.v1", Scope: "root", Provider: "awskms.v1", Active: true, Created: now},
+		} {
+			k.EncryptedData = []byte(plantedSecret)
+			k.Updated = k.Created
+			if _, err := sess.Table("data_keys").Insert(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	collect := func(t *testing.T, cfg *setting.Cfg) secretsStatus {
+		t.Helper()
+
+		item, err := secretsStatusCollector(cfg, sqlStore).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "secrets-status.json", item.Filename)
+		require.NotContains(t, string(item.FileBytes), plantedSecret)
+
+		// as written to the bundle
+		var result secretsStatus
+		require.NoError(t, json.Unmarshal(newRedactor(defaultRedactKeys).redactSecrets(item.Filename, item.FileBytes), &result))
+		return result
+	}
+
+	t.Run("reports data keys of unavailable providers", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.SecretKey = defaultSecretKey
+
+		result := collect(t, cfg)
+		require.Equal(t, "secretKey.v1", result.CurrentProvider)
+		require.True(t, result.CurrentProviderConfigured)
+		require.Equal(t, []string{"secretKey.v1"}, result.AvailableProviders)
+		require.True(t, result.EnvelopeEncryption)
+		require.Equal(t, "15m0s", result.DEKCacheTTL)
+		require.True(t, result.UsesDefaultSecret)
+
+		require.Equal(t, 3, result.DEKStatus.Total)
+		require.Equal(t, 2, result.DEKStatus.Active)
+		require.Equal(t, []*providerKeys{
+			{Provider: "awskms.v1", Total: 1, Active: 1},
+			{Provider: "secretKey.v1", Total: 2, Active: 1, Configured: true},
+		}, result.DEKStatus.ByProvider)
+		require.Len(t, result.DEKStatus.Recent, 3)
+		require.Equal(t, "kms", result.DEKStatus.Recent[0].ID)
+
+		require.Len(t, result.Notes, 2)
+		require.Contains(t, result.Notes[1], "awskms.v1")
+	})
+
+	t.Run("reports the configured providers with their settings redacted", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.SecretKey = "changed"
+		cfg.IsFeatureToggleEnabled = func(key string) bool { return key == featuremgmt.FlagDisableEnvelopeEncryption }
+		cfg.Raw.Section("security").Key("encryption_provider").SetValue("awskms.v1")
+		cfg.Raw.Section("security").Key("available_encryption_providers").SetValue("awskms.v1 azurekv.v1")
+		cfg.Raw.Section("security.encryption.awskms.v1").Key("region").SetValue("eu-west-1")
+		cfg.Raw.Section("security.encryption.awskms.v1").Key("secret_access_key").SetValue(plantedSecret)
+
+		result := collect(t, cfg)
+		require.Equal(t, "awskms.v1", result.CurrentProvider)
+		require.True(t, result.CurrentProviderConfigured)
+		require.Equal(t, []string{"awskms.v1", "azurekv.v1", "secretKey.v1"}, result.AvailableProviders)
+		require.Equal(t, "eu-west-1", result.ProviderSettings["awskms.v1"]["region"])
+		require.False(t, result.EnvelopeEncryption)
+		require.False(t, result.UsesDefaultSecret)
+		require.Equal(t, "awskms.v1", result.DEKStatus.ByProvider[0].Provider)
+		require.True(t, result.DEKStatus.ByProvider[0].Configured)
+		require.Equal(t, []string{"envelope encryption is disabled, the encryption provider is only used with envelope encryption"}, result.Notes)
+	})
+}
@@ -1,29 +1,67 @@
 package supportbundlesimpl
 
 import (
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/robfig/cron/v3"
+
 	grafanaApi "github.com/grafana/grafana/pkg/api"
 	"github.com/grafana/grafana/pkg/api/routing"
 	"github.com/grafana/grafana/pkg/infra/db"
 	"github.com/grafana/grafana/pkg/infra/kvstore"
 	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/remotecache"
 	"github.com/grafana/grafana/pkg/infra/usagestats"
 	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/plugins/manager/process"
+	"github.com/grafana/grafana/pkg/registry"
 	ac "github.com/grafana/grafana/pkg/services/accesscontrol"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/licensing"
+	"github.com/grafana/grafana/pkg/services/live"
+	"github.com/grafana/grafana/pkg/services/ngalert"
+	"github.com/grafana/grafana/pkg/services/org"
 	"github.com/grafana/grafana/pkg/services/pluginsettings"
+	pref "github.com/grafana/grafana/pkg/services/preference"
+	"github.com/grafana/grafana/pkg/services/provisioning"
+	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/services/rendering"
+	"github.com/grafana/grafana/pkg/services/secrets"
 	"github.com/grafana/grafana/pkg/services/supportbundles"
 	"github.com/grafana/grafana/pkg/services/supportbundles/bundleregistry"
 	"github.com/grafana/grafana/pkg/services/user"
 	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
 )
 
 const (
-	cleanUpInterval       = 24 * time.Hour
-	bundleCreationTimeout = 20 * time.Minute
+	defaultCleanUpInterval  = 24 * time.Hour
+	bundleCreationTimeout   = 20 * time.Minute
+	defaultCollectorTimeout = 5 * time.Minute
+
+	defaultMaxSizeMB          = 512
+	defaultCollectorMaxSizeMB = 128
+	defaultCollectorWorkers   = 4
+	defaultMaxUploadSizeMB    = 512
+
+	// validateMemoryLimit is how much of an uploaded bundle is kept in memory, the rest is buffered on disk.
+	validateMemoryLimit = 32 << 20
+)
+
+var (
+	ErrBundleNotPending  = errors.New("support bundle is not being created")
+	ErrUnknownCollector  = errors.New("unknown support bundle collector")
+	ErrTooManyBundles    = errors.New("too many support bundles are being created")
+	ErrInvalidTags       = errors.New("invalid support bundle tags")
+	ErrCollectorDisabled = errors.New("support bundle collector is disabled by the instance configuration")
 )
 
 type Service struct {
@@ -37,8 +75,82 @@ type Service struct {
 
 	log log.Logger
 
-	enabled         bool
-	serverAdminOnly bool
+	enabled                 bool
+	serverAdminOnly         bool
+	defaultCollectorTimeout time.Duration
+	collectorTimeouts       map[string]time.Duration
+	redactor                *redactor
+
+	// archiveFormat and compressionLevel control how the bundle archive is assembled.
+	archiveFormat    string
+	compressionLevel int
+
+	// maxSize and collectorMaxSize bound the uncompressed bundle content and the
+	// output of a single collector, in bytes. Zero means unlimited.
+	maxSize          int64
+	collectorMaxSize int64
+	// collectorWorkers is how many collectors of a bundle run concurrently.
+	collectorWorkers int
+	// maxUploadSize bounds the bundles uploaded for validation, in bytes.
+	maxUploadSize int64
+	// uploads are the bundles being uploaded in chunks for validation.
+	uploads *chunkedUploads
+	// maxAttachments, attachmentMaxSize and attachmentsMaxSize bound the files
+	// attached to a bundle, the sizes are in bytes.
+	maxAttachments     int
+	attachmentMaxSize  int64
+	attachmentsMaxSize int64
+
+	// cleanupInterval is how often expired bundles are removed.
+	cleanupInterval time.Duration
+	// maxRetention is how long after their creation bundles can be kept at most by extending their expiry.
+	maxRetention time.Duration
+
+	// disabledCollectors are the collectors operators forbid from running, keyed by UID.
+	// The ones registered by this service are kept, unregistered, to be listed as disabled.
+	disabledCollectors map[string]*supportbundles.Collector
+
+	// presets are named lists of collectors, keyed by name.
+	presets map[string][]string
+	// templates are the collectors and tags organizations define for their bundles.
+	templates *templateStore
+	// symptoms are the problems looked for before creating a bundle, to add the
+	// collectors investigating them. Nil unless smart collection is enabled.
+	symptoms []symptom
+
+	// creationSlots limits how many bundles can be created concurrently.
+	creationSlots chan struct{}
+
+	metrics *metrics
+
+	// schedule is nil unless bundles are generated on a schedule. lastScheduledUID
+	// is only accessed from the scheduling goroutine.
+	schedule           cron.Schedule
+	scheduleCollectors []string
+	lastScheduledUID   string
+
+	// webhook is notified when bundles finish, nil if not configured.
+	webhook *webhookNotifier
+	// uploader uploads archives to the URL given when creating bundles, nil if uploads are disabled.
+	uploader *bundleUploader
+	// audit records who created, removed and downloaded bundles, nil if auditing is disabled.
+	audit *auditLog
+	// tokens mints and verifies the tokens that allow creating bundles without a session, nil if they're disabled.
+	tokens *tokenSigner
+
+	// downloads throttles bundle downloads per user, nil if downloads aren't limited.
+	downloads *downloadLimiter
+
+	// cancelFuncs holds the cancel functions of bundles being created, keyed by bundle UID.
+	cancelMu    sync.Mutex
+	cancelFuncs map[string]context.CancelFunc
+	// partials holds the output of the collectors of bundles being created, keyed by bundle UID.
+	partialsMu sync.Mutex
+	partials   map[string]*partialBundle
+
+	// lastDurations are how long each collector took the last time it ran, to estimate how long jobs take.
+	durationsMu   sync.Mutex
+	lastDurations map[string]time.Duration
 }
 
 func ProvideService(cfg *setting.Cfg,
@@ -51,21 +163,77 @@ func ProvideService(cfg *setting.Cfg,
 	settings setting.Provider,
 	pluginStore plugins.Store,
 	pluginSettings pluginsettings.Service,
+	pluginClient plugins.Client,
+	pluginErrorResolver plugins.ErrorResolver,
 	features *featuremgmt.FeatureManager,
 	httpServer *grafanaApi.HTTPServer,
-	usageStats usagestats.Service) (*Service, error) {
+	usageStats usagestats.Service,
+	alertNG *ngalert.AlertNG,
+	secretsService secrets.Service,
+	registerer prometheus.Registerer,
+	serviceTracker *registry.BackgroundServiceTracker,
+	liveService *live.GrafanaLive,
+	remoteCache *remotecache.RemoteCache,
+	renderService rendering.Service,
+	pluginProcessManager *process.Manager,
+	provisioningService provisioning.ProvisioningService,
+	quotaService quota.Service,
+	license licensing.Licensing,
+	prefService pref.Service) (*Service, error) {
 	section := cfg.SectionWithEnvOverrides("support_bundles")
+	bundleStore, err := provideStore(cfg, kvStore)
+	if err != nil {
+		return nil, err
+	}
+	if section.Key("encrypt").MustBool(false) {
+		bundleStore = newEncryptedStore(bundleStore, secretsService)
+	}
+
+	logger := log.New("supportbundle.service")
 	s := &Service{
 		cfg:             cfg,
-		store:           newStore(kvStore),
+		store:           bundleStore,
 		pluginStore:     pluginStore,
 		pluginSettings:  pluginSettings,
 		accessControl:   accessControl,
 		features:        features,
 		bundleRegistry:  bundleRegistry,
-		log:             log.New("supportbundle.service"),
+		log:             logger,
 		enabled:         section.Key("enabled").MustBool(true),
 		serverAdminOnly: section.Key("server_admin_only").MustBool(true),
+
+		defaultCollectorTimeout: section.Key("collector_timeout").MustDuration(defaultCollectorTimeout),
+		collectorTimeouts:       readCollectorTimeouts(logger, cfg),
+		redactor:                newRedactor(util.SplitString(section.Key("redact_keys").MustString(strings.Join(defaultRedactKeys, ",")))),
+		archiveFormat:           parseArchiveFormat(logger, section.Key("format").MustString(formatTarGz)),
+		compressionLevel:        parseCompressionLevel(logger, section.Key("compression_level").MustInt(gzip.DefaultCompression)),
+		maxSize:                 section.Key("max_size").MustInt64(defaultMaxSizeMB) * 1024 * 1024,
+		collectorMaxSize:        section.Key("collector_max_size").MustInt64(defaultCollectorMaxSizeMB) * 1024 * 1024,
+		collectorWorkers:        section.Key("collector_workers").MustInt(defaultCollectorWorkers),
+		cancelFuncs:             make(map[string]context.CancelFunc),
+		partials:                make(map[string]*partialBundle),
+		creationSlots:           make(chan struct{}, maxConcurrent(section.Key("max_concurrent").MustInt(1))),
+		metrics:                 newMetrics(registerer),
+		schedule:                parseSchedule(logger, section.Key("schedule").MustString("")),
+		scheduleCollectors:      util.SplitString(section.Key("schedule_collectors").MustString("")),
+		webhook:                 newWebhookNotifier(section.Key("webhook_url").MustString(""), section.Key("webhook_secret").MustString(""), logger),
+		uploader:                newBundleUploader(util.SplitString(section.Key("upload_allowed_schemes").MustString("https")), util.SplitString(section.Key("upload_allowed_hosts").MustString(""))),
+		audit:                   newAuditLog(kvStore, section.Key("audit_log").MustBool(true), section.Key("audit_log_retention").MustDuration(defaultAuditRetention)),
+		tokens:                  newTokenSigner(cfg.SecretKey, section.Key("token_ttl").MustDuration(0), kvStore),
+		downloads:               newDownloadLimiter(section.Key("download_rate").MustInt(0)),
+		maxUploadSize:           section.Key("max_upload_size").MustInt64(defaultMaxUploadSizeMB) * 1024 * 1024,
+		uploads:                 newChunkedUploads(section.Key("max_upload_size").MustInt64(defaultMaxUploadSizeMB)*1024*1024, section.Key("upload_expiry").MustDuration(defaultChunkedUploadExpiry)),
+		cleanupInterval:         parseCleanupInterval(logger, section.Key("cleanup_interval").MustDuration(defaultCleanUpInterval)),
+		maxRetention:            section.Key("max_retention").MustDuration(defaultMaxRetention),
+		disabledCollectors:      readDisabledCollectors(cfg),
+		presets:                 readPresets(cfg),
+		templates:               newTemplateStore(kvStore),
+		maxAttachments:          section.Key("max_attachments").MustInt(defaultMaxAttachments),
+		attachmentMaxSize:       section.Key("attachment_max_size").MustInt64(defaultAttachmentMaxSizeMB) * 1024 * 1024,
+		attachmentsMaxSize:      section.Key("attachments_max_size").MustInt64(defaultAttachmentsMaxSizeMB) * 1024 * 1024,
+	}
+	if section.Key("smart_collection").MustBool(false) {
+		s.symptoms = defaultSymptoms(sql)
 	}
 
 	usageStats.RegisterMetricsFunc(s.getUsageStats)
@@ -83,58 +251,471 @@ func ProvideService(cfg *setting.Cfg,
 	s.registerAPIEndpoints(httpServer, routeRegister)
 
 	// TODO: move to relevant services
-	s.bundleRegistry.RegisterSupportItemCollector(basicCollector(cfg))
-	s.bundleRegistry.RegisterSupportItemCollector(settingsCollector(settings))
-	s.bundleRegistry.RegisterSupportItemCollector(dbCollector(sql))
-	s.bundleRegistry.RegisterSupportItemCollector(pluginInfoCollector(pluginStore, pluginSettings))
+	s.registerOfflineCollectors(cfg, sql, settings)
+	s.registerCollector(pluginInfoCollector(pluginStore, pluginSettings))
+	s.registerCollector(pluginSignatureCollector(cfg, pluginStore, pluginErrorResolver, pluginSettings))
+	s.registerCollector(pluginHealthCollector(pluginStore, pluginClient, pluginHealthCheckTimeout))
+	s.registerCollector(pluginHealthHistoryCollector(pluginProcessManager))
+	s.registerCollector(provisioningErrorsCollector(provisioningService))
+	s.registerCollector(provisioningDriftCollector(sql, provisioningService))
+	for _, collector := range profileCollectors(cfg) {
+		s.registerCollector(collector)
+	}
+	s.registerCollector(runtimeSamplerCollector(cfg))
+	s.registerCollector(alertingStateCollector(alertNG))
+	s.registerCollector(contactPointsCollector(alertNG))
+	s.registerCollector(featureFlagCollector(features))
+	s.registerCollector(backgroundServicesCollector(serviceTracker))
+	s.registerCollector(liveCollector(liveService))
+	s.registerCollector(remoteCacheCollector(cfg, remoteCache))
+	s.registerCollector(renderingCollector(cfg, renderService))
+	s.registerCollector(quotasCollector(cfg, sql, quotaService))
+	s.registerCollector(osLimitsCollector(procSelfPath, cgroupPath))
+	s.registerCollector(localeCollector(cfg, sql, prefService))
+	if hasLicensing(license) {
+		s.registerCollector(licenseCollector(license, sql))
+	}
+	// the registerer is the registry served on /metrics
+	gatherer, _ := registerer.(prometheus.Gatherer)
+	s.registerCollector(metricsSnapshotCollector(gatherer))
+	s.warnUnknownCollectorTimeouts()
 
 	return s, nil
 }
 
+// provideStore returns the bundle store configured by support_bundles.storage.
+func provideStore(cfg *setting.Cfg, kvStore kvstore.KVStore) (bundleStore, error) {
+	section := cfg.SectionWithEnvOverrides("support_bundles")
+	retention := section.Key("retention").MustDuration(defaultBundleExpiration)
+
+	prefix := section.Key("uid_prefix").MustString("")
+	if err := validateUIDPrefix(prefix); err != nil {
+		return nil, err
+	}
+
+	var (
+		bundleStore bundleStore
+		err         error
+	)
+	switch storage := section.Key("storage").MustString("kvstore"); storage {
+	case "kvstore":
+		bundleStore = newStore(kvStore, retention)
+	case "filesystem":
+		bundleStore, err = newFileStore(kvStore, retention, bundleStoragePath(cfg))
+	case "object":
+		bundleStore, err = newObjectStore(context.Background(), kvStore, retention,
+			section.Key("object_storage_url").MustString(""),
+			section.Key("object_storage_prefix").MustString("support-bundles"))
+	default:
+		return nil, fmt.Errorf("unsupported support bundle storage: %s", storage)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p, ok := bundleStore.(uidPrefixer); ok {
+		p.setUIDPrefix(prefix)
+	}
+	return bundleStore, nil
+}
+
+// bundleStoragePath returns the directory bundles are written to by the filesystem storage.
+func bundleStoragePath(cfg *setting.Cfg) string {
+	return cfg.SectionWithEnvOverrides("support_bundles").Key("storage_path").MustString(filepath.Join(cfg.DataPath, "support-bundles"))
+}
+
+// parseCleanupInterval returns the cleanup interval, falling back to the default when it isn't positive.
+func parseCleanupInterval(logger log.Logger, interval time.Duration) time.Duration {
+	if interval <= 0 {
+		logger.Warn("Invalid support bundle cleanup interval, using default", "interval", interval, "default", defaultCleanUpInterval)
+		return defaultCleanUpInterval
+	}
+	return interval
+}
+
+// readDisabledCollectors reads the UIDs of the collectors disabled by support_bundles.disabled_collectors.
+func readDisabledCollectors(cfg *setting.Cfg) map[string]*supportbundles.Collector {
+	disabled := map[string]*supportbundles.Collector{}
+	for _, uid := range util.SplitString(cfg.SectionWithEnvOverrides("support_bundles").Key("disabled_collectors").MustString("")) {
+		disabled[uid] = nil
+	}
+	return disabled
+}
+
+func maxConcurrent(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// readCollectorTimeouts reads per collector timeout overrides from the
+// [support_bundles.collector_timeouts] section, keyed by collector UID. Invalid
+// overrides are ignored, and the ones longer than a bundle creation are capped.
+func readCollectorTimeouts(logger log.Logger, cfg *setting.Cfg) map[string]time.Duration {
+	section := cfg.Raw.Section("support_bundles.collector_timeouts")
+	timeouts := make(map[string]time.Duration, len(section.Keys()))
+	for _, key := range section.Keys() {
+		timeout, err := time.ParseDuration(key.Value())
+		if err != nil || timeout <= 0 {
+			logger.Warn("Ignoring invalid support bundle collector timeout", "collector", key.Name(), "timeout", key.Value())
+			continue
+		}
+		if timeout > bundleCreationTimeout {
+			logger.Warn("Support bundle collector timeout exceeds the bundle creation timeout, capping it",
+				"collector", key.Name(), "timeout", timeout, "max", bundleCreationTimeout)
+			timeout = bundleCreationTimeout
+		}
+		timeouts[key.Name()] = timeout
+	}
+	return timeouts
+}
+
+// warnUnknownCollectorTimeouts logs the timeout overrides of collectors that
+// don't exist, e.g. because of a typo, once every collector is registered.
+func (s *Service) warnUnknownCollectorTimeouts() {
+	registered := s.bundleRegistry.Collectors()
+	for uid := range s.collectorTimeouts {
+		if _, ok := registered[uid]; ok {
+			continue
+		}
+		if _, ok := s.disabledCollectors[uid]; ok {
+			continue
+		}
+		s.log.Warn("Support bundle collector timeout configured for an unknown collector", "collector", uid)
+	}
+}
+
 func (s *Service) Run(ctx context.Context) error {
 	if !s.features.IsEnabled(featuremgmt.FlagSupportBundles) {
 		return nil
 	}
 
-	ticker := time.NewTicker(cleanUpInterval)
+	if s.enabled && s.schedule != nil {
+		go s.runSchedule(ctx)
+	}
+
+	ticker := time.NewTicker(s.cleanupInterval)
 	defer ticker.Stop()
 	s.cleanup(ctx)
-	select {
-	case <-ticker.C:
-		s.cleanup(ctx)
-	case <-ctx.Done():
-		break
+	for {
+		select {
+		case <-ticker.C:
+			s.cleanup(ctx)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
-	return ctx.Err()
 }
 
-func (s *Service) create(ctx context.Context, collectors []string, usr *user.SignedInUser) (*supportbundles.Bundle, error) {
-	bundle, err := s.store.Create(ctx, usr)
+// createOptions are the options of a bundle creation.
+type createOptions struct {
+	// Collectors are the UIDs of the collectors to run on top of the ones included by default.
+	Collectors []string
+	// Retention overrides how long the bundle is kept, zero uses the default retention.
+	Retention   time.Duration
+	Description string
+	Tags        map[string]string
+	// Attachments are extra files to add to the bundle.
+	Attachments []attachment
+	// UploadURL is where to upload the archive to instead of storing it, optional.
+	UploadURL string
+	// Preset names a list of collectors to run along with Collectors, optional.
+	Preset string
+	// Template is the UID of a template of the organization of the creator whose
+	// collectors and tags are added to the requested ones, optional.
+	Template string
+	// Origin is how the request creating the bundle reached Grafana, optional.
+	Origin *requestOrigin
+	// Params are the parameters of the collectors, by collector UID, optional.
+	Params map[string]map[string]int64
+}
+
+func (s *Service) create(ctx context.Context, usr *user.SignedInUser, opts createOptions) (*supportbundles.Bundle, error) {
+	collectors, tags, err := s.withTemplate(ctx, usr.OrgID, opts.Template, opts.Collectors, opts.Tags)
 	if err != nil {
 		return nil, err
 	}
+	collectors, err = s.withPreset(opts.Preset, collectors)
+	if err != nil {
+		return nil, err
+	}
+	opts.Collectors = collectors
+	opts.Tags = tags
 
-	go func(uid string, collectors []string) {
-		ctx, cancel := context.WithTimeout(context.Background(), bundleCreationTimeout)
-		defer func() {
-			if err := recover(); err != nil {
-				s.log.Error("support bundle collection panic", "err", err)
-			}
-			cancel()
-		}()
+	autoAdded := s.diagnose(ctx, opts.Collectors)
+	for _, a := range autoAdded {
+		opts.Collectors = append(opts.Collectors, a.UID)
+	}
+
+	if err := s.validateCollectors(opts.Collectors); err != nil {
+		return nil, err
+	}
+	if err := validateTags(opts.Tags); err != nil {
+		return nil, err
+	}
+	if err := s.validateAttachments(opts.Attachments); err != nil {
+		return nil, err
+	}
+	if err := s.validateCollectorParams(opts.Params); err != nil {
+		return nil, err
+	}
+	if opts.UploadURL != "" {
+		if err := s.uploader.validate(opts.UploadURL); err != nil {
+			return nil, err
+		}
+	}
+
+	select {
+	case s.creationSlots <- struct{}{}:
+	default:
+		return nil, ErrTooManyBundles
+	}
+
+	selected, skipped, err := s.authorizeCollectors(ctx, usr, s.selectCollectors(opts.Collectors))
+	if err != nil {
+		<-s.creationSlots
+		return nil, err
+	}
 
-		s.startBundleWork(ctx, collectors, uid)
-	}(bundle.UID, collectors)
+	bundle, err := s.store.Create(ctx, usr, opts.Retention)
+	if err != nil {
+		<-s.creationSlots
+		return nil, err
+	}
+
+	if len(skipped) > 0 {
+		s.log.Info("Skipping restricted support bundle collectors", "uid", bundle.UID, "collectors", skipped)
+	}
+	collectorUIDs := make([]string, 0, len(selected))
+	for _, c := range selected {
+		collectorUIDs = append(collectorUIDs, c.UID)
+	}
+	annotate := func(b *supportbundles.Bundle) {
+		b.Collectors = collectorUIDs
+		b.SkippedCollectors = skipped
+		b.AutoAddedCollectors = autoAdded
+		b.Description = opts.Description
+		b.Tags = opts.Tags
+		b.Params = opts.Params
+	}
+	annotate(bundle)
+	if err := s.store.UpdateMetadata(ctx, bundle.UID, annotate); err != nil {
+		s.log.Warn("Failed to record support bundle metadata", "uid", bundle.UID, "error", err)
+	}
+
+	s.metrics.bundlesCreated.Inc()
+
+	s.audit.record(ctx, usr, auditEntry{Action: auditActionCreate, BundleUID: bundle.UID, Collectors: collectorUIDs, Encrypted: s.isEncrypted()})
+
+	eta, ok := s.startJob(bundle.UID, selected, attachmentContents(opts.Attachments), opts.UploadURL, opts.Origin, opts.Params)
+	if !ok {
+		<-s.creationSlots
+		return bundle, nil
+	}
+	bundle.EstimatedCompletedAt = eta.Unix()
 
 	return bundle, nil
 }
 
+// Create creates a bundle on behalf of another service, see supportbundles.BundleCreator.
+func (s *Service) Create(ctx context.Context, opts supportbundles.Options) (*supportbundles.Bundle, error) {
+	if !s.enabled || s.features == nil || !s.features.IsEnabled(featuremgmt.FlagSupportBundles) {
+		return nil, supportbundles.ErrBundlesDisabled
+	}
+	if opts.Creator == "" {
+		return nil, fmt.Errorf("%w: the creator is required", supportbundles.ErrInvalidOptions)
+	}
+	if opts.Retention < 0 {
+		return nil, fmt.Errorf("%w: the retention can't be negative", supportbundles.ErrInvalidOptions)
+	}
+
+	bundle, err := s.create(ctx, internalCreator(opts.Creator), createOptions{
+		Collectors:  opts.Collectors,
+		Retention:   opts.Retention,
+		Description: opts.Description,
+		Tags:        opts.Tags,
+		Params:      opts.Params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.log.Info("Support bundle created by another service", "uid", bundle.UID, "creator", opts.Creator)
+	return bundle, nil
+}
+
+// internalCreator is the user creating bundles on behalf of Grafana itself, e.g.
+// on a schedule, allowed to run every collector.
+func internalCreator(login string) *user.SignedInUser {
+	return &user.SignedInUser{
+		Login:       login,
+		Permissions: map[int64]map[string][]string{0: {ActionCreate: {ScopeCollectorsAll}}},
+	}
+}
+
+// trackPending registers the cancel function of a bundle being collected. It
+// returns false if the bundle is already being collected.
+func (s *Service) trackPending(uid string, cancel context.CancelFunc) bool {
+	s.cancelMu.Lock()
+	defer s.cancelMu.Unlock()
+
+	if _, ok := s.cancelFuncs[uid]; ok {
+		return false
+	}
+	s.cancelFuncs[uid] = cancel
+	s.metrics.bundlesPending.Inc()
+	return true
+}
+
+// collectInBackground collects a bundle tracked with trackPending and releases
+// the creation slot held by the caller once done.
+func (s *Service) collectInBackground(ctx context.Context, cancel context.CancelFunc, uid string, collectors []supportbundles.Collector, base *bundleContents, uploadURL string) {
+	defer func() {
+		if err := recover(); err != nil {
+			s.log.Error("support bundle collection panic", "err", err)
+		}
+		s.cancelMu.Lock()
+		delete(s.cancelFuncs, uid)
+		s.cancelMu.Unlock()
+		cancel()
+		s.metrics.bundlesPending.Dec()
+		// released last, so the slot is freed even if the collection panicked
+		<-s.creationSlots
+	}()
+
+	s.startBundleWork(ctx, collectors, uid, base, uploadURL)
+}
+
+// validateCollectors returns ErrUnknownCollector listing every requested collector UID that isn't registered.
+func (s *Service) validateCollectors(collectors []string) error {
+	registered := s.bundleRegistry.Collectors()
+	unknown := make([]string, 0)
+	disabled := make([]string, 0)
+	for _, uid := range collectors {
+		if s.isCollectorDisabled(uid) {
+			disabled = append(disabled, uid)
+		} else if _, ok := registered[uid]; !ok {
+			unknown = append(unknown, uid)
+		}
+	}
+
+	if len(disabled) > 0 {
+		return fmt.Errorf("%w: %s", ErrCollectorDisabled, strings.Join(disabled, ", "))
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownCollector, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// registerCollector registers a collector unless it is disabled by the configuration.
+func (s *Service) registerCollector(collector supportbundles.Collector) {
+	if s.isCollectorDisabled(collector.UID) {
+		s.log.Debug("Support bundle collector is disabled, not registering it", "collector", collector.UID)
+		s.disabledCollectors[collector.UID] = &collector
+		return
+	}
+	s.bundleRegistry.RegisterSupportItemCollector(collector)
+}
+
+// isCollectorDisabled reports whether the configuration forbids the collector
+// from running, including collectors registered by other services.
+func (s *Service) isCollectorDisabled(uid string) bool {
+	_, ok := s.disabledCollectors[uid]
+	return ok
+}
+
+// authorizeCollectors filters out the restricted collectors usr isn't allowed to
+// run and returns the UIDs of the skipped ones.
+func (s *Service) authorizeCollectors(ctx context.Context, usr *user.SignedInUser, collectors []supportbundles.Collector) ([]supportbundles.Collector, []string, error) {
+	acDisabled := s.accessControl == nil || s.accessControl.IsDisabled()
+
+	allowed := make([]supportbundles.Collector, 0, len(collectors))
+	var skipped []string
+	for _, collector := range collectors {
+		if collector.Restricted {
+			evaluator := ac.EvalPermission(ActionCreate, ScopeCollectorsProvider.GetResourceScopeUID(collector.UID))
+			var ok bool
+			if acDisabled {
+				// with access control disabled admins may run every collector, the
+				// others, e.g. the users of creation tokens, only what they're granted
+				ok = usr.HasRole(org.RoleAdmin) || evaluator.Evaluate(usr.Permissions[usr.OrgID])
+			} else {
+				var err error
+				ok, err = s.accessControl.Evaluate(ctx, usr, evaluator)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			if !ok {
+				skipped = append(skipped, collector.UID)
+				continue
+			}
+		}
+		allowed = append(allowed, collector)
+	}
+	return allowed, skipped, nil
+}
+
+// skippedCollectorReports explains why the collectors skipped by authorizeCollectors
+// weren't run, the permission they require.
+func skippedCollectorReports(uids []string) []skippedCollectorReport {
+	if len(uids) == 0 {
+		return nil
+	}
+	reports := make([]skippedCollectorReport, 0, len(uids))
+	for _, uid := range uids {
+		reports = append(reports, skippedCollectorReport{
+			UID: uid,
+			Reason: fmt.Sprintf("the collector is restricted, running it requires the %s action on the %s scope",
+				ActionCreate, ScopeCollectorsProvider.GetResourceScopeUID(uid)),
+		})
+	}
+	return reports
+}
+
 func (s *Service) get(ctx context.Context, uid string) (*supportbundles.Bundle, error) {
 	return s.store.Get(ctx, uid)
 }
 
-func (s *Service) list(ctx context.Context) ([]supportbundles.Bundle, error) {
-	return s.store.List()
+func (s *Service) list(ctx context.Context, query listQuery) ([]supportbundles.Bundle, int, error) {
+	if err := query.validate(); err != nil {
+		return nil, 0, err
+	}
+	return s.store.List(query)
+}
+
+// validateTags returns ErrInvalidTags if a tag has an empty key.
+func validateTags(tags map[string]string) error {
+	for key := range tags {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("%w: tag keys can't be empty", ErrInvalidTags)
+		}
+	}
+	return nil
+}
+
+// cancel aborts the creation of a pending bundle. The bundle is marked as
+// cancelled once its collection goroutine observes the cancellation.
+func (s *Service) cancel(ctx context.Context, uid string) error {
+	s.cancelMu.Lock()
+	cancel, ok := s.cancelFuncs[uid]
+	if ok {
+		// removing the entry guarantees the bundle is only cancelled once, and
+		// cancelling under the lock that the bundle isn't completed meanwhile
+		delete(s.cancelFuncs, uid)
+		cancel()
+	}
+	s.cancelMu.Unlock()
+
+	if !ok {
+		// either the bundle does not exist or it finished before we got to it
+		if _, err := s.store.Get(ctx, uid); err != nil {
+			return fmt.Errorf("could not retrieve support bundle with UID %s: %w", uid, err)
+		}
+		return ErrBundleNotPending
+	}
+	return nil
 }
 
 func (s *Service) remove(ctx context.Context, uid string) error {
@@ -154,7 +735,10 @@ func (s *Service) remove(ctx context.Context, uid string) error {
 }
 
 func (s *Service) cleanup(ctx context.Context) {
-	bundles, err := s.list(ctx)
+	// orphaned bundles are failed first, so that the expired ones are removed right away
+	s.failOrphanedJobs(ctx)
+
+	bundles, _, err := s.list(ctx, listQuery{})
 	if err != nil {
 		s.log.Error("failed to list bundles to clean up", "error", err)
 	}
@@ -168,6 +752,34 @@ func (s *Service) cleanup(ctx context.Context) {
 			}
 		}
 	}
+
+	if r, ok := s.store.(orphanRemover); ok {
+		if err := r.RemoveOrphans(ctx); err != nil {
+			s.log.Error("failed to remove orphaned bundles", "error", err)
+		}
+	}
+
+	if s.uploads != nil {
+		s.uploads.removeExpired()
+	}
+
+	if s.audit != nil {
+		if err := s.audit.prune(ctx); err != nil {
+			s.log.Error("failed to prune the support bundle audit log", "error", err)
+		}
+	}
+
+	if s.tokens != nil {
+		if err := s.tokens.prune(ctx, time.Now()); err != nil {
+			s.log.Error("failed to prune the used support bundle tokens", "error", err)
+		}
+	}
+}
+
+// isEncrypted returns whether bundle archives are encrypted at rest.
+func (s *Service) isEncrypted() bool {
+	_, ok := s.store.(*encryptedStore)
+	return ok
 }
 
 func (s *Service) getUsageStats(ctx context.Context) (map[string]interface{}, error) {
@@ -179,5 +791,31 @@ func (s *Service) getUsageStats(ctx context.Context) (map[string]interface{}, er
 	}
 
 	m["stats.bundles.count"] = count
+
+	// the disk footprint of the bundles currently stored, compressed
+	bundles, _, err := s.store.List(listQuery{})
+	if err != nil {
+		s.log.Warn("unable to list support bundles for usage stats", "error", err)
+		return m, nil
+	}
+	var stored, total, largest int64
+	for _, b := range bundles {
+		if b.Size <= 0 {
+			continue
+		}
+		stored++
+		total += b.Size
+		if b.Size > largest {
+			largest = b.Size
+		}
+	}
+	var average int64
+	if stored > 0 {
+		average = total / stored
+	}
+	m["stats.bundles.stored.count"] = stored
+	m["stats.bundles.stored.bytes"] = total
+	m["stats.bundles.stored.avg_bytes"] = average
+	m["stats.bundles.stored.max_bytes"] = largest
 	return m, nil
 }
@@ -0,0 +1,105 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// serviceAccountsCollector lists the service accounts and API keys. Only the
+// key metadata is read, the hashed keys never leave the database.
+func serviceAccountsCollector(sql db.DB) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "service-accounts",
+		DisplayName:       "Service accounts and API keys",
+		Description:       "Service accounts and API keys with their roles, expiry and last use, without the keys",
+		IncludedByDefault: false,
+		Default:           false,
+		Restricted:        true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type serviceAccount struct {
+				ID         int64      `xorm:"id" json:"id"`
+				OrgID      int64      `xorm:"org_id" json:"org_id"`
+				Login      string     `xorm:"login" json:"login"`
+				Name       string     `xorm:"name" json:"name"`
+				Role       string     `xorm:"role" json:"role"`
+				Disabled   bool       `xorm:"is_disabled" json:"disabled"`
+				Created    time.Time  `xorm:"created" json:"created"`
+				LastSeenAt *time.Time `xorm:"last_seen_at" json:"last_seen_at,omitempty"`
+				Tokens     int64      `xorm:"-" json:"tokens"`
+			}
+			type apiKey struct {
+				ID               int64      `xorm:"id" json:"id"`
+				OrgID            int64      `xorm:"org_id" json:"org_id"`
+				Name             string     `xorm:"name" json:"name"`
+				Role             string     `xorm:"role" json:"role"`
+				Created          time.Time  `xorm:"created" json:"created"`
+				Expires          *int64     `xorm:"expires" json:"-"`
+				ExpiresAt        *time.Time `xorm:"-" json:"expires_at,omitempty"`
+				Expired          bool       `xorm:"-" json:"expired"`
+				LastUsedAt       *time.Time `xorm:"last_used_at" json:"last_used_at,omitempty"`
+				IsRevoked        *bool      `xorm:"is_revoked" json:"-"`
+				Revoked          bool       `xorm:"-" json:"revoked"`
+				ServiceAccountID *int64     `xorm:"service_account_id" json:"service_account_id,omitempty"`
+			}
+			type inventory struct {
+				ServiceAccounts []*serviceAccount `json:"service_accounts"`
+				APIKeys         []*apiKey         `json:"api_keys"`
+			}
+
+			result := inventory{
+				ServiceAccounts: []*serviceAccount{},
+				APIKeys:         []*apiKey{},
+			}
+			dialect := sql.GetDialect()
+			err := sql.WithDbSession(ctx, func(sess *db.Session) error {
+				err := sess.SQL(`SELECT u.id, u.org_id, u.login, u.name, u.is_disabled, u.created, u.last_seen_at, ou.role
+					FROM ` + dialect.Quote("user") + ` AS u
+					LEFT JOIN org_user AS ou ON ou.user_id = u.id AND ou.org_id = u.org_id
+					WHERE u.is_service_account = ` + dialect.BooleanStr(true) + `
+					ORDER BY u.org_id, u.login`).Find(&result.ServiceAccounts)
+				if err != nil {
+					return err
+				}
+
+				// the key column holds the hashed keys, it must not be read
+				return sess.Table("api_key").
+					Cols("id", "org_id", "name", "role", "created", "expires", "last_used_at", "is_revoked", "service_account_id").
+					Asc("org_id", "name").Find(&result.APIKeys)
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			tokens := make(map[int64]int64)
+			now := time.Now()
+			for _, key := range result.APIKeys {
+				if key.Expires != nil {
+					expiresAt := time.Unix(*key.Expires, 0).UTC()
+					key.ExpiresAt = &expiresAt
+					key.Expired = expiresAt.Before(now)
+				}
+				key.Revoked = key.IsRevoked != nil && *key.IsRevoked
+				if key.ServiceAccountID != nil {
+					tokens[*key.ServiceAccountID]++
+				}
+			}
+			for _, sa := range result.ServiceAccounts {
+				sa.Tokens = tokens[sa.ID]
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "service-accounts.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
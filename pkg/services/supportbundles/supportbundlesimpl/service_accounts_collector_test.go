@@ -0,0 +1,88 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/apikey"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestServiceAccountsCollector(t *testing.T) {
+	type serviceAccount struct {
+		Login    string `json:"login"`
+		Role     string `json:"role"`
+		Disabled bool   `json:"disabled"`
+		Tokens   int64  `json:"tokens"`
+	}
+	type apiKey struct {
+		Name             string     `json:"name"`
+		Role             string     `json:"role"`
+		ExpiresAt        *time.Time `json:"expires_at"`
+		Expired          bool       `json:"expired"`
+		Revoked          bool       `json:"revoked"`
+		ServiceAccountID *int64     `json:"service_account_id"`
+	}
+	type inventory struct {
+		ServiceAccounts []serviceAccount `json:"service_accounts"`
+		APIKeys         []apiKey         `json:"api_keys"`
+	}
+
+	sqlStore := db.InitTestDB(t)
+	now := time.Now()
+	var saID int64
+	require.NoError(t, sqlStore.WithDbSession(context.Background(), func(sess *db.Session) error {
+		sa := &user.User{OrgID: 1, Login: "sa-1-ci", Name: "ci", Email: "sa-1-ci", IsServiceAccount: true, IsDisabled: true, Created: now, Updated: now}
+		if _, err := sess.Insert(sa); err != nil {
+			return err
+		}
+		saID = sa.ID
+		if _, err := sess.Insert(&org.OrgUser{OrgID: 1, UserID: sa.ID, Role: org.RoleEditor, Created: now, Updated: now}); err != nil {
+			return err
+		}
+
+		expired := now.Add(-time.Hour).Unix()
+		revoked := true
+		keys := []*apikey.APIKey{
+			{OrgID: 1, Name: "legacy", Key: plantedSecret + "-1", Role: org.RoleAdmin, Created: now, Updated: now, Expires: &expired},
+			{OrgID: 1, Name: "token", Key: plantedSecret + "-2", Role: org.RoleViewer, Created: now, Updated: now, ServiceAccountId: &sa.ID, IsRevoked: &revoked},
+		}
+		for _, key := range keys {
+			if _, err := sess.Insert(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	item, err := serviceAccountsCollector(sqlStore).Fn(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "service-accounts.json", item.Filename)
+	require.NotContains(t, string(item.FileBytes), plantedSecret)
+
+	// as written to the bundle
+	var result inventory
+	require.NoError(t, json.Unmarshal(newRedactor(defaultRedactKeys).redactSecrets(item.Filename, item.FileBytes), &result))
+	require.Equal(t, []serviceAccount{{Login: "sa-1-ci", Role: "Editor", Disabled: true, Tokens: 1}}, result.ServiceAccounts)
+
+	require.Len(t, result.APIKeys, 2)
+	legacy, token := result.APIKeys[0], result.APIKeys[1]
+	require.Equal(t, "legacy", legacy.Name)
+	require.Equal(t, "Admin", legacy.Role)
+	require.NotNil(t, legacy.ExpiresAt)
+	require.True(t, legacy.Expired)
+	require.False(t, legacy.Revoked)
+	require.Nil(t, legacy.ServiceAccountID)
+
+	require.Equal(t, "token", token.Name)
+	require.Nil(t, token.ExpiresAt)
+	require.False(t, token.Expired)
+	require.True(t, token.Revoked)
+	require.Equal(t, &saID, token.ServiceAccountID)
+}
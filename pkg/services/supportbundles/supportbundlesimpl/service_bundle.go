@@ -1,28 +1,69 @@
 package supportbundlesimpl
 
 import (
-	"archive/tar"
 	"bytes"
-	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
-	"path/filepath"
+	"os"
 	"runtime/debug"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/services/supportbundles"
 )
 
 var ErrCollectorPanicked = errors.New("collector panicked")
 
+// bundlePersistTimeout bounds the write of the outcome of a bundle, which can't
+// use the bundle context as it may be done by then.
+const bundlePersistTimeout = time.Minute
+
 type bundleResult struct {
-	tarBytes []byte
+	// archive is the temporary file the archive was written to, see remove.
+	archive  *os.File
+	size     int64
+	checksum string
+	state    supportbundles.State
 	err      error
 }
 
-func (s *Service) startBundleWork(ctx context.Context, collectors []string, uid string) {
-	result := make(chan bundleResult)
+// remove deletes the temporary archive file, if any.
+func (r bundleResult) remove(logger log.Logger) {
+	if r.archive == nil {
+		return
+	}
+	_ = r.archive.Close()
+	if err := os.Remove(r.archive.Name()); err != nil {
+		logger.Warn("Failed to remove support bundle archive file", "file", r.archive.Name(), "error", err)
+	}
+}
+
+// bundleContents are the files, collector reports and attachments of an existing
+// bundle, into which the output of the collectors is merged.
+type bundleContents struct {
+	files       map[string][]byte
+	reports     []collectorReport
+	attachments []attachmentReport
+}
+
+func (s *Service) startBundleWork(ctx context.Context, collectors []supportbundles.Collector, uid string, base *bundleContents, uploadURL string) {
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start)
+		s.metrics.bundleDuration.Observe(duration.Seconds())
+		s.recordCompletion(uid, start.Add(duration), duration)
+		s.notifyWebhook(uid)
+	}()
+
+	// buffered so the collection goroutine never blocks once the bundle is cancelled
+	result := make(chan bundleResult, 1)
 
 	go func() {
 		defer func() {
@@ -32,101 +73,540 @@ func (s *Service) startBundleWork(ctx context.Context, collectors []string, uid
 			}
 		}()
 
-		bundleBytes, err := s.bundle(ctx, collectors, uid)
-		if err != nil {
-			result <- bundleResult{err: err}
-		}
-		result <- bundleResult{tarBytes: bundleBytes}
-		close(result)
+		result <- s.writeBundle(ctx, collectors, uid, base)
 	}()
 
+	var r bundleResult
 	select {
 	case <-ctx.Done():
-		s.log.Warn("Context cancelled while collecting support bundle")
-		if err := s.store.Update(ctx, uid, supportbundles.StateTimeout, nil); err != nil {
-			s.log.Error("failed to update bundle after timeout")
+		// the collection may still be running, its archive is removed once it returns
+		go func() { (<-result).remove(s.log) }()
+	case r = <-result:
+	}
+	defer r.remove(s.log)
+
+	persistCtx, cancelPersist := context.WithTimeout(context.Background(), bundlePersistTimeout)
+	defer cancelPersist()
+
+	if err := s.stopCancellation(ctx, uid); err != nil {
+		state := supportbundles.StateTimeout
+		if errors.Is(err, context.Canceled) {
+			state = supportbundles.StateCancelled
+		}
+		s.log.Warn("Context cancelled while collecting support bundle", "uid", uid, "state", state)
+		if state == supportbundles.StateTimeout {
+			s.metrics.bundlesFailed.WithLabelValues(string(state)).Inc()
+		}
+		if err := s.store.Update(persistCtx, uid, state, nil); err != nil {
+			s.log.Error("failed to update bundle after cancellation", "uid", uid, "error", err)
+		}
+		return
+	}
+
+	if r.err != nil {
+		s.log.Error("failed to make bundle", "error", r.err, "uid", uid)
+		s.metrics.bundlesFailed.WithLabelValues(string(supportbundles.StateError)).Inc()
+		if err := s.store.Update(persistCtx, uid, supportbundles.StateError, nil); err != nil {
+			s.log.Error("failed to update bundle after error")
 		}
 		return
-	case r := <-result:
-		if r.err != nil {
-			s.log.Error("failed to make bundle", "error", r.err, "uid", uid)
-			if err := s.store.Update(ctx, uid, supportbundles.StateError, nil); err != nil {
-				s.log.Error("failed to update bundle after error")
+	}
+	if err := s.store.UpdateMetadata(persistCtx, uid, func(bundle *supportbundles.Bundle) {
+		bundle.Format = s.archiveFormat
+		bundle.Checksum = r.checksum
+	}); err != nil {
+		s.log.Warn("Failed to record support bundle format and checksum", "uid", uid, "error", err)
+	}
+	if uploadURL != "" && r.state != supportbundles.StateError {
+		s.uploadBundle(uid, r, uploadURL)
+		return
+	}
+	switch r.state {
+	case supportbundles.StateError:
+		s.log.Error("All collectors failed, support bundle is failed", "uid", uid)
+		s.metrics.bundlesFailed.WithLabelValues(string(supportbundles.StateError)).Inc()
+	case supportbundles.StatePartial:
+		s.log.Warn("Some collectors failed, support bundle is partial", "uid", uid)
+	}
+	// the archive of a failed bundle can't be downloaded, but it is kept so that its collectors can be retried
+	if err := s.store.WriteArchive(persistCtx, uid, r.state, r.archive); err != nil {
+		s.log.Error("failed to update bundle after completion")
+	}
+}
+
+// stopCancellation decides whether the bundle was collected or cancelled. It
+// returns the error of ctx if the bundle was cancelled or timed out, otherwise
+// the bundle can't be cancelled anymore, so that it's never both.
+func (s *Service) stopCancellation(ctx context.Context, uid string) error {
+	s.cancelMu.Lock()
+	defer s.cancelMu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	delete(s.cancelFuncs, uid)
+	return nil
+}
+
+// recordCompletion records when the collection of the bundle finished and how long it took.
+func (s *Service) recordCompletion(uid string, completedAt time.Time, duration time.Duration) {
+	// the bundle context may be done, e.g. when the bundle timed out
+	if err := s.store.UpdateMetadata(context.Background(), uid, func(bundle *supportbundles.Bundle) {
+		bundle.CompletedAt = completedAt.Unix()
+		bundle.Duration = duration.Milliseconds()
+	}); err != nil {
+		s.log.Warn("Failed to record support bundle completion", "uid", uid, "error", err)
+	}
+}
+
+// writeBundle collects the bundle and archives it to a temporary file, so that
+// it can be streamed to the store or the upload URL.
+func (s *Service) writeBundle(ctx context.Context, collectors []supportbundles.Collector, uid string, base *bundleContents) bundleResult {
+	archive, err := os.CreateTemp("", "grafana-support-bundle-*"+archiveExtension(s.archiveFormat))
+	if err != nil {
+		return bundleResult{err: fmt.Errorf("failed to create support bundle archive file: %w", err)}
+	}
+	r := bundleResult{archive: archive}
+	written := false
+	defer func() {
+		// also when a collector panics
+		if !written {
+			r.remove(s.log)
+		}
+	}()
+
+	hash := sha256.New()
+	state, err := s.bundle(ctx, collectors, uid, base, io.MultiWriter(archive, hash))
+	if err != nil {
+		return bundleResult{err: err}
+	}
+	if r.size, err = archive.Seek(0, io.SeekCurrent); err != nil {
+		return bundleResult{err: err}
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return bundleResult{err: err}
+	}
+
+	written = true
+	r.checksum = hex.EncodeToString(hash.Sum(nil))
+	r.state = state
+	return r
+}
+
+// bundle collects and archives the bundle to w. The returned state is StateComplete when
+// every collector succeeded, StatePartial when some failed and StateError when all did.
+// When base is set, e.g. with the attachments of the bundle, the output of the
+// collectors is merged into it. The output of the collectors is spooled to disk
+// and compressed into the archive file by file, neither is held in memory.
+func (s *Service) bundle(ctx context.Context, collectors []supportbundles.Collector, uid string, base *bundleContents, w io.Writer) (supportbundles.State, error) {
+	// removed once the partial bundle, which reads the spooled files, is untracked
+	spoolDir, err := os.MkdirTemp("", "grafana-support-bundle-")
+	if err != nil {
+		s.log.Warn("Failed to create support bundle spool directory, collecting in memory", "uid", uid, "error", err)
+		spoolDir = ""
+	} else {
+		defer func() {
+			if err := os.RemoveAll(spoolDir); err != nil {
+				s.log.Warn("Failed to remove support bundle spool directory", "uid", uid, "error", err)
+			}
+		}()
+	}
+
+	partial := s.trackPartial(uid, collectors, base)
+	defer s.untrackPartial(uid)
+
+	aw, err := s.newArchiveWriter(w)
+	if err != nil {
+		return "", err
+	}
+
+	written := map[string]bool{}
+	reports, err := s.collectTo(ctx, collectors, func(progress int, currentCollector string) {
+		s.updateProgress(ctx, uid, progress, currentCollector)
+	}, partial, spoolDir, func(f collectorFile) error {
+		written[f.name] = true
+		return addCollectorFile(aw, f)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var attachments []attachmentReport
+	if base != nil {
+		var files map[string][]byte
+		files, reports = base.merge(map[string][]byte{}, reports)
+		for name, data := range files {
+			// the output of the collectors replaces the previous one
+			if written[name] {
+				continue
+			}
+			if err := aw.add(name, int64(len(data)), bytes.NewReader(data)); err != nil {
+				return "", err
 			}
-			return
 		}
-		if err := s.store.Update(ctx, uid, supportbundles.StateComplete, r.tarBytes); err != nil {
-			s.log.Error("failed to update bundle after completion")
+		attachments = base.attachments
+	}
+
+	m := s.newManifest(uid, "", reports, attachments)
+	if b, err := s.store.Get(ctx, uid); err != nil {
+		s.log.Warn("Failed to get support bundle for manifest", "uid", uid, "error", err)
+	} else {
+		m.Creator = b.Creator
+		m.AutoAddedCollectors = b.AutoAddedCollectors
+		m.SkippedCollectors = skippedCollectorReports(b.SkippedCollectors)
+	}
+
+	manifest, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	if err := aw.add(manifestFilename, int64(len(manifest)), bytes.NewReader(manifest)); err != nil {
+		return "", err
+	}
+	if err := aw.close(); err != nil {
+		return "", err
+	}
+
+	return bundleState(reports), nil
+}
+
+// merge returns the contents of the bundle with the outcome of the collectors
+// that ran again replacing their previous one.
+func (b *bundleContents) merge(files map[string][]byte, reports []collectorReport) (map[string][]byte, []collectorReport) {
+	retried := make(map[string]bool, len(reports))
+	for _, report := range reports {
+		retried[report.UID] = true
+	}
+
+	merged := make(map[string][]byte, len(b.files)+len(files))
+	for name, data := range b.files {
+		merged[name] = data
+	}
+
+	mergedReports := make([]collectorReport, 0, len(b.reports)+len(reports))
+	for _, report := range b.reports {
+		if retried[report.UID] {
+			for _, name := range report.filenames() {
+				delete(merged, name)
+			}
+			continue
 		}
-		return
+		mergedReports = append(mergedReports, report)
+	}
+	mergedReports = append(mergedReports, reports...)
+	sort.Slice(mergedReports, func(i, j int) bool {
+		return mergedReports[i].UID < mergedReports[j].UID
+	})
+
+	for name, data := range files {
+		merged[name] = data
 	}
+	return merged, mergedReports
 }
 
-func (s *Service) bundle(ctx context.Context, collectors []string, uid string) ([]byte, error) {
+// selectCollectors returns the requested and included by default collectors that
+// aren't disabled, sorted by UID.
+func (s *Service) selectCollectors(collectors []string) []supportbundles.Collector {
 	lookup := make(map[string]bool, len(collectors))
 	for _, c := range collectors {
 		lookup[c] = true
 	}
 
-	files := map[string][]byte{}
-
+	selected := make([]supportbundles.Collector, 0, len(s.bundleRegistry.Collectors()))
 	for _, collector := range s.bundleRegistry.Collectors() {
 		if !lookup[collector.UID] && !collector.IncludedByDefault {
 			continue
 		}
-		item, err := collector.Fn(ctx)
-		if err != nil {
-			s.log.Warn("Failed to collect support bundle item", "error", err)
+		// collectors registered by other services can't be prevented from registering
+		if s.isCollectorDisabled(collector.UID) {
+			continue
+		}
+		selected = append(selected, collector)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].UID < selected[j].UID
+	})
+	return selected
+}
+
+// collect runs the selected collectors and returns the redacted files to add
+// to the bundle along with the outcome of each collector. See collectTo.
+func (s *Service) collect(ctx context.Context, selected []supportbundles.Collector, onProgress func(progress int, currentCollector string), partial *partialBundle) (map[string][]byte, []collectorReport) {
+	files := map[string][]byte{}
+	// the output is collected in memory, reading it back can't fail
+	reports, _ := s.collectTo(ctx, selected, onProgress, partial, "", func(f collectorFile) error {
+		data, err := f.read()
+		files[f.name] = data
+		return err
+	})
+	return files, reports
+}
+
+// collectTo runs the selected collectors and calls add with the redacted files
+// to add to the bundle, then returns the outcome of each collector. Up to
+// collectorWorkers collectors run concurrently, spooling their output to
+// spoolDir, or memory if empty. Their outcomes are then added in the order of
+// selected so that the bundle doesn't depend on which finished first. onProgress
+// is called before each collector runs and once all of them are done. The output
+// of each collector is added to partial, if set, as soon as it's done.
+func (s *Service) collectTo(ctx context.Context, selected []supportbundles.Collector, onProgress func(progress int, currentCollector string), partial *partialBundle, spoolDir string, add func(f collectorFile) error) ([]collectorReport, error) {
+	runs := s.runCollectors(ctx, selected, onProgress, partial, spoolDir)
+
+	reports := make([]collectorReport, 0, len(selected))
+	var total int64
+
+	for i, collector := range selected {
+		if s.maxSize > 0 && total >= s.maxSize {
+			s.log.Warn("Support bundle size limit reached, skipping collector", "collector", collector.UID, "maxSize", s.maxSize)
+			reports = append(reports, collectorReport{
+				UID:       collector.UID,
+				Error:     fmt.Sprintf("collector %s skipped, the bundle size limit of %d bytes was reached", collector.UID, s.maxSize),
+				Truncated: true,
+			})
+			continue
 		}
 
-		// write item to file
-		if item != nil {
-			files[item.Filename] = item.FileBytes
+		limit := s.outputLimit(total)
+		report, output := s.collectorOutput(collector, runs[i], limit)
+		switch err := runs[i].err; {
+		case errors.Is(err, context.DeadlineExceeded):
+			s.log.Warn("Support bundle collector timed out", "collector", collector.UID)
+		case err != nil:
+			s.log.Warn("Failed to collect support bundle item", "collector", collector.UID, "error", err)
+		case report.Truncated:
+			s.log.Warn("Support bundle collector output exceeds the size limit, truncating", "collector", collector.UID, "size", runs[i].size(), "limit", limit)
 		}
-	}
 
-	// create tar.gz file
-	var buf bytes.Buffer
-	errCompress := compress(files, &buf)
-	if errCompress != nil {
-		return nil, errCompress
+		for _, f := range output {
+			if err := add(f); err != nil {
+				return nil, err
+			}
+		}
+		total += int64(report.Size)
+		reports = append(reports, report)
 	}
 
-	return buf.Bytes(), nil
+	onProgress(100, "")
+
+	return reports, nil
 }
 
-func compress(files map[string][]byte, buf io.Writer) error {
-	// tar > gzip > buf
-	zr := gzip.NewWriter(buf)
-	tw := tar.NewWriter(zr)
+// collectorOutput returns the report of a collector run along with the redacted
+// files to add to the bundle. Unless limit is negative, the files are cut to
+// limit bytes in total, the ones past the limit are left out. There are no files
+// when the collector returned no item.
+func (s *Service) collectorOutput(collector supportbundles.Collector, run collectorRun, limit int64) (collectorReport, []collectorFile) {
+	report := collectorReport{
+		UID:        collector.UID,
+		Success:    run.err == nil,
+		DurationMs: run.duration.Milliseconds(),
+		Truncated:  run.err == nil && run.truncated,
+	}
 
-	for name, data := range files {
-		header := &tar.Header{
-			Name:    name,
-			ModTime: time.Now(),
-			Mode:    int64(0o644),
-			Size:    int64(len(data)),
+	if run.err != nil {
+		if errors.Is(run.err, context.DeadlineExceeded) {
+			report.Error = fmt.Sprintf("collector %s timed out after %s", collector.UID, s.collectorTimeout(collector.UID))
+		} else {
+			report.Error = fmt.Sprintf("collector %s failed: %s", collector.UID, run.err)
 		}
 
-		header.Name = filepath.ToSlash("/bundle/" + header.Name)
-		// write header
-		if err := tw.WriteHeader(header); err != nil {
-			return err
+		// the error is written to the bundle so that it's clear the item is missing
+		report.Filename = collector.UID + ".error.txt"
+		report.Error = s.redactor.redactText(report.Error)
+		data := []byte(report.Error + "\n")
+		report.Size = len(data)
+		return report, []collectorFile{{name: report.Filename, data: data, size: int64(len(data))}}
+	}
+
+	files := make([]collectorFile, 0, len(run.files))
+	cut := false
+	for _, file := range run.files {
+		if limit >= 0 && int64(report.Size)+file.size > limit {
+			file.size = limit - int64(report.Size)
+			cut = true
+		}
+		files = append(files, file)
+		report.Size += int(file.size)
+		if report.Filename == "" {
+			report.Filename = file.name
+		}
+		// single file collectors are only listed in Filename, as they always were
+		if len(run.files) > 1 {
+			report.Files = append(report.Files, file.name)
 		}
+		if cut {
+			report.Truncated = true
+			break
+		}
+	}
+	return report, files
+}
+
+// collectorRun is the outcome of running a single collector.
+type collectorRun struct {
+	// files are the spooled output of the collector, cut to the size limit of a single collector
+	files     []collectorFile
+	truncated bool
+	err       error
+	duration  time.Duration
+}
+
+// size returns the size of the spooled output of the run.
+func (r collectorRun) size() int64 {
+	var size int64
+	for _, f := range r.files {
+		size += f.size
+	}
+	return size
+}
+
+// runCollectors runs the selected collectors on a pool of collectorWorkers
+// workers and returns their outcomes in the order of selected. Their output is
+// spooled to spoolDir, or memory if empty, cut to the size limit of a single collector.
+func (s *Service) runCollectors(ctx context.Context, selected []supportbundles.Collector, onProgress func(progress int, currentCollector string), partial *partialBundle, spoolDir string) []collectorRun {
+	runs := make([]collectorRun, len(selected))
+
+	workers := s.collectorWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(selected) {
+		workers = len(selected)
+	}
+
+	// mu serializes the progress updates
+	var mu sync.Mutex
+	done := 0
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				collector := selected[i]
+				mu.Lock()
+				onProgress(done*100/len(selected), collector.UID)
+				mu.Unlock()
+
+				start := time.Now()
+				sp := newSpool(spoolDir, s.redactor, s.outputLimit(0))
+				err := s.runCollector(ctx, collector, sp)
+				files, truncated, closeErr := sp.close()
+				if err == nil {
+					err = closeErr
+				}
+				duration := time.Since(start)
+				s.metrics.collectorDuration.WithLabelValues(collector.UID).Observe(duration.Seconds())
+				s.recordDuration(collector.UID, duration)
+				runs[i] = collectorRun{files: files, truncated: truncated, err: err, duration: duration}
+				if partial != nil {
+					partial.add(s.collectorOutput(collector, runs[i], s.outputLimit(0)))
+				}
 
-		if _, err := io.Copy(tw, bytes.NewReader(data)); err != nil {
-			return err
+				mu.Lock()
+				done++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range selected {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return runs
+}
+
+// outputLimit returns how many bytes the next collector may add to the bundle
+// given the total written so far, or -1 if there is no limit.
+func (s *Service) outputLimit(total int64) int64 {
+	limit := int64(-1)
+	if s.collectorMaxSize > 0 {
+		limit = s.collectorMaxSize
+	}
+	if s.maxSize > 0 && (limit < 0 || s.maxSize-total < limit) {
+		limit = s.maxSize - total
+	}
+	return limit
+}
+
+// bundleState returns the state of a bundle given the outcome of its collectors.
+// Bundles with truncated output are partial.
+func bundleState(reports []collectorReport) supportbundles.State {
+	failed, truncated := 0, false
+	for _, report := range reports {
+		if !report.Success {
+			failed++
 		}
+		truncated = truncated || report.Truncated
 	}
 
-	// produce tar
-	if err := tw.Close(); err != nil {
-		return err
+	switch {
+	case failed == 0 && !truncated:
+		return supportbundles.StateComplete
+	case failed < len(reports):
+		return supportbundles.StatePartial
+	default:
+		return supportbundles.StateError
 	}
-	// produce gzip
-	if err := zr.Close(); err != nil {
+}
+
+// runCollector runs a single collector bounded by its own timeout, so that a
+// hung collector does not prevent the remaining collectors from running. The
+// output of the collector is written to sp, which is closed if it times out.
+func (s *Service) runCollector(ctx context.Context, collector supportbundles.Collector, sp *spool) error {
+	ctx, cancel := context.WithTimeout(ctx, s.collectorTimeout(collector.UID))
+	defer cancel()
+	ctx = supportbundles.WithParams(ctx, collectorParamsFromContext(ctx)[collector.UID])
+
+	result := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				s.log.Error("support bundle collector panic", "collector", collector.UID, "err", err, "stack", string(debug.Stack()))
+				result <- ErrCollectorPanicked
+			}
+		}()
+
+		if collector.StreamFn != nil {
+			result <- collector.StreamFn(ctx, sp)
+			return
+		}
+		item, err := collector.Fn(ctx)
+		if err == nil && item != nil {
+			err = sp.writeItem(item)
+		}
+		result <- err
+	}()
+
+	select {
+	case <-ctx.Done():
+		// a hung collector can't write to the bundle anymore
+		_, _, _ = sp.close()
+		return ctx.Err()
+	case err := <-result:
+		// output past the limit is left out, the spool records the truncation
+		if errors.Is(err, supportbundles.ErrOutputLimitReached) {
+			return nil
+		}
 		return err
 	}
-	//
-	return nil
+}
+
+func (s *Service) updateProgress(ctx context.Context, uid string, progress int, currentCollector string) {
+	if err := s.store.UpdateProgress(ctx, uid, progress, currentCollector); err != nil {
+		s.log.Warn("Failed to update support bundle progress", "uid", uid, "error", err)
+	}
+}
+
+func (s *Service) collectorTimeout(uid string) time.Duration {
+	if timeout, ok := s.collectorTimeouts[uid]; ok {
+		return timeout
+	}
+	return s.defaultCollectorTimeout
 }
@@ -0,0 +1,560 @@
+package supportbundlesimpl
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/supportbundles/bundleregistry"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func newTestService(t *testing.T, collectors ...supportbundles.Collector) *Service {
+	t.Helper()
+
+	registry := bundleregistry.ProvideService()
+	for _, c := range collectors {
+		registry.RegisterSupportItemCollector(c)
+	}
+
+	return &Service{
+		cfg:                     setting.NewCfg(),
+		store:                   newStore(kvstore.ProvideService(db.InitTestDB(t)), defaultBundleExpiration),
+		bundleRegistry:          registry,
+		log:                     log.NewNopLogger(),
+		defaultCollectorTimeout: time.Second,
+		collectorTimeouts:       map[string]time.Duration{},
+		cancelFuncs:             map[string]context.CancelFunc{},
+		partials:                map[string]*partialBundle{},
+		redactor:                newRedactor(defaultRedactKeys),
+		archiveFormat:           formatTarGz,
+		compressionLevel:        gzip.DefaultCompression,
+		creationSlots:           make(chan struct{}, 1),
+		metrics:                 newMetrics(prometheus.NewRegistry()),
+	}
+}
+
+func newTestCollector(uid string, fn supportbundles.CollectorFunc) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               uid,
+		DisplayName:       uid,
+		IncludedByDefault: true,
+		Fn:                fn,
+	}
+}
+
+// readBundle returns the files of a tar.gz bundle keyed by name.
+// bundleArchive runs s.bundle and returns the archive.
+func bundleArchive(ctx context.Context, s *Service, collectors []supportbundles.Collector, uid string, base *bundleContents) ([]byte, supportbundles.State, error) {
+	var buf bytes.Buffer
+	state, err := s.bundle(ctx, collectors, uid, base, &buf)
+	return buf.Bytes(), state, err
+}
+
+func readBundle(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(zr)
+
+	files := map[string][]byte{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = content
+	}
+	return files
+}
+
+func TestService_bundle_CollectorTimeout(t *testing.T) {
+	hung := newTestCollector("hung", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		// ignores the context on purpose
+		time.Sleep(time.Second)
+		return &supportbundles.SupportItem{Filename: "hung.txt", FileBytes: []byte("late")}, nil
+	})
+	fast := newTestCollector("fast", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "fast.txt", FileBytes: []byte("ok")}, nil
+	})
+
+	s := newTestService(t, hung, fast)
+	s.collectorTimeouts["hung"] = 10 * time.Millisecond
+
+	bundle, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+
+	data, state, err := bundleArchive(context.Background(), s, s.selectCollectors(nil), bundle.UID, nil)
+	require.NoError(t, err)
+	require.Equal(t, supportbundles.StatePartial, state)
+
+	files := readBundle(t, data)
+	require.Equal(t, []byte("ok"), files["/bundle/fast.txt"])
+	require.NotContains(t, files, "/bundle/hung.txt")
+	require.Contains(t, string(files["/bundle/hung.error.txt"]), "timed out")
+}
+
+func TestService_bundle_Progress(t *testing.T) {
+	var s *Service
+	var uid string
+	progress := []int{}
+
+	collector := func(name string) supportbundles.Collector {
+		return newTestCollector(name, func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			b, err := s.store.Get(ctx, uid)
+			require.NoError(t, err)
+			require.Equal(t, name, b.CurrentCollector)
+			progress = append(progress, b.Progress)
+			return &supportbundles.SupportItem{Filename: name + ".txt", FileBytes: []byte(name)}, nil
+		})
+	}
+
+	s = newTestService(t, collector("a"), collector("b"))
+	bundle, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+	uid = bundle.UID
+
+	_, state, err := bundleArchive(context.Background(), s, s.selectCollectors(nil), uid, nil)
+	require.NoError(t, err)
+	require.Equal(t, supportbundles.StateComplete, state)
+	require.Equal(t, []int{0, 50}, progress)
+
+	b, err := s.store.Get(context.Background(), uid)
+	require.NoError(t, err)
+	require.Equal(t, 100, b.Progress)
+	require.Empty(t, b.CurrentCollector)
+}
+
+func TestService_startBundleWork_Completion(t *testing.T) {
+	s := newTestService(t, newTestCollector("slow", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		time.Sleep(20 * time.Millisecond)
+		return &supportbundles.SupportItem{Filename: "slow.txt", FileBytes: []byte("slow")}, nil
+	}))
+	bundle, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+	require.Zero(t, bundle.CompletedAt)
+
+	s.startBundleWork(context.Background(), s.selectCollectors(nil), bundle.UID, nil, "")
+
+	b, err := s.get(context.Background(), bundle.UID)
+	require.NoError(t, err)
+	require.Equal(t, supportbundles.StateComplete, b.State)
+	require.GreaterOrEqual(t, b.CompletedAt, b.CreatedAt)
+	require.GreaterOrEqual(t, b.Duration, int64(20))
+
+	t.Run("is recorded for failed bundles", func(t *testing.T) {
+		s := newTestService(t, newTestCollector("failing", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			return nil, errors.New("failed")
+		}))
+		bundle, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+		require.NoError(t, err)
+
+		s.startBundleWork(context.Background(), s.selectCollectors(nil), bundle.UID, nil, "")
+
+		b, err := s.get(context.Background(), bundle.UID)
+		require.NoError(t, err)
+		require.Equal(t, supportbundles.StateError, b.State)
+		require.NotZero(t, b.CompletedAt)
+	})
+}
+
+func TestService_startBundleWork_CancelledOnceCollected(t *testing.T) {
+	var s *Service
+	var uid string
+	s = newTestService(t, newTestCollector("ok", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		// cancelled once the collectors are done, before the outcome is written
+		require.NoError(t, s.cancel(context.Background(), uid))
+		return &supportbundles.SupportItem{Filename: "ok.txt", FileBytes: []byte("ok")}, nil
+	}))
+	bundle, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+	uid = bundle.UID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.True(t, s.trackPending(uid, cancel))
+	s.startBundleWork(ctx, s.selectCollectors(nil), uid, nil, "")
+
+	// the cancellation is persisted although the bundle context is done
+	b, err := s.store.Get(context.Background(), uid)
+	require.NoError(t, err)
+	require.Equal(t, supportbundles.StateCancelled, b.State)
+
+	t.Run("can't be cancelled once completed", func(t *testing.T) {
+		s := newTestService(t, newTestCollector("ok", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			return &supportbundles.SupportItem{Filename: "ok.txt", FileBytes: []byte("ok")}, nil
+		}))
+		bundle, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.True(t, s.trackPending(bundle.UID, cancel))
+		s.startBundleWork(ctx, s.selectCollectors(nil), bundle.UID, nil, "")
+
+		require.ErrorIs(t, s.cancel(context.Background(), bundle.UID), ErrBundleNotPending)
+		b, err := s.store.Get(context.Background(), bundle.UID)
+		require.NoError(t, err)
+		require.Equal(t, supportbundles.StateComplete, b.State)
+	})
+}
+
+func TestService_bundle_Manifest(t *testing.T) {
+	ok := newTestCollector("ok", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "ok.txt", FileBytes: []byte("hello")}, nil
+	})
+	failing := newTestCollector("failing", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return nil, errors.New("database is down")
+	})
+
+	s := newTestService(t, ok, failing)
+	bundle, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+
+	data, state, err := bundleArchive(context.Background(), s, s.selectCollectors(nil), bundle.UID, nil)
+	require.NoError(t, err)
+	require.Equal(t, supportbundles.StatePartial, state)
+
+	files := readBundle(t, data)
+	require.Contains(t, files, "/bundle/manifest.json")
+	require.Equal(t, "collector failing failed: database is down\n", string(files["/bundle/failing.error.txt"]))
+
+	var m manifest
+	require.NoError(t, json.Unmarshal(files["/bundle/manifest.json"], &m))
+	require.Equal(t, bundle.UID, m.BundleUID)
+	require.Equal(t, "admin", m.Creator)
+	require.Len(t, m.Collectors, 2)
+
+	require.Equal(t, "failing", m.Collectors[0].UID)
+	require.False(t, m.Collectors[0].Success)
+	require.Equal(t, "collector failing failed: database is down", m.Collectors[0].Error)
+	require.Equal(t, "failing.error.txt", m.Collectors[0].Filename)
+
+	require.Equal(t, "ok", m.Collectors[1].UID)
+	require.True(t, m.Collectors[1].Success)
+	require.Equal(t, "ok.txt", m.Collectors[1].Filename)
+	require.Equal(t, 5, m.Collectors[1].Size)
+}
+
+func TestService_bundle_MultipleFiles(t *testing.T) {
+	alerting := newTestCollector("alerting", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Files: []supportbundles.SupportFile{
+			{Filename: "alerting/rules.json", FileBytes: []byte(`{"rules":[]}`)},
+			{Filename: "alerting/eval-state.json", FileBytes: []byte(`{"password":"` + plantedSecret + `"}`)},
+		}}, nil
+	})
+	truncated := newTestCollector("truncated", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{
+			Filename:  "first.txt",
+			FileBytes: bytes.Repeat([]byte("x"), 60),
+			Files: []supportbundles.SupportFile{
+				{Filename: "second.txt", FileBytes: bytes.Repeat([]byte("x"), 60)},
+				{Filename: "third.txt", FileBytes: bytes.Repeat([]byte("x"), 60)},
+			},
+		}, nil
+	})
+
+	s := newTestService(t, alerting, truncated)
+	s.collectorMaxSize = 100
+	bundle, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+
+	data, state, err := bundleArchive(context.Background(), s, s.selectCollectors(nil), bundle.UID, nil)
+	require.NoError(t, err)
+	require.Equal(t, supportbundles.StatePartial, state)
+
+	files := readBundle(t, data)
+	require.Equal(t, `{"rules":[]}`, string(files["/bundle/alerting/rules.json"]))
+	require.Contains(t, files, "/bundle/alerting/eval-state.json")
+	require.NotContains(t, string(files["/bundle/alerting/eval-state.json"]), plantedSecret)
+	require.Len(t, files["/bundle/first.txt"], 60)
+	require.Len(t, files["/bundle/second.txt"], 40)
+	require.NotContains(t, files, "/bundle/third.txt")
+
+	var m manifest
+	require.NoError(t, json.Unmarshal(files["/bundle/manifest.json"], &m))
+	require.Len(t, m.Collectors, 2)
+	require.Equal(t, "alerting/rules.json", m.Collectors[0].Filename)
+	require.Equal(t, []string{"alerting/rules.json", "alerting/eval-state.json"}, m.Collectors[0].Files)
+	require.Equal(t, len(files["/bundle/alerting/rules.json"])+len(files["/bundle/alerting/eval-state.json"]), m.Collectors[0].Size)
+	require.False(t, m.Collectors[0].Truncated)
+
+	require.Equal(t, []string{"first.txt", "second.txt"}, m.Collectors[1].Files)
+	require.Equal(t, 100, m.Collectors[1].Size)
+	require.True(t, m.Collectors[1].Truncated)
+
+	t.Run("retried collectors replace all their files", func(t *testing.T) {
+		base := &bundleContents{
+			files: map[string][]byte{
+				"alerting/rules.json":      []byte("old"),
+				"alerting/eval-state.json": []byte("old"),
+				"attachments/notes.txt":    []byte("notes"),
+			},
+			reports: []collectorReport{{UID: "alerting", Filename: "alerting/rules.json", Files: []string{"alerting/rules.json", "alerting/eval-state.json"}}},
+		}
+		merged, _ := base.merge(map[string][]byte{"alerting.error.txt": []byte("failed")}, []collectorReport{{UID: "alerting", Filename: "alerting.error.txt"}})
+		require.Equal(t, map[string][]byte{
+			"alerting.error.txt":    []byte("failed"),
+			"attachments/notes.txt": []byte("notes"),
+		}, merged)
+	})
+}
+
+func TestService_bundle_SizeLimits(t *testing.T) {
+	emit := func(filename string, size int) supportbundles.CollectorFunc {
+		return func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			return &supportbundles.SupportItem{Filename: filename, FileBytes: bytes.Repeat([]byte("x"), size)}, nil
+		}
+	}
+
+	s := newTestService(t,
+		newTestCollector("a-huge", emit("huge.txt", 100)),
+		newTestCollector("b-large", emit("large.txt", 50)),
+		newTestCollector("c-small", emit("small.txt", 10)),
+	)
+	s.collectorMaxSize = 60
+	s.maxSize = 100
+
+	bundle, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+
+	data, state, err := bundleArchive(context.Background(), s, s.selectCollectors(nil), bundle.UID, nil)
+	require.NoError(t, err)
+	require.Equal(t, supportbundles.StatePartial, state)
+
+	files := readBundle(t, data)
+	// capped by the per collector limit
+	require.Len(t, files["/bundle/huge.txt"], 60)
+	// capped by what is left of the bundle budget
+	require.Len(t, files["/bundle/large.txt"], 40)
+	require.NotContains(t, files, "/bundle/small.txt")
+
+	var m manifest
+	require.NoError(t, json.Unmarshal(files["/bundle/manifest.json"], &m))
+	require.True(t, m.Truncated)
+	require.Len(t, m.Collectors, 3)
+
+	require.True(t, m.Collectors[0].Success)
+	require.True(t, m.Collectors[0].Truncated)
+	require.Equal(t, 60, m.Collectors[0].Size)
+
+	require.True(t, m.Collectors[1].Success)
+	require.True(t, m.Collectors[1].Truncated)
+
+	require.False(t, m.Collectors[2].Success)
+	require.True(t, m.Collectors[2].Truncated)
+	require.Contains(t, m.Collectors[2].Error, "size limit")
+}
+
+func TestService_bundle_StreamingCollector(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes hundreds of MB")
+	}
+
+	const size = 256 << 20
+	// random chunks repeated further apart than the deflate window don't compress,
+	// the archive is as large as the output
+	chunk := make([]byte, 64<<10)
+	_, err := rand.Read(chunk)
+	require.NoError(t, err)
+
+	var stopped error
+	synthetic := supportbundles.Collector{
+		UID:               "synthetic",
+		IncludedByDefault: true,
+		StreamFn: func(ctx context.Context, fw supportbundles.FileWriter) error {
+			w, err := fw.Create("synthetic.log")
+			if err != nil {
+				return err
+			}
+			for written := 0; written < size; written += len(chunk) {
+				if _, err := w.Write(chunk); err != nil {
+					stopped = err
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	// peakHeap runs fn and returns how much the heap grew at most meanwhile
+	peakHeap := func(fn func()) uint64 {
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		baseline := stats.HeapInuse
+
+		var peak uint64
+		done := make(chan struct{})
+		sampled := make(chan struct{})
+		go func() {
+			defer close(sampled)
+			ticker := time.NewTicker(10 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				var stats runtime.MemStats
+				runtime.ReadMemStats(&stats)
+				if stats.HeapInuse > baseline && stats.HeapInuse-baseline > peak {
+					peak = stats.HeapInuse - baseline
+				}
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+		fn()
+		close(done)
+		<-sampled
+		return peak
+	}
+
+	s := newTestService(t, synthetic)
+	// compressing incompressible data is slow, especially with the race detector
+	s.defaultCollectorTimeout = 10 * time.Minute
+	s.compressionLevel = gzip.BestSpeed
+
+	t.Run("streams the output into the stored archive", func(t *testing.T) {
+		ctx := context.Background()
+		store, err := newFileStore(kvstore.ProvideService(db.InitTestDB(t)), defaultBundleExpiration, t.TempDir())
+		require.NoError(t, err)
+		s.store = store
+		bundle, err := s.store.Create(ctx, &user.SignedInUser{Login: "admin"}, 0)
+		require.NoError(t, err)
+
+		var r bundleResult
+		peak := peakHeap(func() {
+			r = s.writeBundle(ctx, s.selectCollectors(nil), bundle.UID, nil)
+			require.NoError(t, r.err)
+			defer r.remove(s.log)
+			require.NoError(t, s.store.WriteArchive(ctx, bundle.UID, r.state, r.archive))
+		})
+		require.Equal(t, supportbundles.StateComplete, r.state)
+		require.GreaterOrEqual(t, r.size, int64(size))
+		// holding the archive or the output in memory would grow the heap by at least size
+		require.Less(t, peak, uint64(size/8), "the heap grew by %d bytes", peak)
+
+		reader, stored, err := s.store.GetReader(ctx, bundle.UID)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, reader.Close()) }()
+		require.Equal(t, r.size, stored)
+
+		var archived int64
+		require.NoError(t, walkArchive(formatTarGz, reader, stored, func(name string, r io.Reader, _ int64) error {
+			if name == "synthetic.log" {
+				n, err := io.Copy(io.Discard, r)
+				archived = n
+				return err
+			}
+			return nil
+		}))
+		require.GreaterOrEqual(t, archived, int64(size))
+	})
+
+	t.Run("enforces the size limit on the stream", func(t *testing.T) {
+		s.collectorMaxSize = 8 << 20
+
+		data, state, err := bundleArchive(context.Background(), s, s.selectCollectors(nil), "capped", nil)
+		require.NoError(t, err)
+		require.Equal(t, supportbundles.StatePartial, state)
+		require.ErrorIs(t, stopped, supportbundles.ErrOutputLimitReached)
+
+		files := readBundle(t, data)
+		require.Len(t, files["/bundle/synthetic.log"], 8<<20)
+		var m manifest
+		require.NoError(t, json.Unmarshal(files["/bundle/manifest.json"], &m))
+		require.True(t, m.Collectors[0].Success)
+		require.True(t, m.Collectors[0].Truncated)
+	})
+}
+
+func TestService_bundle_Workers(t *testing.T) {
+	const delay = 200 * time.Millisecond
+
+	var running, maxRunning int32
+	slow := func(name string) supportbundles.Collector {
+		return newTestCollector(name, func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+
+			time.Sleep(delay)
+			return &supportbundles.SupportItem{Filename: name + ".txt", FileBytes: []byte(name)}, nil
+		})
+	}
+
+	// registered in reverse order, the bundle must not depend on it
+	s := newTestService(t, slow("d"), slow("c"), slow("b"), slow("a"))
+	s.collectorWorkers = 4
+	bundle, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+
+	start := time.Now()
+	data, state, err := bundleArchive(context.Background(), s, s.selectCollectors(nil), bundle.UID, nil)
+	elapsed := time.Since(start)
+	require.NoError(t, err)
+	require.Equal(t, supportbundles.StateComplete, state)
+
+	// bounded by the slowest collector, not the sum of all of them
+	require.Less(t, elapsed, 2*delay)
+	require.Equal(t, int32(4), atomic.LoadInt32(&maxRunning))
+
+	files := readBundle(t, data)
+	var m manifest
+	require.NoError(t, json.Unmarshal(files["/bundle/manifest.json"], &m))
+	uids := make([]string, 0, len(m.Collectors))
+	for _, report := range m.Collectors {
+		require.True(t, report.Success, report.UID)
+		uids = append(uids, report.UID)
+	}
+	require.Equal(t, []string{"a", "b", "c", "d"}, uids)
+
+	t.Run("no more collectors than workers run at once", func(t *testing.T) {
+		atomic.StoreInt32(&maxRunning, 0)
+		s.collectorWorkers = 2
+
+		_, state, err := bundleArchive(context.Background(), s, s.selectCollectors(nil), bundle.UID, nil)
+		require.NoError(t, err)
+		require.Equal(t, supportbundles.StateComplete, state)
+		require.Equal(t, int32(2), atomic.LoadInt32(&maxRunning))
+	})
+}
+
+func TestBundleState(t *testing.T) {
+	ok := collectorReport{UID: "ok", Success: true}
+	failed := collectorReport{UID: "failed", Success: false}
+	truncated := collectorReport{UID: "truncated", Success: true, Truncated: true}
+
+	require.Equal(t, supportbundles.StateComplete, bundleState(nil))
+	require.Equal(t, supportbundles.StateComplete, bundleState([]collectorReport{ok, ok}))
+	require.Equal(t, supportbundles.StatePartial, bundleState([]collectorReport{ok, failed}))
+	require.Equal(t, supportbundles.StateError, bundleState([]collectorReport{failed, failed}))
+	require.Equal(t, supportbundles.StatePartial, bundleState([]collectorReport{ok, truncated}))
+}
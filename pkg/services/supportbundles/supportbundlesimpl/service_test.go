@@ -0,0 +1,514 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/acimpl"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestService_cancel(t *testing.T) {
+	started := make(chan struct{})
+	blocking := newTestCollector("blocking", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	s := newTestService(t, blocking)
+	s.defaultCollectorTimeout = time.Minute
+
+	bundle, err := s.create(context.Background(), &user.SignedInUser{Login: "admin"}, createOptions{})
+	require.NoError(t, err)
+	<-started
+
+	require.NoError(t, s.cancel(context.Background(), bundle.UID))
+
+	require.Eventually(t, func() bool {
+		b, err := s.get(context.Background(), bundle.UID)
+		require.NoError(t, err)
+		return b.State == supportbundles.StateCancelled
+	}, 5*time.Second, 10*time.Millisecond)
+
+	t.Run("cancelling a finished bundle fails", func(t *testing.T) {
+		require.ErrorIs(t, s.cancel(context.Background(), bundle.UID), ErrBundleNotPending)
+	})
+
+	t.Run("cancelling an unknown bundle fails", func(t *testing.T) {
+		err := s.cancel(context.Background(), "unknown")
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrBundleNotPending)
+	})
+}
+
+func TestService_validateCollectors(t *testing.T) {
+	s := newTestService(t, newTestCollector("a", nil), newTestCollector("b", nil))
+
+	require.NoError(t, s.validateCollectors([]string{"a", "b"}))
+
+	err := s.validateCollectors([]string{"a", "c", "d"})
+	require.ErrorIs(t, err, ErrUnknownCollector)
+	require.Contains(t, err.Error(), "c, d")
+}
+
+func TestService_create_ConcurrencyLimit(t *testing.T) {
+	const limit = 2
+
+	release := make(chan struct{})
+	blocking := newTestCollector("blocking", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		<-release
+		return nil, nil
+	})
+
+	s := newTestService(t, blocking)
+	s.defaultCollectorTimeout = time.Minute
+	s.creationSlots = make(chan struct{}, limit)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, limit+1)
+	for i := 0; i < limit+1; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.create(context.Background(), &user.SignedInUser{Login: "admin"}, createOptions{})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	rejected := 0
+	for err := range errs {
+		if err != nil {
+			require.ErrorIs(t, err, ErrTooManyBundles)
+			rejected++
+		}
+	}
+	require.Equal(t, 1, rejected)
+
+	t.Run("slots are released once bundles finish, even after a panic", func(t *testing.T) {
+		close(release)
+		require.Eventually(t, func() bool { return len(s.creationSlots) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+		s.bundleRegistry.RegisterSupportItemCollector(newTestCollector("blocking", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			panic("boom")
+		}))
+		_, err := s.create(context.Background(), &user.SignedInUser{Login: "admin"}, createOptions{})
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return len(s.creationSlots) == 0 }, 5*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestService_create_Metrics(t *testing.T) {
+	ok := newTestCollector("ok", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "ok.txt", FileBytes: []byte("ok")}, nil
+	})
+
+	s := newTestService(t, ok)
+	bundle, err := s.create(context.Background(), &user.SignedInUser{Login: "admin"}, createOptions{})
+	require.NoError(t, err)
+	require.Equal(t, float64(1), testutil.ToFloat64(s.metrics.bundlesCreated))
+
+	require.Eventually(t, func() bool {
+		b, err := s.store.Get(context.Background(), bundle.UID)
+		return err == nil && b.State == supportbundles.StateComplete
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(s.metrics.bundlesPending) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Equal(t, 1, testutil.CollectAndCount(s.metrics.collectorDuration))
+	require.Equal(t, 1, testutil.CollectAndCount(s.metrics.bundleDuration))
+	require.Equal(t, 0, testutil.CollectAndCount(s.metrics.bundlesFailed))
+}
+
+func TestService_create_RestrictedCollectors(t *testing.T) {
+	item := func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "item.txt", FileBytes: []byte("item")}, nil
+	}
+	restricted := newTestCollector("restricted", item)
+	restricted.Restricted = true
+
+	s := newTestService(t, newTestCollector("basic", item), restricted)
+	s.accessControl = acimpl.ProvideAccessControl(setting.NewCfg())
+
+	testCases := []struct {
+		desc        string
+		permissions map[string][]string
+		skipped     []string
+	}{
+		{
+			desc:        "unscoped create permission skips restricted collectors",
+			permissions: map[string][]string{ActionCreate: {}},
+			skipped:     []string{"restricted"},
+		},
+		{
+			desc:        "collector scope allows the restricted collector",
+			permissions: map[string][]string{ActionCreate: {ScopeCollectorsProvider.GetResourceScopeUID("restricted")}},
+		},
+		{
+			desc:        "wildcard scope allows every collector",
+			permissions: map[string][]string{ActionCreate: {ScopeCollectorsAll}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			usr := &user.SignedInUser{Login: "editor", OrgID: 1, Permissions: map[int64]map[string][]string{1: tc.permissions}}
+
+			bundle, err := s.create(context.Background(), usr, createOptions{})
+			require.NoError(t, err)
+			require.Equal(t, tc.skipped, bundle.SkippedCollectors)
+
+			require.Eventually(t, func() bool {
+				b, err := s.store.Get(context.Background(), bundle.UID)
+				return err == nil && b.State == supportbundles.StateComplete
+			}, 5*time.Second, 10*time.Millisecond)
+
+			stored, err := s.store.Get(context.Background(), bundle.UID)
+			require.NoError(t, err)
+			require.Equal(t, tc.skipped, stored.SkippedCollectors)
+			require.Eventually(t, func() bool { return len(s.creationSlots) == 0 }, 5*time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestService_authorizeCollectors_AccessControlDisabled(t *testing.T) {
+	restricted := newTestCollector("restricted", nil)
+	restricted.Restricted = true
+	s := newTestService(t, newTestCollector("basic", nil), restricted)
+	collectors := s.selectCollectors([]string{"basic", "restricted"})
+
+	testCases := []struct {
+		desc    string
+		usr     *user.SignedInUser
+		skipped []string
+	}{
+		{
+			desc: "server admin",
+			usr:  &user.SignedInUser{Login: "admin", OrgID: 1, IsGrafanaAdmin: true},
+		},
+		{
+			desc: "org admin",
+			usr:  &user.SignedInUser{Login: "admin", OrgID: 1, OrgRole: org.RoleAdmin},
+		},
+		{
+			desc:    "token user",
+			usr:     tokenUser(&creationTokenClaims{Creator: "admin", OrgID: 1}),
+			skipped: []string{"restricted"},
+		},
+		{
+			desc: "internal creator",
+			usr:  internalCreator("crash-handler"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			allowed, skipped, err := s.authorizeCollectors(context.Background(), tc.usr, collectors)
+			require.NoError(t, err)
+			require.Equal(t, tc.skipped, skipped)
+			require.Len(t, allowed, len(collectors)-len(tc.skipped))
+		})
+	}
+}
+
+func TestService_create_MixedPrivileges(t *testing.T) {
+	item := func(name string) supportbundles.CollectorFunc {
+		return func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			return &supportbundles.SupportItem{Filename: name + ".txt", FileBytes: []byte(name)}, nil
+		}
+	}
+	allowed := newTestCollector("allowed", item("allowed"))
+	allowed.Restricted = true
+	denied := newTestCollector("denied", item("denied"))
+	denied.Restricted = true
+
+	s := newTestService(t, newTestCollector("basic", item("basic")), allowed, denied)
+	s.accessControl = acimpl.ProvideAccessControl(setting.NewCfg())
+
+	// a help-desk user allowed to run one of the restricted collectors only
+	usr := &user.SignedInUser{Login: "helpdesk", OrgID: 1, Permissions: map[int64]map[string][]string{1: {
+		ActionCreate: {ScopeCollectorsProvider.GetResourceScopeUID("allowed")},
+	}}}
+	bundle, err := s.create(context.Background(), usr, createOptions{Collectors: []string{"basic", "allowed", "denied"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"denied"}, bundle.SkippedCollectors)
+
+	require.Eventually(t, func() bool {
+		b, err := s.store.Get(context.Background(), bundle.UID)
+		return err == nil && b.State == supportbundles.StateComplete
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stored, err := s.get(context.Background(), bundle.UID)
+	require.NoError(t, err)
+	files := readBundle(t, stored.TarBytes)
+	require.Contains(t, files, "/bundle/basic.txt")
+	require.Contains(t, files, "/bundle/allowed.txt")
+	require.NotContains(t, files, "/bundle/denied.txt")
+
+	var m manifest
+	require.NoError(t, json.Unmarshal(files["/bundle/manifest.json"], &m))
+	require.Len(t, m.Collectors, 2)
+	require.Len(t, m.SkippedCollectors, 1)
+	require.Equal(t, "denied", m.SkippedCollectors[0].UID)
+	require.Contains(t, m.SkippedCollectors[0].Reason, ScopeCollectorsProvider.GetResourceScopeUID("denied"))
+	require.Eventually(t, func() bool { return len(s.creationSlots) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestService_Create(t *testing.T) {
+	restricted := newTestCollector("restricted", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "restricted.txt", FileBytes: []byte("restricted")}, nil
+	})
+	restricted.Restricted = true
+	s := newTestService(t, restricted)
+	s.accessControl = acimpl.ProvideAccessControl(setting.NewCfg())
+
+	opts := supportbundles.Options{
+		Creator:     "crash-handler",
+		Collectors:  []string{"restricted"},
+		Description: "fatal error",
+		Tags:        map[string]string{"reason": "panic"},
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		_, err := s.Create(context.Background(), opts)
+		require.ErrorIs(t, err, supportbundles.ErrBundlesDisabled)
+	})
+
+	s.enabled = true
+	s.features = featuremgmt.WithFeatures(featuremgmt.FlagSupportBundles)
+
+	t.Run("without creator", func(t *testing.T) {
+		_, err := s.Create(context.Background(), supportbundles.Options{})
+		require.ErrorIs(t, err, supportbundles.ErrInvalidOptions)
+	})
+
+	t.Run("created", func(t *testing.T) {
+		bundle, err := s.Create(context.Background(), opts)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			b, err := s.store.Get(context.Background(), bundle.UID)
+			return err == nil && b.State == supportbundles.StateComplete
+		}, 5*time.Second, 10*time.Millisecond)
+
+		stored, err := s.get(context.Background(), bundle.UID)
+		require.NoError(t, err)
+		require.Equal(t, "crash-handler", stored.Creator)
+		require.Equal(t, "fatal error", stored.Description)
+		require.Equal(t, map[string]string{"reason": "panic"}, stored.Tags)
+		require.Contains(t, readBundle(t, stored.TarBytes), "/bundle/restricted.txt")
+		require.Eventually(t, func() bool { return len(s.creationSlots) == 0 }, 5*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestService_list_Tags(t *testing.T) {
+	s := newTestService(t, newTestCollector("ok", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return nil, nil
+	}))
+	usr := &user.SignedInUser{Login: "admin"}
+
+	create := func(t *testing.T, opts createOptions) *supportbundles.Bundle {
+		t.Helper()
+		bundle, err := s.create(context.Background(), usr, opts)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return len(s.creationSlots) == 0 }, 5*time.Second, 10*time.Millisecond)
+		return bundle
+	}
+
+	incident := create(t, createOptions{
+		Description: "dashboards fail to load",
+		Tags:        map[string]string{"incident": "INC-1234", "team": "core"},
+	})
+	other := create(t, createOptions{Tags: map[string]string{"incident": "INC-5678"}})
+	untagged := create(t, createOptions{})
+
+	uids := func(t *testing.T, tags map[string]string) []string {
+		t.Helper()
+		bundles, _, err := s.list(context.Background(), listQuery{Tags: tags})
+		require.NoError(t, err)
+		uids := make([]string, 0, len(bundles))
+		for _, b := range bundles {
+			uids = append(uids, b.UID)
+		}
+		return uids
+	}
+
+	require.ElementsMatch(t, []string{incident.UID, other.UID, untagged.UID}, uids(t, nil))
+	require.ElementsMatch(t, []string{incident.UID}, uids(t, map[string]string{"incident": "INC-1234"}))
+	require.ElementsMatch(t, []string{incident.UID, other.UID}, uids(t, map[string]string{"incident": ""}))
+	require.Empty(t, uids(t, map[string]string{"incident": "INC-1234", "team": "alerting"}))
+
+	stored, err := s.get(context.Background(), incident.UID)
+	require.NoError(t, err)
+	require.Equal(t, "dashboards fail to load", stored.Description)
+	require.Equal(t, map[string]string{"incident": "INC-1234", "team": "core"}, stored.Tags)
+
+	t.Run("tags with an empty key are rejected", func(t *testing.T) {
+		_, err := s.create(context.Background(), usr, createOptions{Tags: map[string]string{" ": "value"}})
+		require.ErrorIs(t, err, ErrInvalidTags)
+	})
+}
+
+// countingOrphanRemover counts the cleanup cycles through the orphan removal they end with.
+type countingOrphanRemover struct {
+	bundleStore
+	mu    sync.Mutex
+	calls int
+}
+
+func (s *countingOrphanRemover) RemoveOrphans(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	return nil
+}
+
+func (s *countingOrphanRemover) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func TestService_getUsageStats(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	usr := &user.SignedInUser{Login: "admin"}
+
+	for _, archive := range [][]byte{[]byte("small"), []byte("a larger archive"), nil} {
+		b, err := s.store.Create(ctx, usr, 0)
+		require.NoError(t, err)
+		if archive != nil {
+			require.NoError(t, s.store.Update(ctx, b.UID, supportbundles.StateComplete, archive))
+		}
+	}
+	// stored before the size was recorded
+	require.NoError(t, s.store.(*store).set(ctx, &supportbundles.Bundle{UID: "legacy", State: supportbundles.StateComplete, TarBytes: []byte("legacy archive")}))
+
+	m, err := s.getUsageStats(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, m["stats.bundles.count"])
+	require.EqualValues(t, 3, m["stats.bundles.stored.count"])
+	require.EqualValues(t, len("small")+len("a larger archive")+len("legacy archive"), m["stats.bundles.stored.bytes"])
+	require.EqualValues(t, (len("small")+len("a larger archive")+len("legacy archive"))/3, m["stats.bundles.stored.avg_bytes"])
+	require.EqualValues(t, len("a larger archive"), m["stats.bundles.stored.max_bytes"])
+}
+
+func TestService_Run_Cleanup(t *testing.T) {
+	s := newTestService(t)
+	s.features = featuremgmt.WithFeatures(featuremgmt.FlagSupportBundles)
+	s.cleanupInterval = 10 * time.Millisecond
+	store := &countingOrphanRemover{bundleStore: s.store}
+	s.store = store
+
+	expired, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.store.UpdateMetadata(context.Background(), expired.UID, func(b *supportbundles.Bundle) {
+		b.State = supportbundles.StateComplete
+		b.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	// the initial cleanup and at least two periodic ones
+	require.Eventually(t, func() bool { return store.count() >= 3 }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	_, err = s.store.Get(context.Background(), expired.UID)
+	require.Error(t, err)
+}
+
+func TestService_Run_OrphanedBundles(t *testing.T) {
+	s := newTestService(t)
+	s.features = featuremgmt.WithFeatures(featuremgmt.FlagSupportBundles)
+	s.cleanupInterval = time.Hour
+
+	// a bundle left pending by a collection that crashed along with Grafana
+	crashed, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.store.UpdateMetadata(context.Background(), crashed.UID, func(b *supportbundles.Bundle) {
+		b.CreatedAt = time.Now().Add(-bundleCreationTimeout - time.Minute).Unix()
+		b.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+	}))
+	require.Error(t, s.remove(context.Background(), crashed.UID))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	// failed, then removed as it's expired
+	require.Eventually(t, func() bool {
+		_, err := s.store.Get(context.Background(), crashed.UID)
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestService_DisabledCollectors(t *testing.T) {
+	item := func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "item.txt", FileBytes: []byte("item")}, nil
+	}
+
+	// external is registered by another service, bypassing registerCollector
+	s := newTestService(t, newTestCollector("basic", item), newTestCollector("external", item))
+	s.disabledCollectors = map[string]*supportbundles.Collector{"datasources": nil, "external": nil}
+	s.registerCollector(newTestCollector("datasources", item))
+	s.registerCollector(newTestCollector("settings", item))
+
+	require.NotContains(t, s.bundleRegistry.Collectors(), "datasources")
+	require.Contains(t, s.bundleRegistry.Collectors(), "settings")
+	require.NotNil(t, s.disabledCollectors["datasources"])
+
+	selected := make([]string, 0)
+	for _, c := range s.selectCollectors(nil) {
+		selected = append(selected, c.UID)
+	}
+	require.Equal(t, []string{"basic", "settings"}, selected)
+
+	for _, uid := range []string{"datasources", "external"} {
+		_, err := s.create(context.Background(), &user.SignedInUser{Login: "admin"}, createOptions{Collectors: []string{uid}})
+		require.ErrorIs(t, err, ErrCollectorDisabled)
+		require.Contains(t, err.Error(), uid)
+	}
+}
+
+func TestReadCollectorTimeouts(t *testing.T) {
+	cfg := setting.NewCfg()
+	section := cfg.Raw.Section("support_bundles.collector_timeouts")
+	section.Key("db").SetValue("10m")
+	section.Key("basic").SetValue("5s")
+	section.Key("plugins").SetValue("forever")
+	section.Key("settings").SetValue("-1m")
+	section.Key("cpu-profile").SetValue("1h")
+
+	timeouts := readCollectorTimeouts(log.NewNopLogger(), cfg)
+	require.Equal(t, map[string]time.Duration{
+		"db":          10 * time.Minute,
+		"basic":       5 * time.Second,
+		"cpu-profile": bundleCreationTimeout,
+	}, timeouts)
+
+	s := newTestService(t)
+	s.collectorTimeouts = timeouts
+	s.defaultCollectorTimeout = time.Minute
+	require.Equal(t, 10*time.Minute, s.collectorTimeout("db"))
+	require.Equal(t, time.Minute, s.collectorTimeout("plugins"))
+}
@@ -0,0 +1,134 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// sessionsCollector reports how login sessions are configured and counts the user
+// auth tokens backing them, for users being logged out unexpectedly. Only aggregate
+// queries are run, the tokens never leave the database.
+func sessionsCollector(cfg *setting.Cfg, sql db.DB) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "sessions",
+		DisplayName:       "Login sessions",
+		Description:       "Session settings and counts of active, expired and revoked user sessions, without the tokens",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type sessionSettings struct {
+				// Backend is where the sessions are stored, user auth tokens are always kept in the database.
+				Backend                      string `json:"backend"`
+				CookieName                   string `json:"cookie_name"`
+				CookieSecure                 bool   `json:"cookie_secure"`
+				CookieSameSite               string `json:"cookie_samesite"`
+				MaxInactiveLifetime          string `json:"login_maximum_inactive_lifetime_duration"`
+				MaxLifetime                  string `json:"login_maximum_lifetime_duration"`
+				TokenRotationIntervalMinutes int    `json:"token_rotation_interval_minutes"`
+			}
+			type sessionCounts struct {
+				Total   int64 `json:"total"`
+				Active  int64 `json:"active"`
+				Revoked int64 `json:"revoked"`
+				// Expired are the sessions that reached the maximum lifetime or were inactive for too long.
+				Expired            int64 `json:"expired"`
+				UsersWithSessions  int64 `json:"users_with_active_sessions"`
+				MaxSessionsPerUser int64 `json:"max_active_sessions_per_user"`
+				CreatedLastDay     int64 `json:"created_last_24h"`
+				// Unseen are the active sessions whose rotated token the browser hasn't
+				// sent back yet, many of them hint at cookies being dropped.
+				Unseen int64 `json:"active_unseen_rotated_tokens"`
+			}
+			type sessions struct {
+				Settings sessionSettings `json:"settings"`
+				Counts   sessionCounts   `json:"counts"`
+			}
+
+			sameSite := "disabled"
+			if !cfg.CookieSameSiteDisabled {
+				sameSite = sameSiteName(cfg.CookieSameSiteMode)
+			}
+			result := sessions{
+				Settings: sessionSettings{
+					Backend:                      "database",
+					CookieName:                   cfg.LoginCookieName,
+					CookieSecure:                 cfg.CookieSecure,
+					CookieSameSite:               sameSite,
+					MaxInactiveLifetime:          cfg.LoginMaxInactiveLifetime.String(),
+					MaxLifetime:                  cfg.LoginMaxLifetime.String(),
+					TokenRotationIntervalMinutes: cfg.TokenRotationIntervalMinutes,
+				},
+			}
+
+			// the same conditions as the ones the auth token service looks tokens up with
+			now := time.Now()
+			createdAfter := now.Add(-cfg.LoginMaxLifetime).Unix()
+			rotatedAfter := now.Add(-cfg.LoginMaxInactiveLifetime).Unix()
+			const active = "created_at > ? AND rotated_at > ? AND revoked_at = 0"
+
+			counts := &result.Counts
+			err := sql.WithDbSession(ctx, func(sess *db.Session) error {
+				var err error
+				if counts.Total, err = sess.Table("user_auth_token").Count(); err != nil {
+					return err
+				}
+				if counts.Active, err = sess.Table("user_auth_token").Where(active, createdAfter, rotatedAfter).Count(); err != nil {
+					return err
+				}
+				if counts.Revoked, err = sess.Table("user_auth_token").Where("revoked_at > 0").Count(); err != nil {
+					return err
+				}
+				counts.Expired = counts.Total - counts.Active - counts.Revoked
+				if counts.CreatedLastDay, err = sess.Table("user_auth_token").Where("created_at > ?", now.Add(-24*time.Hour).Unix()).Count(); err != nil {
+					return err
+				}
+				if counts.Unseen, err = sess.Table("user_auth_token").Where(active+" AND auth_token_seen = ?", createdAfter, rotatedAfter, false).Count(); err != nil {
+					return err
+				}
+
+				var perUser struct {
+					Users int64 `xorm:"users"`
+					Max   int64 `xorm:"max_sessions"`
+				}
+				_, err = sess.SQL(`SELECT COUNT(*) AS users, COALESCE(MAX(sessions), 0) AS max_sessions
+					FROM (SELECT user_id, COUNT(*) AS sessions FROM user_auth_token WHERE `+active+` GROUP BY user_id) AS per_user`,
+					createdAfter, rotatedAfter).Get(&perUser)
+				counts.UsersWithSessions, counts.MaxSessionsPerUser = perUser.Users, perUser.Max
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			data, err := json.Marshal(result)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "sessions.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+// sameSiteName returns the cookie_samesite value of mode.
+func sameSiteName(mode http.SameSite) string {
+	switch mode {
+	case http.SameSiteLaxMode:
+		return "lax"
+	case http.SameSiteStrictMode:
+		return "strict"
+	case http.SameSiteNoneMode:
+		return "none"
+	default:
+		return "default"
+	}
+}
@@ -0,0 +1,103 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestSessionsCollector(t *testing.T) {
+	type userAuthToken struct {
+		Id            int64
+		UserId        int64
+		AuthToken     string
+		PrevAuthToken string
+		UserAgent     string
+		ClientIp      string
+		AuthTokenSeen bool
+		SeenAt        int64
+		RotatedAt     int64
+		CreatedAt     int64
+		UpdatedAt     int64
+		RevokedAt     int64
+	}
+	type sessions struct {
+		Settings struct {
+			Backend          string `json:"backend"`
+			CookieName       string `json:"cookie_name"`
+			CookieSameSite   string `json:"cookie_samesite"`
+			MaxLifetime      string `json:"login_maximum_lifetime_duration"`
+			RotationInterval int    `json:"token_rotation_interval_minutes"`
+		} `json:"settings"`
+		Counts struct {
+			Total              int64 `json:"total"`
+			Active             int64 `json:"active"`
+			Revoked            int64 `json:"revoked"`
+			Expired            int64 `json:"expired"`
+			UsersWithSessions  int64 `json:"users_with_active_sessions"`
+			MaxSessionsPerUser int64 `json:"max_active_sessions_per_user"`
+			CreatedLastDay     int64 `json:"created_last_24h"`
+			Unseen             int64 `json:"active_unseen_rotated_tokens"`
+		} `json:"counts"`
+	}
+
+	cfg := setting.NewCfg()
+	cfg.LoginCookieName = "grafana_session"
+	cfg.LoginMaxLifetime = 30 * 24 * time.Hour
+	cfg.LoginMaxInactiveLifetime = 7 * 24 * time.Hour
+	cfg.TokenRotationIntervalMinutes = 10
+
+	sqlStore := db.InitTestDB(t)
+	now := time.Now()
+	recent := now.Add(-time.Hour).Unix()
+	old := now.Add(-10 * 24 * time.Hour).Unix()
+	require.NoError(t, sqlStore.WithDbSession(context.Background(), func(sess *db.Session) error {
+		tokens := []*userAuthToken{
+			// active, two for the same user and one of them not seen since rotated
+			{UserId: 1, CreatedAt: recent, RotatedAt: recent, AuthTokenSeen: true},
+			{UserId: 1, CreatedAt: recent, RotatedAt: recent},
+			{UserId: 2, CreatedAt: old, RotatedAt: recent, AuthTokenSeen: true},
+			// revoked
+			{UserId: 2, CreatedAt: recent, RotatedAt: recent, AuthTokenSeen: true, RevokedAt: recent},
+			// inactive for too long
+			{UserId: 3, CreatedAt: old, RotatedAt: old, AuthTokenSeen: true},
+		}
+		for i, token := range tokens {
+			token.AuthToken = plantedSecret + "-" + string(rune('a'+i))
+			token.PrevAuthToken = token.AuthToken
+			if _, err := sess.Table("user_auth_token").Insert(token); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	item, err := sessionsCollector(cfg, sqlStore).Fn(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "sessions.json", item.Filename)
+	require.NotContains(t, string(item.FileBytes), plantedSecret)
+
+	// as written to the bundle
+	var result sessions
+	require.NoError(t, json.Unmarshal(newRedactor(defaultRedactKeys).redactSecrets(item.Filename, item.FileBytes), &result))
+	require.Equal(t, "database", result.Settings.Backend)
+	require.Equal(t, "grafana_session", result.Settings.CookieName)
+	require.Equal(t, "720h0m0s", result.Settings.MaxLifetime)
+	require.Equal(t, 10, result.Settings.RotationInterval)
+
+	counts := result.Counts
+	require.EqualValues(t, 5, counts.Total)
+	require.EqualValues(t, 3, counts.Active)
+	require.EqualValues(t, 1, counts.Revoked)
+	require.EqualValues(t, 1, counts.Expired)
+	require.EqualValues(t, 2, counts.UsersWithSessions)
+	require.EqualValues(t, 2, counts.MaxSessionsPerUser)
+	require.EqualValues(t, 3, counts.CreatedLastDay)
+	require.EqualValues(t, 1, counts.Unseen)
+}
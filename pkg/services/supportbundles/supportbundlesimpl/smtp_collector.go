@@ -0,0 +1,90 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+
+	"github.com/grafana/grafana/pkg/services/notifications"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// smtpCollector reports the SMTP settings and, when testConnection is set,
+// whether Grafana can connect and authenticate to the SMTP server. No email is sent.
+func smtpCollector(cfg *setting.Cfg, testConnection bool) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "smtp",
+		DisplayName:       "SMTP",
+		Description:       "The SMTP settings and, if enabled, the result of a connection test to the SMTP server",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type connectionTest struct {
+				Success bool   `json:"success"`
+				Error   string `json:"error,omitempty"`
+			}
+			type smtpStatus struct {
+				Enabled        bool            `json:"enabled"`
+				Host           string          `json:"host"`
+				Port           string          `json:"port"`
+				User           string          `json:"user,omitempty"`
+				Password       string          `json:"password,omitempty"`
+				FromAddress    string          `json:"from_address"`
+				FromName       string          `json:"from_name"`
+				EhloIdentity   string          `json:"ehlo_identity,omitempty"`
+				StartTLSPolicy string          `json:"start_tls_policy"`
+				SkipVerify     bool            `json:"skip_verify"`
+				ClientCert     bool            `json:"client_cert"`
+				ConnectionTest *connectionTest `json:"connection_test,omitempty"`
+			}
+
+			smtp := cfg.Smtp
+			status := smtpStatus{
+				Enabled:        smtp.Enabled,
+				Host:           smtp.Host,
+				User:           smtp.User,
+				FromAddress:    smtp.FromAddress,
+				FromName:       smtp.FromName,
+				EhloIdentity:   smtp.EhloIdentity,
+				StartTLSPolicy: smtp.StartTLSPolicy,
+				SkipVerify:     smtp.SkipVerify,
+				ClientCert:     smtp.CertFile != "",
+			}
+			if host, port, err := net.SplitHostPort(smtp.Host); err == nil {
+				status.Host = host
+				status.Port = port
+			}
+			if status.StartTLSPolicy == "" {
+				status.StartTLSPolicy = "OpportunisticStartTLS"
+			}
+			if smtp.Password != "" {
+				status.Password = redactedValue
+			}
+
+			// only test the connection if explicitly allowed, it's an outbound connection
+			if testConnection && smtp.Enabled {
+				status.ConnectionTest = &connectionTest{}
+				client, err := notifications.NewSmtpClient(smtp)
+				if err == nil {
+					err = client.TestConnection()
+				}
+				if err != nil {
+					status.ConnectionTest.Error = err.Error()
+				} else {
+					status.ConnectionTest.Success = true
+				}
+			}
+
+			data, err := json.Marshal(status)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "smtp.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
@@ -0,0 +1,125 @@
+package supportbundlesimpl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// fakeSMTPServer accepts SMTP connections that don't advertise any extension
+// and closes them on QUIT. Mail transactions aren't supported.
+func fakeSMTPServer(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer func() { _ = conn.Close() }()
+				r := bufio.NewReader(conn)
+				_, _ = conn.Write([]byte("220 localhost ESMTP\r\n"))
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if strings.HasPrefix(strings.ToUpper(line), "QUIT") {
+						_, _ = conn.Write([]byte("221 bye\r\n"))
+						return
+					}
+					_, _ = conn.Write([]byte("250 localhost\r\n"))
+				}
+			}(conn)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestSMTPCollector(t *testing.T) {
+	type connectionTest struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+	type smtpStatus struct {
+		Enabled        bool            `json:"enabled"`
+		Host           string          `json:"host"`
+		Port           string          `json:"port"`
+		Password       string          `json:"password"`
+		FromAddress    string          `json:"from_address"`
+		StartTLSPolicy string          `json:"start_tls_policy"`
+		ConnectionTest *connectionTest `json:"connection_test"`
+	}
+
+	addr := fakeSMTPServer(t)
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+
+	collect := func(t *testing.T, smtp setting.SmtpSettings, testConnection bool) smtpStatus {
+		t.Helper()
+
+		cfg := setting.NewCfg()
+		cfg.Smtp = smtp
+		item, err := smtpCollector(cfg, testConnection).Fn(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "smtp.json", item.Filename)
+		require.NotContains(t, string(item.FileBytes), plantedSecret)
+
+		var status smtpStatus
+		require.NoError(t, json.Unmarshal(item.FileBytes, &status))
+		return status
+	}
+
+	smtp := setting.SmtpSettings{
+		Enabled:     true,
+		Host:        addr,
+		Password:    plantedSecret,
+		FromAddress: "admin@grafana.localhost",
+	}
+
+	t.Run("reports the settings without testing the connection by default", func(t *testing.T) {
+		require.Equal(t, smtpStatus{
+			Enabled:        true,
+			Host:           host,
+			Port:           port,
+			Password:       redactedValue,
+			FromAddress:    "admin@grafana.localhost",
+			StartTLSPolicy: "OpportunisticStartTLS",
+		}, collect(t, smtp, false))
+	})
+
+	t.Run("tests the connection when enabled", func(t *testing.T) {
+		status := collect(t, smtp, true)
+		require.Equal(t, &connectionTest{Success: true}, status.ConnectionTest)
+	})
+
+	t.Run("records failed connection tests", func(t *testing.T) {
+		smtp := smtp
+		smtp.StartTLSPolicy = "MandatoryStartTLS"
+
+		status := collect(t, smtp, true)
+		require.NotNil(t, status.ConnectionTest)
+		require.False(t, status.ConnectionTest.Success)
+		require.Contains(t, status.ConnectionTest.Error, "STARTTLS")
+	})
+
+	t.Run("doesn't test the connection when SMTP is disabled", func(t *testing.T) {
+		smtp := smtp
+		smtp.Enabled = false
+		require.Nil(t, collect(t, smtp, true).ConnectionTest)
+	})
+}
@@ -0,0 +1,251 @@
+package supportbundlesimpl
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// maxRedactedLine bounds the text buffered to be redacted line by line, longer
+// lines are redacted in chunks.
+const maxRedactedLine = 1 << 20
+
+// collectorFile is a file of the output of a collector, redacted and cut to the
+// size limit. It's spooled to path, or held in data when there's no spool directory.
+type collectorFile struct {
+	name string
+	path string
+	data []byte
+	size int64
+}
+
+// open returns a reader of the first size bytes of the file.
+func (f collectorFile) open() (io.ReadCloser, error) {
+	if f.path == "" {
+		return io.NopCloser(bytes.NewReader(f.data[:f.size])), nil
+	}
+	// the path is a spool file created by the service, not user input
+	// nolint:gosec
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, f.size), file}, nil
+}
+
+// read returns the content of the file.
+func (f collectorFile) read() ([]byte, error) {
+	r, err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	return io.ReadAll(r)
+}
+
+// spool writes the output of a collector to files in dir, or in memory if dir is
+// empty, so that the output of collectors doesn't pile up in memory until the
+// bundle is archived. The output is redacted and cut to limit bytes in total,
+// unless limit is negative. It's the supportbundles.FileWriter of streaming collectors.
+type spool struct {
+	mu       sync.Mutex
+	dir      string
+	redactor *redactor
+	limit    int64
+
+	files     []collectorFile
+	current   *spoolFile
+	written   int64
+	truncated bool
+	closed    bool
+}
+
+func newSpool(dir string, r *redactor, limit int64) *spool {
+	return &spool{dir: dir, redactor: r, limit: limit}
+}
+
+// spoolFile is the file of a spool being written. Text is redacted line by line.
+type spoolFile struct {
+	s      *spool
+	index  int
+	out    io.Writer
+	file   *os.File
+	buf    *bytes.Buffer
+	redact bool
+	json   bool
+	line   []byte
+}
+
+func (s *spool) Create(filename string) (io.Writer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ext := strings.ToLower(filepath.Ext(filename))
+	f, err := s.create(filename, ext != ".pprof")
+	if err != nil {
+		return nil, err
+	}
+	f.json = ext == ".json"
+	return f, nil
+}
+
+func (s *spool) create(filename string, redact bool) (*spoolFile, error) {
+	if s.closed {
+		return nil, os.ErrClosed
+	}
+	if s.truncated {
+		return nil, supportbundles.ErrOutputLimitReached
+	}
+	if err := s.finish(); err != nil {
+		return nil, err
+	}
+
+	f := &spoolFile{s: s, index: len(s.files), redact: redact}
+	if s.dir == "" {
+		f.buf = &bytes.Buffer{}
+		f.out = f.buf
+	} else {
+		file, err := os.CreateTemp(s.dir, "collector-")
+		if err != nil {
+			return nil, err
+		}
+		f.file, f.out = file, file
+	}
+	file := collectorFile{name: filename}
+	if f.file != nil {
+		file.path = f.file.Name()
+	}
+	s.files = append(s.files, file)
+	s.current = f
+	return f, nil
+}
+
+func (f *spoolFile) Write(p []byte) (int, error) {
+	f.s.mu.Lock()
+	defer f.s.mu.Unlock()
+
+	if f.s.closed || f.s.current != f {
+		return 0, os.ErrClosed
+	}
+	if !f.redact {
+		return len(p), f.s.write(p)
+	}
+
+	for i := 0; i < len(p); {
+		end := bytes.IndexByte(p[i:], '\n')
+		if end < 0 {
+			f.line = append(f.line, p[i:]...)
+			if len(f.line) < maxRedactedLine {
+				break
+			}
+		} else {
+			f.line = append(f.line, p[i:i+end+1]...)
+			i += end
+		}
+		if err := f.flush(); err != nil {
+			return 0, err
+		}
+		if end < 0 {
+			break
+		}
+		i++
+	}
+	return len(p), nil
+}
+
+// flush writes the buffered line, redacted.
+func (f *spoolFile) flush() error {
+	if len(f.line) == 0 {
+		return nil
+	}
+	var line string
+	if f.json {
+		line = f.s.redactor.redactJSONLine(string(f.line))
+	} else {
+		line = f.s.redactor.redactText(string(f.line))
+	}
+	f.line = f.line[:0]
+	return f.s.write([]byte(line))
+}
+
+// write writes data to the current file, cut to the limit. Callers must hold s.mu.
+func (s *spool) write(data []byte) error {
+	if s.truncated {
+		return supportbundles.ErrOutputLimitReached
+	}
+	if s.limit >= 0 && s.written+int64(len(data)) > s.limit {
+		data = data[:s.limit-s.written]
+		s.truncated = true
+	}
+	n, err := s.current.out.Write(data)
+	s.written += int64(n)
+	s.files[s.current.index].size += int64(n)
+	if err != nil {
+		return err
+	}
+	if s.truncated {
+		return supportbundles.ErrOutputLimitReached
+	}
+	return nil
+}
+
+// finish flushes and closes the current file, if any. Callers must hold s.mu.
+func (s *spool) finish() error {
+	f := s.current
+	if f == nil {
+		return nil
+	}
+	// flushed while the file is still current, the limit may cut the line
+	err := f.flush()
+	s.current = nil
+	if f.buf != nil {
+		s.files[f.index].data = f.buf.Bytes()
+	}
+	if f.file != nil {
+		if closeErr := f.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if errors.Is(err, supportbundles.ErrOutputLimitReached) {
+		return nil
+	}
+	return err
+}
+
+// writeItem writes the files of an item returned by a collector, redacted with
+// redactSecrets as a whole, JSON files structurally.
+func (s *spool) writeItem(item *supportbundles.SupportItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, file := range item.AllFiles() {
+		if _, err := s.create(file.Filename, false); err != nil {
+			return err
+		}
+		if err := s.write(s.redactor.redactSecrets(file.Filename, file.FileBytes)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close finishes the current file and returns the files written. Later writes fail.
+func (s *spool) close() ([]collectorFile, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return s.files, s.truncated, nil
+	}
+	err := s.finish()
+	s.closed = true
+	return s.files, s.truncated, err
+}
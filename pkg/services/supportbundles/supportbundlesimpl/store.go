@@ -1,10 +1,13 @@
 package supportbundlesimpl
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -25,46 +28,198 @@ const (
 
 const key = "count"
 
-func newStore(kv kvstore.KVStore) *store {
+const (
+	// maxUIDAttempts is how many UIDs are generated at most to find one no bundle has.
+	maxUIDAttempts     = 5
+	maxUIDPrefixLength = 40
+)
+
+const (
+	listSortCreatedAt = "createdAt"
+	listSortExpiresAt = "expiresAt"
+	listSortState     = "state"
+)
+
+var ErrInvalidListQuery = errors.New("invalid support bundle list query")
+
+// listQuery filters, sorts and pages the bundles returned by List. The zero
+// value lists every bundle, newest first.
+type listQuery struct {
+	// Tags the bundles must all have. An empty tag value matches any value of the tag.
+	Tags map[string]string
+	// Sort is createdAt, expiresAt or state. Defaults to createdAt.
+	Sort string
+	// Ascending sorts oldest, or alphabetically first, first.
+	Ascending bool
+	// Limit is the page size, zero disables paging.
+	Limit int
+	// Page starts at 1.
+	Page int
+}
+
+func (q listQuery) validate() error {
+	switch q.Sort {
+	case "", listSortCreatedAt, listSortExpiresAt, listSortState:
+	default:
+		return fmt.Errorf("%w: unknown sort %q", ErrInvalidListQuery, q.Sort)
+	}
+	if q.Limit < 0 || q.Page < 0 {
+		return fmt.Errorf("%w: limit and page can't be negative", ErrInvalidListQuery)
+	}
+	return nil
+}
+
+func (q listQuery) sort(bundles []supportbundles.Bundle) {
+	less := func(a, b supportbundles.Bundle) bool {
+		return a.CreatedAt < b.CreatedAt
+	}
+	switch q.Sort {
+	case listSortExpiresAt:
+		less = func(a, b supportbundles.Bundle) bool {
+			return a.ExpiresAt < b.ExpiresAt
+		}
+	case listSortState:
+		less = func(a, b supportbundles.Bundle) bool {
+			if a.State == b.State {
+				return a.CreatedAt < b.CreatedAt
+			}
+			return a.State < b.State
+		}
+	}
+
+	sort.SliceStable(bundles, func(i, j int) bool {
+		if q.Ascending {
+			return less(bundles[i], bundles[j])
+		}
+		return less(bundles[j], bundles[i])
+	})
+}
+
+func (q listQuery) page(bundles []supportbundles.Bundle) []supportbundles.Bundle {
+	if q.Limit <= 0 {
+		return bundles
+	}
+
+	page := q.Page
+	if page < 1 {
+		page = 1
+	}
+	start := (page - 1) * q.Limit
+	if start >= len(bundles) {
+		return []supportbundles.Bundle{}
+	}
+	end := start + q.Limit
+	if end > len(bundles) {
+		end = len(bundles)
+	}
+	return bundles[start:end]
+}
+
+func hasTags(b supportbundles.Bundle, tags map[string]string) bool {
+	for key, value := range tags {
+		v, ok := b.Tags[key]
+		if !ok || (value != "" && v != value) {
+			return false
+		}
+	}
+	return true
+}
+
+func newStore(kv kvstore.KVStore, retention time.Duration) *store {
+	if retention <= 0 {
+		retention = defaultBundleExpiration
+	}
+
 	return &store{
-		kv:     kvstore.WithNamespace(kv, 0, "supportbundle"),
-		statKV: kvstore.WithNamespace(kv, 0, "supportbundlestats"),
-		log:    log.New("supportbundle.store"),
+		kv:        kvstore.WithNamespace(kv, 0, "supportbundle"),
+		statKV:    kvstore.WithNamespace(kv, 0, "supportbundlestats"),
+		log:       log.New("supportbundle.store"),
+		retention: retention,
+		newUID:    uuid.NewRandom,
 	}
 }
 
 type store struct {
-	kv     *kvstore.NamespacedKVStore
-	log    log.Logger
-	mu     sync.Mutex
-	statKV *kvstore.NamespacedKVStore
+	kv  *kvstore.NamespacedKVStore
+	log log.Logger
+	// mu serializes the writes of the bundle metadata, which are read-modify-writes
+	// of the whole bundle, so that concurrent ones don't overwrite each other.
+	mu        sync.Mutex
+	statKV    *kvstore.NamespacedKVStore
+	retention time.Duration
+	// uidPrefix is prepended to the UIDs of the bundles, see validateUIDPrefix.
+	uidPrefix string
+	newUID    func() (uuid.UUID, error)
 }
 
 type bundleStore interface {
-	Create(ctx context.Context, usr *user.SignedInUser) (*supportbundles.Bundle, error)
+	// Create creates a pending bundle. A zero retention uses the store's default retention.
+	Create(ctx context.Context, usr *user.SignedInUser, retention time.Duration) (*supportbundles.Bundle, error)
+	// Get returns the bundle metadata. Use GetReader to read the bundle archive.
 	Get(ctx context.Context, uid string) (*supportbundles.Bundle, error)
+	// GetReader returns a reader of the bundle archive and its size in bytes.
+	GetReader(ctx context.Context, uid string) (io.ReadCloser, int64, error)
 	StatsCount(ctx context.Context) (int64, error)
-	List() ([]supportbundles.Bundle, error)
+	// List returns the bundles matching query and the total number of matches before paging.
+	List(query listQuery) ([]supportbundles.Bundle, int, error)
 	Remove(ctx context.Context, uid string) error
 	Update(ctx context.Context, uid string, state supportbundles.State, tarBytes []byte) error
+	// UpdateWithMetadata is Update also applying update to the bundle metadata,
+	// in the same write. update must not call the store.
+	UpdateWithMetadata(ctx context.Context, uid string, state supportbundles.State, tarBytes []byte, update func(bundle *supportbundles.Bundle)) error
+	// WriteArchive is Update with the archive read from archive, the stores
+	// keeping archives outside of the KV store copy it without buffering it.
+	WriteArchive(ctx context.Context, uid string, state supportbundles.State, archive io.Reader) error
+	UpdateProgress(ctx context.Context, uid string, progress int, currentCollector string) error
+	// UpdateMetadata applies update to the bundle metadata and persists it. update
+	// must not call the store.
+	UpdateMetadata(ctx context.Context, uid string, update func(bundle *supportbundles.Bundle)) error
+}
+
+// orphanRemover is implemented by stores that keep bundle archives outside of
+// the KV store and may end up with archives that have no metadata entry.
+type orphanRemover interface {
+	RemoveOrphans(ctx context.Context) error
 }
 
-func (s *store) Create(ctx context.Context, usr *user.SignedInUser) (*supportbundles.Bundle, error) {
-	uid, err := uuid.NewRandom()
+// uidPrefixer is implemented by the stores generating the UIDs of the bundles.
+type uidPrefixer interface {
+	setUIDPrefix(prefix string)
+}
+
+var uidPrefixPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]*$`)
+
+// validateUIDPrefix makes sure that prefixed UIDs are still valid file names and URL path segments.
+func validateUIDPrefix(prefix string) error {
+	if len(prefix) > maxUIDPrefixLength || !uidPrefixPattern.MatchString(prefix) {
+		return fmt.Errorf("invalid support bundle UID prefix %q: it must be at most %d letters, digits, '-' or '_'",
+			prefix, maxUIDPrefixLength)
+	}
+	return nil
+}
+
+func (s *store) Create(ctx context.Context, usr *user.SignedInUser, retention time.Duration) (*supportbundles.Bundle, error) {
+	if retention <= 0 {
+		retention = s.retention
+	}
+
+	// held until the bundle is stored, so that concurrent creations can't pick the same UID
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uid, err := s.uniqueUID(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	bundle := supportbundles.Bundle{
-		UID:       uid.String(),
+		UID:       uid,
 		State:     supportbundles.StatePending,
 		Creator:   usr.Login,
 		CreatedAt: time.Now().Unix(),
-		ExpiresAt: time.Now().Add(defaultBundleExpiration).Unix(),
+		ExpiresAt: time.Now().Add(retention).Unix(),
 	}
 
-	s.mu.Lock()
-
 	bundlesCreatedString, _, err := s.statKV.Get(ctx, key)
 	if err != nil {
 		s.log.Warn("An error has occurred upon retrieving value at statKV", "key", key)
@@ -80,7 +235,6 @@ func (s *store) Create(ctx context.Context, usr *user.SignedInUser) (*supportbun
 	if err := s.statKV.Set(ctx, key, fmt.Sprint(bundlesCreated)); err != nil {
 		s.log.Warn("An error has occurred upon setting a value at statKV", "key", key)
 	}
-	s.mu.Unlock()
 
 	if err := s.set(ctx, &bundle); err != nil {
 		return nil, err
@@ -88,7 +242,57 @@ func (s *store) Create(ctx context.Context, usr *user.SignedInUser) (*supportbun
 	return &bundle, nil
 }
 
+// uniqueUID returns a prefixed UID no bundle has yet, generating another one
+// when it is taken.
+func (s *store) uniqueUID(ctx context.Context) (string, error) {
+	for attempt := 1; attempt <= maxUIDAttempts; attempt++ {
+		id, err := s.newUID()
+		if err != nil {
+			return "", err
+		}
+
+		uid := s.uidPrefix + id.String()
+		_, exists, err := s.kv.Get(ctx, uid)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return uid, nil
+		}
+		s.log.Warn("Support bundle UID collision, generating another one", "uid", uid, "attempt", attempt)
+	}
+	return "", fmt.Errorf("could not generate a unique support bundle UID in %d attempts", maxUIDAttempts)
+}
+
+func (s *store) setUIDPrefix(prefix string) {
+	s.uidPrefix = prefix
+}
+
 func (s *store) Update(ctx context.Context, uid string, state supportbundles.State, tarBytes []byte) error {
+	return s.UpdateWithMetadata(ctx, uid, state, tarBytes, nil)
+}
+
+func (s *store) UpdateWithMetadata(ctx context.Context, uid string, state supportbundles.State, tarBytes []byte, update func(bundle *supportbundles.Bundle)) error {
+	return s.update(ctx, uid, state, tarBytes, int64(len(tarBytes)), update)
+}
+
+// WriteArchive reads the whole archive, the KV store keeps it in the bundle metadata.
+func (s *store) WriteArchive(ctx context.Context, uid string, state supportbundles.State, archive io.Reader) error {
+	tarBytes, err := io.ReadAll(archive)
+	if err != nil {
+		return err
+	}
+	return s.update(ctx, uid, state, tarBytes, int64(len(tarBytes)), nil)
+}
+
+// update sets the state of the bundle and the archive kept in the KV store, and
+// applies annotate, if set, to the metadata. The stores keeping the archives
+// elsewhere pass no archive but its size, a negative size leaves the size of the
+// bundle unchanged.
+func (s *store) update(ctx context.Context, uid string, state supportbundles.State, tarBytes []byte, size int64, annotate func(bundle *supportbundles.Bundle)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	bundle, err := s.Get(ctx, uid)
 	if err != nil {
 		return err
@@ -96,6 +300,37 @@ func (s *store) Update(ctx context.Context, uid string, state supportbundles.Sta
 
 	bundle.State = state
 	bundle.TarBytes = tarBytes
+	bundle.CurrentCollector = ""
+	if size >= 0 {
+		bundle.Size = size
+	}
+	if state.HasArchive() {
+		bundle.Progress = 100
+	}
+	if annotate != nil {
+		annotate(bundle)
+	}
+
+	return s.set(ctx, bundle)
+}
+
+func (s *store) UpdateProgress(ctx context.Context, uid string, progress int, currentCollector string) error {
+	return s.UpdateMetadata(ctx, uid, func(bundle *supportbundles.Bundle) {
+		bundle.Progress = progress
+		bundle.CurrentCollector = currentCollector
+	})
+}
+
+func (s *store) UpdateMetadata(ctx context.Context, uid string, update func(bundle *supportbundles.Bundle)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bundle, err := s.Get(ctx, uid)
+	if err != nil {
+		return err
+	}
+
+	update(bundle)
 
 	return s.set(ctx, bundle)
 }
@@ -125,14 +360,26 @@ func (s *store) Get(ctx context.Context, uid string) (*supportbundles.Bundle, er
 	return &b, nil
 }
 
+func (s *store) GetReader(ctx context.Context, uid string) (io.ReadCloser, int64, error) {
+	bundle, err := s.Get(ctx, uid)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return io.NopCloser(bytes.NewReader(bundle.TarBytes)), int64(len(bundle.TarBytes)), nil
+}
+
 func (s *store) Remove(ctx context.Context, uid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	return s.kv.Del(ctx, uid)
 }
 
-func (s *store) List() ([]supportbundles.Bundle, error) {
+func (s *store) List(query listQuery) ([]supportbundles.Bundle, int, error) {
 	data, err := s.kv.GetAll(context.Background())
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	res := make([]supportbundles.Bundle, 0)
@@ -140,19 +387,23 @@ func (s *store) List() ([]supportbundles.Bundle, error) {
 		for _, s := range items {
 			var b supportbundles.Bundle
 			if err := json.NewDecoder(strings.NewReader(s)).Decode(&b); err != nil {
-				return nil, err
+				return nil, 0, err
 			}
 
+			if !hasTags(b, query.Tags) {
+				continue
+			}
+			if b.Size == 0 {
+				// bundles stored before their size was recorded
+				b.Size = int64(len(b.TarBytes))
+			}
 			b.TarBytes = nil
 			res = append(res, b)
 		}
 	}
 
-	sort.Slice(res, func(i, j int) bool {
-		return res[i].CreatedAt > res[j].CreatedAt
-	})
-
-	return res, nil
+	query.sort(res)
+	return query.page(res), len(res), nil
 }
 
 func (s *store) StatsCount(ctx context.Context) (int64, error) {
@@ -0,0 +1,141 @@
+package supportbundlesimpl
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/grafana/grafana/pkg/services/secrets"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+const (
+	// maxEncryptedBundleSize bounds the archives of encrypted bundles, which are
+	// encrypted and decrypted in memory.
+	maxEncryptedBundleSize = 512 << 20
+	// maxEncryptionOverhead bounds how much larger than the archive its encrypted payload is.
+	maxEncryptionOverhead = 1 << 10
+)
+
+var ErrEncryptedBundleTooLarge = errors.New("support bundle is too large to be encrypted")
+
+// encryptedStore encrypts bundle archives with the secrets service before
+// handing them to the underlying store and decrypts them when read back.
+type encryptedStore struct {
+	bundleStore
+	secrets secrets.Service
+	maxSize int64
+}
+
+func newEncryptedStore(inner bundleStore, secretsService secrets.Service) *encryptedStore {
+	return &encryptedStore{
+		bundleStore: inner,
+		secrets:     secretsService,
+		maxSize:     maxEncryptedBundleSize,
+	}
+}
+
+func (s *encryptedStore) Update(ctx context.Context, uid string, state supportbundles.State, tarBytes []byte) error {
+	return s.UpdateWithMetadata(ctx, uid, state, tarBytes, nil)
+}
+
+func (s *encryptedStore) UpdateWithMetadata(ctx context.Context, uid string, state supportbundles.State, tarBytes []byte, update func(bundle *supportbundles.Bundle)) error {
+	if tarBytes == nil {
+		return s.bundleStore.UpdateWithMetadata(ctx, uid, state, nil, update)
+	}
+	if int64(len(tarBytes)) > s.maxSize {
+		return fmt.Errorf("%w: the archive is %d bytes", ErrEncryptedBundleTooLarge, len(tarBytes))
+	}
+
+	encrypted, err := s.secrets.Encrypt(ctx, tarBytes, secrets.WithoutScope())
+	if err != nil {
+		return fmt.Errorf("failed to encrypt support bundle: %w", err)
+	}
+
+	// flagged in the same write as the archive, so that it's never served undecrypted
+	return s.bundleStore.UpdateWithMetadata(ctx, uid, state, encrypted, func(bundle *supportbundles.Bundle) {
+		bundle.Encrypted = true
+		// the key reference lets us tell which data key is needed after a key rotation
+		bundle.EncryptionKeyID = encryptionKeyID(encrypted)
+		if update != nil {
+			update(bundle)
+		}
+	})
+}
+
+// WriteArchive reads the archive in memory, the secrets service can't encrypt a
+// stream. The maximum size of encrypted bundles bounds the memory used.
+func (s *encryptedStore) WriteArchive(ctx context.Context, uid string, state supportbundles.State, archive io.Reader) error {
+	tarBytes, err := io.ReadAll(io.LimitReader(archive, s.maxSize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(tarBytes)) > s.maxSize {
+		return fmt.Errorf("%w: the archive is larger than %d bytes", ErrEncryptedBundleTooLarge, s.maxSize)
+	}
+	return s.UpdateWithMetadata(ctx, uid, state, tarBytes, nil)
+}
+
+// GetReader decrypts the archive in memory, the secrets service can't decrypt
+// a stream. The maximum size of encrypted bundles bounds the memory used.
+func (s *encryptedStore) GetReader(ctx context.Context, uid string) (io.ReadCloser, int64, error) {
+	bundle, err := s.bundleStore.Get(ctx, uid)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	reader, size, err := s.bundleStore.GetReader(ctx, uid)
+	if err != nil || !bundle.Encrypted {
+		// bundles created while encryption was disabled are served as is
+		return reader, size, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	limit := s.maxSize + maxEncryptionOverhead
+	if size > limit {
+		return nil, 0, fmt.Errorf("%w: the encrypted archive is %d bytes", ErrEncryptedBundleTooLarge, size)
+	}
+	encrypted, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if int64(len(encrypted)) > limit {
+		return nil, 0, fmt.Errorf("%w: the encrypted archive is larger than its recorded size", ErrEncryptedBundleTooLarge)
+	}
+
+	decrypted, err := s.secrets.Decrypt(ctx, encrypted)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decrypt support bundle: %w", err)
+	}
+
+	return io.NopCloser(bytes.NewReader(decrypted)), int64(len(decrypted)), nil
+}
+
+func (s *encryptedStore) RemoveOrphans(ctx context.Context) error {
+	if r, ok := s.bundleStore.(orphanRemover); ok {
+		return r.RemoveOrphans(ctx)
+	}
+	return nil
+}
+
+// encryptionKeyID extracts the data key ID from an envelope encrypted payload,
+// which has the form #<base64 key id>#<encrypted data>.
+func encryptionKeyID(payload []byte) string {
+	if len(payload) == 0 || payload[0] != '#' {
+		return ""
+	}
+
+	end := bytes.IndexByte(payload[1:], '#')
+	if end == -1 {
+		return ""
+	}
+
+	keyID, err := base64.RawStdEncoding.DecodeString(string(payload[1 : end+1]))
+	if err != nil {
+		return ""
+	}
+	return string(keyID)
+}
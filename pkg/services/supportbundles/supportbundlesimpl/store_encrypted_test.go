@@ -0,0 +1,113 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/services/secrets/database"
+	"github.com/grafana/grafana/pkg/services/secrets/manager"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestEncryptedStore(t *testing.T) {
+	ctx := context.Background()
+	sqlStore := db.InitTestDB(t)
+	secretsService := manager.SetupTestService(t, database.ProvideSecretsStore(sqlStore))
+	inner := newStore(kvstore.ProvideService(sqlStore), defaultBundleExpiration)
+	s := newEncryptedStore(inner, secretsService)
+
+	bundle, err := s.Create(ctx, &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Update(ctx, bundle.UID, supportbundles.StateComplete, []byte("archive")))
+
+	stored, err := inner.Get(ctx, bundle.UID)
+	require.NoError(t, err)
+	require.True(t, stored.Encrypted)
+	require.NotEmpty(t, stored.EncryptionKeyID)
+	require.NotContains(t, string(stored.TarBytes), "archive")
+	require.Equal(t, int64(len(stored.TarBytes)), stored.Size)
+
+	// bundles encrypted with a previous data key must still be readable
+	require.NoError(t, secretsService.RotateDataKeys(ctx))
+
+	r, size, err := s.GetReader(ctx, bundle.UID)
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, []byte("archive"), data)
+	require.Equal(t, int64(len("archive")), size)
+}
+
+// writeCountingStore counts the writes of the bundle metadata.
+type writeCountingStore struct {
+	bundleStore
+	writes int
+}
+
+func (s *writeCountingStore) UpdateWithMetadata(ctx context.Context, uid string, state supportbundles.State, tarBytes []byte, update func(bundle *supportbundles.Bundle)) error {
+	s.writes++
+	return s.bundleStore.UpdateWithMetadata(ctx, uid, state, tarBytes, update)
+}
+
+func (s *writeCountingStore) UpdateMetadata(ctx context.Context, uid string, update func(bundle *supportbundles.Bundle)) error {
+	s.writes++
+	return s.bundleStore.UpdateMetadata(ctx, uid, update)
+}
+
+func TestEncryptedStore_Update(t *testing.T) {
+	ctx := context.Background()
+	sqlStore := db.InitTestDB(t)
+	secretsService := manager.SetupTestService(t, database.ProvideSecretsStore(sqlStore))
+	inner := &writeCountingStore{bundleStore: newStore(kvstore.ProvideService(sqlStore), defaultBundleExpiration)}
+	s := newEncryptedStore(inner, secretsService)
+
+	bundle, err := s.Create(ctx, &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+
+	t.Run("flags the archive as encrypted in the same write", func(t *testing.T) {
+		require.NoError(t, s.Update(ctx, bundle.UID, supportbundles.StateComplete, []byte("archive")))
+		require.Equal(t, 1, inner.writes)
+
+		stored, err := inner.Get(ctx, bundle.UID)
+		require.NoError(t, err)
+		require.Equal(t, supportbundles.StateComplete, stored.State)
+		require.True(t, stored.Encrypted)
+	})
+
+	t.Run("encrypts streamed archives in the same write", func(t *testing.T) {
+		inner.writes = 0
+		require.NoError(t, s.WriteArchive(ctx, bundle.UID, supportbundles.StatePartial, strings.NewReader("streamed")))
+		require.Equal(t, 1, inner.writes)
+
+		stored, err := inner.Get(ctx, bundle.UID)
+		require.NoError(t, err)
+		require.Equal(t, supportbundles.StatePartial, stored.State)
+		require.True(t, stored.Encrypted)
+		require.NotContains(t, string(stored.TarBytes), "streamed")
+	})
+
+	t.Run("refuses archives too large to be encrypted in memory", func(t *testing.T) {
+		s.maxSize = 4
+		defer func() { s.maxSize = maxEncryptedBundleSize }()
+
+		err := s.Update(ctx, bundle.UID, supportbundles.StateComplete, []byte("archive"))
+		require.ErrorIs(t, err, ErrEncryptedBundleTooLarge)
+		err = s.WriteArchive(ctx, bundle.UID, supportbundles.StateComplete, strings.NewReader("archive"))
+		require.ErrorIs(t, err, ErrEncryptedBundleTooLarge)
+	})
+
+	t.Run("refuses to decrypt archives larger than the limit", func(t *testing.T) {
+		s.maxSize = -maxEncryptionOverhead
+		defer func() { s.maxSize = maxEncryptedBundleSize }()
+
+		_, _, err := s.GetReader(ctx, bundle.UID)
+		require.ErrorIs(t, err, ErrEncryptedBundleTooLarge)
+	})
+}
@@ -0,0 +1,174 @@
+package supportbundlesimpl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// fileStore keeps bundle metadata in the KV store and the bundle archives on the filesystem.
+type fileStore struct {
+	*store
+	path string
+}
+
+func newFileStore(kv kvstore.KVStore, retention time.Duration, path string) (*fileStore, error) {
+	if err := os.MkdirAll(path, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create support bundle storage directory: %w", err)
+	}
+
+	return &fileStore{
+		store: newStore(kv, retention),
+		path:  path,
+	}, nil
+}
+
+func (s *fileStore) filePath(uid string, format string) string {
+	return filepath.Join(s.path, filepath.Base(uid)+archiveExtension(format))
+}
+
+func (s *fileStore) Update(ctx context.Context, uid string, state supportbundles.State, tarBytes []byte) error {
+	return s.UpdateWithMetadata(ctx, uid, state, tarBytes, nil)
+}
+
+func (s *fileStore) UpdateWithMetadata(ctx context.Context, uid string, state supportbundles.State, tarBytes []byte, update func(bundle *supportbundles.Bundle)) error {
+	size := int64(-1)
+	if tarBytes != nil {
+		var err error
+		if size, err = s.writeArchive(ctx, uid, bytes.NewReader(tarBytes)); err != nil {
+			return err
+		}
+	}
+	return s.store.update(ctx, uid, state, nil, size, update)
+}
+
+func (s *fileStore) WriteArchive(ctx context.Context, uid string, state supportbundles.State, archive io.Reader) error {
+	size, err := s.writeArchive(ctx, uid, archive)
+	if err != nil {
+		return err
+	}
+	return s.store.update(ctx, uid, state, nil, size, nil)
+}
+
+// writeArchive copies archive to the archive file of the bundle and returns its size.
+func (s *fileStore) writeArchive(ctx context.Context, uid string, archive io.Reader) (int64, error) {
+	bundle, err := s.store.Get(ctx, uid)
+	if err != nil {
+		return 0, err
+	}
+
+	// write to a temporary file first so a partially written archive is never served
+	path := s.filePath(uid, bundle.Format)
+	tmp := path + ".tmp"
+	// nolint:gosec
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(f, archive)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, err
+	}
+
+	// the bundle may have been archived in another format before it was retried
+	if err := s.removeArchives(uid, archiveExtension(bundle.Format)); err != nil {
+		s.log.Warn("Failed to remove previous support bundle archive", "uid", uid, "error", err)
+	}
+	return size, nil
+}
+
+func (s *fileStore) GetReader(ctx context.Context, uid string) (io.ReadCloser, int64, error) {
+	bundle, err := s.store.Get(ctx, uid)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// nolint:gosec
+	f, err := os.Open(s.filePath(uid, bundle.Format))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open support bundle archive: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, 0, err
+	}
+
+	return f, info.Size(), nil
+}
+
+func (s *fileStore) Remove(ctx context.Context, uid string) error {
+	if err := s.removeArchives(uid, ""); err != nil {
+		return err
+	}
+
+	return s.store.Remove(ctx, uid)
+}
+
+// removeArchives deletes the archives of the bundle in every format but the one
+// with the extension except.
+func (s *fileStore) removeArchives(uid string, except string) error {
+	for _, format := range archiveFormats {
+		if archiveExtension(format) == except {
+			continue
+		}
+		if err := os.Remove(s.filePath(uid, format)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveOrphans deletes archives on disk that no longer have a metadata entry.
+// The other files are left alone, storage_path may be shared, e.g. with the data path.
+func (s *fileStore) RemoveOrphans(ctx context.Context) error {
+	bundles, _, err := s.store.List(listQuery{})
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool, len(bundles))
+	for _, b := range bundles {
+		known[b.UID] = true
+	}
+
+	entries, err := os.ReadDir(s.path)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		uid, ok := archiveUID(strings.TrimSuffix(entry.Name(), ".tmp"))
+		if !ok || known[uid] {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(s.path, entry.Name())); err != nil {
+			s.log.Warn("Failed to remove orphaned support bundle file", "file", entry.Name(), "error", err)
+		}
+	}
+
+	return nil
+}
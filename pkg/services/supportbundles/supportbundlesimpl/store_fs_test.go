@@ -0,0 +1,134 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := newFileStore(kvstore.ProvideService(db.InitTestDB(t)), defaultBundleExpiration, dir)
+	require.NoError(t, err)
+
+	bundle, err := s.Create(ctx, &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Update(ctx, bundle.UID, supportbundles.StateComplete, []byte("archive")))
+
+	t.Run("archive is stored on disk and not in the KV store", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join(dir, bundle.UID+archiveExtension(formatTarGz)))
+		require.NoError(t, err)
+		require.Equal(t, []byte("archive"), data)
+
+		meta, err := s.store.Get(ctx, bundle.UID)
+		require.NoError(t, err)
+		require.Nil(t, meta.TarBytes)
+		require.Equal(t, int64(len("archive")), meta.Size)
+
+		r, size, err := s.GetReader(ctx, bundle.UID)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, r.Close()) }()
+
+		data, err = io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, []byte("archive"), data)
+		require.Equal(t, int64(len("archive")), size)
+	})
+
+	t.Run("the size is kept when the state changes without a new archive", func(t *testing.T) {
+		require.NoError(t, s.Update(ctx, bundle.UID, supportbundles.StatePartial, nil))
+
+		meta, err := s.store.Get(ctx, bundle.UID)
+		require.NoError(t, err)
+		require.Equal(t, int64(len("archive")), meta.Size)
+	})
+
+	t.Run("archives can be streamed to disk", func(t *testing.T) {
+		streamed, err := s.Create(ctx, &user.SignedInUser{Login: "admin"}, 0)
+		require.NoError(t, err)
+		require.NoError(t, s.WriteArchive(ctx, streamed.UID, supportbundles.StateComplete, strings.NewReader("streamed")))
+
+		data, err := os.ReadFile(filepath.Join(dir, streamed.UID+archiveExtension(formatTarGz)))
+		require.NoError(t, err)
+		require.Equal(t, []byte("streamed"), data)
+		require.NoFileExists(t, filepath.Join(dir, streamed.UID+archiveExtension(formatTarGz)+".tmp"))
+
+		meta, err := s.store.Get(ctx, streamed.UID)
+		require.NoError(t, err)
+		require.Equal(t, supportbundles.StateComplete, meta.State)
+		require.Nil(t, meta.TarBytes)
+		require.Equal(t, int64(len("streamed")), meta.Size)
+	})
+
+	t.Run("orphaned archives are removed", func(t *testing.T) {
+		orphan := filepath.Join(dir, "orphan"+archiveExtension(formatTarGz))
+		require.NoError(t, os.WriteFile(orphan, []byte("orphan"), 0o600))
+		orphanTmp := filepath.Join(dir, "interrupted"+archiveExtension(formatTarGz)+".tmp")
+		require.NoError(t, os.WriteFile(orphanTmp, []byte("orphan"), 0o600))
+
+		require.NoError(t, s.RemoveOrphans(ctx))
+		require.NoFileExists(t, orphan)
+		require.NoFileExists(t, orphanTmp)
+		require.FileExists(t, filepath.Join(dir, bundle.UID+archiveExtension(formatTarGz)))
+	})
+
+	t.Run("files other than archives are left alone", func(t *testing.T) {
+		// e.g. when storage_path is the data path
+		unrelated := []string{filepath.Join(dir, "grafana.db"), filepath.Join(dir, "notes.tmp")}
+		for _, path := range unrelated {
+			require.NoError(t, os.WriteFile(path, []byte("data"), 0o600))
+		}
+
+		require.NoError(t, s.RemoveOrphans(ctx))
+		for _, path := range unrelated {
+			require.FileExists(t, path)
+		}
+	})
+
+	t.Run("archives are stored with the extension of their format", func(t *testing.T) {
+		zipped, err := s.Create(ctx, &user.SignedInUser{Login: "admin"}, 0)
+		require.NoError(t, err)
+		require.NoError(t, s.UpdateMetadata(ctx, zipped.UID, func(b *supportbundles.Bundle) { b.Format = formatZip }))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, zipped.UID+archiveExtension(formatTarGz)), []byte("retried"), 0o600))
+		require.NoError(t, s.Update(ctx, zipped.UID, supportbundles.StateComplete, []byte("zip")))
+
+		require.FileExists(t, filepath.Join(dir, zipped.UID+".zip"))
+		// the archive of the previous attempt is replaced
+		require.NoFileExists(t, filepath.Join(dir, zipped.UID+archiveExtension(formatTarGz)))
+
+		r, _, err := s.GetReader(ctx, zipped.UID)
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, []byte("zip"), data)
+
+		orphan := filepath.Join(dir, "orphan.zip")
+		require.NoError(t, os.WriteFile(orphan, []byte("orphan"), 0o600))
+		require.NoError(t, s.RemoveOrphans(ctx))
+		require.NoFileExists(t, orphan)
+		require.FileExists(t, filepath.Join(dir, zipped.UID+".zip"))
+
+		require.NoError(t, s.Remove(ctx, zipped.UID))
+		require.NoFileExists(t, filepath.Join(dir, zipped.UID+".zip"))
+	})
+
+	t.Run("remove deletes both metadata and archive", func(t *testing.T) {
+		require.NoError(t, s.Remove(ctx, bundle.UID))
+		require.NoFileExists(t, filepath.Join(dir, bundle.UID+archiveExtension(formatTarGz)))
+
+		_, err := s.store.Get(ctx, bundle.UID)
+		require.Error(t, err)
+	})
+}
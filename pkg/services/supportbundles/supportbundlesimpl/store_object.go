@@ -0,0 +1,181 @@
+package supportbundlesimpl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gocloud.dev/blob"
+	// register the object storage drivers usable in support_bundles.object_storage_url
+	_ "gocloud.dev/blob/azureblob"
+	_ "gocloud.dev/blob/fileblob"
+	_ "gocloud.dev/blob/s3blob"
+	"gocloud.dev/gcerrors"
+
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// objectStore keeps bundle metadata in the KV store and the bundle archives in an
+// object storage bucket, so that every replica of an HA setup can serve them.
+type objectStore struct {
+	*store
+	bucket *blob.Bucket
+}
+
+// newObjectStore opens the bucket described by url, e.g. s3://bucket?region=us-east-1
+// or azblob://container. Archives are stored under prefix, which is required so
+// that orphaned archives can be told apart from the other objects of the bucket.
+func newObjectStore(ctx context.Context, kv kvstore.KVStore, retention time.Duration, url string, prefix string) (*objectStore, error) {
+	if url == "" {
+		return nil, fmt.Errorf("support_bundles.object_storage_url is required for object storage")
+	}
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return nil, fmt.Errorf("support_bundles.object_storage_prefix is required for object storage, bundles can't be stored at the root of the bucket")
+	}
+
+	bucket, err := blob.OpenBucket(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open support bundle bucket: %w", err)
+	}
+	bucket = blob.PrefixedBucket(bucket, prefix+"/")
+
+	return &objectStore{
+		store:  newStore(kv, retention),
+		bucket: bucket,
+	}, nil
+}
+
+func (s *objectStore) Update(ctx context.Context, uid string, state supportbundles.State, tarBytes []byte) error {
+	return s.UpdateWithMetadata(ctx, uid, state, tarBytes, nil)
+}
+
+func (s *objectStore) UpdateWithMetadata(ctx context.Context, uid string, state supportbundles.State, tarBytes []byte, update func(bundle *supportbundles.Bundle)) error {
+	size := int64(-1)
+	if tarBytes != nil {
+		var err error
+		if size, err = s.writeArchive(ctx, uid, bytes.NewReader(tarBytes)); err != nil {
+			return err
+		}
+	}
+	return s.store.update(ctx, uid, state, nil, size, update)
+}
+
+func (s *objectStore) WriteArchive(ctx context.Context, uid string, state supportbundles.State, archive io.Reader) error {
+	size, err := s.writeArchive(ctx, uid, archive)
+	if err != nil {
+		return err
+	}
+	return s.store.update(ctx, uid, state, nil, size, nil)
+}
+
+// writeArchive uploads archive to the object of the bundle and returns its size.
+func (s *objectStore) writeArchive(ctx context.Context, uid string, archive io.Reader) (int64, error) {
+	bundle, err := s.store.Get(ctx, uid)
+	if err != nil {
+		return 0, err
+	}
+
+	// cancelling the context of the writer discards the partially written object
+	writeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w, err := s.bucket.NewWriter(writeCtx, uid+archiveExtension(bundle.Format), &blob.WriterOptions{
+		ContentType: archiveContentType(bundle.Format),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to upload support bundle archive: %w", err)
+	}
+	size, err := io.Copy(w, archive)
+	if err != nil {
+		cancel()
+		_ = w.Close()
+		return 0, fmt.Errorf("failed to upload support bundle archive: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return 0, fmt.Errorf("failed to upload support bundle archive: %w", err)
+	}
+
+	// the bundle may have been archived in another format before it was retried
+	if err := s.removeArchives(ctx, uid, archiveExtension(bundle.Format)); err != nil {
+		s.log.Warn("Failed to remove previous support bundle archive", "uid", uid, "error", err)
+	}
+	return size, nil
+}
+
+// GetReader streams the bundle archive from the bucket, so downloads can be
+// proxied without buffering the object in memory.
+func (s *objectStore) GetReader(ctx context.Context, uid string) (io.ReadCloser, int64, error) {
+	bundle, err := s.store.Get(ctx, uid)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	r, err := s.bucket.NewReader(ctx, uid+archiveExtension(bundle.Format), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to download support bundle archive: %w", err)
+	}
+	return r, r.Size(), nil
+}
+
+func (s *objectStore) Remove(ctx context.Context, uid string) error {
+	if err := s.removeArchives(ctx, uid, ""); err != nil {
+		return err
+	}
+
+	return s.store.Remove(ctx, uid)
+}
+
+// removeArchives deletes the archives of the bundle in every format but the one
+// with the extension except.
+func (s *objectStore) removeArchives(ctx context.Context, uid string, except string) error {
+	for _, format := range archiveFormats {
+		if archiveExtension(format) == except {
+			continue
+		}
+		if err := s.bucket.Delete(ctx, uid+archiveExtension(format)); err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveOrphans deletes archives in the bucket that no longer have a metadata
+// entry. The other objects under the prefix are left alone.
+func (s *objectStore) RemoveOrphans(ctx context.Context) error {
+	bundles, _, err := s.store.List(listQuery{})
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool, len(bundles))
+	for _, b := range bundles {
+		known[b.UID] = true
+	}
+
+	iter := s.bucket.List(nil)
+	for {
+		obj, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if obj.IsDir {
+			continue
+		}
+		if uid, ok := archiveUID(obj.Key); !ok || known[uid] {
+			continue
+		}
+
+		if err := s.bucket.Delete(ctx, obj.Key); err != nil {
+			s.log.Warn("Failed to remove orphaned support bundle object", "key", obj.Key, "error", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	_ "gocloud.dev/blob/memblob"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestNewObjectStore_RequiresPrefix(t *testing.T) {
+	for _, prefix := range []string{"", "/"} {
+		_, err := newObjectStore(context.Background(), kvstore.ProvideService(db.InitTestDB(t)), defaultBundleExpiration, "mem://", prefix)
+		require.ErrorContains(t, err, "object_storage_prefix is required")
+	}
+}
+
+func TestObjectStore(t *testing.T) {
+	ctx := context.Background()
+	s, err := newObjectStore(ctx, kvstore.ProvideService(db.InitTestDB(t)), defaultBundleExpiration, "mem://", "bundles")
+	require.NoError(t, err)
+
+	bundle, err := s.Create(ctx, &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Update(ctx, bundle.UID, supportbundles.StateComplete, []byte("archive")))
+
+	t.Run("archive can be streamed from the bucket", func(t *testing.T) {
+		r, size, err := s.GetReader(ctx, bundle.UID)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, r.Close()) }()
+
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, []byte("archive"), data)
+		require.Equal(t, int64(len("archive")), size)
+	})
+
+	t.Run("archives can be streamed to the bucket", func(t *testing.T) {
+		streamed, err := s.Create(ctx, &user.SignedInUser{Login: "admin"}, 0)
+		require.NoError(t, err)
+		require.NoError(t, s.WriteArchive(ctx, streamed.UID, supportbundles.StateComplete, strings.NewReader("streamed")))
+
+		data, err := s.bucket.ReadAll(ctx, streamed.UID+archiveExtension(formatTarGz))
+		require.NoError(t, err)
+		require.Equal(t, []byte("streamed"), data)
+
+		meta, err := s.store.Get(ctx, streamed.UID)
+		require.NoError(t, err)
+		require.Equal(t, int64(len("streamed")), meta.Size)
+	})
+
+	t.Run("orphaned objects are removed", func(t *testing.T) {
+		require.NoError(t, s.bucket.WriteAll(ctx, "orphan"+archiveExtension(formatTarGz), []byte("orphan"), nil))
+		require.NoError(t, s.RemoveOrphans(ctx))
+
+		exists, err := s.bucket.Exists(ctx, "orphan"+archiveExtension(formatTarGz))
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+
+	t.Run("objects other than archives are left alone", func(t *testing.T) {
+		require.NoError(t, s.bucket.WriteAll(ctx, "reports/weekly.csv", []byte("data"), nil))
+		require.NoError(t, s.RemoveOrphans(ctx))
+
+		exists, err := s.bucket.Exists(ctx, "reports/weekly.csv")
+		require.NoError(t, err)
+		require.True(t, exists)
+	})
+
+	t.Run("archives are stored with the extension and content type of their format", func(t *testing.T) {
+		zipped, err := s.Create(ctx, &user.SignedInUser{Login: "admin"}, 0)
+		require.NoError(t, err)
+		require.NoError(t, s.UpdateMetadata(ctx, zipped.UID, func(b *supportbundles.Bundle) { b.Format = formatZip }))
+		require.NoError(t, s.Update(ctx, zipped.UID, supportbundles.StateComplete, []byte("zip")))
+
+		attrs, err := s.bucket.Attributes(ctx, zipped.UID+".zip")
+		require.NoError(t, err)
+		require.Equal(t, "application/zip", attrs.ContentType)
+
+		r, _, err := s.GetReader(ctx, zipped.UID)
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, []byte("zip"), data)
+
+		require.NoError(t, s.Remove(ctx, zipped.UID))
+		exists, err := s.bucket.Exists(ctx, zipped.UID+".zip")
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+
+	t.Run("remove deletes both metadata and object", func(t *testing.T) {
+		require.NoError(t, s.Remove(ctx, bundle.UID))
+
+		exists, err := s.bucket.Exists(ctx, bundle.UID+archiveExtension(formatTarGz))
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+}
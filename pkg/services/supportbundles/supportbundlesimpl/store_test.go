@@ -0,0 +1,231 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestStore_CreateRetention(t *testing.T) {
+	s := newStore(kvstore.ProvideService(db.InitTestDB(t)), time.Hour)
+	usr := &user.SignedInUser{Login: "admin"}
+
+	t.Run("uses the configured retention by default", func(t *testing.T) {
+		b, err := s.Create(context.Background(), usr, 0)
+		require.NoError(t, err)
+		require.Equal(t, int64(time.Hour.Seconds()), b.ExpiresAt-b.CreatedAt)
+	})
+
+	t.Run("uses the per bundle retention when set", func(t *testing.T) {
+		b, err := s.Create(context.Background(), usr, 24*time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, int64((24 * time.Hour).Seconds()), b.ExpiresAt-b.CreatedAt)
+	})
+}
+
+func TestStore_CreateUID(t *testing.T) {
+	usr := &user.SignedInUser{Login: "admin"}
+	taken := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	free := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+
+	t.Run("prefixes the UIDs", func(t *testing.T) {
+		s := newStore(kvstore.ProvideService(db.InitTestDB(t)), time.Hour)
+		s.setUIDPrefix("staging-")
+
+		b, err := s.Create(context.Background(), usr, 0)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(b.UID, "staging-"))
+		_, err = uuid.Parse(strings.TrimPrefix(b.UID, "staging-"))
+		require.NoError(t, err)
+	})
+
+	t.Run("retries on collisions", func(t *testing.T) {
+		s := newStore(kvstore.ProvideService(db.InitTestDB(t)), time.Hour)
+		require.NoError(t, s.set(context.Background(), &supportbundles.Bundle{UID: taken.String(), State: supportbundles.StateComplete}))
+
+		var generated int
+		s.newUID = func() (uuid.UUID, error) {
+			generated++
+			if generated == 1 {
+				return taken, nil
+			}
+			return free, nil
+		}
+
+		b, err := s.Create(context.Background(), usr, 0)
+		require.NoError(t, err)
+		require.Equal(t, 2, generated)
+		require.Equal(t, free.String(), b.UID)
+
+		existing, err := s.Get(context.Background(), taken.String())
+		require.NoError(t, err)
+		require.Equal(t, supportbundles.StateComplete, existing.State)
+	})
+
+	t.Run("gives up after repeated collisions", func(t *testing.T) {
+		s := newStore(kvstore.ProvideService(db.InitTestDB(t)), time.Hour)
+		require.NoError(t, s.set(context.Background(), &supportbundles.Bundle{UID: taken.String()}))
+		s.newUID = func() (uuid.UUID, error) { return taken, nil }
+
+		_, err := s.Create(context.Background(), usr, 0)
+		require.Error(t, err)
+	})
+}
+
+func TestProvideStore_UIDPrefix(t *testing.T) {
+	kv := kvstore.ProvideService(db.InitTestDB(t))
+	usr := &user.SignedInUser{Login: "admin"}
+
+	for _, storage := range []string{"kvstore", "filesystem"} {
+		t.Run(storage, func(t *testing.T) {
+			cfg := setting.NewCfg()
+			cfg.DataPath = t.TempDir()
+			cfg.Raw.Section("support_bundles").Key("storage").SetValue(storage)
+			cfg.Raw.Section("support_bundles").Key("uid_prefix").SetValue("prod-")
+
+			s, err := provideStore(cfg, kv)
+			require.NoError(t, err)
+			b, err := s.Create(context.Background(), usr, 0)
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(b.UID, "prod-"))
+		})
+	}
+
+	t.Run("rejects invalid prefixes", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.Raw.Section("support_bundles").Key("uid_prefix").SetValue("prod/")
+
+		_, err := provideStore(cfg, kv)
+		require.Error(t, err)
+	})
+}
+
+func TestValidateUIDPrefix(t *testing.T) {
+	for _, prefix := range []string{"", "prod-", "eu_west_1-"} {
+		require.NoError(t, validateUIDPrefix(prefix), prefix)
+	}
+	for _, prefix := range []string{"../", "prod/", "prod ", "prod?", strings.Repeat("a", maxUIDPrefixLength+1)} {
+		require.Error(t, validateUIDPrefix(prefix), prefix)
+	}
+}
+
+func TestStore_List(t *testing.T) {
+	s := newStore(kvstore.ProvideService(db.InitTestDB(t)), time.Hour)
+	for _, b := range []supportbundles.Bundle{
+		{UID: "old", State: supportbundles.StateComplete, CreatedAt: 1, ExpiresAt: 30},
+		{UID: "mid", State: supportbundles.StateError, CreatedAt: 2, ExpiresAt: 10, Tags: map[string]string{"incident": "INC-1"}},
+		{UID: "new", State: supportbundles.StateComplete, CreatedAt: 3, ExpiresAt: 20, TarBytes: []byte("archive")},
+	} {
+		b := b
+		require.NoError(t, s.set(context.Background(), &b))
+	}
+
+	uids := func(bundles []supportbundles.Bundle) []string {
+		uids := make([]string, 0, len(bundles))
+		for _, b := range bundles {
+			uids = append(uids, b.UID)
+		}
+		return uids
+	}
+
+	testCases := []struct {
+		desc  string
+		query listQuery
+		uids  []string
+		total int
+	}{
+		{desc: "newest first by default", query: listQuery{}, uids: []string{"new", "mid", "old"}, total: 3},
+		{desc: "oldest first", query: listQuery{Ascending: true}, uids: []string{"old", "mid", "new"}, total: 3},
+		{desc: "by expiry", query: listQuery{Sort: listSortExpiresAt, Ascending: true}, uids: []string{"mid", "new", "old"}, total: 3},
+		{desc: "by state, newest first within a state", query: listQuery{Sort: listSortState}, uids: []string{"mid", "new", "old"}, total: 3},
+		{desc: "first page", query: listQuery{Limit: 2, Page: 1}, uids: []string{"new", "mid"}, total: 3},
+		{desc: "last page", query: listQuery{Limit: 2, Page: 2}, uids: []string{"old"}, total: 3},
+		{desc: "past the last page", query: listQuery{Limit: 2, Page: 3}, uids: []string{}, total: 3},
+		{desc: "by tag", query: listQuery{Tags: map[string]string{"incident": ""}}, uids: []string{"mid"}, total: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			bundles, total, err := s.List(tc.query)
+			require.NoError(t, err)
+			require.Equal(t, tc.uids, uids(bundles))
+			require.Equal(t, tc.total, total)
+			for _, b := range bundles {
+				require.Nil(t, b.TarBytes)
+			}
+		})
+	}
+
+	t.Run("unknown sort is invalid", func(t *testing.T) {
+		require.ErrorIs(t, listQuery{Sort: "size"}.validate(), ErrInvalidListQuery)
+	})
+}
+
+func TestStore_ConcurrentUpdates(t *testing.T) {
+	s := newStore(kvstore.ProvideService(db.InitTestDB(t)), time.Hour)
+	b, err := s.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(progress int) {
+			defer wg.Done()
+			require.NoError(t, s.UpdateProgress(context.Background(), b.UID, progress, "collector"))
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		require.NoError(t, s.Update(context.Background(), b.UID, supportbundles.StateComplete, []byte("archive")))
+	}()
+	wg.Wait()
+
+	// progress updates only change the progress, they never revert the final update
+	stored, err := s.Get(context.Background(), b.UID)
+	require.NoError(t, err)
+	require.Equal(t, supportbundles.StateComplete, stored.State)
+	require.Equal(t, []byte("archive"), stored.TarBytes)
+}
+
+func TestStore_ConcurrentMetadataUpdates(t *testing.T) {
+	s := newStore(kvstore.ProvideService(db.InitTestDB(t)), time.Hour)
+	b, err := s.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+	expiresAt := b.ExpiresAt + 3600
+
+	updating := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		// e.g. the retention being extended while the bundle is collected
+		require.NoError(t, s.UpdateMetadata(context.Background(), b.UID, func(bundle *supportbundles.Bundle) {
+			close(updating)
+			time.Sleep(50 * time.Millisecond)
+			bundle.ExpiresAt = expiresAt
+		}))
+	}()
+	go func() {
+		defer wg.Done()
+		<-updating
+		require.NoError(t, s.UpdateProgress(context.Background(), b.UID, 50, "collector"))
+	}()
+	wg.Wait()
+
+	stored, err := s.Get(context.Background(), b.UID)
+	require.NoError(t, err)
+	require.Equal(t, expiresAt, stored.ExpiresAt)
+	require.Equal(t, 50, stored.Progress, "the progress update was lost")
+}
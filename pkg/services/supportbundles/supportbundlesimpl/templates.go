@@ -0,0 +1,199 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+)
+
+var (
+	ErrTemplateNotFound  = errors.New("support bundle template not found")
+	ErrInvalidTemplate   = errors.New("invalid support bundle template")
+	ErrTemplateNameTaken = errors.New("a support bundle template with this name already exists")
+)
+
+// bundleTemplate is a reusable list of collectors and tags defined by an
+// organization, so that its teams create bundles with the same contents.
+type bundleTemplate struct {
+	UID   string `json:"uid"`
+	OrgID int64  `json:"orgId"`
+	Name  string `json:"name"`
+	// Collectors are run along with the ones requested when creating a bundle from the template.
+	Collectors []string `json:"collectors"`
+	// Tags are set on the bundles created from the template, the tags requested take precedence.
+	Tags      map[string]string `json:"tags,omitempty"`
+	CreatedAt int64             `json:"createdAt"`
+	UpdatedAt int64             `json:"updatedAt"`
+}
+
+// templateStore keeps the templates in the KV store, in a namespace per organization
+// so that an organization never reads or changes the templates of another one.
+type templateStore struct {
+	kv kvstore.KVStore
+}
+
+func newTemplateStore(kv kvstore.KVStore) *templateStore {
+	return &templateStore{kv: kv}
+}
+
+func (s *templateStore) orgKV(orgID int64) (*kvstore.NamespacedKVStore, error) {
+	// the KV store reads every organization for non positive IDs
+	if orgID <= 0 {
+		return nil, fmt.Errorf("%w: templates belong to an organization", ErrInvalidTemplate)
+	}
+	return kvstore.WithNamespace(s.kv, orgID, "supportbundletemplate"), nil
+}
+
+// list returns the templates of an organization, sorted by name.
+func (s *templateStore) list(ctx context.Context, orgID int64) ([]bundleTemplate, error) {
+	kv, err := s.orgKV(orgID)
+	if err != nil {
+		return nil, err
+	}
+	all, err := kv.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make([]bundleTemplate, 0)
+	for _, data := range all[orgID] {
+		var t bundleTemplate
+		if err := json.Unmarshal([]byte(data), &t); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, nil
+}
+
+func (s *templateStore) get(ctx context.Context, orgID int64, uid string) (*bundleTemplate, error) {
+	kv, err := s.orgKV(orgID)
+	if err != nil {
+		return nil, err
+	}
+	data, ok, err := kv.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, uid)
+	}
+
+	var t bundleTemplate
+	if err := json.Unmarshal([]byte(data), &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// save creates the template if its UID is empty and replaces it otherwise. The
+// template must exist in the organization to be replaced.
+func (s *templateStore) save(ctx context.Context, t *bundleTemplate) error {
+	kv, err := s.orgKV(t.OrgID)
+	if err != nil {
+		return err
+	}
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return fmt.Errorf("%w: the name is required", ErrInvalidTemplate)
+	}
+
+	existing, err := s.list(ctx, t.OrgID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	found := t.UID == ""
+	for _, e := range existing {
+		if e.UID == t.UID {
+			found = true
+			t.CreatedAt = e.CreatedAt
+		} else if strings.EqualFold(e.Name, t.Name) {
+			return fmt.Errorf("%w: %s", ErrTemplateNameTaken, t.Name)
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, t.UID)
+	}
+	if t.UID == "" {
+		t.UID = uuid.NewString()
+		t.CreatedAt = now
+	}
+	t.UpdatedAt = now
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return kv.Set(ctx, t.UID, string(data))
+}
+
+func (s *templateStore) delete(ctx context.Context, orgID int64, uid string) error {
+	if _, err := s.get(ctx, orgID, uid); err != nil {
+		return err
+	}
+	kv, err := s.orgKV(orgID)
+	if err != nil {
+		return err
+	}
+	return kv.Del(ctx, uid)
+}
+
+// withTemplate adds the collectors and tags of a template of the organization to
+// the requested ones, if a template is given. The requested tags take precedence.
+func (s *Service) withTemplate(ctx context.Context, orgID int64, uid string, collectors []string, tags map[string]string) ([]string, map[string]string, error) {
+	if uid == "" {
+		return collectors, tags, nil
+	}
+
+	t, err := s.templates.get(ctx, orgID, uid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	merged := append([]string{}, t.Collectors...)
+	seen := make(map[string]bool, len(merged))
+	for _, uid := range merged {
+		seen[uid] = true
+	}
+	for _, uid := range collectors {
+		if !seen[uid] {
+			merged = append(merged, uid)
+			seen[uid] = true
+		}
+	}
+
+	mergedTags := make(map[string]string, len(t.Tags)+len(tags))
+	for k, v := range t.Tags {
+		mergedTags[k] = v
+	}
+	for k, v := range tags {
+		mergedTags[k] = v
+	}
+	if len(mergedTags) == 0 {
+		mergedTags = nil
+	}
+	return merged, mergedTags, nil
+}
+
+// saveTemplate validates the collectors and tags of a template before saving it.
+func (s *Service) saveTemplate(ctx context.Context, t *bundleTemplate) error {
+	if err := s.validateCollectors(t.Collectors); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidTemplate, err)
+	}
+	if err := validateTags(t.Tags); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidTemplate, err)
+	}
+	return s.templates.save(ctx, t)
+}
@@ -0,0 +1,114 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestTemplateStore(t *testing.T) {
+	ctx := context.Background()
+	store := newTemplateStore(kvstore.ProvideService(db.InitTestDB(t)))
+
+	incident := &bundleTemplate{OrgID: 1, Name: "incident", Collectors: []string{"basic"}}
+	require.NoError(t, store.save(ctx, incident))
+	require.NotEmpty(t, incident.UID)
+	require.NotZero(t, incident.CreatedAt)
+	require.NoError(t, store.save(ctx, &bundleTemplate{OrgID: 1, Name: "alerting", Collectors: []string{"db"}}))
+	require.NoError(t, store.save(ctx, &bundleTemplate{OrgID: 2, Name: "incident", Collectors: []string{"tls"}}))
+
+	t.Run("lists the templates of an organization only", func(t *testing.T) {
+		templates, err := store.list(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, templates, 2)
+		require.Equal(t, "alerting", templates[0].Name)
+		require.Equal(t, "incident", templates[1].Name)
+
+		templates, err = store.list(ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, templates, 1)
+		require.Equal(t, []string{"tls"}, templates[0].Collectors)
+	})
+
+	t.Run("isolates organizations", func(t *testing.T) {
+		_, err := store.get(ctx, 2, incident.UID)
+		require.ErrorIs(t, err, ErrTemplateNotFound)
+
+		err = store.save(ctx, &bundleTemplate{UID: incident.UID, OrgID: 2, Name: "stolen"})
+		require.ErrorIs(t, err, ErrTemplateNotFound)
+
+		require.ErrorIs(t, store.delete(ctx, 2, incident.UID), ErrTemplateNotFound)
+
+		_, err = store.list(ctx, 0)
+		require.ErrorIs(t, err, ErrInvalidTemplate)
+
+		stored, err := store.get(ctx, 1, incident.UID)
+		require.NoError(t, err)
+		require.Equal(t, "incident", stored.Name)
+	})
+
+	t.Run("rejects duplicate and empty names", func(t *testing.T) {
+		require.ErrorIs(t, store.save(ctx, &bundleTemplate{OrgID: 1, Name: "Incident"}), ErrTemplateNameTaken)
+		require.ErrorIs(t, store.save(ctx, &bundleTemplate{OrgID: 1, Name: " "}), ErrInvalidTemplate)
+	})
+
+	t.Run("updates and deletes templates", func(t *testing.T) {
+		updated := &bundleTemplate{UID: incident.UID, OrgID: 1, Name: "incident", Collectors: []string{"basic", "tls"}}
+		require.NoError(t, store.save(ctx, updated))
+		require.Equal(t, incident.CreatedAt, updated.CreatedAt)
+
+		stored, err := store.get(ctx, 1, incident.UID)
+		require.NoError(t, err)
+		require.Equal(t, []string{"basic", "tls"}, stored.Collectors)
+
+		require.NoError(t, store.delete(ctx, 1, incident.UID))
+		_, err = store.get(ctx, 1, incident.UID)
+		require.ErrorIs(t, err, ErrTemplateNotFound)
+	})
+}
+
+func TestService_create_Template(t *testing.T) {
+	item := func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "item.txt", FileBytes: []byte("item")}, nil
+	}
+	optional := func(uid string) supportbundles.Collector {
+		c := newTestCollector(uid, item)
+		c.IncludedByDefault = false
+		return c
+	}
+	s := newTestService(t, optional("basic"), optional("settings"), optional("tls"))
+	s.templates = newTemplateStore(kvstore.ProvideService(db.InitTestDB(t)))
+
+	ctx := context.Background()
+	tmpl := &bundleTemplate{OrgID: 1, Name: "network", Collectors: []string{"basic", "tls"}, Tags: map[string]string{"team": "sre", "source": "template"}}
+	require.NoError(t, s.saveTemplate(ctx, tmpl))
+
+	t.Run("rejects templates with unknown collectors", func(t *testing.T) {
+		err := s.saveTemplate(ctx, &bundleTemplate{OrgID: 1, Name: "broken", Collectors: []string{"nope"}})
+		require.ErrorIs(t, err, ErrInvalidTemplate)
+	})
+
+	t.Run("rejects templates of other organizations", func(t *testing.T) {
+		_, err := s.create(ctx, &user.SignedInUser{Login: "admin", OrgID: 2}, createOptions{Template: tmpl.UID})
+		require.ErrorIs(t, err, ErrTemplateNotFound)
+	})
+
+	t.Run("adds the collectors and tags of the template", func(t *testing.T) {
+		bundle, err := s.create(ctx, &user.SignedInUser{Login: "admin", OrgID: 1}, createOptions{
+			Template:   tmpl.UID,
+			Collectors: []string{"settings", "basic"},
+			Tags:       map[string]string{"source": "request"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"basic", "settings", "tls"}, bundle.Collectors)
+		require.Equal(t, map[string]string{"team": "sre", "source": "request"}, bundle.Tags)
+		require.Eventually(t, func() bool { return len(s.creationSlots) == 0 }, 5*time.Second, 10*time.Millisecond)
+	})
+}
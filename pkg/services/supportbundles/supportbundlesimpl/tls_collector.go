@@ -0,0 +1,123 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// tlsExpiryWarning is how long before their expiry certificates are reported as expiring soon.
+const tlsExpiryWarning = 30 * 24 * time.Hour
+
+type tlsCertificate struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	DNSNames     []string  `json:"dns_names,omitempty"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	Expired      bool      `json:"expired"`
+	ExpiringSoon bool      `json:"expiring_soon"` // ExpiringSoon is set when the certificate expires in less than 30 days.
+}
+
+type tlsCertificateFile struct {
+	Source string `json:"source"`
+	Path   string `json:"path"`
+	// Chain are the certificates of the file, in order.
+	Chain []tlsCertificate `json:"chain,omitempty"`
+	Error string           `json:"error,omitempty"`
+}
+
+func tlsCollector(cfg *setting.Cfg) supportbundles.Collector {
+	return supportbundles.Collector{
+		UID:               "tls",
+		DisplayName:       "TLS certificates",
+		Description:       "Subject, issuer and expiry of the server and database certificates. Private keys are never read",
+		IncludedByDefault: false,
+		Default:           true,
+		Fn: func(ctx context.Context) (*supportbundles.SupportItem, error) {
+			type tlsInfo struct {
+				Protocol string               `json:"protocol"`
+				Files    []tlsCertificateFile `json:"files"`
+			}
+
+			database := cfg.Raw.Section("database")
+			sources := []struct{ source, path string }{
+				{"server.cert_file", cfg.CertFile},
+				{"database.ca_cert_path", database.Key("ca_cert_path").String()},
+				{"database.client_cert_path", database.Key("client_cert_path").String()},
+			}
+
+			// private keys, e.g. server.cert_key, are never read. Data source client
+			// certificates are encrypted in the database and are not inspected.
+			info := tlsInfo{Protocol: string(cfg.Protocol), Files: []tlsCertificateFile{}}
+			now := time.Now()
+			for _, s := range sources {
+				if s.path == "" {
+					continue
+				}
+				info.Files = append(info.Files, readCertificateFile(s.source, s.path, now))
+			}
+
+			data, err := json.Marshal(info)
+			if err != nil {
+				return nil, err
+			}
+
+			return &supportbundles.SupportItem{
+				Filename:  "tls.json",
+				FileBytes: data,
+			}, nil
+		},
+	}
+}
+
+// readCertificateFile reports the certificates of a PEM file. Any block that
+// isn't a certificate, such as a private key bundled in the same file, is skipped.
+func readCertificateFile(source, path string, now time.Time) tlsCertificateFile {
+	file := tlsCertificateFile{Source: source, Path: path}
+
+	// the path is read from the server configuration, not from user input
+	// nolint:gosec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		file.Error = err.Error()
+		return file
+	}
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			file.Error = err.Error()
+			continue
+		}
+		file.Chain = append(file.Chain, tlsCertificate{
+			Subject:      cert.Subject.String(),
+			Issuer:       cert.Issuer.String(),
+			DNSNames:     cert.DNSNames,
+			NotBefore:    cert.NotBefore.UTC(),
+			NotAfter:     cert.NotAfter.UTC(),
+			Expired:      now.After(cert.NotAfter),
+			ExpiringSoon: now.Add(tlsExpiryWarning).After(cert.NotAfter),
+		})
+	}
+
+	if len(file.Chain) == 0 && file.Error == "" {
+		file.Error = "no certificate found"
+	}
+	return file
+}
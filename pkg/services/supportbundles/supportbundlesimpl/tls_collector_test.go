@@ -0,0 +1,74 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestTLSCollector(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "grafana.example.com"},
+		DNSNames:     []string{"grafana.example.com"},
+		NotBefore:    time.Now().Add(-48 * time.Hour),
+		NotAfter:     time.Now().Add(-24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	// the private key is bundled in the same file as the certificate
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	certFile := filepath.Join(t.TempDir(), "grafana.pem")
+	require.NoError(t, os.WriteFile(certFile, append(keyPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...), 0o600))
+
+	cfg := setting.NewCfg()
+	cfg.Protocol = setting.HTTPSScheme
+	cfg.CertFile = certFile
+	cfg.KeyFile = certFile
+	cfg.Raw.Section("database").Key("ca_cert_path").SetValue(filepath.Join(t.TempDir(), "missing.pem"))
+
+	item, err := tlsCollector(cfg).Fn(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "tls.json", item.Filename)
+	require.NotContains(t, string(item.FileBytes), "PRIVATE KEY")
+
+	var info struct {
+		Protocol string               `json:"protocol"`
+		Files    []tlsCertificateFile `json:"files"`
+	}
+	// as written to the bundle
+	require.NoError(t, json.Unmarshal(newRedactor(defaultRedactKeys).redactSecrets(item.Filename, item.FileBytes), &info))
+	require.Equal(t, "https", info.Protocol)
+	require.Len(t, info.Files, 2)
+
+	server := info.Files[0]
+	require.Equal(t, "server.cert_file", server.Source)
+	require.Empty(t, server.Error)
+	require.Len(t, server.Chain, 1)
+	require.Equal(t, "CN=grafana.example.com", server.Chain[0].Subject)
+	require.True(t, server.Chain[0].Expired)
+	require.True(t, server.Chain[0].ExpiringSoon)
+
+	require.Equal(t, "database.ca_cert_path", info.Files[1].Source)
+	require.NotEmpty(t, info.Files[1].Error)
+}
@@ -0,0 +1,174 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+const (
+	// creationTokenHeader carries the token that authorizes a bundle creation without a session.
+	creationTokenHeader = "X-Grafana-Support-Bundle-Token"
+	// creationTokenPurpose scopes the signing key, so that the instance secret
+	// isn't used as is and other signatures can't be replayed as tokens.
+	creationTokenPurpose = "support-bundle-creation"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid support bundle token")
+	ErrTokenUsed    = errors.New("support bundle token has already been used")
+)
+
+// creationTokenClaims are signed into a token. The token only allows creating a
+// bundle in OrgID, on behalf of Creator, until ExpiresAt.
+type creationTokenClaims struct {
+	Creator   string `json:"creator"`
+	OrgID     int64  `json:"orgId"`
+	ExpiresAt int64  `json:"exp"`
+	Nonce     string `json:"nonce"`
+}
+
+// tokenSigner mints and verifies the short-lived tokens that let automation create
+// bundles without an interactive session, e.g. from a support script. Tokens
+// can be used once, the nonces of the used ones are kept until they expire.
+type tokenSigner struct {
+	key []byte
+	ttl time.Duration
+
+	// usedMu serializes the redemptions of this instance, replicas sharing the
+	// KV store can still both accept a token presented to them at the same time.
+	usedMu sync.Mutex
+	used   *kvstore.NamespacedKVStore
+}
+
+// newTokenSigner returns nil when ttl isn't positive, disabling creation tokens.
+func newTokenSigner(secretKey string, ttl time.Duration, kv kvstore.KVStore) *tokenSigner {
+	if ttl <= 0 {
+		return nil
+	}
+
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(creationTokenPurpose))
+	return &tokenSigner{
+		key:  mac.Sum(nil),
+		ttl:  ttl,
+		used: kvstore.WithNamespace(kv, 0, "supportbundletokens"),
+	}
+}
+
+// mint returns a token allowing to create a bundle on behalf of usr, and when it expires.
+func (t *tokenSigner) mint(usr *user.SignedInUser, now time.Time) (string, time.Time, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := now.Add(t.ttl)
+	payload, err := json.Marshal(creationTokenClaims{
+		Creator:   usr.Login,
+		OrgID:     usr.OrgID,
+		ExpiresAt: expiresAt.Unix(),
+		Nonce:     hex.EncodeToString(nonce),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(t.sign(encoded)), expiresAt, nil
+}
+
+// verify checks the signature and the expiry of token and returns its claims.
+func (t *tokenSigner) verify(token string, now time.Time) (*creationTokenClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, t.sign(encoded)) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims creationTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Creator == "" || claims.Nonce == "" || !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims, nil
+}
+
+// redeem marks the verified token with claims as used, it returns ErrTokenUsed
+// if it already was.
+func (t *tokenSigner) redeem(ctx context.Context, claims *creationTokenClaims) error {
+	t.usedMu.Lock()
+	defer t.usedMu.Unlock()
+
+	_, used, err := t.used.Get(ctx, claims.Nonce)
+	if err != nil {
+		return err
+	}
+	if used {
+		return ErrTokenUsed
+	}
+	return t.used.Set(ctx, claims.Nonce, strconv.FormatInt(claims.ExpiresAt, 10))
+}
+
+// prune forgets the used tokens that have expired, they're refused anyway.
+func (t *tokenSigner) prune(ctx context.Context, now time.Time) error {
+	all, err := t.used.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, items := range all {
+		for nonce, value := range items {
+			expiresAt, err := strconv.ParseInt(value, 10, 64)
+			if err == nil && now.Before(time.Unix(expiresAt, 0)) {
+				continue
+			}
+			if err := t.used.Del(ctx, nonce); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *tokenSigner) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// tokenUser is who bundles created with a token are attributed to. It is only
+// allowed to create bundles, without the restricted collectors.
+func tokenUser(claims *creationTokenClaims) *user.SignedInUser {
+	return &user.SignedInUser{
+		Login: claims.Creator + " (token)",
+		OrgID: claims.OrgID,
+		Permissions: map[int64]map[string][]string{
+			claims.OrgID: {ActionCreate: {}},
+		},
+	}
+}
@@ -0,0 +1,105 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestTokenSigner(t *testing.T) {
+	kv := kvstore.ProvideService(db.InitTestDB(t))
+	require.Nil(t, newTokenSigner("secret", 0, kv))
+
+	signer := newTokenSigner("secret", time.Hour, kv)
+	now := time.Now()
+	token, expiresAt, err := signer.mint(&user.SignedInUser{Login: "admin", OrgID: 2}, now)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(time.Hour), expiresAt)
+
+	t.Run("valid token", func(t *testing.T) {
+		claims, err := signer.verify(token, now.Add(time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, "admin", claims.Creator)
+		require.EqualValues(t, 2, claims.OrgID)
+
+		usr := tokenUser(claims)
+		require.Equal(t, "admin (token)", usr.Login)
+		require.EqualValues(t, 2, usr.OrgID)
+		require.Contains(t, usr.Permissions[2], ActionCreate)
+		require.Len(t, usr.Permissions[2], 1)
+	})
+
+	t.Run("tokens are unique", func(t *testing.T) {
+		other, _, err := signer.mint(&user.SignedInUser{Login: "admin", OrgID: 2}, now)
+		require.NoError(t, err)
+		require.NotEqual(t, token, other)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		_, err := signer.verify(token, now.Add(time.Hour+time.Second))
+		require.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("tampered token", func(t *testing.T) {
+		_, signature, _ := strings.Cut(token, ".")
+		claims := base64.RawURLEncoding.EncodeToString([]byte(`{"creator":"admin","orgId":1,"exp":9999999999,"nonce":"x"}`))
+		_, err := signer.verify(claims+"."+signature, now)
+		require.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("token signed with another secret", func(t *testing.T) {
+		_, err := newTokenSigner("other secret", time.Hour, kv).verify(token, now)
+		require.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("malformed tokens", func(t *testing.T) {
+		for _, malformed := range []string{"", "token", ".", "a.b.c", token + "x"} {
+			_, err := signer.verify(malformed, now)
+			require.ErrorIs(t, err, ErrInvalidToken, malformed)
+		}
+	})
+}
+
+func TestTokenSigner_redeem(t *testing.T) {
+	ctx := context.Background()
+	signer := newTokenSigner("secret", time.Hour, kvstore.ProvideService(db.InitTestDB(t)))
+	now := time.Now()
+
+	mint := func(t *testing.T) *creationTokenClaims {
+		token, _, err := signer.mint(&user.SignedInUser{Login: "admin", OrgID: 1}, now)
+		require.NoError(t, err)
+		claims, err := signer.verify(token, now)
+		require.NoError(t, err)
+		return claims
+	}
+
+	t.Run("tokens can only be used once", func(t *testing.T) {
+		claims := mint(t)
+		require.NoError(t, signer.redeem(ctx, claims))
+		require.ErrorIs(t, signer.redeem(ctx, claims), ErrTokenUsed)
+
+		// other tokens are unaffected
+		require.NoError(t, signer.redeem(ctx, mint(t)))
+	})
+
+	t.Run("expired tokens are forgotten", func(t *testing.T) {
+		claims := mint(t)
+		require.NoError(t, signer.redeem(ctx, claims))
+
+		require.NoError(t, signer.prune(ctx, now))
+		require.ErrorIs(t, signer.redeem(ctx, claims), ErrTokenUsed)
+
+		require.NoError(t, signer.prune(ctx, now.Add(time.Hour)))
+		keys, err := signer.used.Keys(ctx, "")
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	})
+}
@@ -0,0 +1,142 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+// bundleUploadTimeout bounds the upload of an archive, on top of its collection.
+const bundleUploadTimeout = 10 * time.Minute
+
+var ErrInvalidUploadURL = errors.New("invalid support bundle upload URL")
+
+// bundleUploader uploads bundle archives to a URL given when creating them,
+// e.g. a pre-signed S3 PUT URL, instead of storing them. Only the configured
+// schemes and hosts are allowed so that bundles can't be sent to arbitrary,
+// possibly internal, services.
+type bundleUploader struct {
+	schemes map[string]bool
+	// hosts are the allowed hosts, or domains when prefixed with *.
+	hosts  []string
+	client *http.Client
+}
+
+// newBundleUploader returns nil when no scheme or host is allowed, disabling uploads.
+func newBundleUploader(schemes, hosts []string) *bundleUploader {
+	if len(schemes) == 0 || len(hosts) == 0 {
+		return nil
+	}
+
+	u := &bundleUploader{
+		schemes: make(map[string]bool, len(schemes)),
+		client:  &http.Client{},
+	}
+	for _, scheme := range schemes {
+		u.schemes[strings.ToLower(scheme)] = true
+	}
+	for _, host := range hosts {
+		u.hosts = append(u.hosts, strings.ToLower(host))
+	}
+	return u
+}
+
+// validate returns ErrInvalidUploadURL if bundles can't be uploaded to rawURL.
+func (u *bundleUploader) validate(rawURL string) error {
+	if u == nil {
+		return fmt.Errorf("%w: uploads are disabled, see support_bundles.upload_allowed_hosts", ErrInvalidUploadURL)
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("%w: not an absolute URL", ErrInvalidUploadURL)
+	}
+	if !u.schemes[strings.ToLower(parsed.Scheme)] {
+		return fmt.Errorf("%w: the %q scheme is not allowed", ErrInvalidUploadURL, parsed.Scheme)
+	}
+	if parsed.User != nil {
+		return fmt.Errorf("%w: credentials in the URL are not allowed", ErrInvalidUploadURL)
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	for _, allowed := range u.hosts {
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: the host %s is not allowed", ErrInvalidUploadURL, host)
+}
+
+// upload PUTs the size bytes archive to rawURL, streaming it from archive.
+func (u *bundleUploader) upload(ctx context.Context, rawURL string, archive io.Reader, size int64) error {
+	// the caller owns archive, the client would close it
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, rawURL, io.NopCloser(archive))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		// the URL in the error could carry the signature of a pre-signed URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("upload responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// uploadBundle uploads the archive of a collected bundle instead of storing it
+// and records where it went. The bundle fails if the upload does.
+func (s *Service) uploadBundle(uid string, r bundleResult, uploadURL string) {
+	// the collection may have used most of the bundle timeout, the upload gets its own
+	ctx, cancel := context.WithTimeout(context.Background(), bundleUploadTimeout)
+	defer cancel()
+
+	destination := uploadDestination(uploadURL)
+	state := r.state
+	err := s.uploader.upload(ctx, uploadURL, r.archive, r.size)
+	if err != nil {
+		s.log.Error("Failed to upload support bundle", "uid", uid, "destination", destination, "error", err)
+		s.metrics.bundlesFailed.WithLabelValues(string(supportbundles.StateError)).Inc()
+		state = supportbundles.StateError
+	}
+
+	if err := s.store.UpdateMetadata(ctx, uid, func(bundle *supportbundles.Bundle) {
+		if err != nil {
+			bundle.UploadError = fmt.Sprintf("failed to upload the bundle to %s: %s", destination, err)
+		} else {
+			bundle.UploadedTo = destination
+		}
+	}); err != nil {
+		s.log.Warn("Failed to record support bundle upload", "uid", uid, "error", err)
+	}
+	if err := s.store.Update(ctx, uid, state, nil); err != nil {
+		s.log.Error("failed to update bundle after upload", "uid", uid, "error", err)
+	}
+}
+
+// uploadDestination returns where a bundle was uploaded without the query, which
+// holds the signature of pre-signed URLs.
+func uploadDestination(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return (&url.URL{Scheme: parsed.Scheme, Host: parsed.Host, Path: parsed.Path}).String()
+}
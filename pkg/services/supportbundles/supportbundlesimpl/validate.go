@@ -0,0 +1,167 @@
+package supportbundlesimpl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+var ErrInvalidBundle = errors.New("not a valid support bundle")
+
+// bundleValidation describes an uploaded bundle without persisting it.
+type bundleValidation struct {
+	Format         string   `json:"format"`
+	Manifest       manifest `json:"manifest"`
+	GrafanaVersion string   `json:"grafanaVersion"`
+	// Checksum is the hex encoded SHA-256 of the archive.
+	Checksum string `json:"checksum"`
+	// ChecksumVerified is set when the checksum matched the expected one.
+	ChecksumVerified bool `json:"checksumVerified"`
+	// Present are the collectors whose output is in the bundle.
+	Present []string `json:"present"`
+	// Failed are the collectors that ran but failed, or whose output is missing from the archive.
+	Failed []string `json:"failed"`
+	// Missing are the collectors registered in this instance that didn't run for the bundle.
+	Missing []string `json:"missing"`
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// validateBundle inspects a bundle archive of the given size, detecting its format from its
+// content, and reports which collectors it contains. The archive must contain a manifest.
+// The checksum of the archive must match the expected one if set, or otherwise the one of
+// the bundle it was downloaded from, if this instance still has it.
+func (s *Service) validateBundle(ctx context.Context, r io.Reader, size int64, expectedChecksum string) (*bundleValidation, error) {
+	hash := sha256.New()
+	if ra, ok := r.(io.ReaderAt); ok {
+		// zip archives aren't read sequentially, hash the file upfront
+		if _, err := io.Copy(hash, io.NewSectionReader(ra, 0, size)); err != nil {
+			return nil, err
+		}
+	} else {
+		r = io.TeeReader(r, hash)
+	}
+
+	br := bufio.NewReader(r)
+	header, _ := br.Peek(len(zipMagic))
+
+	var format string
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		format = formatTarGz
+	case bytes.HasPrefix(header, zipMagic):
+		format = formatZip
+	default:
+		return nil, fmt.Errorf("%w: the file is neither a tar.gz nor a zip archive", ErrInvalidBundle)
+	}
+
+	// io.ReaderAt is kept so that zip archives on disk are read in place
+	var archive io.Reader = br
+	if ra, ok := r.(io.ReaderAt); ok {
+		archive = io.NewSectionReader(ra, 0, size)
+	}
+
+	var m *manifest
+	var buildInfo struct {
+		Version string `json:"version"`
+	}
+	files := map[string]bool{}
+	err := walkArchive(format, archive, size, func(name string, r io.Reader, _ int64) error {
+		files[name] = true
+		switch name {
+		case manifestFilename:
+			m = &manifest{}
+			if err := json.NewDecoder(r).Decode(m); err != nil {
+				return fmt.Errorf("%w: the manifest is not valid JSON: %s", ErrInvalidBundle, err)
+			}
+		case "build-info.json":
+			// only used when the manifest doesn't record the version
+			_ = json.NewDecoder(r).Decode(&buildInfo)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrInvalidBundle) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: the archive could not be read: %s", ErrInvalidBundle, err)
+	}
+	if m == nil {
+		return nil, fmt.Errorf("%w: the archive has no %s", ErrInvalidBundle, manifestFilename)
+	}
+
+	if _, ok := r.(io.ReaderAt); !ok {
+		// the walk can stop before the end of the archive, e.g. the gzip trailer
+		if _, err := io.Copy(io.Discard, br); err != nil {
+			return nil, err
+		}
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	expected := parseChecksum(expectedChecksum)
+	if expected == "" && m.BundleUID != "" {
+		if original, err := s.store.Get(ctx, m.BundleUID); err == nil {
+			expected = original.Checksum
+		}
+	}
+	if expected != "" && expected != checksum {
+		return nil, fmt.Errorf("%w: the checksum %s doesn't match the expected %s, the file may be corrupted", ErrInvalidBundle, checksum, expected)
+	}
+
+	version := m.GrafanaVersion
+	if version == "" {
+		version = buildInfo.Version
+	}
+
+	validation := &bundleValidation{
+		Format:           format,
+		Manifest:         *m,
+		GrafanaVersion:   version,
+		Checksum:         checksum,
+		ChecksumVerified: expected != "",
+		Present:          []string{},
+		Failed:           []string{},
+		Missing:          []string{},
+	}
+
+	ran := map[string]bool{}
+	for _, report := range m.Collectors {
+		ran[report.UID] = true
+		// collectors may succeed without writing a file
+		if report.Success && hasAll(files, report.filenames()) {
+			validation.Present = append(validation.Present, report.UID)
+		} else {
+			validation.Failed = append(validation.Failed, report.UID)
+		}
+	}
+	for uid := range s.bundleRegistry.Collectors() {
+		if !ran[uid] {
+			validation.Missing = append(validation.Missing, uid)
+		}
+	}
+
+	sort.Strings(validation.Present)
+	sort.Strings(validation.Failed)
+	sort.Strings(validation.Missing)
+	return validation, nil
+}
+
+// hasAll reports whether every file in names is present.
+func hasAll(present map[string]bool, names []string) bool {
+	for _, name := range names {
+		if !present[name] {
+			return false
+		}
+	}
+	return true
+}
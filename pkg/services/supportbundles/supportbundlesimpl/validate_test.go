@@ -0,0 +1,125 @@
+package supportbundlesimpl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestService_validateBundle(t *testing.T) {
+	ok := newTestCollector("ok", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "ok.txt", FileBytes: []byte("hello")}, nil
+	})
+	failing := newTestCollector("failing", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return nil, errors.New("database is down")
+	})
+	optional := supportbundles.Collector{UID: "optional"}
+
+	for _, format := range []string{formatTarGz, formatZip} {
+		t.Run("inspects a "+format+" bundle", func(t *testing.T) {
+			s := newTestService(t, ok, failing, optional)
+			s.cfg.BuildVersion = "9.4.0"
+			s.archiveFormat = format
+
+			bundle, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+			require.NoError(t, err)
+			data, _, err := bundleArchive(context.Background(), s, s.selectCollectors(nil), bundle.UID, nil)
+			require.NoError(t, err)
+
+			validation, err := s.validateBundle(context.Background(), bytes.NewReader(data), int64(len(data)), "")
+			require.NoError(t, err)
+			require.Equal(t, format, validation.Format)
+			require.Equal(t, "9.4.0", validation.GrafanaVersion)
+			require.Equal(t, bundle.UID, validation.Manifest.BundleUID)
+			require.Equal(t, []string{"ok"}, validation.Present)
+			require.Equal(t, []string{"failing"}, validation.Failed)
+			require.Equal(t, []string{"optional"}, validation.Missing)
+		})
+	}
+
+	t.Run("rejects files that aren't archives", func(t *testing.T) {
+		s := newTestService(t)
+		data := []byte("not a bundle")
+
+		_, err := s.validateBundle(context.Background(), bytes.NewReader(data), int64(len(data)), "")
+		require.ErrorIs(t, err, ErrInvalidBundle)
+	})
+
+	t.Run("rejects archives without a manifest", func(t *testing.T) {
+		s := newTestService(t)
+		var buf bytes.Buffer
+		require.NoError(t, compress(map[string][]byte{"ok.txt": []byte("hello")}, &buf, gzip.DefaultCompression))
+
+		_, err := s.validateBundle(context.Background(), bytes.NewReader(buf.Bytes()), int64(buf.Len()), "")
+		require.ErrorIs(t, err, ErrInvalidBundle)
+		require.Contains(t, err.Error(), "manifest")
+	})
+
+	t.Run("rejects corrupted archives", func(t *testing.T) {
+		s := newTestService(t)
+		data := append([]byte{0x1f, 0x8b}, []byte("truncated")...)
+
+		_, err := s.validateBundle(context.Background(), bytes.NewReader(data), int64(len(data)), "")
+		require.ErrorIs(t, err, ErrInvalidBundle)
+	})
+}
+
+func TestService_validateBundle_Checksum(t *testing.T) {
+	s := newTestService(t, newTestCollector("ok", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "ok.txt", FileBytes: []byte("hello")}, nil
+	}))
+
+	bundle, err := s.store.Create(context.Background(), &user.SignedInUser{Login: "admin"}, 0)
+	require.NoError(t, err)
+	s.startBundleWork(context.Background(), s.selectCollectors(nil), bundle.UID, nil, "")
+
+	stored, err := s.get(context.Background(), bundle.UID)
+	require.NoError(t, err)
+	require.Len(t, stored.Checksum, 64)
+	data := stored.TarBytes
+
+	t.Run("verifies the checksum of the original bundle", func(t *testing.T) {
+		// readers without ReadAt are hashed as they are walked
+		validation, err := s.validateBundle(context.Background(), io.MultiReader(bytes.NewReader(data)), int64(len(data)), "")
+		require.NoError(t, err)
+		require.Equal(t, stored.Checksum, validation.Checksum)
+		require.True(t, validation.ChecksumVerified)
+	})
+
+	t.Run("verifies the expected checksum", func(t *testing.T) {
+		sums := strings.ToUpper(stored.Checksum) + "  " + bundle.UID + ".tar.gz\n"
+		validation, err := s.validateBundle(context.Background(), bytes.NewReader(data), int64(len(data)), sums)
+		require.NoError(t, err)
+		require.True(t, validation.ChecksumVerified)
+	})
+
+	t.Run("rejects corrupted bundles", func(t *testing.T) {
+		_, err := s.validateBundle(context.Background(), bytes.NewReader(data), int64(len(data)), strings.Repeat("0", 64))
+		require.ErrorIs(t, err, ErrInvalidBundle)
+		require.Contains(t, err.Error(), "checksum")
+	})
+
+	t.Run("bundles from other instances can't be verified", func(t *testing.T) {
+		other := newTestService(t)
+		validation, err := other.validateBundle(context.Background(), bytes.NewReader(data), int64(len(data)), "")
+		require.NoError(t, err)
+		require.Equal(t, stored.Checksum, validation.Checksum)
+		require.False(t, validation.ChecksumVerified)
+	})
+}
+
+func TestChecksumDigest(t *testing.T) {
+	require.Equal(t, "sha-256=LCa0a2j/xo/5m0U8HTBBNBNCLXBkg7+g+YpeiGJm564=",
+		checksumDigest("2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"))
+	require.Empty(t, checksumDigest(""))
+	require.Empty(t, checksumDigest("not hex"))
+}
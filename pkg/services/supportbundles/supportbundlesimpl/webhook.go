@@ -0,0 +1,142 @@
+package supportbundlesimpl
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
+
+const (
+	// webhookSignatureHeader carries the hex encoded HMAC-SHA256 of the request body, prefixed with `sha256=`.
+	webhookSignatureHeader = "X-Grafana-Signature"
+	webhookMaxAttempts     = 3
+	webhookTimeout         = time.Minute
+)
+
+type webhookPayload struct {
+	UID         string               `json:"uid"`
+	State       supportbundles.State `json:"state"`
+	Creator     string               `json:"creator"`
+	DownloadURL string               `json:"downloadUrl,omitempty"`
+}
+
+// webhookNotifier posts the final state of bundles to a webhook.
+type webhookNotifier struct {
+	url    string
+	secret string
+	client *http.Client
+	// backoff is the delay before the first retry, doubled for every following one.
+	backoff time.Duration
+	log     log.Logger
+}
+
+// newWebhookNotifier returns nil when url is empty, disabling notifications.
+func newWebhookNotifier(url, secret string, logger log.Logger) *webhookNotifier {
+	if url == "" {
+		return nil
+	}
+
+	return &webhookNotifier{
+		url:     url,
+		secret:  secret,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		backoff: time.Second,
+		log:     logger,
+	}
+}
+
+func (w *webhookNotifier) notify(ctx context.Context, payload webhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	backoff := w.backoff
+	for attempt := 1; ; attempt++ {
+		err = w.send(ctx, body)
+		if err == nil || attempt == webhookMaxAttempts {
+			return err
+		}
+
+		w.log.Warn("Failed to send support bundle webhook, retrying", "uid", payload.UID, "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (w *webhookNotifier) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		req.Header.Set(webhookSignatureHeader, "sha256="+signWebhookBody(w.secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			w.log.Warn("Failed to close support bundle webhook response body", "error", err)
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyWebhook sends the final state of the bundle to the configured webhook in the background.
+func (s *Service) notifyWebhook(uid string) {
+	if s.webhook == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+		defer cancel()
+
+		bundle, err := s.store.Get(ctx, uid)
+		if err != nil {
+			s.log.Error("Failed to get support bundle for webhook", "uid", uid, "error", err)
+			return
+		}
+
+		payload := webhookPayload{
+			UID:     bundle.UID,
+			State:   bundle.State,
+			Creator: bundle.Creator,
+		}
+		if bundle.State.HasArchive() {
+			payload.DownloadURL = strings.TrimSuffix(s.cfg.AppURL, "/") + rootUrl + "/" + bundle.UID
+		}
+
+		if err := s.webhook.notify(ctx, payload); err != nil {
+			s.log.Error("Failed to send support bundle webhook", "uid", uid, "error", err)
+		}
+	}()
+}
@@ -0,0 +1,105 @@
+package supportbundlesimpl
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestWebhookNotifier_notify(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "sha256="+signWebhookBody("s3cr3t", body), r.Header.Get(webhookSignatureHeader))
+
+		attempts++
+		if attempts < 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		received = body
+	}))
+	t.Cleanup(server.Close)
+
+	w := newWebhookNotifier(server.URL, "s3cr3t", log.NewNopLogger())
+	w.backoff = time.Millisecond
+
+	payload := webhookPayload{UID: "abc", State: supportbundles.StateComplete, Creator: "admin", DownloadURL: "http://grafana/api/support-bundles/abc"}
+	require.NoError(t, w.notify(context.Background(), payload))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, 2, attempts)
+	var got webhookPayload
+	require.NoError(t, json.Unmarshal(received, &got))
+	require.Equal(t, payload, got)
+}
+
+func TestWebhookNotifier_notifyGivesUp(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	w := newWebhookNotifier(server.URL, "", log.NewNopLogger())
+	w.backoff = time.Millisecond
+
+	require.Error(t, w.notify(context.Background(), webhookPayload{UID: "abc"}))
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, webhookMaxAttempts, attempts)
+}
+
+func TestService_create_NotifiesWebhook(t *testing.T) {
+	payloads := make(chan webhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhookPayload
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads <- payload
+	}))
+	t.Cleanup(server.Close)
+
+	ok := newTestCollector("ok", func(ctx context.Context) (*supportbundles.SupportItem, error) {
+		return &supportbundles.SupportItem{Filename: "ok.txt", FileBytes: []byte("ok")}, nil
+	})
+	s := newTestService(t, ok)
+	s.cfg.AppURL = "https://grafana.example.com/"
+	s.webhook = newWebhookNotifier(server.URL, "", log.NewNopLogger())
+
+	bundle, err := s.create(context.Background(), &user.SignedInUser{Login: "admin"}, createOptions{})
+	require.NoError(t, err)
+
+	select {
+	case payload := <-payloads:
+		require.Equal(t, webhookPayload{
+			UID:         bundle.UID,
+			State:       supportbundles.StateComplete,
+			Creator:     "admin",
+			DownloadURL: "https://grafana.example.com/api/support-bundles/" + bundle.UID,
+		}, payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not notified")
+	}
+}
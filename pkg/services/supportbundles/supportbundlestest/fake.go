@@ -1,6 +1,10 @@
 package supportbundlestest
 
-import "github.com/grafana/grafana/pkg/services/supportbundles"
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/supportbundles"
+)
 
 type FakeBundleService struct {
 }
@@ -10,3 +14,17 @@ func NewFakeBundleService() *FakeBundleService {
 }
 
 func (s *FakeBundleService) RegisterSupportItemCollector(collector supportbundles.Collector) {}
+
+// FakeBundleCreator records the bundles created with it.
+type FakeBundleCreator struct {
+	Created []supportbundles.Options
+	Err     error
+}
+
+func (c *FakeBundleCreator) Create(ctx context.Context, opts supportbundles.Options) (*supportbundles.Bundle, error) {
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	c.Created = append(c.Created, opts)
+	return &supportbundles.Bundle{Creator: opts.Creator, Description: opts.Description, Tags: opts.Tags, State: supportbundles.StatePending}, nil
+}
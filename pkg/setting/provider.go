@@ -89,6 +89,22 @@ type ReloadHandler interface {
 	Validate(section Section) error
 }
 
+// ReloadStatus describes the runtime configuration reloads of a Provider.
+type ReloadStatus struct {
+	// LastReload is when the configuration was last reloaded, zero if it never was.
+	LastReload time.Time
+	// Pending is true when an update has been received but not reloaded yet.
+	Pending bool
+	// Errors are the errors returned by the reload handlers during the last reload, keyed by section.
+	Errors map[string]string
+}
+
+// ReloadStatusProvider is implemented by the providers
+// that support configuration reloads at runtime.
+type ReloadStatusProvider interface {
+	ReloadStatus() ReloadStatus
+}
+
 type SettingsBag map[string]map[string]string
 type SettingsRemovals map[string][]string
 
@@ -85,14 +85,25 @@ const SupportBundlesUnconnected = ({ supportBundles, isLoading, loadBundles, rem
                 <th>{dateTimeFormat(bundle.expiresAt * 1000)}</th>
                 <th>{bundle.state === 'pending' && <Spinner />}</th>
                 <th>
-                  <LinkButton
-                    fill="outline"
-                    disabled={bundle.state !== 'complete'}
-                    target={'_self'}
-                    href={`/api/support-bundles/${bundle.uid}`}
-                  >
-                    Download
-                  </LinkButton>
+                  {bundle.state === 'pending' ? (
+                    <LinkButton
+                      fill="outline"
+                      target={'_self'}
+                      href={`/api/support-bundles/${bundle.uid}?partial=true`}
+                      tooltip="Download the collectors that are done so far"
+                    >
+                      Download partial
+                    </LinkButton>
+                  ) : (
+                    <LinkButton
+                      fill="outline"
+                      disabled={bundle.state !== 'complete' && bundle.state !== 'partial'}
+                      target={'_self'}
+                      href={`/api/support-bundles/${bundle.uid}`}
+                    >
+                      Download
+                    </LinkButton>
+                  )}
                 </th>
                 <th>
                   {hasDeleteAccess && (
@@ -50,7 +50,7 @@ export const SupportBundlesCreateUnconnected = ({
 
   // turn components into a uuid -> enabled map
   const values: Record<string, boolean> = collectors.reduce((acc, curr) => {
-    return { ...acc, [curr.uid]: curr.default };
+    return { ...acc, [curr.uid]: curr.default && !curr.disabled };
   }, {});
 
   return (
@@ -74,8 +74,8 @@ export const SupportBundlesCreateUnconnected = ({
                           label={component.displayName}
                           id={component.uid}
                           description={component.description}
-                          defaultChecked={component.default}
-                          disabled={component.includedByDefault}
+                          defaultChecked={component.default && !component.disabled}
+                          disabled={component.includedByDefault || component.disabled}
                         />
                       </Field>
                     );
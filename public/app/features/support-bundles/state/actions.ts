@@ -1,7 +1,7 @@
 import { throttle } from 'lodash';
 
 import { getBackendSrv, locationService } from '@grafana/runtime';
-import { SupportBundle, SupportBundleCollector, SupportBundleCreateRequest, ThunkResult } from 'app/types';
+import { SupportBundleCollector, SupportBundleCreateRequest, SupportBundleListResponse, ThunkResult } from 'app/types';
 
 import {
   collectorsFetchBegin,
@@ -20,8 +20,8 @@ export function loadBundles(skipPageRefresh = false): ThunkResult<void> {
       if (!skipPageRefresh) {
         dispatch(fetchBegin());
       }
-      const result = await getBackendSrv().get<SupportBundle[]>('/api/support-bundles');
-      dispatch(supportBundlesLoaded(result));
+      const result = await getBackendSrv().get<SupportBundleListResponse>('/api/support-bundles');
+      dispatch(supportBundlesLoaded(result.bundles));
     } finally {
       dispatch(fetchEnd());
     }
@@ -29,8 +29,8 @@ export function loadBundles(skipPageRefresh = false): ThunkResult<void> {
 }
 
 const checkBundlesStatusThrottled = throttle(async (dispatch) => {
-  const result = await getBackendSrv().get<SupportBundle[]>('/api/support-bundles');
-  dispatch(supportBundlesLoaded(result));
+  const result = await getBackendSrv().get<SupportBundleListResponse>('/api/support-bundles');
+  dispatch(supportBundlesLoaded(result.bundles));
 }, 1000);
 
 export function checkBundles(): ThunkResult<void> {
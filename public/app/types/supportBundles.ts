@@ -1,4 +1,4 @@
-type SupportBundleState = 'complete' | 'error' | 'timeout' | 'pending';
+type SupportBundleState = 'complete' | 'partial' | 'error' | 'timeout' | 'pending';
 
 export interface SupportBundle {
   uid: string;
@@ -6,6 +6,26 @@ export interface SupportBundle {
   creator: string;
   createdAt: number;
   expiresAt: number;
+  description?: string;
+  tags?: Record<string, string>;
+  collectors?: string[];
+  params?: Record<string, Record<string, number>>;
+  skippedCollectors?: string[];
+  autoAddedCollectors?: Array<{ uid: string; reason: string }>;
+  checksum?: string;
+  size?: number;
+  estimatedCompletedAt?: number;
+  completedAt?: number;
+  durationMs?: number;
+  uploadedTo?: string;
+  uploadError?: string;
+}
+
+export interface SupportBundleListResponse {
+  totalCount: number;
+  bundles: SupportBundle[];
+  page: number;
+  perPage: number;
 }
 
 export interface SupportBundlesState {
@@ -23,8 +43,34 @@ export interface SupportBundleCollector {
   description: string;
   includedByDefault: boolean;
   default: boolean;
+  restricted: boolean;
+  disabled: boolean;
+  params?: SupportBundleCollectorParam[];
+}
+
+export interface SupportBundleCollectorParam {
+  name: string;
+  description: string;
+  default: number;
+  min: number;
+  max: number;
 }
 
 export interface SupportBundleCreateRequest {
   collectors: string[];
+  preset?: string;
+  template?: string;
+  description?: string;
+  tags?: Record<string, string>;
+  params?: Record<string, Record<string, number>>;
+}
+
+export interface SupportBundleTemplate {
+  uid: string;
+  orgId: number;
+  name: string;
+  collectors: string[];
+  tags?: Record<string, string>;
+  createdAt: number;
+  updatedAt: number;
 }